@@ -58,8 +58,81 @@ func InitDatabase() *gorm.DB {
 		&model.AccessToken{},
 		&model.Bill_Header_Installment{},
 		&model.Bill_Details_Installment{},
+		&model.Pawn_Forfeit_Policy{},
+		&model.Pawn_Forfeit_Notice{},
+		&model.Inventory_Unit{},
+		&model.Pawn_Appraisal{},
+		&model.Pawn_Appraisal_Photo{},
+		&model.Pawn_Ltv_Policy{},
+		&model.Pawn_Renewal{},
+		&model.Pawn_Principal_Change{},
+		&model.Bill_Status_Transition{},
+		&model.Cash_Sale{},
+		&model.Cash_Sale_Line{},
+		&model.Cash_Sale_Tender{},
+		&model.Product_Stock{},
+		&model.Trade_In{},
+		&model.Bill_Line{},
+		&model.Bill_Restructure{},
+		&model.Payment_Allocation_Policy{},
+		&model.Payment{},
+		&model.Payment_Allocation{},
+		&model.Payment_Tender{},
+		&model.Bill_Cancellation{},
+		&model.Credit_Movement{},
+		&model.User_Session{},
+		&model.Session_Policy{},
+		&model.Security_Event{},
+		&model.User_Two_Factor{},
+		&model.User_Recovery_Code{},
+		&model.Login_Challenge{},
+		&model.Login_Throttle{},
+		&model.Api_Key{},
+		&model.Permission{},
+		&model.Role_Permission{},
+		&model.Audit_Log{},
+		&model.Password_History{},
+		&model.Login_History{},
+		&model.Schema_Migration{},
 	)
 
+	// ก่อนเปลี่ยน bill_headers.deleted_at เป็น soft delete ทุกแถวถูกบันทึกค่า deleted_at ไว้ (เวลา 0 หรือเวลาสร้าง)
+	// โดยยังไม่เคยมีการลบบิลจริง จึงล้างค่าเดิมทั้งหมดครั้งเดียว ไม่เช่นนั้นบิลเก่าทั้งหมดจะถูกมองว่าถูกลบ
+	if err := runMigrationOnce(db, "bill_headers_clear_legacy_deleted_at", func(tx *gorm.DB) error {
+		return tx.Exec("UPDATE bill_headers SET deleted_at = NULL WHERE deleted_at IS NOT NULL").Error
+	}); err != nil {
+		log.Printf("❌ ล้าง deleted_at ของ bill_headers ไม่สำเร็จ: %v", err)
+	}
+
+	// login_failures เดิมรวมเข้า login_histories (success = false) ย้ายแถวเก่ามาครั้งเดียว ตารางเดิมเก็บไว้ไม่ลบ
+	if err := runMigrationOnce(db, "login_failures_into_login_histories", func(tx *gorm.DB) error {
+		if !tx.Migrator().HasTable("login_failures") {
+			return nil
+		}
+		return tx.Exec(`INSERT INTO login_histories (username, user_id, success, reason, ip, user_agent, unusual_hour, created_at)
+			SELECT username, user_id, false, reason, ip, user_agent, false, created_at FROM login_failures`).Error
+	}); err != nil {
+		log.Printf("❌ ย้ายประวัติการเข้าสู่ระบบผิดไม่สำเร็จ: %v", err)
+	}
+
 	return db
 
 }
+
+// รันการแก้ข้อมูลครั้งเดียว แล้วจดชื่อไว้ใน schema_migrations ใน transaction เดียวกัน
+func runMigrationOnce(db *gorm.DB, name string, fn func(tx *gorm.DB) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&model.Schema_Migration{}).Where("name = ?", name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		if err := fn(tx); err != nil {
+			return err
+		}
+		log.Printf("🛠️ migration %s เรียบร้อย", name)
+		return tx.Create(&model.Schema_Migration{Name: name}).Error
+	})
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"rrmobile/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ApiKeyRequestHandler interface {
+	GetApiScopes(c *fiber.Ctx) error
+	GetApiKeys(c *fiber.Ctx) error
+	CreateApiKey(c *fiber.Ctx) error
+	RotateApiKey(c *fiber.Ctx) error
+	RevokeApiKey(c *fiber.Ctx) error
+}
+
+type apiKeyHandler struct {
+	apiKeyService service.ApiKeyService
+}
+
+func NewApiKeyHandler(apiKeyService service.ApiKeyService) *apiKeyHandler {
+	return &apiKeyHandler{apiKeyService: apiKeyService}
+}
+
+func (h *apiKeyHandler) GetApiScopes(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"data": h.apiKeyService.GetApiScopes()})
+}
+
+// ?include_revoked=true แสดง key ที่ถูกยกเลิกด้วย
+func (h *apiKeyHandler) GetApiKeys(c *fiber.Ctx) error {
+	keys, err := h.apiKeyService.GetApiKeys(c.QueryBool("include_revoked", false))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": keys})
+}
+
+func (h *apiKeyHandler) CreateApiKey(c *fiber.Ctx) error {
+	var req service.CreateApiKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	actorID, _ := c.Locals("user_id").(uint)
+	created, err := h.apiKeyService.CreateApiKey(actorID, req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"data": created})
+}
+
+func (h *apiKeyHandler) RotateApiKey(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+	var req service.RotateApiKeyRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+		}
+	}
+
+	actorID, _ := c.Locals("user_id").(uint)
+	rotated, err := h.apiKeyService.RotateApiKey(actorID, uint(id), req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": rotated})
+}
+
+func (h *apiKeyHandler) RevokeApiKey(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+
+	actorID, _ := c.Locals("user_id").(uint)
+	if err := h.apiKeyService.RevokeApiKey(actorID, uint(id)); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "ยกเลิก API key เรียบร้อย"})
+}
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"rrmobile/service"
+	"rrmobile/util"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AppraisalRequestHandler interface {
+	CreateAppraisal(c *fiber.Ctx) error
+	GetAppraisals(c *fiber.Ctx) error
+	GetAppraisalById(c *fiber.Ctx) error
+
+	GetLtvPolicies(c *fiber.Ctx) error
+	UpsertLtvPolicy(c *fiber.Ctx) error
+	DeleteLtvPolicy(c *fiber.Ctx) error
+}
+
+type appraisalHandler struct {
+	appraisalService service.AppraisalService
+}
+
+func NewAppraisalHandler(appraisalService service.AppraisalService) *appraisalHandler {
+	return &appraisalHandler{appraisalService: appraisalService}
+}
+
+// รับ form-data: product_id, imei, condition_grade, battery_health, appraised_value, note,
+// checklist (JSON เช่น {"screen":true}) และรูปถ่ายเครื่องใน photos
+func (h *appraisalHandler) CreateAppraisal(c *fiber.Ctx) error {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid form-data"})
+	}
+
+	files := form.File["photos"]
+	for _, file := range files {
+		ext := strings.ToLower(filepath.Ext(file.Filename))
+		mime := file.Header.Get("Content-Type")
+		if !isValidImage(ext, mime) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid image file: %s", file.Filename)})
+		}
+	}
+
+	productID, err := strconv.Atoi(c.FormValue("product_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid product ID"})
+	}
+	batteryHealth, _ := strconv.Atoi(c.FormValue("battery_health"))
+	appraisedValue, err := strconv.ParseFloat(c.FormValue("appraised_value"), 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid appraised value"})
+	}
+
+	checklist := map[string]bool{}
+	if raw := c.FormValue("checklist"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &checklist); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "checklist ไม่ถูกต้อง"})
+		}
+	}
+
+	userID, _ := c.Locals("user_id").(uint)
+
+	photoUrls := make([]string, 0, len(files))
+	for _, file := range files {
+		newName := util.GenerateFileName(file.Filename)
+		savePath := fmt.Sprintf("../uploads/%s", newName)
+
+		tempPath := fmt.Sprintf("../uploads/temp_%s", newName)
+		if err := c.SaveFile(file, tempPath); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save file"})
+		}
+
+		if err := util.ResizeImage(tempPath, savePath, 800, 800); err != nil {
+			os.Remove(tempPath)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to resize image"})
+		}
+		os.Remove(tempPath)
+
+		photoUrls = append(photoUrls, "../uploads/"+newName)
+	}
+
+	req := service.NewAppraisalRequest{
+		ProductId:       uint(productID),
+		Imei:            c.FormValue("imei"),
+		Condition_Grade: c.FormValue("condition_grade"),
+		Battery_Health:  batteryHealth,
+		Checklist:       checklist,
+		Appraised_Value: appraisedValue,
+		Note:            c.FormValue("note"),
+		AppraiserId:     int(userID),
+		Photos:          photoUrls,
+	}
+
+	appraisal, err := h.appraisalService.CreateAppraisal(req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"data": appraisal})
+}
+
+func (h *appraisalHandler) GetAppraisals(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "0"))
+	unused, _ := strconv.ParseBool(c.Query("unused", "false"))
+
+	resp, err := h.appraisalService.GetAllAppraisals(c.Query("grade"), unused, page, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(resp)
+}
+
+func (h *appraisalHandler) GetAppraisalById(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+	appraisal, err := h.appraisalService.GetAppraisalById(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": appraisal})
+}
+
+func (h *appraisalHandler) GetLtvPolicies(c *fiber.Ctx) error {
+	policies, err := h.appraisalService.GetLtvPolicies()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": policies})
+}
+
+func (h *appraisalHandler) UpsertLtvPolicy(c *fiber.Ctx) error {
+	var request service.UpsertLtvPolicyRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+	policy, err := h.appraisalService.UpsertLtvPolicy(request)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": policy})
+}
+
+func (h *appraisalHandler) DeleteLtvPolicy(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+	if err := h.appraisalService.DeleteLtvPolicy(uint(id)); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "ไม่สามารถลบข้อมูลได้"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"rrmobile/service"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type AuditRequestHandler interface {
+	GetAuditLogs(c *fiber.Ctx) error
+}
+
+type auditHandler struct {
+	auditService service.AuditService
+}
+
+func NewAuditHandler(auditService service.AuditService) *auditHandler {
+	return &auditHandler{auditService: auditService}
+}
+
+// ผู้ทำรายการของ request นี้ (ผู้ใช้จาก JWT หรือ API key) สำหรับบันทึกประวัติการแก้ไข
+func auditActor(c *fiber.Ctx) service.AuditActor {
+	actor := service.AuditActor{Ip: c.IP()}
+	if userID, ok := c.Locals("user_id").(uint); ok {
+		actor.User_Id = int(userID)
+	}
+	if keyID, ok := c.Locals("api_key_id").(uint); ok {
+		actor.Api_Key_Id = &keyID
+	}
+	if requestID, ok := c.Locals("requestid").(string); ok {
+		actor.Request_Id = requestID
+	}
+	return actor
+}
+
+// ค้นหาประวัติการแก้ไข ?entity=bill&entity_id=&user_id=&action=&date_from=&date_to=&page=&limit=
+func (h *auditHandler) GetAuditLogs(c *fiber.Ctx) error {
+	query := service.AuditLogQuery{
+		Entity:    c.Query("entity"),
+		Entity_Id: queryIntPtr(c, "entity_id"),
+		Actor_Id:  queryIntPtr(c, "user_id"),
+		Action:    c.Query("action"),
+		Page:      c.QueryInt("page", 1),
+		Limit:     c.QueryInt("limit", 50),
+	}
+	if df := c.Query("date_from"); df != "" {
+		if t, err := time.Parse("2006-01-02", df); err == nil {
+			query.DateFrom = &t
+		}
+	}
+	if dt := c.Query("date_to"); dt != "" {
+		if t, err := time.Parse("2006-01-02", dt); err == nil {
+			query.DateTo = &t
+		}
+	}
+
+	logs, err := h.auditService.GetAuditLogs(query)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": logs})
+}
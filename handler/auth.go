@@ -1,9 +1,14 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"rrmobile/model"
 	"rrmobile/service"
+	"rrmobile/util"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
@@ -14,6 +19,38 @@ type AuthRequestHandler interface {
 	// Register(c *fiber.Ctx) error
 	Refresh(c *fiber.Ctx) error
 	Logout(c *fiber.Ctx) error
+
+	GetMySessions(c *fiber.Ctx) error
+	RevokeMySession(c *fiber.Ctx) error
+	GetUserSessions(c *fiber.Ctx) error
+	RevokeUserSession(c *fiber.Ctx) error
+	RevokeUserSessions(c *fiber.Ctx) error
+	GetSessionPolicies(c *fiber.Ctx) error
+	UpdateSessionPolicy(c *fiber.Ctx) error
+	GetSecurityEvents(c *fiber.Ctx) error
+
+	SetupLoginTwoFactor(c *fiber.Ctx) error
+	VerifyLoginTwoFactor(c *fiber.Ctx) error
+	GetTwoFactorStatus(c *fiber.Ctx) error
+	SetupTwoFactor(c *fiber.Ctx) error
+	EnableTwoFactor(c *fiber.Ctx) error
+	DisableTwoFactor(c *fiber.Ctx) error
+	RegenerateRecoveryCodes(c *fiber.Ctx) error
+	ResetUserTwoFactor(c *fiber.Ctx) error
+
+	GetLoginLocks(c *fiber.Ctx) error
+	UnlockLogin(c *fiber.Ctx) error
+	UnlockUserLogin(c *fiber.Ctx) error
+	GetLoginFailures(c *fiber.Ctx) error
+	GetUserLoginHistory(c *fiber.Ctx) error
+
+	GetPermissions(c *fiber.Ctx) error
+	GetRolePermissions(c *fiber.Ctx) error
+	UpdateRolePermissions(c *fiber.Ctx) error
+	GetMyPermissions(c *fiber.Ctx) error
+
+	ChangePassword(c *fiber.Ctx) error
+	GetPasswordPolicy(c *fiber.Ctx) error
 }
 
 type authHandler struct {
@@ -27,38 +64,86 @@ func NewAuthHandler(authService service.AuthService, db *gorm.DB) *authHandler {
 
 func (ah *authHandler) Login(c *fiber.Ctx) error {
 	var loginRequest struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
+		Username   string `json:"username"`
+		Password   string `json:"password"`
+		DeviceName string `json:"device_name"`
 	}
 
 	if err := c.BodyParser(&loginRequest); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ส่งข้อมูลมาไม่ครบ"})
 	}
+	if !util.GetLimiter(c.IP()).Allow() {
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": "ส่งคำขอถี่เกินไป กรุณารอสักครู่"})
+	}
 
-	authResponse, err := ah.authService.Login(c.Context(), loginRequest.Username, loginRequest.Password)
+	device := service.LoginDevice{
+		Name:      loginRequest.DeviceName,
+		Ip:        c.IP(),
+		UserAgent: c.Get("User-Agent"),
+	}
+	authResponse, err := ah.authService.Login(c.Context(), loginRequest.Username, loginRequest.Password, device)
 	if err != nil {
+		var locked *service.LoginLockedError
+		if errors.As(err, &locked) {
+			return loginLockedResponse(c, locked)
+		}
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "username or password ไม่ถูกต้อง"})
 	}
 
-	return c.JSON(fiber.Map{
-		"role_id":       authResponse.RoleID,
-		"access_token":  authResponse.Token,
-		"refresh_token": authResponse.RefreshToken,
-	})
+	// ต้องยืนยัน 2FA ก่อน ยังไม่ออก token
+	if authResponse.Two_Factor_Required {
+		return c.JSON(fiber.Map{
+			"two_factor_required": true,
+			"two_factor_setup":    authResponse.Two_Factor_Setup,
+			"challenge_token":     authResponse.Challenge_Token,
+		})
+	}
+
+	resp, err := sessionResponse(c, authResponse)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(resp)
 }
 
+// แลก token ใหม่ ส่ง refresh_token ใน body หรือใช้ refresh cookie (โหมด cookie ต้องมี X-CSRF-Token)
 func (ah *authHandler) Refresh(c *fiber.Ctx) error {
 	var req struct {
 		RefreshToken string `json:"refresh_token"`
 	}
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ส่งข้อมูลมาไม่ครบ"})
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ส่งข้อมูลมาไม่ครบ"})
+		}
 	}
-	newAccessToken, newRefreshToken, err := ah.authService.RefreshTokens(c.Context(), req.RefreshToken)
+	fromCookie := false
+	if req.RefreshToken == "" {
+		req.RefreshToken = c.Cookies(refreshCookieName)
+		fromCookie = req.RefreshToken != ""
+	}
+	if fromCookie && !validCSRF(c) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "CSRF token ไม่ถูกต้อง"})
+	}
+
+	device := service.LoginDevice{Ip: c.IP(), UserAgent: c.Get("User-Agent")}
+	newAccessToken, newRefreshToken, err := ah.authService.RefreshTokens(c.Context(), req.RefreshToken, device)
 	if err != nil {
+		if fromCookie {
+			clearSessionCookies(c)
+		}
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "ข้อมูล ไม่ถูกต้อง หรือ หมดอายุ"})
 	}
 
+	if fromCookie {
+		csrfToken, err := setSessionCookies(c, newRefreshToken)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.JSON(fiber.Map{
+			"access_token": newAccessToken,
+			"csrf_token":   csrfToken,
+		})
+	}
 	return c.JSON(fiber.Map{
 		"access_token":  newAccessToken,
 		"refresh_token": newRefreshToken,
@@ -77,7 +162,25 @@ func GetUsersHandler(db *gorm.DB) fiber.Handler {
 
 func (h *authHandler) Logout(c *fiber.Ctx) error {
 	authHeader := c.Get("Authorization")
+	refreshCookie := c.Cookies(refreshCookieName)
+	if refreshCookie != "" {
+		if !validCSRF(c) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "CSRF token ไม่ถูกต้อง"})
+		}
+		defer clearSessionCookies(c)
+	}
 	if authHeader == "" {
+		// โหมด cookie: access token ในหน่วยความจำหายไปแล้ว (เช่น รีเฟรชหน้า) ออกจากระบบด้วย refresh cookie
+		if refreshCookie != "" {
+			if err := h.authService.LogoutByRefreshToken(refreshCookie); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": err.Error(),
+				})
+			}
+			return c.JSON(fiber.Map{
+				"message": "User logged out and session tokens revoked",
+			})
+		}
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "Authorization header missing",
 		})
@@ -93,13 +196,427 @@ func (h *authHandler) Logout(c *fiber.Ctx) error {
 
 	userID := claims.UserID
 
-	if err := h.authService.LogoutAndRevokeAll(userID); err != nil {
+	// ออกจากระบบเฉพาะเครื่องนี้ เครื่องอื่นยังใช้งานต่อได้
+	if err := h.authService.Logout(userID, claims.SessionID); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
 	return c.JSON(fiber.Map{
-		"message": "User logged out and all tokens revoked",
+		"message": "User logged out and session tokens revoked",
+	})
+}
+
+// เซสชันของตัวเอง (ระบุเครื่องที่ใช้อยู่ด้วย current = true)
+func (h *authHandler) GetMySessions(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(uint)
+	sessionID, _ := c.Locals("session_id").(uint)
+
+	sessions, err := h.authService.GetMySessions(userID, sessionID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": sessions})
+}
+
+func (h *authHandler) RevokeMySession(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+
+	userID, _ := c.Locals("user_id").(uint)
+	if err := h.authService.RevokeMySession(userID, uint(id)); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "ยกเลิกเซสชันเรียบร้อย"})
+}
+
+func (h *authHandler) GetUserSessions(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+
+	sessions, err := h.authService.GetUserSessions(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": sessions})
+}
+
+func (h *authHandler) RevokeUserSession(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("session_id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+
+	actorID, _ := c.Locals("user_id").(uint)
+	if err := h.authService.RevokeUserSession(actorID, uint(id)); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "ยกเลิกเซสชันเรียบร้อย"})
+}
+
+func (h *authHandler) RevokeUserSessions(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+
+	actorID, _ := c.Locals("user_id").(uint)
+	if err := h.authService.RevokeUserSessions(actorID, uint(id)); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "ยกเลิกเซสชันทั้งหมดของผู้ใช้เรียบร้อย"})
+}
+
+func (h *authHandler) GetSessionPolicies(c *fiber.Ctx) error {
+	policies, err := h.authService.GetSessionPolicies()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": policies})
+}
+
+func (h *authHandler) UpdateSessionPolicy(c *fiber.Ctx) error {
+	roleID, err := c.ParamsInt("role_id")
+	if err != nil || roleID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "role ไม่ถูกต้อง"})
+	}
+
+	var req service.UpdateSessionPolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	policy, err := h.authService.UpdateSessionPolicy(roleID, req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": policy})
+}
+
+// เหตุการณ์ด้านความปลอดภัย ?type=refresh_token_reuse&user_id=&date_from=&date_to=&page=&limit=
+func (h *authHandler) GetSecurityEvents(c *fiber.Ctx) error {
+	query := service.SecurityEventQuery{
+		Event_Type: c.Query("type"),
+		User_Id:    queryIntPtr(c, "user_id"),
+		Page:       c.QueryInt("page", 1),
+		Limit:      c.QueryInt("limit", 50),
+	}
+	if df := c.Query("date_from"); df != "" {
+		if t, err := time.Parse("2006-01-02", df); err == nil {
+			query.DateFrom = &t
+		}
+	}
+	if dt := c.Query("date_to"); dt != "" {
+		if t, err := time.Parse("2006-01-02", dt); err == nil {
+			query.DateTo = &t
+		}
+	}
+
+	events, err := h.authService.GetSecurityEvents(query)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": events})
+}
+
+// role บังคับ 2FA แต่ยังไม่ได้ลงทะเบียน: ขอ secret / QR ด้วย challenge token
+func (h *authHandler) SetupLoginTwoFactor(c *fiber.Ctx) error {
+	var req service.LoginTwoFactorRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ส่งข้อมูลมาไม่ครบ"})
+	}
+
+	setup, err := h.authService.SetupLoginTwoFactor(req.Challenge_Token)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": setup})
+}
+
+func (h *authHandler) VerifyLoginTwoFactor(c *fiber.Ctx) error {
+	var req service.LoginTwoFactorRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ส่งข้อมูลมาไม่ครบ"})
+	}
+	if !util.GetLimiter(c.IP()).Allow() {
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": "ส่งคำขอถี่เกินไป กรุณารอสักครู่"})
+	}
+
+	authResponse, err := h.authService.VerifyLoginChallenge(c.Context(), req.Challenge_Token, req.Code)
+	if err != nil {
+		var locked *service.LoginLockedError
+		if errors.As(err, &locked) {
+			return loginLockedResponse(c, locked)
+		}
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	resp, err := sessionResponse(c, authResponse)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	if len(authResponse.Recovery_Codes) > 0 {
+		resp["recovery_codes"] = authResponse.Recovery_Codes
+	}
+	return c.JSON(resp)
+}
+
+func (h *authHandler) GetTwoFactorStatus(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(uint)
+
+	status, err := h.authService.GetTwoFactorStatus(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": status})
+}
+
+func (h *authHandler) SetupTwoFactor(c *fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(uint)
+
+	setup, err := h.authService.SetupTwoFactor(userID)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": setup})
+}
+
+func (h *authHandler) EnableTwoFactor(c *fiber.Ctx) error {
+	var req service.TwoFactorCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	userID, _ := c.Locals("user_id").(uint)
+	codes, err := h.authService.EnableTwoFactor(userID, req.Code)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": fiber.Map{"recovery_codes": codes}})
+}
+
+func (h *authHandler) ChangePassword(c *fiber.Ctx) error {
+	var req service.ChangePasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	userID, _ := c.Locals("user_id").(uint)
+	sessionID, _ := c.Locals("session_id").(uint)
+	device := service.LoginDevice{Ip: c.IP(), UserAgent: c.Get("User-Agent")}
+	if err := h.authService.ChangePassword(userID, sessionID, req, device); err != nil {
+		var policyErr *service.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "เปลี่ยนรหัสผ่านเรียบร้อย เซสชันอื่นถูกออกจากระบบแล้ว"})
+}
+
+func (h *authHandler) GetPasswordPolicy(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"data": h.authService.GetPasswordPolicy()})
+}
+
+func (h *authHandler) DisableTwoFactor(c *fiber.Ctx) error {
+	var req service.TwoFactorCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	userID, _ := c.Locals("user_id").(uint)
+	if err := h.authService.DisableTwoFactor(userID, req.Code); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "ปิดใช้ 2FA เรียบร้อย"})
+}
+
+func (h *authHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	var req service.TwoFactorCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	userID, _ := c.Locals("user_id").(uint)
+	codes, err := h.authService.RegenerateRecoveryCodes(userID, req.Code)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": fiber.Map{"recovery_codes": codes}})
+}
+
+func (h *authHandler) ResetUserTwoFactor(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+
+	actorID, _ := c.Locals("user_id").(uint)
+	if err := h.authService.ResetTwoFactor(actorID, uint(id)); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "ล้าง 2FA ของผู้ใช้เรียบร้อย"})
+}
+
+// บัญชี / IP ถูกล็อกชั่วคราว แจ้งเวลาที่ลองใหม่ได้
+func loginLockedResponse(c *fiber.Ctx, locked *service.LoginLockedError) error {
+	retryAfter := int(math.Ceil(time.Until(locked.Until).Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Set(fiber.HeaderRetryAfter, fmt.Sprint(retryAfter))
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"error":        locked.Error(),
+		"locked_until": locked.Until,
+		"retry_after":  retryAfter,
 	})
 }
+
+// รายการ username / IP ที่ถูกล็อกอยู่
+func (h *authHandler) GetLoginLocks(c *fiber.Ctx) error {
+	locks, err := h.authService.GetLoginLocks()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": locks})
+}
+
+func (h *authHandler) UnlockLogin(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+
+	actorID, _ := c.Locals("user_id").(uint)
+	if err := h.authService.UnlockLogin(actorID, uint(id)); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "ปลดล็อกเรียบร้อย"})
+}
+
+func (h *authHandler) UnlockUserLogin(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+
+	actorID, _ := c.Locals("user_id").(uint)
+	if err := h.authService.UnlockUserLogin(actorID, uint(id)); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "ปลดล็อกบัญชีผู้ใช้เรียบร้อย"})
+}
+
+// การเข้าสู่ระบบที่ไม่สำเร็จ ?username=&ip=&user_id=&date_from=&date_to=&page=&limit=
+func (h *authHandler) GetLoginFailures(c *fiber.Ctx) error {
+	query := service.LoginFailureQuery{
+		Username: c.Query("username"),
+		Ip:       c.Query("ip"),
+		User_Id:  queryIntPtr(c, "user_id"),
+		Page:     c.QueryInt("page", 1),
+		Limit:    c.QueryInt("limit", 50),
+	}
+	if df := c.Query("date_from"); df != "" {
+		if t, err := time.Parse("2006-01-02", df); err == nil {
+			query.DateFrom = &t
+		}
+	}
+	if dt := c.Query("date_to"); dt != "" {
+		if t, err := time.Parse("2006-01-02", dt); err == nil {
+			query.DateTo = &t
+		}
+	}
+
+	failures, err := h.authService.GetLoginFailures(query)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": failures})
+}
+
+func (h *authHandler) GetUserLoginHistory(c *fiber.Ctx) error {
+	userID, err := c.ParamsInt("id")
+	if err != nil || userID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ผู้ใช้ไม่ถูกต้อง"})
+	}
+
+	query := service.LoginHistoryQuery{
+		Page:  c.QueryInt("page", 1),
+		Limit: c.QueryInt("limit", 50),
+	}
+	if success := c.Query("success"); success != "" {
+		v := success == "true" || success == "1"
+		query.Success = &v
+	}
+	if df := c.Query("date_from"); df != "" {
+		if t, err := time.Parse("2006-01-02", df); err == nil {
+			query.DateFrom = &t
+		}
+	}
+	if dt := c.Query("date_to"); dt != "" {
+		if t, err := time.Parse("2006-01-02", dt); err == nil {
+			query.DateTo = &t
+		}
+	}
+
+	history, err := h.authService.GetLoginHistory(uint(userID), query)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": history})
+}
+
+func (h *authHandler) GetPermissions(c *fiber.Ctx) error {
+	perms, err := h.authService.GetPermissionCatalog()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": perms})
+}
+
+func (h *authHandler) GetRolePermissions(c *fiber.Ctx) error {
+	roleID, err := c.ParamsInt("role_id")
+	if err != nil || roleID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "role ไม่ถูกต้อง"})
+	}
+
+	perms, err := h.authService.GetRolePermissions(roleID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": perms})
+}
+
+func (h *authHandler) UpdateRolePermissions(c *fiber.Ctx) error {
+	roleID, err := c.ParamsInt("role_id")
+	if err != nil || roleID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "role ไม่ถูกต้อง"})
+	}
+
+	var req service.UpdateRolePermissionsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	actorRoleID, _ := c.Locals("role_id").(int)
+	perms, err := h.authService.UpdateRolePermissions(actorRoleID, roleID, req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": perms})
+}
+
+// สิทธิ์ของผู้ใช้ที่เข้าสู่ระบบอยู่ (ให้หน้าเว็บซ่อนเมนูที่ไม่มีสิทธิ์)
+func (h *authHandler) GetMyPermissions(c *fiber.Ctx) error {
+	roleID, _ := c.Locals("role_id").(int)
+	perms, err := h.authService.GetRolePermissions(roleID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": perms})
+}
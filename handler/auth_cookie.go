@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"rrmobile/service"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/spf13/viper"
+)
+
+// โหมด cookie สำหรับหน้าเว็บหลังร้าน: refresh token อยู่ใน cookie HttpOnly ที่ JS อ่านไม่ได้
+// access token (อายุสั้น) ส่งใน body ให้หน้าเว็บเก็บไว้ในหน่วยความจำแล้วส่งเป็น Bearer ตามเดิม
+// แอปมือถือ / bot ไม่ส่ง X-Auth-Mode ก็ได้ refresh token ใน body เหมือนเดิม
+const (
+	authModeHeader    = "X-Auth-Mode"
+	csrfHeader        = "X-CSRF-Token"
+	refreshCookieName = "rr_refresh"
+	csrfCookieName    = "rr_csrf"
+	refreshCookiePath = "/auth"
+
+	// ตรงกับอายุ refresh token / เซสชันใน authService
+	refreshCookieMaxAge = 7 * 24 * time.Hour
+)
+
+func wantsCookieMode(c *fiber.Ctx) bool {
+	return strings.EqualFold(strings.TrimSpace(c.Get(authModeHeader)), "cookie")
+}
+
+// ค่า cookie ตั้งจาก .env: COOKIE_SECURE (ค่าเริ่มต้น true), COOKIE_SAMESITE (Strict / Lax / None), COOKIE_DOMAIN
+func cookieSecure() bool {
+	if !viper.IsSet("COOKIE_SECURE") {
+		return true
+	}
+	return viper.GetBool("COOKIE_SECURE")
+}
+
+func cookieSameSite() string {
+	switch strings.ToLower(strings.TrimSpace(viper.GetString("COOKIE_SAMESITE"))) {
+	case "lax":
+		return fiber.CookieSameSiteLaxMode
+	case "none":
+		return fiber.CookieSameSiteNoneMode
+	}
+	return fiber.CookieSameSiteStrictMode
+}
+
+// ตั้ง refresh cookie + CSRF cookie ใหม่ คืน CSRF token ให้ส่งกลับใน body ด้วย
+// (หน้าเว็บที่อยู่คนละโดเมนกับ API อ่าน cookie ของ API ไม่ได้)
+func setSessionCookies(c *fiber.Ctx, refreshToken string) (string, error) {
+	csrfToken, err := service.GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	expires := time.Now().Add(refreshCookieMaxAge)
+	c.Cookie(&fiber.Cookie{
+		Name:     refreshCookieName,
+		Value:    refreshToken,
+		Path:     refreshCookiePath,
+		Domain:   viper.GetString("COOKIE_DOMAIN"),
+		Expires:  expires,
+		Secure:   cookieSecure(),
+		HTTPOnly: true,
+		SameSite: cookieSameSite(),
+	})
+	c.Cookie(&fiber.Cookie{
+		Name:     csrfCookieName,
+		Value:    csrfToken,
+		Path:     "/",
+		Domain:   viper.GetString("COOKIE_DOMAIN"),
+		Expires:  expires,
+		Secure:   cookieSecure(),
+		HTTPOnly: false, // JS ต้องอ่านไปใส่ header X-CSRF-Token
+		SameSite: cookieSameSite(),
+	})
+	return csrfToken, nil
+}
+
+func clearSessionCookies(c *fiber.Ctx) {
+	for _, cookie := range []struct{ name, path string }{
+		{refreshCookieName, refreshCookiePath},
+		{csrfCookieName, "/"},
+	} {
+		c.Cookie(&fiber.Cookie{
+			Name:     cookie.name,
+			Value:    "",
+			Path:     cookie.path,
+			Domain:   viper.GetString("COOKIE_DOMAIN"),
+			Expires:  time.Unix(0, 0),
+			MaxAge:   -1,
+			Secure:   cookieSecure(),
+			HTTPOnly: cookie.name == refreshCookieName,
+			SameSite: cookieSameSite(),
+		})
+	}
+}
+
+// double-submit: request ที่ยืนยันตัวตนด้วย refresh cookie ต้องส่ง X-CSRF-Token ตรงกับ cookie rr_csrf
+// เว็บอื่นส่ง cookie มากับ request ได้ แต่อ่านค่า cookie มาใส่ header ไม่ได้
+func validCSRF(c *fiber.Ctx) bool {
+	cookie := c.Cookies(csrfCookieName)
+	header := c.Get(csrfHeader)
+	if cookie == "" || header == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) == 1
+}
+
+// ตอบกลับหลังเข้าสู่ระบบสำเร็จ โหมด cookie จะไม่ส่ง refresh token ใน body
+func sessionResponse(c *fiber.Ctx, authResponse *service.AuthResponse) (fiber.Map, error) {
+	resp := fiber.Map{
+		"role_id":      authResponse.RoleID,
+		"access_token": authResponse.Token,
+		"session_id":   authResponse.SessionID,
+	}
+	if authResponse.Password_Change_Required {
+		resp["password_change_required"] = true
+	}
+	if !wantsCookieMode(c) {
+		resp["refresh_token"] = authResponse.RefreshToken
+		return resp, nil
+	}
+	csrfToken, err := setSessionCookies(c, authResponse.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	resp["csrf_token"] = csrfToken
+	return resp, nil
+}
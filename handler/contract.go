@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"errors"
+	"rrmobile/service"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ContractRequestHandler interface {
+	CreateContract(c *fiber.Ctx) error
+	PayContract(c *fiber.Ctx) error
+	GetContract(c *fiber.Ctx) error
+	GetContracts(c *fiber.Ctx) error
+	GetContractSummary(c *fiber.Ctx) error
+
+	CreateCashSale(c *fiber.Ctx) error
+	GetCashSaleReceipt(c *fiber.Ctx) error
+	GetSalesReport(c *fiber.Ctx) error
+}
+
+type contractHandler struct {
+	contractService service.ContractService
+}
+
+func NewContractHandler(contractService service.ContractService) *contractHandler {
+	return &contractHandler{contractService: contractService}
+}
+
+func contractListFilter(c *fiber.Ctx) service.ContractListFilter {
+	filter := service.ContractListFilter{
+		Product_Type: c.Query("product_type"),
+		Status:       queryIntPtr(c, "status"),
+		Search:       c.Query("search"),
+	}
+	if m := queryIntPtr(c, "member_id"); m != nil && *m > 0 {
+		memberID := uint(*m)
+		filter.MemberId = &memberID
+	}
+	if df := c.Query("date_from"); df != "" {
+		if t, err := time.Parse("2006-01-02", df); err == nil {
+			filter.DateFrom = &t
+		}
+	}
+	if dt := c.Query("date_to"); dt != "" {
+		if t, err := time.Parse("2006-01-02", dt); err == nil {
+			filter.DateTo = &t
+		}
+	}
+	return filter
+}
+
+func (h *contractHandler) CreateContract(c *fiber.Ctx) error {
+	var request service.NewContractRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	contract, err := h.contractService.CreateContract(request, auditActor(c))
+	if errors.Is(err, service.ErrAppraisalInUse) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": contract})
+}
+
+func (h *contractHandler) PayContract(c *fiber.Ctx) error {
+	var request service.ContractPayRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	userID, _ := c.Locals("user_id").(uint)
+	resp, err := h.contractService.PayContract(request, int(userID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": resp})
+}
+
+func (h *contractHandler) GetContract(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+
+	contract, err := h.contractService.GetContract(c.Params("type"), uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": contract})
+}
+
+func (h *contractHandler) GetContracts(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "0")) // default 0 = all
+
+	resp, err := h.contractService.GetContracts(contractListFilter(c), page, limit)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(resp)
+}
+
+func (h *contractHandler) GetContractSummary(c *fiber.Ctx) error {
+	resp, err := h.contractService.GetContractSummary(contractListFilter(c))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": resp})
+}
+
+func (h *contractHandler) CreateCashSale(c *fiber.Ctx) error {
+	var request service.NewCashSaleRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	receipt, err := h.contractService.CreateCashSale(request, auditActor(c))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": receipt})
+}
+
+func (h *contractHandler) GetCashSaleReceipt(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+	receipt, err := h.contractService.GetCashSaleReceipt(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": receipt})
+}
+
+func (h *contractHandler) GetSalesReport(c *fiber.Ctx) error {
+	filter := contractListFilter(c)
+	report, err := h.contractService.GetSalesReport(filter.DateFrom, filter.DateTo)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": report})
+}
@@ -55,7 +55,7 @@ func (ih *fineHandler) CreateFine(c *fiber.Ctx) error {
 	if err := c.BodyParser(&request); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
 	}
-	installment, err := ih.fineService.CreateFine(request)
+	installment, err := ih.fineService.CreateFine(request, auditActor(c))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "ไม่สามารถสร้างข้อมูลได้"})
 	}
@@ -70,7 +70,7 @@ func (ih *fineHandler) UpdateFine(c *fiber.Ctx) error {
 	if err := c.BodyParser(&request); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
 	}
-	installment, err := ih.fineService.UpdateFine(uint(id), request)
+	installment, err := ih.fineService.UpdateFine(uint(id), request, auditActor(c))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "ไม่สามารถอัพเดทข้อมูลได้"})
 	}
@@ -82,7 +82,7 @@ func (ih *fineHandler) DeleteFine(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
 	}
-	if err := ih.fineService.DeleteFine(uint(id)); err != nil {
+	if err := ih.fineService.DeleteFine(uint(id), auditActor(c)); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "ไม่สามารถลบข้อมูลได้"})
 	}
 	return c.SendStatus(fiber.StatusNoContent)
@@ -53,7 +53,7 @@ func (ih *fineCategoryHandler) CreateFineCategory(c *fiber.Ctx) error {
 	if err := c.BodyParser(&request); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
 	}
-	category, err := ih.fineCategoryService.CreateFineCategory(request)
+	category, err := ih.fineCategoryService.CreateFineCategory(request, auditActor(c))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "ไม่สามารถสร้างข้อมูลได้"})
 	}
@@ -70,7 +70,7 @@ func (ih *fineCategoryHandler) UpdateFineCategory(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
 	}
 
-	category, err := ih.fineCategoryService.UpdateFineCategory(uint(id), request)
+	category, err := ih.fineCategoryService.UpdateFineCategory(uint(id), request, auditActor(c))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "ไม่สามารถอัปเดตข้อมูลได้"})
 	}
@@ -82,7 +82,7 @@ func (ih *fineCategoryHandler) DeleteFineCategory(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
 	}
 
-	if err := ih.fineCategoryService.DeleteFineCategory(uint(id)); err != nil {
+	if err := ih.fineCategoryService.DeleteFineCategory(uint(id), auditActor(c)); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "ไม่สามารถลบข้อมูลได้"})
 	}
 	return c.SendStatus(fiber.StatusNoContent)
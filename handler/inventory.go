@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"rrmobile/service"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type InventoryRequestHandler interface {
+	GetInventoryUnits(c *fiber.Ctx) error
+	GetInventoryUnitById(c *fiber.Ctx) error
+	UpdateInventoryUnit(c *fiber.Ctx) error
+	CreateInventoryUnit(c *fiber.Ctx) error
+	GetProductStocks(c *fiber.Ctx) error
+	AdjustProductStock(c *fiber.Ctx) error
+}
+
+type inventoryHandler struct {
+	inventoryService service.InventoryService
+}
+
+func NewInventoryHandler(inventoryService service.InventoryService) *inventoryHandler {
+	return &inventoryHandler{inventoryService: inventoryService}
+}
+
+func queryIntPtr(c *fiber.Ctx, key string) *int {
+	raw := c.Query(key)
+	if raw == "" {
+		return nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+func (h *inventoryHandler) GetInventoryUnits(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "0")) // default 0 = all
+
+	resp, err := h.inventoryService.GetAllInventoryUnits(queryIntPtr(c, "source"), queryIntPtr(c, "status"), page, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(resp)
+}
+
+func (h *inventoryHandler) GetInventoryUnitById(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+	unit, err := h.inventoryService.GetInventoryUnitById(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": unit})
+}
+
+func (h *inventoryHandler) UpdateInventoryUnit(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+	var request service.UpdateInventoryUnitRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+	unit, err := h.inventoryService.UpdateInventoryUnit(uint(id), request)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": unit})
+}
+
+func (h *inventoryHandler) CreateInventoryUnit(c *fiber.Ctx) error {
+	var request service.NewInventoryUnitRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+	unit, err := h.inventoryService.CreateInventoryUnit(request)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": unit})
+}
+
+func (h *inventoryHandler) GetProductStocks(c *fiber.Ctx) error {
+	stocks, err := h.inventoryService.GetProductStocks()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": stocks})
+}
+
+func (h *inventoryHandler) AdjustProductStock(c *fiber.Ctx) error {
+	productID, err := c.ParamsInt("product_id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+	var request service.AdjustProductStockRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+	stock, err := h.inventoryService.AdjustProductStock(uint(productID), request)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": stock})
+}
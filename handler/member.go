@@ -58,7 +58,7 @@ func (ih *memberHandler) CreateMember(c *fiber.Ctx) error {
 	if err := c.BodyParser(&request); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
 	}
-	member, err := ih.memberService.CreateMember(request)
+	member, err := ih.memberService.CreateMember(request, auditActor(c))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "ไม่สามารถสร้างข้อมูลได้"})
 	}
@@ -73,7 +73,7 @@ func (ih *memberHandler) UpdateMember(c *fiber.Ctx) error {
 	if err := c.BodyParser(&request); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
 	}
-	member, err := ih.memberService.EditMember(uint(id), request)
+	member, err := ih.memberService.EditMember(uint(id), request, auditActor(c))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "ไม่สามารถอัพเดทข้อมูลได้"})
 	}
@@ -85,7 +85,7 @@ func (ih *memberHandler) DeleteInstallment(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
 	}
-	if err := ih.memberService.DeleteMember(uint(id)); err != nil {
+	if err := ih.memberService.DeleteMember(uint(id), auditActor(c)); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "ไม่สามารถลบข้อมูลได้"})
 	}
 	return c.SendStatus(fiber.StatusNoContent)
@@ -139,7 +139,7 @@ func (h *memberHandler) LinkUserByTel(c *fiber.Ctx) error {
 	result, err := h.memberService.UpdateMemberByTel(service.UpdateMemberRequest1{
 		Tel:    req.Tel,
 		UserId: req.UserId,
-	})
+	}, auditActor(c))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
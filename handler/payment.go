@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"rrmobile/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type PaymentRequestHandler interface {
+	GetAllocationPolicy(c *fiber.Ctx) error
+	UpdateAllocationPolicy(c *fiber.Ctx) error
+	GetPaymentReceipt(c *fiber.Ctx) error
+	GetBillLedger(c *fiber.Ctx) error
+}
+
+type paymentHandler struct {
+	paymentService service.PaymentService
+}
+
+func NewPaymentHandler(paymentService service.PaymentService) *paymentHandler {
+	return &paymentHandler{paymentService: paymentService}
+}
+
+func (h *paymentHandler) GetAllocationPolicy(c *fiber.Ctx) error {
+	policy, err := h.paymentService.GetAllocationPolicy()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": policy})
+}
+
+func (h *paymentHandler) UpdateAllocationPolicy(c *fiber.Ctx) error {
+	var req service.UpdatePaymentAllocationPolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	policy, err := h.paymentService.UpdateAllocationPolicy(req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": policy})
+}
+
+func (h *paymentHandler) GetPaymentReceipt(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+
+	receipt, err := h.paymentService.GetPaymentReceipt(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": receipt})
+}
+
+func (h *paymentHandler) GetBillLedger(c *fiber.Ctx) error {
+	billType, err := c.ParamsInt("bill_type")
+	if err != nil || billType <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ประเภทสัญญาไม่ถูกต้อง"})
+	}
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+
+	ledger, err := h.paymentService.GetBillLedger(billType, uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": ledger})
+}
@@ -52,7 +52,7 @@ func (h *productCategoryHandler) CreateCategory(c *fiber.Ctx) error {
 			"error": "Invalid request body",
 		})
 	}
-	category, err := h.productCategoryService.CreateCategory(req)
+	category, err := h.productCategoryService.CreateCategory(req, auditActor(c))
 	if err != nil {
 		return c.Status(fiber.ErrBadRequest.Code).JSON(fiber.Map{
 			"error": "Failed to create category",
@@ -103,7 +103,7 @@ func (h *productCategoryHandler) UpdateCategory(c *fiber.Ctx) error {
 			"error": "Invalid request body",
 		})
 	}
-	category, err := h.productCategoryService.EditCategory(uint(id), req)
+	category, err := h.productCategoryService.EditCategory(uint(id), req, auditActor(c))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to update category",
@@ -119,7 +119,7 @@ func (h *productCategoryHandler) DeleteCategory(c *fiber.Ctx) error {
 			"error": "Invalid category ID",
 		})
 	}
-	if err := h.productCategoryService.DeleteCategory(uint(id)); err != nil {
+	if err := h.productCategoryService.DeleteCategory(uint(id), auditActor(c)); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to delete category",
 		})
@@ -194,7 +194,7 @@ func (h *productHandler) AddProduct(c *fiber.Ctx) error {
 		Images:      imgUrls,
 	}
 
-	product, err := h.productService.CreateProduct(req)
+	product, err := h.productService.CreateProduct(req, auditActor(c))
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -344,7 +344,7 @@ func (h *productHandler) UpdateProduct(c *fiber.Ctx) error {
 		DeleteImageIDs: deleteIDs,
 	}
 
-	updated, err := h.productService.EditProduct(uint(id), req)
+	updated, err := h.productService.EditProduct(uint(id), req, auditActor(c))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -359,7 +359,7 @@ func (h *productHandler) DeleteProduct(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid product ID"})
 	}
 
-	if err := h.productService.DeleteProduct(uint(id)); err != nil {
+	if err := h.productService.DeleteProduct(uint(id), auditActor(c)); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
 
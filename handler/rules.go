@@ -90,7 +90,7 @@ func (rh *rulesHandler) CreateRule(c *fiber.Ctx) error {
 		})
 	}
 
-	rule, err := rh.rulesService.CreateRule(request)
+	rule, err := rh.rulesService.CreateRule(request, auditActor(c))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "ไม่สามารถสร้างกฎได้",
@@ -115,7 +115,7 @@ func (rh *rulesHandler) EditRule(c *fiber.Ctx) error {
 		})
 	}
 
-	rule, err := rh.rulesService.EditRule(uint(id), request)
+	rule, err := rh.rulesService.EditRule(uint(id), request, auditActor(c))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "ไม่สามารถแก้ไขกฎได้",
@@ -132,7 +132,7 @@ func (rh *rulesHandler) DeleteRule(c *fiber.Ctx) error {
 		})
 	}
 
-	err = rh.rulesService.DeleteRule(uint(id))
+	err = rh.rulesService.DeleteRule(uint(id), auditActor(c))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "ไม่สามารถลบกฎได้",
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"rrmobile/config"
 	"rrmobile/service"
 	"strconv"
@@ -139,8 +140,12 @@ func (uh *usersHandler) CreateUsers(c *fiber.Ctx) error {
 		})
 
 	}
-	createdUser, err := uh.usersService.CreateUser(user)
+	createdUser, err := uh.usersService.CreateUser(user, auditActor(c))
 	if err != nil {
+		var policyErr *service.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": config.Error_dupli,
 		})
@@ -173,8 +178,12 @@ func (uh *usersHandler) UpdateUser(c *fiber.Ctx) error {
 	}
 
 	// Call the service to update the role
-	updatedRole, err := uh.usersService.EditUser(uint(id), user.Username, user.FullName, user.Password, user.Is_active)
+	updatedRole, err := uh.usersService.EditUser(uint(id), user.Username, user.FullName, user.Password, user.Is_active, auditActor(c))
 	if err != nil {
+		var policyErr *service.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": config.Error_update,
 		})
@@ -195,7 +204,7 @@ func (uh *usersHandler) DeleteUser(c *fiber.Ctx) error {
 		})
 	}
 
-	err = uh.usersService.DeleteUser(uint(id))
+	err = uh.usersService.DeleteUser(uint(id), auditActor(c))
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": config.Error_del,
@@ -222,8 +231,12 @@ func (uh *usersHandler) ResetPassword(c *fiber.Ctx) error {
 	}
 
 	// Call the service to update the role
-	updatedRole, err := uh.usersService.ResetPasswordUser(uint(id), user.Password)
+	updatedRole, err := uh.usersService.ResetPasswordUser(uint(id), user.Password, auditActor(c))
 	if err != nil {
+		var policyErr *service.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": config.Error_update,
 		})
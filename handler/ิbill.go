@@ -1,8 +1,10 @@
 package handler
 
 import (
+	"errors"
 	"log"
 	"rrmobile/config"
+	"rrmobile/model"
 	"rrmobile/service"
 	"strconv"
 	"strings"
@@ -41,13 +43,50 @@ type BillRequestHandler interface {
 	GetpaidInstallBillByIdHandler(c *fiber.Ctx) error
 
 	RenewInterest(c *fiber.Ctx) error
+
+	GetPawnForfeitPolicy(c *fiber.Ctx) error
+	UpdatePawnForfeitPolicy(c *fiber.Ctx) error
+	RunPawnForfeitures(c *fiber.Ctx) error
+	GetPawnForfeitReport(c *fiber.Ctx) error
+	GetPendingForfeitNotices(c *fiber.Ctx) error
+	MarkForfeitNoticeSent(c *fiber.Ctx) error
+
+	GetPawnRenewalHistory(c *fiber.Ctx) error
+	GetPawnRenewalHistoryBot(c *fiber.Ctx) error
+
+	ReducePawnPrincipal(c *fiber.Ctx) error
+	TopUpPawnPrincipal(c *fiber.Ctx) error
+	GetPawnPrincipalChanges(c *fiber.Ctx) error
+
+	TransitionBill(c *fiber.Ctx) error
+	TransitionInstallmentBill(c *fiber.Ctx) error
+	GetBillStatusHistory(c *fiber.Ctx) error
+	GetInstallmentBillStatusHistory(c *fiber.Ctx) error
+	GetBillStateMachine(c *fiber.Ctx) error
+
+	RestructureBill(c *fiber.Ctx) error
+	GetBillRestructures(c *fiber.Ctx) error
+
+	CancelBill(c *fiber.Ctx) error
+	CancelInstallmentBill(c *fiber.Ctx) error
+	GetBillCancellation(c *fiber.Ctx) error
+	GetInstallmentBillCancellation(c *fiber.Ctx) error
+	ReverseBillPayment(c *fiber.Ctx) error
+
+	GetCreditLedger(c *fiber.Ctx) error
+	GetInstallmentCreditLedger(c *fiber.Ctx) error
+	RefundCredit(c *fiber.Ctx) error
+	RefundInstallmentCredit(c *fiber.Ctx) error
+	TransferCredit(c *fiber.Ctx) error
+	TransferInstallmentCredit(c *fiber.Ctx) error
 }
 type billHandler struct {
-	billService service.BillService
+	billService     service.BillService
+	contractService service.ContractService
 }
 
-func NewBillHandler(billService service.BillService) *billHandler {
-	return &billHandler{billService: billService}
+func NewBillHandler(billService service.BillService, contractService service.ContractService) *billHandler {
+	return &billHandler{billService: billService, contractService: contractService}
 }
 
 func (ih *billHandler) CreateBill(c *fiber.Ctx) error {
@@ -55,7 +94,15 @@ func (ih *billHandler) CreateBill(c *fiber.Ctx) error {
 	if err := c.BodyParser(&request); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
 	}
-	category, err := ih.billService.CreateBill(request)
+	// route เดิม: ส่งต่อให้ contract engine แล้วตอบกลับในรูปแบบเดิม
+	contract, err := ih.contractService.CreateContract(service.NewContractRequest{
+		Product_Type:  service.ContractTypeHirePurchase,
+		Hire_Purchase: &request,
+	}, auditActor(c))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "ไม่สามารถสร้างข้อมูลได้"})
+	}
+	category, err := ih.billService.GetBillById(contract.Id)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "ไม่สามารถสร้างข้อมูลได้"})
 	}
@@ -67,6 +114,8 @@ func (h *billHandler) PayInstallment(c *fiber.Ctx) error {
 		BillID       uint    `json:"bill_id"`
 		BillDetailID uint    `json:"bill_detail_id"`
 		Amount       float64 `json:"amount"`
+
+		Tenders []service.PaymentTenderRequest `json:"tenders"`
 	}
 
 	var req Request
@@ -82,7 +131,14 @@ func (h *billHandler) PayInstallment(c *fiber.Ctx) error {
 		})
 	}
 
-	results, err := h.billService.PayInstallment(req.BillID, req.BillDetailID, req.Amount)
+	userID, _ := c.Locals("user_id").(uint)
+	paid, err := h.contractService.PayContract(service.ContractPayRequest{
+		Product_Type: service.ContractTypeHirePurchase,
+		Contract_Id:  req.BillID,
+		Detail_Id:    req.BillDetailID,
+		Amount:       req.Amount,
+		Tenders:      req.Tenders,
+	}, int(userID))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
@@ -92,7 +148,7 @@ func (h *billHandler) PayInstallment(c *fiber.Ctx) error {
 	// ส่งกลับผลลัพธ์ของแต่ละงวดพร้อม Case และเครดิตที่เหลือ
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "installment processed successfully",
-		"results": results,
+		"results": paid.Results,
 		"bill_id": req.BillID,
 		"amount":  req.Amount,
 	})
@@ -107,7 +163,8 @@ func (h *billHandler) AddExtraPayment(c *fiber.Ctx) error {
 	}
 
 	// เรียก service
-	if err := h.billService.AddExtraPayment(req.BillID, req.InstallmentID, req); err != nil {
+	userID, _ := c.Locals("user_id").(uint)
+	if err := h.billService.AddExtraPayment(req.BillID, req.InstallmentID, req, int(userID)); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
 		})
@@ -229,14 +286,22 @@ func (ih *billHandler) CreateBillInsallments(c *fiber.Ctx) error {
 	if err := c.BodyParser(&request); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
 	}
-
-
-	resp, err := ih.billService.CreateInstallmentBill(request, uint(request.InstallmentId))
+	contract, err := ih.contractService.CreateContract(service.NewContractRequest{
+		Product_Type: service.ContractTypePawn,
+		Pawn:         &request,
+	}, auditActor(c))
+	if errors.Is(err, service.ErrAppraisalInUse) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(), // 👈 โชว์ error จริงไว้ก่อน (ตอน dev)
 		})
 	}
+	resp, err := ih.billService.GetInstallmentBillById(contract.Id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
 
 	return c.JSON(resp)
 }
@@ -269,6 +334,8 @@ func (h *billHandler) PayInstallmentBill(c *fiber.Ctx) error {
 		BillDetailID uint `json:"bill_detail_id"`
 
 		Amount float64 `json:"amount"`
+
+		Tenders []service.PaymentTenderRequest `json:"tenders"`
 	}
 
 	var req Request
@@ -284,8 +351,15 @@ func (h *billHandler) PayInstallmentBill(c *fiber.Ctx) error {
 		})
 	}
 
-	// เรียก service
-	results, err := h.billService.PayPurchaseInstallment(req.BillID, req.BillDetailID, req.Amount)
+	// เรียก contract engine
+	userID, _ := c.Locals("user_id").(uint)
+	paid, err := h.contractService.PayContract(service.ContractPayRequest{
+		Product_Type: service.ContractTypePawn,
+		Contract_Id:  req.BillID,
+		Detail_Id:    req.BillDetailID,
+		Amount:       req.Amount,
+		Tenders:      req.Tenders,
+	}, int(userID))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
@@ -294,7 +368,7 @@ func (h *billHandler) PayInstallmentBill(c *fiber.Ctx) error {
 
 	// ส่ง JSON Response กลับ
 	return c.JSON(fiber.Map{
-		"data": results,
+		"data": paid.Results,
 	})
 }
 
@@ -307,7 +381,8 @@ func (h *billHandler) AddInstallmentExtraPayment(c *fiber.Ctx) error {
 	}
 
 	// เรียก service
-	if err := h.billService.AddInstallmentExtraPayment(req.BillID, req.InstallmentID, req); err != nil {
+	userID, _ := c.Locals("user_id").(uint)
+	if err := h.billService.AddInstallmentExtraPayment(req.BillID, req.InstallmentID, req, int(userID)); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
 		})
@@ -400,9 +475,9 @@ func (h *billHandler) UpdateBill(c *fiber.Ctx) error {
 	}
 
 	// เรียก service
-	updatedBill, err := h.billService.UpdateBill(uint(id), request)
+	updatedBill, err := h.billService.UpdateBill(uint(id), request, auditActor(c))
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
@@ -430,9 +505,9 @@ func (h *billHandler) UpdateBill_Installments(c *fiber.Ctx) error {
 	}
 
 	// เรียก service
-	updatedBill, err := h.billService.UpdateBill_Installment(uint(id), request)
+	updatedBill, err := h.billService.UpdateBill_Installment(uint(id), request, auditActor(c))
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
@@ -639,6 +714,9 @@ type RenewInterestRequest struct {
 	PayAmount float64 `json:"pay_amount"`
 	payNext string  `json:"pay_date"`
 	PayDate   string  `json:"pay_date"`
+	PaymentRef string  `json:"payment_ref"`
+
+	Tenders []service.PaymentTenderRequest `json:"tenders"`
 }
 
 // func (h *billHandler) RenewInterest(c *fiber.Ctx) error {
@@ -699,7 +777,8 @@ func (h *billHandler) RenewInterest(c *fiber.Ctx) error {
 	}
 
 	log.Print("payDate", payDate)
-	_, err = h.billService.RenewInterest(uint(billID), req.PayAmount, payDate)
+	userID, _ := c.Locals("user_id").(uint)
+	_, err = h.billService.RenewInterest(uint(billID), req.PayAmount, payDate, int(userID), req.PaymentRef, req.Tenders)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -708,3 +787,365 @@ func (h *billHandler) RenewInterest(c *fiber.Ctx) error {
 		"message": "ต่อดอกสำเร็จ",
 	})
 }
+
+func (h *billHandler) GetPawnForfeitPolicy(c *fiber.Ctx) error {
+	policy, err := h.billService.GetPawnForfeitPolicy()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": policy})
+}
+
+func (h *billHandler) UpdatePawnForfeitPolicy(c *fiber.Ctx) error {
+	var req service.UpdatePawnForfeitPolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	policy, err := h.billService.UpdatePawnForfeitPolicy(req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": policy})
+}
+
+// สั่งตรวจหลุดจำนำทันที (ปกติรันโดย cron ตอนเที่ยงคืน)
+func (h *billHandler) RunPawnForfeitures(c *fiber.Ctx) error {
+	if err := h.billService.ProcessPawnForfeitures(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "ตรวจสอบหลุดจำนำเรียบร้อย"})
+}
+
+func (h *billHandler) GetPawnForfeitReport(c *fiber.Ctx) error {
+	report, err := h.billService.GetPawnForfeitReport()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": report})
+}
+
+func (h *billHandler) GetPendingForfeitNotices(c *fiber.Ctx) error {
+	notices, err := h.billService.GetPendingForfeitNotices()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": notices})
+}
+
+func (h *billHandler) MarkForfeitNoticeSent(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+
+	notice, err := h.billService.MarkForfeitNoticeSent(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": notice})
+}
+
+func (h *billHandler) GetPawnRenewalHistory(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+
+	history, err := h.billService.GetPawnRenewalHistory(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": history})
+}
+
+type GetPawnRenewalHistoryRequest struct {
+	UserId string `json:"user_id"`
+	BillID uint   `json:"bill_id"`
+}
+
+func (h *billHandler) GetPawnRenewalHistoryBot(c *fiber.Ctx) error {
+	var req GetPawnRenewalHistoryRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ไม่สามารถอ่านข้อมูลได้"})
+	}
+	if req.UserId == "" || req.BillID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "กรุณาระบุ user_id และ bill_id"})
+	}
+
+	history, err := h.billService.GetPawnRenewalHistoryForMember(req.UserId, req.BillID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "ไม่พบข้อมูลที่ต้องการ"})
+	}
+	return c.JSON(history)
+}
+
+func (h *billHandler) ReducePawnPrincipal(c *fiber.Ctx) error {
+	return h.changePawnPrincipal(c, h.billService.ReducePawnPrincipal)
+}
+
+func (h *billHandler) TopUpPawnPrincipal(c *fiber.Ctx) error {
+	return h.changePawnPrincipal(c, h.billService.TopUpPawnPrincipal)
+}
+
+func (h *billHandler) changePawnPrincipal(c *fiber.Ctx, fn func(uint, service.PawnPrincipalChangeRequest, int) (*service.PawnPrincipalChangeResponse, error)) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+
+	var req service.PawnPrincipalChangeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	userID, _ := c.Locals("user_id").(uint)
+	change, err := fn(uint(id), req, int(userID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": change})
+}
+
+func (h *billHandler) GetPawnPrincipalChanges(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+
+	changes, err := h.billService.GetPawnPrincipalChanges(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": changes})
+}
+
+func (h *billHandler) TransitionBill(c *fiber.Ctx) error {
+	return h.transitionBill(c, model.BillTypeHirePurchase)
+}
+
+func (h *billHandler) TransitionInstallmentBill(c *fiber.Ctx) error {
+	return h.transitionBill(c, model.BillTypeInstallment)
+}
+
+func (h *billHandler) transitionBill(c *fiber.Ctx, billType int) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+
+	var req service.BillTransitionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+	if req.Transition == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "กรุณาระบุ transition"})
+	}
+
+	userID, _ := c.Locals("user_id").(uint)
+	transition, err := h.billService.TransitionBill(billType, uint(id), req.Transition, int(userID), req.Reason)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": transition})
+}
+
+func (h *billHandler) GetBillStatusHistory(c *fiber.Ctx) error {
+	return h.getBillStatusHistory(c, model.BillTypeHirePurchase)
+}
+
+func (h *billHandler) GetInstallmentBillStatusHistory(c *fiber.Ctx) error {
+	return h.getBillStatusHistory(c, model.BillTypeInstallment)
+}
+
+func (h *billHandler) getBillStatusHistory(c *fiber.Ctx, billType int) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+
+	history, err := h.billService.GetBillStatusHistory(billType, uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": history})
+}
+
+func (h *billHandler) GetBillStateMachine(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"data": h.billService.GetBillStateMachine()})
+}
+
+// ปรับโครงสร้างหนี้ (แอดมินเป็นผู้อนุมัติ)
+func (h *billHandler) RestructureBill(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+
+	var req service.RestructureBillRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	userID, _ := c.Locals("user_id").(uint)
+	restructure, err := h.billService.RestructureBill(uint(id), req, int(userID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": restructure})
+}
+
+func (h *billHandler) GetBillRestructures(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+
+	restructures, err := h.billService.GetBillRestructures(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": restructures})
+}
+
+func (h *billHandler) CancelBill(c *fiber.Ctx) error {
+	return h.cancelBill(c, model.BillTypeHirePurchase)
+}
+
+func (h *billHandler) CancelInstallmentBill(c *fiber.Ctx) error {
+	return h.cancelBill(c, model.BillTypeInstallment)
+}
+
+func (h *billHandler) cancelBill(c *fiber.Ctx, billType int) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+
+	var req service.CancelBillRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	userID, _ := c.Locals("user_id").(uint)
+	cancellation, err := h.billService.CancelBill(billType, uint(id), req, int(userID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": cancellation})
+}
+
+func (h *billHandler) GetBillCancellation(c *fiber.Ctx) error {
+	return h.getBillCancellation(c, model.BillTypeHirePurchase)
+}
+
+func (h *billHandler) GetInstallmentBillCancellation(c *fiber.Ctx) error {
+	return h.getBillCancellation(c, model.BillTypeInstallment)
+}
+
+func (h *billHandler) getBillCancellation(c *fiber.Ctx, billType int) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+
+	cancellation, err := h.billService.GetBillCancellation(billType, uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": cancellation})
+}
+
+func (h *billHandler) ReverseBillPayment(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+
+	var req service.ReversePaymentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	userID, _ := c.Locals("user_id").(uint)
+	reversal, err := h.billService.ReverseBillPayment(uint(id), req.Reason, int(userID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": reversal})
+}
+
+func (h *billHandler) GetCreditLedger(c *fiber.Ctx) error {
+	return h.getCreditLedger(c, model.BillTypeHirePurchase)
+}
+
+func (h *billHandler) GetInstallmentCreditLedger(c *fiber.Ctx) error {
+	return h.getCreditLedger(c, model.BillTypeInstallment)
+}
+
+func (h *billHandler) getCreditLedger(c *fiber.Ctx, billType int) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+
+	ledger, err := h.billService.GetCreditLedger(billType, uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": ledger})
+}
+
+func (h *billHandler) RefundCredit(c *fiber.Ctx) error {
+	return h.refundCredit(c, model.BillTypeHirePurchase)
+}
+
+func (h *billHandler) RefundInstallmentCredit(c *fiber.Ctx) error {
+	return h.refundCredit(c, model.BillTypeInstallment)
+}
+
+func (h *billHandler) refundCredit(c *fiber.Ctx, billType int) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+
+	var req service.RefundCreditRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	userID, _ := c.Locals("user_id").(uint)
+	refund, err := h.billService.RefundCredit(billType, uint(id), req, int(userID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": refund})
+}
+
+func (h *billHandler) TransferCredit(c *fiber.Ctx) error {
+	return h.transferCredit(c, model.BillTypeHirePurchase)
+}
+
+func (h *billHandler) TransferInstallmentCredit(c *fiber.Ctx) error {
+	return h.transferCredit(c, model.BillTypeInstallment)
+}
+
+func (h *billHandler) transferCredit(c *fiber.Ctx, billType int) error {
+	id, err := c.ParamsInt("id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ID ไม่ถูกต้อง"})
+	}
+
+	var req service.TransferCreditRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "ข้อมูลไม่ถูกต้อง"})
+	}
+
+	userID, _ := c.Locals("user_id").(uint)
+	transfer, err := h.billService.TransferCredit(billType, uint(id), req, int(userID))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": transfer})
+}
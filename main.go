@@ -24,6 +24,7 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/compress"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/pprof"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"github.com/joho/godotenv"
 )
 
@@ -59,6 +60,8 @@ func main() {
 	// })
 
 	app.Use(fiberRecover.New())
+	// X-Request-ID ใช้อ้างอิงในประวัติการแก้ไข
+	app.Use(requestid.New())
 	viper.SetConfigFile(".env") // หรือ config.yaml
 	viper.ReadInConfig()
 
@@ -71,7 +74,7 @@ func main() {
 		// AllowOrigins: "*", // ต้องใส่ origin ที่เจาะจง
 		AllowOrigins:     corsOrigins,
 		AllowCredentials: true,
-		AllowHeaders:     "Origin, Content-Type, Accept, Authorization",
+		AllowHeaders:     "Origin, Content-Type, Accept, Authorization, X-Auth-Mode, X-CSRF-Token",
 	}))
 
 	app.Use(compress.New(compress.Config{
@@ -135,22 +138,36 @@ func main() {
 	authsDB := respository.NewAuthRepositoryDB(db)
 	authsService := service.NewAuthService(authsDB)
 	authsHandler := handler.NewAuthHandler(authsService, db)
+	if err := authsService.SyncPermissionCatalog(); err != nil {
+		log.Printf("❌ ซิงก์รายการสิทธิ์ไม่สำเร็จ: %v", err)
+	}
+
+	apiKeyDB := respository.NewApiKeyRepositoryDB(db)
+	apiKeyService := service.NewApiKeyService(apiKeyDB)
+	apiKeyHandler := handler.NewApiKeyHandler(apiKeyService)
+	if err := apiKeyService.ImportLegacyToken(viper.GetString("BILL_API_TOKEN")); err != nil {
+		log.Printf("❌ ย้าย BILL_API_TOKEN เป็น API key ไม่สำเร็จ: %v", err)
+	}
+
+	auditDB := respository.NewAuditRepositoryDB(db)
+	auditService := service.NewAuditService(auditDB)
+	auditHandler := handler.NewAuditHandler(auditService)
 
 	usersDB := respository.NewUserRepositoryDB(db)
-	usersService := service.NewUsersService(usersDB)
+	usersService := service.NewUsersService(usersDB, authsService, auditDB)
 	usersHandler := handler.NewUsersHandler(usersService)
 
 	productsDB := respository.NewProductRepositoryDB(db)
-	productsService := service.NewProductService(productsDB)
+	productsService := service.NewProductService(productsDB, auditDB)
 	productsHandler := handler.NewProductHandler(productsService)
 	// path.ProductPath(app, productsHandler)
 
 	productCategoryDB := respository.NewProductCategoryRepositoryDB(db)
-	productCategoryService := service.NewProductCategoryService(productCategoryDB)
+	productCategoryService := service.NewProductCategoryService(productCategoryDB, auditDB)
 	productCategoryHandler := handler.NewProductCategoryHandler(productCategoryService)
 
 	rulesDB := respository.NewRulesRepositoryDB(db)
-	rulesService := service.NewRulesService(rulesDB)
+	rulesService := service.NewRulesService(rulesDB, auditDB)
 	rulesHandler := handler.NewRulesHandler(rulesService)
 
 	installmentDB := respository.NewInstallmentRepositoryDB(db)
@@ -158,32 +175,58 @@ func main() {
 	installmentHandler := handler.NewInstallmentHandler(installmentService)
 
 	fineDB := respository.NewFineRepositoryDB(db)
-	fineService := service.NewFineService(fineDB)
+	fineService := service.NewFineService(fineDB, auditDB)
 	fineHandler := handler.NewFineHandler(fineService)
 
 	fineCategoryDB := respository.NewFineCategoryRepositoryDB(db)
-	fineCategoryService := service.NewFineCategoryService(fineCategoryDB)
+	fineCategoryService := service.NewFineCategoryService(fineCategoryDB, auditDB)
 	fineCategoryHandler := handler.NewFineCategoryHandler(fineCategoryService)
 
 	memberDB := respository.NewMemberRepositoryDB(db)
-	memberService := service.NewMemberService(memberDB)
+	memberService := service.NewMemberService(memberDB, auditDB)
 	memberHandler := handler.NewMemberHandler(memberService)
 
+	appraisalDB := respository.NewAppraisalRepositoryDB(db)
+	appraisalService := service.NewAppraisalService(appraisalDB, productsDB)
+	if err := appraisalService.SeedDefaultLtvPolicies(); err != nil {
+		log.Printf("❌ ตั้งค่าเพดาน LTV เริ่มต้นไม่สำเร็จ: %v", err)
+	}
+	appraisalHandler := handler.NewAppraisalHandler(appraisalService)
+
+	inventoryDB := respository.NewInventoryRepositoryDB(db)
+	inventoryService := service.NewInventoryService(inventoryDB)
+	inventoryHandler := handler.NewInventoryHandler(inventoryService)
+
+	paymentDB := respository.NewPaymentRepositoryDB(db)
+	paymentService := service.NewPaymentService(paymentDB)
+	paymentHandler := handler.NewPaymentHandler(paymentService)
+
 	billDB := respository.NewBillRepositoryDB(db)
-	billService := service.NewBillService(billDB, productsDB, fineDB, installmentDB)
-	billHandler := handler.NewBillHandler(billService)
+	billService := service.NewBillService(billDB, productsDB, fineDB, installmentDB, appraisalDB, paymentDB, auditDB)
+
+	cashSaleDB := respository.NewCashSaleRepositoryDB(db)
+	contractDB := respository.NewContractRepositoryDB(db)
+	contractService := service.NewContractService(billService, billDB, contractDB, cashSaleDB, productsDB, inventoryDB, appraisalDB)
+	contractHandler := handler.NewContractHandler(contractService)
+	billHandler := handler.NewBillHandler(billService, contractService)
 
 	path.ProductCategoryPath(app, productCategoryHandler, authsService, usersService)
 	path.RulesPath(app, rulesHandler, authsService, usersService)
 	path.InstallmentPath(app, installmentHandler, authsService, usersService)
 	path.FinePath(app, fineHandler, authsService, usersService)
 	path.FineCategoryPath(app, fineCategoryHandler, authsService, usersService)
-	path.MemberPath(app, memberHandler, authsService, usersService)
-	path.BillPath(app, billHandler, authsService, usersService)
+	path.MemberPath(app, memberHandler, authsService, usersService, apiKeyService)
+	path.BillPath(app, billHandler, authsService, usersService, apiKeyService)
+	path.ContractPath(app, contractHandler, authsService, usersService)
+	path.PaymentPath(app, paymentHandler, authsService, usersService)
+	path.InventoryPath(app, inventoryHandler, authsService, usersService)
+	path.AppraisalPath(app, appraisalHandler, authsService, usersService)
 	path.ProductPath(app, productsHandler, authsService, usersService)
 	path.RolesPath(app, rolesHandler, authsService, usersService)
 	path.UsersPath(app, usersHandler, authsService, usersService)
 	path.AuthPath(app, authsHandler, authsService, usersService)
+	path.ApiKeyPath(app, apiKeyHandler, authsService, usersService)
+	path.AuditPath(app, auditHandler, authsService, usersService)
 
 	backup.StartAutoBackupScheduler()
 
@@ -253,6 +296,27 @@ func main() {
 			}
 			log.Println("✅ AutoApplyInstallementLateFees() finished.")
 
+			log.Println("3️⃣ Calling ProcessPawnForfeitures()...")
+			if err := billService.ProcessPawnForfeitures(); err != nil {
+				log.Printf("❌ Cron Job Error in ProcessPawnForfeitures: %v", err)
+				continue
+			}
+			log.Println("✅ ProcessPawnForfeitures() finished.")
+
+			log.Println("4️⃣ Calling UpdateOverdueStatuses()...")
+			if err := billService.UpdateOverdueStatuses(); err != nil {
+				log.Printf("❌ Cron Job Error in UpdateOverdueStatuses: %v", err)
+				continue
+			}
+			log.Println("✅ UpdateOverdueStatuses() finished.")
+
+			log.Println("5️⃣ Calling PurgeLoginThrottles()...")
+			if err := authsService.PurgeLoginThrottles(); err != nil {
+				log.Printf("❌ Cron Job Error in PurgeLoginThrottles: %v", err)
+			} else {
+				log.Println("✅ PurgeLoginThrottles() finished.")
+			}
+
 			log.Println("🎉 Daily bill updates completed successfully.")
 			log.Println("==================================================")
 
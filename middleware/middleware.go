@@ -6,10 +6,10 @@ import (
 	"rrmobile/service"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/spf13/viper"
 )
 
-func RequireBillAuth() fiber.Handler {
+// ระบบภายนอก (LINE bot ฯลฯ) ส่ง API key มาใน Authorization: Bearer ... ต้องมีสิทธิ์ครบทุก scope ที่ระบุ
+func RequireScope(apiKeySvc service.ApiKeyService, scopes ...string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
 		if !strings.HasPrefix(authHeader, "Bearer ") {
@@ -17,85 +17,102 @@ func RequireBillAuth() fiber.Handler {
 
 		}
 
-		token := strings.TrimPrefix(authHeader, "Bearer ")
-		expectedToken := strings.TrimSpace(viper.GetString("BILL_API_TOKEN"))
-
-		if token != expectedToken {
+		key, err := apiKeySvc.Authenticate(strings.TrimPrefix(authHeader, "Bearer "), c.IP())
+		if err != nil {
 			return c.Status(fiber.StatusUnauthorized).SendFile("./static/404.html")
 
 		}
 
+		for _, scope := range scopes {
+			if !key.HasScope(scope) {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"error": "API key นี้ไม่มีสิทธิ์ " + scope,
+				})
+			}
+		}
+
+		c.Locals("api_key_id", key.Id)
+		c.Locals("api_key_name", key.Name)
 		return c.Next()
 	}
 }
 func JWTMiddleware(authSvc service.AuthService, usersSvc service.UsersService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		authHeader := c.Get("Authorization")
-		if authHeader == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "กรุณาเข้าสู่ระบบ"})
-		}
-
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "กรุณาเข้าสู่ระบบ"})
+		if ok, err := authenticateUser(c, authSvc, usersSvc); !ok {
+			return err
 		}
+		return c.Next()
+	}
+}
 
-		claims, err := authSvc.ValidateToken(tokenString)
-		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
-		}
+// ตรวจ token และสถานะบัญชี (ปิดใช้งาน / ต้องเปลี่ยนรหัสผ่าน) แล้วเก็บข้อมูลผู้ใช้ใน Locals
+// คืน false เมื่อตอบ error กลับไปแล้ว
+func authenticateUser(c *fiber.Ctx, authSvc service.AuthService, usersSvc service.UsersService) (bool, error) {
+	authHeader := c.Get("Authorization")
+	if authHeader == "" {
+		return false, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "กรุณาเข้าสู่ระบบ"})
+	}
 
-		user, err := usersSvc.GetUserById(claims.UserID)
-		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "บัญชีไม่พบ"})
-		}
-		if !user.Is_active {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "บัญชีของคุณถูกปิดใช้งาน, กรุณาเข้าสู่ระบบใหม่"})
-		}
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if tokenString == "" {
+		return false, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "กรุณาเข้าสู่ระบบ"})
+	}
 
-		c.Locals("user_id", claims.UserID)
-		c.Locals("username", claims.Username)
-		c.Locals("role_id", claims.RoleId)
+	claims, err := authSvc.ValidateToken(tokenString)
+	if err != nil {
+		return false, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	}
 
-		return c.Next()
+	user, err := usersSvc.GetUserById(claims.UserID)
+	if err != nil {
+		return false, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "บัญชีไม่พบ"})
+	}
+	if !user.Is_active {
+		return false, c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "บัญชีของคุณถูกปิดใช้งาน, กรุณาเข้าสู่ระบบใหม่"})
 	}
+	if allowed, _ := c.Locals("allow_password_change").(bool); user.Password_Change_Required && !allowed {
+		return false, c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":                    "กรุณาเปลี่ยนรหัสผ่านก่อนใช้งาน",
+			"password_change_required": true,
+		})
+	}
+
+	c.Locals("user_id", claims.UserID)
+	c.Locals("username", claims.Username)
+	c.Locals("role_id", claims.RoleId)
+	c.Locals("session_id", claims.SessionID)
+	return true, nil
 }
 
-func RoleMiddleware(authSvc service.AuthService, allowedRoles ...int) fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		authHeader := c.Get("Authorization")
-		if authHeader == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "กรุณาเข้าสู่ระบบ",
-			})
-		}
+// ให้เส้นทางนี้ใช้ได้แม้ต้องเปลี่ยนรหัสผ่านก่อน (วางก่อน JWTMiddleware)
+func AllowPasswordChange(c *fiber.Ctx) error {
+	c.Locals("allow_password_change", true)
+	return c.Next()
+}
 
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "กรุณาเข้าสู่ระบบ",
-			})
+// ผ่านเมื่อ role ของผู้ใช้มีสิทธิ์อย่างน้อยหนึ่งรายการที่ระบุ (กำหนดใน Role_Permission)
+func RequirePermission(authSvc service.AuthService, usersSvc service.UsersService, permissions ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// ผ่าน JWTMiddleware มาแล้วไม่ต้องตรวจซ้ำ
+		if _, authed := c.Locals("user_id").(uint); !authed {
+			if ok, err := authenticateUser(c, authSvc, usersSvc); !ok {
+				return err
+			}
 		}
 
-		claims, err := authSvc.ValidateToken(tokenString)
+		// 4. ตรวจสอบสิทธิ์ของ role
+		roleID, _ := c.Locals("role_id").(int)
+		allowed, err := authSvc.RoleHasPermissions(roleID, permissions...)
 		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": err.Error(),
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "ตรวจสอบสิทธิ์ไม่สำเร็จ",
 			})
 		}
-
-		// 4. ตรวจสอบ role
-		for _, role := range allowedRoles {
-			if claims.RoleId == role {
-				c.Locals("user_id", claims.UserID)
-				c.Locals("username", claims.Username)
-				c.Locals("role_id", claims.RoleId)
-				return c.Next()
-			}
+		if !allowed {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "คุณไม่มีสิทธิ์เข้าถึงหน้านี้",
+			})
 		}
-
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
-			"error": "คุณไม่มีสิทธิ์เข้าถึงหน้านี้",
-		})
+		return c.Next()
 	}
 }
@@ -16,23 +16,116 @@ type Users struct {
 	Is_active  bool      `gorm:"default:true;index:idx_is_active"`
 	Created_At time.Time `gorm:"autoCreateTime"`
 	Updated_At time.Time `gorm:"autoUpdateTime"`
+
+	Must_Change_Password bool `gorm:"default:false"` // สร้าง / รีเซ็ตรหัสผ่านโดยผู้ดูแลระบบ ต้องเปลี่ยนเองก่อนใช้งาน
+	Password_Changed_At  *time.Time
+
+	Last_Login_At *time.Time // เข้าสู่ระบบสำเร็จครั้งล่าสุด
 }
 type RefreshToken struct {
-	Id        uint      `gorm:"primaryKey"`
-	User_Id   int       `gorm:"index"`
-	User      Users     `gorm:"constraint:OnUpdate:CASCADE;OnDelete:RESTRICT;"`
-	TokenHash string    `gorm:"not null;index:idx_token_hash"`
-	ExpiresAt time.Time `gorm:"index:expires_at"` // ใช้ time.Time แทน int เพื่อความชัดเจน
-	CreatedAt time.Time
-	IsRevoked bool `gorm:"default:false;index:idx_token_isrevoked"` // ใช้ bool แทน int เพื่อความชัดเจน
+	Id         uint      `gorm:"primaryKey"`
+	User_Id    int       `gorm:"index"`
+	User       Users     `gorm:"constraint:OnUpdate:CASCADE;OnDelete:RESTRICT;"`
+	Session_Id *uint     `gorm:"index:idx_refresh_token_session"`
+	Family_Id  string    `gorm:"size:36;index:idx_refresh_token_family"` // token ที่หมุนต่อกันมาจากการ login ครั้งเดียวกัน
+	TokenHash  string    `gorm:"not null;index:idx_token_hash"`
+	ExpiresAt  time.Time `gorm:"index:expires_at"` // ใช้ time.Time แทน int เพื่อความชัดเจน
+	CreatedAt  time.Time
+	IsRevoked  bool `gorm:"default:false;index:idx_token_isrevoked"` // ใช้ bool แทน int เพื่อความชัดเจน
+
+	Rotated_At  *time.Time // ถูกแลกเป็น token ใหม่แล้ว ถ้าถูกส่งมาอีก = ถูกขโมย
+	Replaced_By *uint
 }
 type AccessToken struct {
-	Id        uint `gorm:"primaryKey"`
-	User_Id   uint `gorm:"index"`
-	Token     string
-	ExpiresAt time.Time `gorm:"index"`
-	IsRevoked bool      `gorm:"default:false;index"`
-	CreatedAt time.Time
+	Id         uint  `gorm:"primaryKey"`
+	User_Id    uint  `gorm:"index"`
+	Session_Id *uint `gorm:"index:idx_access_token_session"`
+	Token      string
+	ExpiresAt  time.Time `gorm:"index"`
+	IsRevoked  bool      `gorm:"default:false;index"`
+	CreatedAt  time.Time
+}
+
+// เซสชันการเข้าสู่ระบบ หนึ่งอุปกรณ์ต่อหนึ่งเซสชัน (refresh / access token ผูกกับเซสชัน)
+type User_Session struct {
+	Id      uint  `gorm:"primaryKey"`
+	User_Id int   `gorm:"index:idx_user_session_user"`
+	User    Users `gorm:"foreignKey:User_Id;references:Id;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+
+	Device_Name string `gorm:"size:100"`
+	Ip          string `gorm:"size:64"`
+	User_Agent  string `gorm:"size:255"`
+
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+	Last_Seen_At time.Time `gorm:"index:idx_user_session_last_seen"`
+	Expires_At   time.Time `gorm:"index:idx_user_session_expires"`
+
+	Revoked_At    *time.Time `gorm:"index:idx_user_session_revoked"`
+	Revoked_By    *int       // nil = ผู้ใช้ออกจากระบบเอง / ระบบ
+	Revoke_Reason string     `gorm:"size:100"`
+
+	Compromised_At *time.Time // ตรวจพบ refresh token ถูกใช้ซ้ำ (เซสชันถูกปิดพร้อมกัน)
+}
+
+// รูปแบบเซสชันของ role
+const (
+	SessionModeSingle = 1 // เข้าสู่ระบบใหม่ เซสชันเดิมทุกเครื่องหลุด
+	SessionModeMulti  = 2 // ใช้ได้หลายเครื่องพร้อมกัน
+)
+
+var SessionModeNames = map[int]string{
+	SessionModeSingle: "เครื่องเดียว",
+	SessionModeMulti:  "หลายเครื่อง",
+}
+
+// นโยบายการเข้าสู่ระบบต่อ role (ไม่มีแถว = หลายเครื่องไม่จำกัด ไม่บังคับ 2FA)
+type Session_Policy struct {
+	Id                 uint      `gorm:"primaryKey"`
+	RoleID             int       `gorm:"uniqueIndex"`
+	Mode               int       `gorm:"default:2"` // SessionModeSingle / SessionModeMulti
+	Max_Sessions       int       `gorm:"default:0"` // จำนวนเครื่องสูงสุดของ SessionModeMulti (0 = ไม่จำกัด)
+	Require_Two_Factor bool      `gorm:"default:false"`
+	UpdatedAt          time.Time `gorm:"autoUpdateTime"`
+}
+
+// TOTP ของผู้ใช้ (Enabled_At = nil คือยังลงทะเบียนไม่เสร็จ)
+type User_Two_Factor struct {
+	Id      uint  `gorm:"primaryKey"`
+	User_Id int   `gorm:"uniqueIndex"`
+	User    Users `gorm:"foreignKey:User_Id;references:Id;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+
+	Secret         string `gorm:"size:255"` // เข้ารหัสด้วย SECRET_KEY
+	Enabled_At     *time.Time
+	Last_Used_Step int64 // รอบเวลาของรหัสล่าสุดที่ใช้ไปแล้ว กันใช้รหัสเดิมซ้ำ
+
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// รหัสกู้คืนแบบใช้ครั้งเดียว (เก็บเฉพาะ hash)
+type User_Recovery_Code struct {
+	Id        uint   `gorm:"primaryKey"`
+	User_Id   int    `gorm:"index:idx_recovery_code_user"`
+	Code_Hash string `gorm:"size:64;index:idx_recovery_code_hash"`
+	Used_At   *time.Time
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// ขั้นตอนยืนยันตัวตนชั้นที่สองหลังรหัสผ่านถูกต้อง แลกเป็น token ได้ครั้งเดียว
+type Login_Challenge struct {
+	Id         uint   `gorm:"primaryKey"`
+	User_Id    int    `gorm:"index:idx_login_challenge_user"`
+	Token_Hash string `gorm:"size:64;uniqueIndex"`
+	Setup      bool   // role บังคับ 2FA แต่ยังไม่ได้ลงทะเบียน ต้องลงทะเบียนในขั้นตอนนี้
+
+	Device_Name string `gorm:"size:100"`
+	Ip          string `gorm:"size:64"`
+	User_Agent  string `gorm:"size:255"`
+
+	Attempts   int
+	Expires_At time.Time `gorm:"index:idx_login_challenge_expires"`
+	Used_At    *time.Time
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
 }
 
 type Role struct {
@@ -105,9 +198,9 @@ type Member struct {
 type Bill_Header struct {
 	Id        uint `gorm:"primaryKey"`
 	Invoice   string
-	CreatedAt time.Time `gorm:"autoCreateTime"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime"`
-	DeletedAt time.Time `gorm:"autoCreateTime"`
+	CreatedAt time.Time      `gorm:"autoCreateTime"`
+	UpdatedAt time.Time      `gorm:"autoUpdateTime"`
+	DeletedAt gorm.DeletedAt `gorm:"index"`
 	// Member    Member    `gorm:"constraint:OnUpdate:CASCADE;OnDelete:RESTRICT;"`
 	// MemberId  uint      `gorm:"index:idx_member_id"`
 	MemberId uint   `gorm:"index:idx_member_id1"`
@@ -135,6 +228,12 @@ type Bill_Header struct {
 	Fee_Amount     float64
 	Credit_Balance float64 `gorm:"default:0"`
 
+	Down_Payment    float64 `gorm:"type:decimal(10,2);default:0"` // เงินดาวน์ทั้งหมด (รวมมูลค่าเครื่องเทิร์น)
+	Trade_In_Amount float64 `gorm:"type:decimal(10,2);default:0"` // ส่วนของเงินดาวน์ที่จ่ายด้วยเครื่องเทิร์น
+	Upfront_Amount  float64 `gorm:"type:decimal(10,2);default:0"` // รายการเสริมที่จ่ายสดตอนทำสัญญา (ไม่เข้างวดผ่อน)
+
+	Schedule_Version int `gorm:"default:1"` // เพิ่มขึ้นทุกครั้งที่ปรับโครงสร้างหนี้
+
 	Status int
 
 	Note string `gorm:"type:text"`
@@ -161,6 +260,14 @@ type Bill_Details struct {
 
 	Credit_Balance float64 `gorm:"default:0"`
 	Payment_No     string
+
+	Schedule_Version int `gorm:"default:1;index:idx_bill_details_version"`
+
+	// แยกองค์ประกอบของงวด: Installment_Price = ค่าปรับ (Fee_Amount) + ดอกเบี้ย + เงินต้น
+	Interest_Amount float64 `gorm:"type:decimal(10,2);default:0"`
+	Fee_Paid        float64 `gorm:"type:decimal(10,2);default:0"`
+	Interest_Paid   float64 `gorm:"type:decimal(10,2);default:0"`
+	Principal_Paid  float64 `gorm:"type:decimal(10,2);default:0"`
 }
 
 type Bill_Header_Installment struct {
@@ -208,6 +315,9 @@ type Bill_Header_Installment struct {
 	LastRenewDate time.Time
 	NextDueDate   time.Time
 	RenewCount    int
+	Forfeited_At  *time.Time // วันที่หลุดจำนำ (Status = 3)
+
+	Pending_Loan_Amount *float64 // เงินต้นใหม่หลังลด/เพิ่มเงินต้น ใช้เป็นฐานดอกเบี้ยตั้งแต่รอบถัดไป
 
 	// ✅ One-To-Many Relation
 	BillDetailsInstallment []Bill_Details_Installment `gorm:"foreignKey:Bill_Header_InstallmentId"`
@@ -234,3 +344,903 @@ type Bill_Details_Installment struct {
 
 	Is_Interest_Only bool
 }
+
+// นโยบายหลุดจำนำ (ใช้แถวเดียว id = 1)
+type Pawn_Forfeit_Policy struct {
+	Id                uint      `gorm:"primaryKey"`
+	Missed_Cycles     int       `gorm:"default:3"`                     // จำนวนรอบ 10 วันที่ขาดต่อดอก ก่อนออกหนังสือแจ้ง
+	Notice_Days       int       `gorm:"default:7"`                     // จำนวนวันหลังแจ้งลูกค้า ก่อนหลุดจำนำ
+	Valuation_Base    int       `gorm:"default:1"`                     // 1 = ยอดเงินต้น, 2 = ราคาสินค้า
+	Valuation_Percent float64   `gorm:"type:decimal(5,2);default:100"` // % ของฐานที่ใช้ตั้งราคาขาย
+	UpdatedAt         time.Time `gorm:"autoUpdateTime"`
+}
+
+type Pawn_Forfeit_Notice struct {
+	Id uint `gorm:"primaryKey"`
+
+	Bill_Header_InstallmentId uint                    `gorm:"index:idx_forfeit_notice_bill"`
+	Bill_Header_Installment   Bill_Header_Installment `gorm:"foreignKey:Bill_Header_InstallmentId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+
+	MemberId uint   `gorm:"index:idx_forfeit_notice_member"`
+	Member   Member `gorm:"foreignKey:MemberId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+
+	Missed_Cycles int
+	Notice_Date   time.Time
+	Forfeit_Date  time.Time `gorm:"index:idx_forfeit_notice_date"`
+	Sent_At       *time.Time
+
+	Status int `gorm:"index:idx_forfeit_notice_status"` // 0 = รอแจ้ง, 1 = แจ้งแล้ว, 2 = ยกเลิก (ลูกค้าต่อดอก), 3 = หลุดจำนำแล้ว
+
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// สินค้าในสต็อก (รายเครื่อง)
+type Inventory_Unit struct {
+	Id uint `gorm:"primaryKey"`
+
+	ProductId uint    `gorm:"index:idx_inventory_product_id"`
+	Product   Product `gorm:"foreignKey:ProductId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+
+	Source                    int    `gorm:"index:idx_inventory_source"` // 1 = หลุดจำนำ, 2 = รับเข้าสต็อก, 3 = เครื่องเทิร์น
+	Bill_Header_InstallmentId *uint  `gorm:"index:idx_inventory_bill_installment"`
+	Imei                      string `gorm:"size:20;index:idx_inventory_imei"`
+	Cash_SaleId               *uint  `gorm:"index:idx_inventory_cash_sale"` // ขายออกในบิลขายสดใบไหน
+
+	Valuation float64 `gorm:"type:decimal(10,2)"`
+	Status    int     `gorm:"index:idx_inventory_status"` // 0 = พร้อมขาย, 1 = ขายแล้ว, 2 = คืนเจ้าของ
+	Note      string  `gorm:"type:text"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// สถานะเครื่องในสต็อก (inventory_units.status)
+const (
+	InventoryUnitAvailable = 0
+	InventoryUnitSold      = 1
+	InventoryUnitReturned  = 2 // คืนเจ้าของเดิม เช่น เครื่องเทิร์นของสัญญาที่ยกเลิก
+)
+
+// ที่มาของเครื่องในสต็อก (inventory_units.source)
+const (
+	InventorySourceForfeit = 1 // หลุดจำนำ
+	InventorySourceStock   = 2 // รับเข้าสต็อก
+	InventorySourceTradeIn = 3 // เครื่องเทิร์น
+)
+
+// ใบประเมินสภาพเครื่องก่อนรับจำนำ
+type Pawn_Appraisal struct {
+	Id uint `gorm:"primaryKey"`
+
+	ProductId uint    `gorm:"index:idx_appraisal_product_id"`
+	Product   Product `gorm:"foreignKey:ProductId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+
+	Imei            string  `gorm:"size:20;index:idx_appraisal_imei"`
+	Condition_Grade string  `gorm:"size:2;index:idx_appraisal_grade"` // A, B, C, D
+	Battery_Health  int     // % สุขภาพแบตเตอรี่
+	Checklist       string  `gorm:"type:text"` // JSON เช่น {"screen":true,"face_id":false}
+	Appraised_Value float64 `gorm:"type:decimal(10,2)"`
+	Note            string  `gorm:"type:text"`
+
+	Appraiser_Id int   `gorm:"index:idx_appraisal_appraiser"`
+	Appraiser    Users `gorm:"foreignKey:Appraiser_Id;references:Id;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+
+	Bill_Header_InstallmentId *uint `gorm:"uniqueIndex:idx_appraisal_bill"` // ผูกกับบิลจำนำได้ครั้งเดียว
+
+	Photos []Pawn_Appraisal_Photo `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+type Pawn_Appraisal_Photo struct {
+	Id               uint      `gorm:"primaryKey"`
+	Pawn_AppraisalId uint      `gorm:"index:idx_appraisal_photo_appraisal_id"`
+	ImageUrl         string    `gorm:"size:255;not null"`
+	CreatedAt        time.Time `gorm:"autoCreateTime"`
+}
+
+// เพดาน LTV ต่อหมวดสินค้าและเกรดสภาพ (CategoryId = 0 ใช้กับทุกหมวด)
+type Pawn_Ltv_Policy struct {
+	Id              uint      `gorm:"primaryKey"`
+	CategoryId      uint      `gorm:"uniqueIndex:idx_ltv_category_grade"`
+	Condition_Grade string    `gorm:"size:2;uniqueIndex:idx_ltv_category_grade"`
+	Max_Ltv_Percent float64   `gorm:"type:decimal(5,2)"`
+	UpdatedAt       time.Time `gorm:"autoUpdateTime"`
+}
+
+// ประวัติการต่อดอกบิลจำนำ (1 แถวต่อการต่อดอก 1 ครั้ง)
+type Pawn_Renewal struct {
+	Id uint `gorm:"primaryKey"`
+
+	Bill_Header_InstallmentId uint                    `gorm:"index:idx_renewal_bill"`
+	Bill_Header_Installment   Bill_Header_Installment `gorm:"foreignKey:Bill_Header_InstallmentId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+
+	Renewal_No       int     // ครั้งที่ต่อดอก
+	Cycles_Covered   int     // จำนวนรอบ 10 วันที่ชำระ
+	Interest_Paid    float64 `gorm:"type:decimal(10,2)"`
+	Fee_Paid         float64 `gorm:"type:decimal(10,2)"`
+	Principal        float64 `gorm:"type:decimal(10,2)"` // เงินต้น (ฐานดอกเบี้ย) ของรอบใหม่
+	Principal_Change float64 `gorm:"type:decimal(10,2)"` // เงินต้นที่เปลี่ยนในการต่อดอกครั้งนี้ (+ เพิ่ม / - ลด)
+	Old_Due_Date     time.Time
+	New_Due_Date     time.Time
+	Pay_Date         time.Time `gorm:"index:idx_renewal_pay_date"`
+
+	User_Id int   `gorm:"index:idx_renewal_user"`
+	User    Users `gorm:"foreignKey:User_Id;references:Id;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+
+	Payment_Ref string `gorm:"size:100"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// รายการลดเงินต้น / เพิ่มเงินต้นของบิลจำนำ
+type Pawn_Principal_Change struct {
+	Id uint `gorm:"primaryKey"`
+
+	Bill_Header_InstallmentId uint                    `gorm:"index:idx_principal_change_bill"`
+	Bill_Header_Installment   Bill_Header_Installment `gorm:"foreignKey:Bill_Header_InstallmentId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+
+	Type             int     `gorm:"index:idx_principal_change_type"` // 1 = ลดเงินต้น, 2 = เพิ่มเงินต้น
+	Amount           float64 `gorm:"type:decimal(10,2)"`
+	Principal_Before float64 `gorm:"type:decimal(10,2)"`
+	Principal_After  float64 `gorm:"type:decimal(10,2)"`
+
+	Effective_Date time.Time  // เริ่มคิดดอกจากเงินต้นใหม่ (รอบถัดไป)
+	Applied_At     *time.Time // วันที่ต่อดอกแล้วเปลี่ยนฐานดอกเบี้ยจริง
+	Pawn_RenewalId *uint      `gorm:"index:idx_principal_change_renewal"`
+
+	User_Id int   `gorm:"index:idx_principal_change_user"`
+	User    Users `gorm:"foreignKey:User_Id;references:Id;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+
+	Payment_Ref string `gorm:"size:100"`
+	Note        string `gorm:"type:text"`
+	PaymentId   *uint  `gorm:"index:idx_principal_change_payment"` // ใบเสร็จรับเงิน (ลด) / จ่ายเงิน (เพิ่ม)
+
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// ประเภทสัญญา
+const (
+	BillTypeHirePurchase = 1 // Bill_Header (ผ่อนสินค้า)
+	BillTypeInstallment  = 2 // Bill_Header_Installment (จำนำ / เงินกู้)
+	BillTypeCashSale     = 3 // Cash_Sale (ขายสด)
+)
+
+// สถานะสัญญา (Status ของ Bill_Header / Bill_Header_Installment)
+const (
+	BillStatusDraft       = 0
+	BillStatusActive      = 1
+	BillStatusSettled     = 2
+	BillStatusForfeited   = 3
+	BillStatusOverdue     = 4
+	BillStatusDefaulted   = 5
+	BillStatusCancelled   = 6
+	BillStatusRepossessed = 7
+)
+
+var BillStatusNames = map[int]string{
+	BillStatusDraft:       "draft",
+	BillStatusActive:      "active",
+	BillStatusSettled:     "settled",
+	BillStatusForfeited:   "forfeited",
+	BillStatusOverdue:     "overdue",
+	BillStatusDefaulted:   "defaulted",
+	BillStatusCancelled:   "cancelled",
+	BillStatusRepossessed: "repossessed",
+}
+
+// สถานะที่สัญญายังเปิดอยู่ (ยังต้องเก็บเงิน)
+var OpenBillStatuses = []int{BillStatusActive, BillStatusOverdue, BillStatusDefaulted}
+
+func IsOpenBillStatus(status int) bool {
+	for _, s := range OpenBillStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// ประวัติการเปลี่ยนสถานะสัญญา
+type Bill_Status_Transition struct {
+	Id uint `gorm:"primaryKey"`
+
+	Bill_Type int  `gorm:"index:idx_transition_bill"` // BillTypeHirePurchase / BillTypeInstallment / BillTypeCashSale
+	Bill_Id   uint `gorm:"index:idx_transition_bill"`
+
+	From_Status int
+	To_Status   int
+	Transition  string `gorm:"size:30"`
+
+	Actor_Id *int  `gorm:"index:idx_transition_actor"` // nil = ระบบ (cron)
+	Actor    Users `gorm:"foreignKey:Actor_Id;references:Id;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+
+	Reason string `gorm:"type:text"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// การจัดการเงินดาวน์เมื่อยกเลิกสัญญา
+const (
+	DownPaymentRefund  = 1 // คืนเงินดาวน์ และคืนเครื่องเทิร์นให้ลูกค้า
+	DownPaymentForfeit = 2 // ร้านยึดเงินดาวน์ (เครื่องเทิร์นอยู่ในสต็อกร้านต่อ)
+)
+
+var DownPaymentActionNames = map[int]string{
+	DownPaymentRefund:  "คืนเงินดาวน์",
+	DownPaymentForfeit: "ยึดเงินดาวน์",
+}
+
+// บันทึกการยกเลิกสัญญา (ใครยกเลิก เหตุผล และเงินดาวน์ / เครื่องเทิร์นไปทางไหน)
+type Bill_Cancellation struct {
+	Id uint `gorm:"primaryKey"`
+
+	Bill_Type int  `gorm:"uniqueIndex:idx_cancellation_bill"`
+	Bill_Id   uint `gorm:"uniqueIndex:idx_cancellation_bill"`
+
+	From_Status int
+	Reason      string `gorm:"type:text"`
+
+	Down_Payment_Action int     // DownPaymentRefund / DownPaymentForfeit
+	Refunded_Amount     float64 `gorm:"type:decimal(10,2)"`
+	Forfeited_Amount    float64 `gorm:"type:decimal(10,2)"`
+	Inventory_UnitId    *uint   // เครื่องเทิร์นที่คืนลูกค้า
+
+	Actor_Id int   `gorm:"index:idx_cancellation_actor"`
+	Actor    Users `gorm:"foreignKey:Actor_Id;references:Id;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// บิลขายสด (จ่ายครบตอนขาย)
+type Cash_Sale struct {
+	Id      uint   `gorm:"primaryKey"`
+	Invoice string `gorm:"size:30;uniqueIndex"`
+
+	MemberId *uint  `gorm:"index:idx_cash_sale_member_id"` // nil = ลูกค้าทั่วไป
+	Member   Member `gorm:"foreignKey:MemberId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+
+	User_Id int   `gorm:"index:idx_cash_sale_user_id"`
+	User    Users `gorm:"foreignKey:User_Id;references:Id;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+
+	Subtotal      float64 `gorm:"type:decimal(10,2)"` // รวมทุกบรรทัด (หลังส่วนลดรายบรรทัด)
+	Discount      float64 `gorm:"type:decimal(10,2)"` // ส่วนลดท้ายบิล
+	Total_Price   float64 `gorm:"type:decimal(10,2)"`
+	Paid_Amount   float64 `gorm:"type:decimal(10,2)"`
+	Change_Amount float64 `gorm:"type:decimal(10,2)"` // เงินทอน
+
+	Trade_In_Amount float64 `gorm:"type:decimal(10,2);default:0"` // มูลค่าเครื่องเทิร์นที่หักจากยอดบิล
+
+	Status int    `gorm:"index:idx_cash_sale_status"` // BillStatusSettled / BillStatusCancelled
+	Note   string `gorm:"type:text"`
+
+	Lines   []Cash_Sale_Line   `gorm:"foreignKey:Cash_SaleId;references:Id"`
+	Tenders []Cash_Sale_Tender `gorm:"foreignKey:Cash_SaleId;references:Id"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+type Cash_Sale_Line struct {
+	Id uint `gorm:"primaryKey"`
+
+	Cash_SaleId uint `gorm:"index:idx_cash_sale_line_sale_id"`
+
+	ProductId uint    `gorm:"index:idx_cash_sale_line_product_id"`
+	Product   Product `gorm:"foreignKey:ProductId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+
+	Line_Type        int             // 1 = เครื่อง (ตัดจาก Inventory_Unit), 2 = อุปกรณ์เสริม (ตัดจาก Product_Stock)
+	Inventory_UnitId *uint           `gorm:"index:idx_cash_sale_line_unit_id"`
+	Inventory_Unit   *Inventory_Unit `gorm:"foreignKey:Inventory_UnitId;references:Id"`
+
+	Quantity   int
+	Unit_Price float64 `gorm:"type:decimal(10,2)"`
+	Discount   float64 `gorm:"type:decimal(10,2)"`
+	Amount     float64 `gorm:"type:decimal(10,2)"`
+}
+
+// ประเภทรายการสินค้า ใช้ทั้งบิลขายสดและรายการเสริมในสัญญา
+const (
+	SaleLineHandset   = 1
+	SaleLineAccessory = 2
+	SaleLineInsurance = 3
+	SaleLineService   = 4
+)
+
+var SaleLineTypeNames = map[int]string{
+	SaleLineHandset:   "เครื่อง",
+	SaleLineAccessory: "อุปกรณ์เสริม",
+	SaleLineInsurance: "ประกัน",
+	SaleLineService:   "บริการ",
+}
+
+// ช่องทางรับเงิน
+const (
+	PaymentMethodCash      = "cash"
+	PaymentMethodTransfer  = "transfer"
+	PaymentMethodCard      = "card"
+	PaymentMethodPromptPay = "promptpay"
+	PaymentMethodCredit    = "credit" // หักจากเครดิตคงเหลือของสัญญา (ไม่ใช่เงินเข้าร้าน)
+
+	PaymentMethodSplit = "split" // ใบเสร็จที่แบ่งจ่ายหลายช่องทาง (ดูรายละเอียดใน Payment_Tender)
+)
+
+var PaymentMethodNames = map[string]string{
+	PaymentMethodCash:      "เงินสด",
+	PaymentMethodTransfer:  "โอนเงิน",
+	PaymentMethodCard:      "บัตร",
+	PaymentMethodPromptPay: "พร้อมเพย์",
+	PaymentMethodCredit:    "เครดิตคงเหลือ",
+}
+
+// การรับเงินของบิลขายสด (แบ่งจ่ายได้หลายช่องทาง)
+type Cash_Sale_Tender struct {
+	Id uint `gorm:"primaryKey"`
+
+	Cash_SaleId uint `gorm:"index:idx_cash_sale_tender_sale_id"`
+
+	Method    string  `gorm:"size:20;index:idx_cash_sale_tender_method"`
+	Amount    float64 `gorm:"type:decimal(10,2)"` // ยอดที่ตัดเข้าบิล
+	Received  float64 `gorm:"type:decimal(10,2)"` // ยอดที่ลูกค้าให้มา (เงินสดอาจมากกว่า Amount)
+	Reference string  `gorm:"size:100"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// สต็อกสินค้าที่นับเป็นจำนวน (อุปกรณ์เสริม)
+type Product_Stock struct {
+	Id uint `gorm:"primaryKey"`
+
+	ProductId uint    `gorm:"uniqueIndex"`
+	Product   Product `gorm:"foreignKey:ProductId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+
+	Quantity int
+
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// เครื่องเก่าที่ลูกค้านำมาเทิร์นเป็นเงินดาวน์ / หักยอดขาย
+type Trade_In struct {
+	Id uint `gorm:"primaryKey"`
+
+	Bill_Type int  `gorm:"index:idx_trade_in_bill"` // BillTypeHirePurchase / BillTypeCashSale
+	Bill_Id   uint `gorm:"index:idx_trade_in_bill"`
+
+	ProductId uint    `gorm:"index:idx_trade_in_product_id"`
+	Product   Product `gorm:"foreignKey:ProductId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+
+	Pawn_AppraisalId *uint           `gorm:"uniqueIndex:idx_trade_in_appraisal"` // ใบประเมินสภาพเครื่อง (ถ้ามี)
+	Pawn_Appraisal   *Pawn_Appraisal `gorm:"foreignKey:Pawn_AppraisalId;references:Id"`
+
+	Imei            string  `gorm:"size:20;index:idx_trade_in_imei"`
+	Condition_Grade string  `gorm:"size:2"`
+	Appraised_Value float64 `gorm:"type:decimal(10,2)"`
+	Agreed_Value    float64 `gorm:"type:decimal(10,2)"` // ราคาที่ตกลงรับเทิร์น
+
+	Inventory_UnitId *uint `gorm:"index:idx_trade_in_unit"`
+
+	User_Id int   `gorm:"index:idx_trade_in_user"`
+	User    Users `gorm:"foreignKey:User_Id;references:Id;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+
+	Note string `gorm:"type:text"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// รายการสินค้าในสัญญา (เครื่องหลัก + อุปกรณ์เสริม / ประกัน / บริการ)
+type Bill_Line struct {
+	Id uint `gorm:"primaryKey"`
+
+	Bill_Type int  `gorm:"index:idx_bill_line_bill"` // BillTypeHirePurchase
+	Bill_Id   uint `gorm:"index:idx_bill_line_bill"`
+
+	Line_Type int     `gorm:"index:idx_bill_line_type"` // SaleLineHandset / Accessory / Insurance / Service
+	ProductId uint    `gorm:"index:idx_bill_line_product_id"`
+	Product   Product `gorm:"foreignKey:ProductId;references:Id;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+
+	Unit        string `gorm:"size:20"`
+	Quantity    int
+	Unit_Price  float64 `gorm:"type:decimal(10,2)"`
+	Amount      float64 `gorm:"type:decimal(10,2)"`
+	Is_Financed bool    // true = รวมในยอดผ่อน, false = จ่ายสดตอนทำสัญญา
+
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// สถานะงวดผ่อน (bill_details.status)
+const (
+	BillDetailStatusUnpaid       = 0
+	BillDetailStatusPaid         = 1
+	BillDetailStatusRestructured = 3 // ปิดจากการปรับโครงสร้างหนี้ ยอดค้างย้ายไปตารางผ่อนชุดใหม่
+)
+
+// ประวัติการปรับโครงสร้างหนี้ / ขยายงวด ตารางผ่อนชุดเดิมยังเก็บไว้ดูย้อนหลังได้
+type Bill_Restructure struct {
+	Id uint `gorm:"primaryKey"`
+
+	Bill_Type int  `gorm:"index:idx_bill_restructure_bill"` // BillTypeHirePurchase
+	Bill_Id   uint `gorm:"index:idx_bill_restructure_bill"`
+
+	From_Version int
+	To_Version   int
+
+	Closed_Installments    int     // จำนวนงวดเดิมที่ถูกปิด
+	Outstanding            float64 `gorm:"type:decimal(10,2)"` // ยอดค้างที่ยกไปตารางใหม่
+	Old_Net_installment    float64 `gorm:"type:decimal(10,2)"`
+	Old_Installments_Count int
+
+	New_Installments_Month int
+	New_Extra_Percent      float64 `gorm:"type:decimal(5,2)"`
+	Interest_Amount        float64 `gorm:"type:decimal(10,2)"`
+	New_Total              float64 `gorm:"type:decimal(10,2)"`
+	New_Net_installment    float64 `gorm:"type:decimal(10,2)"`
+	First_Due_Date         time.Time
+
+	Requested_By *int  `gorm:"index:idx_bill_restructure_requested_by"` // พนักงานที่เจรจากับลูกค้า
+	Approved_By  int   `gorm:"index:idx_bill_restructure_approved_by"`
+	Approver     Users `gorm:"foreignKey:Approved_By;references:Id;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+
+	Reason string `gorm:"type:text"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// ประเภทการรับเงินใน ledger
+const (
+	PaymentKindInstallment = 1 // ชำระค่างวด
+	PaymentKindDownPayment = 2 // เงินดาวน์ (+ รายการเสริมที่จ่ายสด) ตอนทำสัญญา
+	PaymentKindRefund      = 3 // รายการกลับ/คืนเงิน (ยอดติดลบ)
+	PaymentKindRenewal     = 4 // ต่อดอกบิลจำนำ
+	PaymentKindCredit      = 5 // คืนเครดิตคงเหลือให้ลูกค้า (ยอดติดลบ)
+	PaymentKindPrincipal   = 6 // ลดเงินต้นบิลจำนำ (รับเงิน) / เพิ่มเงินต้น (จ่ายเงินให้ลูกค้า ยอดติดลบ)
+)
+
+var PaymentKindNames = map[int]string{
+	PaymentKindInstallment: "ชำระค่างวด",
+	PaymentKindDownPayment: "เงินดาวน์",
+	PaymentKindRefund:      "คืนเงิน",
+	PaymentKindRenewal:     "ต่อดอก",
+	PaymentKindCredit:      "คืนเครดิต",
+	PaymentKindPrincipal:   "ลด/เพิ่มเงินต้น",
+}
+
+const (
+	PaymentStatusPosted   = 0
+	PaymentStatusReversed = 1 // ถูกกลับรายการแล้ว (ดูรายการคืนเงินที่อ้างถึง)
+)
+
+// องค์ประกอบที่เงินแต่ละบาทถูกตัดไป
+const (
+	AllocationFee       = "fee"
+	AllocationInterest  = "interest"
+	AllocationPrincipal = "principal"
+)
+
+var AllocationComponentNames = map[string]string{
+	AllocationFee:       "ค่าปรับ",
+	AllocationInterest:  "ดอกเบี้ย",
+	AllocationPrincipal: "เงินต้น",
+}
+
+// ลำดับการตัดเงิน (ใช้แถวเดียว id = 1)
+type Payment_Allocation_Policy struct {
+	Id                uint      `gorm:"primaryKey"`
+	Component_Order   string    `gorm:"size:50;default:'fee,interest,principal'"`
+	Scope             int       `gorm:"default:1"` // 1 = ปิดทีละงวด, 2 = ตัดองค์ประกอบเดียวกันทุกงวดก่อน
+	Installment_Order int       `gorm:"default:1"` // 1 = งวดเก่าก่อน, 2 = งวดใหม่ก่อน
+	UpdatedAt         time.Time `gorm:"autoUpdateTime"`
+}
+
+// รายการรับเงิน (ใบเสร็จ) ของสัญญา
+type Payment struct {
+	Id         uint   `gorm:"primaryKey"`
+	Receipt_No string `gorm:"size:30;uniqueIndex"`
+
+	Bill_Type int  `gorm:"index:idx_payment_bill"`
+	Bill_Id   uint `gorm:"index:idx_payment_bill"`
+	Kind      int  `gorm:"index:idx_payment_kind"` // PaymentKindInstallment
+
+	Amount       float64 `gorm:"type:decimal(10,2)"` // เงินที่ลูกค้าจ่ายมา
+	Credit_Used  float64 `gorm:"type:decimal(10,2);default:0"`
+	Credit_Added float64 `gorm:"type:decimal(10,2);default:0"` // เงินเกินที่เก็บเป็นเครดิต
+
+	Method    string `gorm:"size:20;default:'cash';index:idx_payment_method"` // PaymentMethodCash / Transfer / ...
+	Reference string `gorm:"size:100"`
+
+	Status      int   `gorm:"default:0;index:idx_payment_status"` // PaymentStatusPosted / Reversed
+	Reversal_Of *uint `gorm:"index:idx_payment_reversal_of"`      // รายการคืนเงิน อ้างถึงใบเสร็จเดิม
+	Reversed_At *time.Time
+
+	User_Id *int  `gorm:"index:idx_payment_user"` // nil = รับผ่านระบบ (เช่น LINE bot)
+	User    Users `gorm:"foreignKey:User_Id;references:Id;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+
+	Note string `gorm:"type:text"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime;index:idx_payment_created_at"`
+
+	Allocations []Payment_Allocation `gorm:"foreignKey:PaymentId"`
+	Tenders     []Payment_Tender     `gorm:"foreignKey:PaymentId"`
+}
+
+// ช่องทางรับเงินของใบเสร็จ (ใบเสร็จเดียวแบ่งจ่ายได้หลายช่องทาง)
+type Payment_Tender struct {
+	Id        uint `gorm:"primaryKey"`
+	PaymentId uint `gorm:"index:idx_payment_tender_payment_id"`
+
+	Method    string  `gorm:"size:20;index:idx_payment_tender_method"`
+	Amount    float64 `gorm:"type:decimal(10,2)"` // ติดลบเมื่อเป็นรายการคืนเงิน
+	Reference string  `gorm:"size:100"`           // เลขอ้างอิงสลิปโอน / บัตร / พร้อมเพย์
+	Account   string  `gorm:"size:100"`           // บัญชีที่รับเงิน เช่น ลิ้นชักหน้าร้าน, KBank xxx-1234
+
+	CreatedAt time.Time `gorm:"autoCreateTime;index:idx_payment_tender_created_at"`
+}
+
+type Payment_Allocation struct {
+	Id        uint `gorm:"primaryKey"`
+	PaymentId uint `gorm:"index:idx_payment_allocation_payment"`
+
+	Bill_DetailsId uint    `gorm:"index:idx_payment_allocation_detail"`
+	Payment_No     string  `gorm:"size:20"`
+	Component      string  `gorm:"size:20"` // AllocationFee / Interest / Principal
+	Amount         float64 `gorm:"type:decimal(10,2)"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// ประเภทการเคลื่อนไหวของเครดิตคงเหลือ
+const (
+	CreditMovementEarned      = 1 // ชำระเกิน เก็บเป็นเครดิต
+	CreditMovementUsed        = 2 // ใช้เครดิตตัดงวด
+	CreditMovementRefund      = 3 // คืนเครดิตเป็นเงินสด / โอน
+	CreditMovementTransferOut = 4 // โอนเครดิตไปสัญญาอื่น
+	CreditMovementTransferIn  = 5 // รับเครดิตจากสัญญาอื่น
+	CreditMovementReversal    = 6 // กลับรายการใบเสร็จที่สร้าง / ใช้เครดิต
+)
+
+var CreditMovementKindNames = map[int]string{
+	CreditMovementEarned:      "ชำระเกินเก็บเป็นเครดิต",
+	CreditMovementUsed:        "ใช้เครดิตตัดงวด",
+	CreditMovementRefund:      "คืนเครดิต",
+	CreditMovementTransferOut: "โอนเครดิตออก",
+	CreditMovementTransferIn:  "รับโอนเครดิต",
+	CreditMovementReversal:    "กลับรายการ",
+}
+
+// ความเคลื่อนไหวของเครดิตคงเหลือบนหัวสัญญา
+type Credit_Movement struct {
+	Id uint `gorm:"primaryKey"`
+
+	Bill_Type int  `gorm:"index:idx_credit_movement_bill"`
+	Bill_Id   uint `gorm:"index:idx_credit_movement_bill"`
+	MemberId  uint `gorm:"index:idx_credit_movement_member"`
+
+	Kind          int     // CreditMovementEarned / Used / ...
+	Amount        float64 `gorm:"type:decimal(10,2)"` // + เพิ่มเครดิต, - ลดเครดิต
+	Balance_After float64 `gorm:"type:decimal(10,2)"`
+
+	PaymentId *uint `gorm:"index:idx_credit_movement_payment"` // ใบเสร็จที่ทำให้เกิดรายการ
+
+	Counterpart_Bill_Type int // สัญญาต้นทาง / ปลายทางของการโอน
+	Counterpart_Bill_Id   *uint
+
+	Actor_Id *int  `gorm:"index:idx_credit_movement_actor"` // nil = ระบบ
+	Actor    Users `gorm:"foreignKey:Actor_Id;references:Id;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+
+	Reason string `gorm:"type:text"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime;index:idx_credit_movement_created_at"`
+}
+
+// ประเภทเหตุการณ์ด้านความปลอดภัย
+const (
+	SecurityEventRefreshReuse     = "refresh_token_reuse" // refresh token ที่หมุนไปแล้วถูกส่งมาอีก
+	SecurityEventLoginLocked      = "login_locked"        // เข้าสู่ระบบผิดครบจำนวนครั้ง ถูกล็อกชั่วคราว
+	SecurityEventLoginUnlock      = "login_unlocked"      // ผู้ดูแลระบบปลดล็อก
+	SecurityEventPasswordChanged  = "password_changed"    // ผู้ใช้เปลี่ยนรหัสผ่านเอง เซสชันอื่นถูกปิด
+	SecurityEventPasswordReset    = "password_reset"      // ผู้ดูแลระบบตั้งรหัสผ่านใหม่ให้ เซสชันทั้งหมดถูกปิด
+	SecurityEventLoginUnusualHour = "login_unusual_hour"  // เข้าสู่ระบบสำเร็จนอกเวลาทำการ
+)
+
+var SecurityEventNames = map[string]string{
+	SecurityEventRefreshReuse:     "refresh token ถูกใช้ซ้ำ",
+	SecurityEventLoginLocked:      "ล็อกการเข้าสู่ระบบชั่วคราว",
+	SecurityEventLoginUnlock:      "ปลดล็อกการเข้าสู่ระบบ",
+	SecurityEventPasswordChanged:  "เปลี่ยนรหัสผ่าน",
+	SecurityEventPasswordReset:    "ผู้ดูแลระบบตั้งรหัสผ่านใหม่",
+	SecurityEventLoginUnusualHour: "เข้าสู่ระบบนอกเวลาทำการ",
+}
+
+// เหตุการณ์ด้านความปลอดภัยให้ผู้ดูแลตรวจสอบ
+type Security_Event struct {
+	Id         uint   `gorm:"primaryKey"`
+	Event_Type string `gorm:"size:50;index:idx_security_event_type"`
+
+	User_Id *int  `gorm:"index:idx_security_event_user"`
+	User    Users `gorm:"foreignKey:User_Id;references:Id;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+
+	Session_Id *uint  `gorm:"index:idx_security_event_session"`
+	Family_Id  string `gorm:"size:36"`
+
+	Ip         string `gorm:"size:64"`
+	User_Agent string `gorm:"size:255"`
+	Detail     string `gorm:"type:text"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime;index:idx_security_event_created_at"`
+}
+
+// ขอบเขตการนับการเข้าสู่ระบบผิด
+const (
+	LoginThrottleUsername = "username"
+	LoginThrottleIp       = "ip"
+)
+
+// ตัวนับการเข้าสู่ระบบผิดต่อ username / IP เก็บใน DB ให้ทุก instance ใช้ร่วมกันและไม่หายเมื่อ restart
+type Login_Throttle struct {
+	Id           uint   `gorm:"primaryKey"`
+	Scope        string `gorm:"size:20;uniqueIndex:idx_login_throttle_key"`
+	Throttle_Key string `gorm:"size:255;uniqueIndex:idx_login_throttle_key"` // username (ตัวพิมพ์เล็ก) หรือ IP
+
+	Failures        int // จำนวนครั้งที่ผิดในรอบปัจจุบัน
+	Window_Start_At time.Time
+	Last_Failure_At time.Time `gorm:"index:idx_login_throttle_last_failure"`
+
+	Locked_Until *time.Time `gorm:"index:idx_login_throttle_locked_until"`
+	Lock_Count   int        // ถูกล็อกมาแล้วกี่ครั้ง ล็อกซ้ำนานขึ้นเรื่อยๆ
+
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+}
+
+// สาเหตุที่เข้าสู่ระบบไม่สำเร็จ
+const (
+	LoginFailureUnknownUser = "unknown_user"
+	LoginFailurePassword    = "bad_password"
+	LoginFailureTwoFactor   = "bad_two_factor"
+	LoginFailureLocked      = "locked"
+)
+
+var LoginFailureNames = map[string]string{
+	LoginFailureUnknownUser: "ไม่พบผู้ใช้",
+	LoginFailurePassword:    "รหัสผ่านไม่ถูกต้อง",
+	LoginFailureTwoFactor:   "รหัส 2FA ไม่ถูกต้อง",
+	LoginFailureLocked:      "ถูกล็อกชั่วคราว",
+}
+
+// สิทธิ์ของ API key สำหรับระบบภายนอก
+const (
+	ApiScopeBillsRead     = "bills:read"     // ดูบิลค้างชำระ / ชำระแล้ว / ประวัติต่อดอก / รายการแจ้งหลุดจำนำ
+	ApiScopePaymentsWrite = "payments:write" // รับชำระค่างวด
+	ApiScopeNoticesWrite  = "notices:write"  // ยืนยันการส่งแจ้งเตือนหลุดจำนำ
+	ApiScopeMembersRead   = "members:read"   // ค้นหาสมาชิกจาก LINE user
+	ApiScopeMembersLink   = "members:link"   // ผูก LINE user กับสมาชิกด้วยเบอร์โทร
+)
+
+var ApiScopeNames = map[string]string{
+	ApiScopeBillsRead:     "ดูข้อมูลบิล",
+	ApiScopePaymentsWrite: "รับชำระค่างวด",
+	ApiScopeNoticesWrite:  "ยืนยันส่งแจ้งเตือน",
+	ApiScopeMembersRead:   "ค้นหาสมาชิก",
+	ApiScopeMembersLink:   "ผูกบัญชีสมาชิก",
+}
+
+// key ของระบบภายนอก (LINE bot ฯลฯ) เก็บเฉพาะ hash
+// หมุน key = ออก key ใหม่ชื่อเดิม แล้วให้ key เดิมใช้ต่อได้อีกช่วงหนึ่งจนกว่าระบบภายนอกจะเปลี่ยน
+type Api_Key struct {
+	Id       uint   `gorm:"primaryKey"`
+	Name     string `gorm:"size:100;index:idx_api_key_name"`
+	Prefix   string `gorm:"size:16"` // ส่วนต้นของ key ให้ผู้ดูแลจำได้ว่าเป็น key ไหน
+	Key_Hash string `gorm:"size:64;uniqueIndex"`
+	Scopes   string `gorm:"size:255"` // คั่นด้วย comma เช่น bills:read,payments:write
+
+	Expires_At   *time.Time `gorm:"index:idx_api_key_expires_at"`
+	Last_Used_At *time.Time
+	Last_Used_Ip string `gorm:"size:64"`
+	Rotated_From *uint  // key เดิมที่หมุนมา
+
+	Revoked_At *time.Time
+	Revoked_By *int
+
+	Created_By *int
+	Creator    Users `gorm:"foreignKey:Created_By;references:Id;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// role เริ่มต้นของระบบ ใช้เป็นค่าเริ่มต้นตอนเพิ่มสิทธิ์ใหม่เข้าแคตตาล็อกเท่านั้น (หลังจากนั้นกำหนดสิทธิ์ได้จาก DB)
+const (
+	RoleAdmin = 1
+	RoleStaff = 2
+)
+
+// รหัสสิทธิ์ที่ตรวจใน middleware
+const (
+	PermBillView       = "bill.view"
+	PermBillCreate     = "bill.create"
+	PermBillEdit       = "bill.edit"
+	PermBillPay        = "bill.pay"
+	PermBillPrincipal  = "bill.principal"
+	PermBillCredit     = "bill.credit"
+	PermBillTransition = "bill.transition"
+	PermBillRestruct   = "bill.restructure"
+	PermBillCancel     = "bill.cancel"
+	PermPaymentReverse = "payment.reverse"
+	PermPaymentPolicy  = "payment.policy"
+	PermForfeitNotify  = "forfeit.notify"
+	PermForfeitManage  = "forfeit.manage"
+	PermSaleCreate     = "sale.create"
+	PermSaleView       = "sale.view"
+	PermReportView     = "report.view"
+	PermMemberView     = "member.view"
+	PermMemberEdit     = "member.edit"
+	PermMemberDelete   = "member.delete"
+	PermProductView    = "product.view"
+	PermProductCreate  = "product.create"
+	PermProductEdit    = "product.edit"
+	PermProductCat     = "product.category"
+	PermInventoryView  = "inventory.view"
+	PermInventoryEdit  = "inventory.edit"
+	PermAppraisalView  = "appraisal.view"
+	PermAppraisalNew   = "appraisal.create"
+	PermAppraisalLtv   = "appraisal.policy"
+	PermInstallView    = "installment.view"
+	PermInstallEdit    = "installment.edit"
+	PermFineView       = "fine.view"
+	PermFineEdit       = "fine.edit"
+	PermRuleManage     = "rule.manage"
+	PermUserManage     = "user.manage"
+	PermRoleManage     = "role.manage"
+	PermSecurityManage = "security.manage"
+	PermApiKeyManage   = "apikey.manage"
+	PermAuditView      = "audit.view"
+)
+
+type PermissionDef struct {
+	Code          string
+	Name          string
+	Group         string
+	Default_Roles []int // role ที่ได้สิทธิ์นี้อัตโนมัติเมื่อสิทธิ์ถูกเพิ่มเข้าระบบครั้งแรก
+}
+
+var PermissionCatalog = []PermissionDef{
+	{PermBillView, "ดูสัญญา / บิล", "สัญญา", []int{RoleAdmin, RoleStaff}},
+	{PermBillCreate, "เปิดสัญญา", "สัญญา", []int{RoleAdmin, RoleStaff}},
+	{PermBillEdit, "แก้ไขสัญญา", "สัญญา", []int{RoleAdmin, RoleStaff}},
+	{PermBillPay, "รับชำระค่างวด / ต่อดอก", "สัญญา", []int{RoleAdmin, RoleStaff}},
+	{PermBillPrincipal, "ลด / เพิ่มเงินต้นจำนำ", "สัญญา", []int{RoleAdmin, RoleStaff}},
+	{PermBillCredit, "คืน / โอนเครดิต", "สัญญา", []int{RoleAdmin, RoleStaff}},
+	{PermBillTransition, "เปลี่ยนสถานะสัญญา", "สัญญา", []int{RoleAdmin}},
+	{PermBillRestruct, "ปรับโครงสร้างหนี้", "สัญญา", []int{RoleAdmin}},
+	{PermBillCancel, "ยกเลิกสัญญา", "สัญญา", []int{RoleAdmin}},
+	{PermPaymentReverse, "กลับรายการใบเสร็จ", "การเงิน", []int{RoleAdmin}},
+	{PermPaymentPolicy, "ตั้งค่าลำดับตัดชำระ", "การเงิน", []int{RoleAdmin}},
+	{PermForfeitNotify, "ยืนยันส่งแจ้งเตือนหลุดจำนำ", "หลุดจำนำ", []int{RoleAdmin, RoleStaff}},
+	{PermForfeitManage, "ตั้งค่า / สั่งประมวลผลหลุดจำนำ", "หลุดจำนำ", []int{RoleAdmin}},
+	{PermSaleCreate, "ขายหน้าร้าน", "ขาย", []int{RoleAdmin, RoleStaff}},
+	{PermSaleView, "ดูใบเสร็จขายหน้าร้าน", "ขาย", []int{RoleAdmin, RoleStaff}},
+	{PermReportView, "ดูรายงาน", "รายงาน", []int{RoleAdmin, RoleStaff}},
+	{PermMemberView, "ดูสมาชิก", "สมาชิก", []int{RoleAdmin, RoleStaff}},
+	{PermMemberEdit, "เพิ่ม / แก้ไขสมาชิก", "สมาชิก", []int{RoleAdmin, RoleStaff}},
+	{PermMemberDelete, "ลบสมาชิก", "สมาชิก", []int{RoleAdmin}},
+	{PermProductView, "ดูสินค้า", "สินค้า", []int{RoleAdmin, RoleStaff}},
+	{PermProductCreate, "เพิ่มสินค้า", "สินค้า", []int{RoleAdmin, RoleStaff}},
+	{PermProductEdit, "แก้ไข / ลบสินค้า", "สินค้า", []int{RoleAdmin}},
+	{PermProductCat, "จัดการหมวดหมู่สินค้า", "สินค้า", []int{RoleAdmin, RoleStaff}},
+	{PermInventoryView, "ดูสต็อก", "สต็อก", []int{RoleAdmin, RoleStaff}},
+	{PermInventoryEdit, "รับเข้า / ปรับสต็อก", "สต็อก", []int{RoleAdmin}},
+	{PermAppraisalView, "ดูการประเมินราคา", "ประเมินราคา", []int{RoleAdmin, RoleStaff}},
+	{PermAppraisalNew, "ประเมินราคา", "ประเมินราคา", []int{RoleAdmin, RoleStaff}},
+	{PermAppraisalLtv, "ตั้งค่าเพดานวงเงิน (LTV)", "ประเมินราคา", []int{RoleAdmin}},
+	{PermInstallView, "ดูแผนผ่อน", "ตั้งค่า", []int{RoleAdmin, RoleStaff}},
+	{PermInstallEdit, "จัดการแผนผ่อน", "ตั้งค่า", []int{RoleAdmin}},
+	{PermFineView, "ดูค่าปรับ", "ตั้งค่า", []int{RoleAdmin}},
+	{PermFineEdit, "จัดการค่าปรับ", "ตั้งค่า", []int{RoleAdmin}},
+	{PermRuleManage, "จัดการกฎ", "ตั้งค่า", []int{RoleAdmin}},
+	{PermUserManage, "จัดการผู้ใช้", "ผู้ดูแลระบบ", []int{RoleAdmin}},
+	{PermRoleManage, "จัดการ role และสิทธิ์", "ผู้ดูแลระบบ", []int{RoleAdmin}},
+	{PermSecurityManage, "ตั้งค่าความปลอดภัย / ดูเหตุการณ์", "ผู้ดูแลระบบ", []int{RoleAdmin}},
+	{PermApiKeyManage, "จัดการ API key", "ผู้ดูแลระบบ", []int{RoleAdmin}},
+	{PermAuditView, "ดูประวัติการแก้ไขข้อมูล", "ผู้ดูแลระบบ", []int{RoleAdmin}},
+}
+
+// สิทธิ์ในแคตตาล็อก (sync จากโค้ดตอนเริ่มระบบ)
+type Permission struct {
+	Id         uint      `gorm:"primaryKey"`
+	Code       string    `gorm:"size:50;uniqueIndex"`
+	Name       string    `gorm:"size:100"`
+	Group_Name string    `gorm:"size:50"`
+	CreatedAt  time.Time `gorm:"autoCreateTime"`
+}
+
+// สิทธิ์ที่ role ได้รับ
+type Role_Permission struct {
+	Id              uint      `gorm:"primaryKey"`
+	RoleID          int       `gorm:"uniqueIndex:idx_role_permission"`
+	Role            Role      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Permission_Code string    `gorm:"size:50;uniqueIndex:idx_role_permission"`
+	CreatedAt       time.Time `gorm:"autoCreateTime"`
+}
+
+// ประวัติการแก้ไขข้อมูล (audit log)
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+)
+
+const (
+	AuditEntityBill            = "bill"
+	AuditEntityInstallmentBill = "installment_bill"
+	AuditEntityFine            = "fine"
+	AuditEntityFineCategory    = "fine_category"
+	AuditEntityRule            = "rule"
+	AuditEntityProduct         = "product"
+	AuditEntityProductCategory = "product_category"
+	AuditEntityMember          = "member"
+	AuditEntityUser            = "user"
+)
+
+var AuditEntityNames = map[string]string{
+	AuditEntityBill:            "สัญญาผ่อนสินค้า",
+	AuditEntityInstallmentBill: "สัญญาจำนำ / ผ่อน",
+	AuditEntityFine:            "ค่าปรับ",
+	AuditEntityFineCategory:    "หมวดค่าปรับ",
+	AuditEntityRule:            "กฎ",
+	AuditEntityProduct:         "สินค้า",
+	AuditEntityProductCategory: "หมวดหมู่สินค้า",
+	AuditEntityMember:          "สมาชิก",
+	AuditEntityUser:            "ผู้ใช้",
+}
+
+type Audit_Log struct {
+	Id uint `gorm:"primaryKey"`
+
+	Actor_Id   *int  `gorm:"index:idx_audit_log_actor"`
+	Actor      Users `gorm:"foreignKey:Actor_Id;references:Id;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+	Api_Key_Id *uint // ทำผ่าน API key แทนผู้ใช้
+
+	Action    string `gorm:"size:20"`
+	Entity    string `gorm:"size:50;index:idx_audit_log_entity"`
+	Entity_Id uint   `gorm:"index:idx_audit_log_entity"`
+	Changes   string `gorm:"type:text"` // JSON {"field":{"before":...,"after":...}}
+
+	Ip         string `gorm:"size:64"`
+	Request_Id string `gorm:"size:64"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime;index:idx_audit_log_created_at"`
+}
+
+// รหัสผ่านเดิมของผู้ใช้ (เก็บเฉพาะ hash) ใช้กันการตั้งรหัสผ่านซ้ำกับที่เคยใช้
+type Password_History struct {
+	Id            uint   `gorm:"primaryKey"`
+	User_Id       uint   `gorm:"index:idx_password_history_user"`
+	User          Users  `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Password_Hash string `gorm:"not null"`
+
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// ประวัติการเข้าสู่ระบบทุกครั้ง ทั้งสำเร็จและไม่สำเร็จ (Reason ตาม LoginFailure*) ใช้ทั้งประวัติรายผู้ใช้และรายการเข้าสู่ระบบผิด
+type Login_History struct {
+	Id       uint   `gorm:"primaryKey"`
+	Username string `gorm:"size:255;index:idx_login_history_username"`
+
+	User_Id *int  `gorm:"index:idx_login_history_user"` // nil = ไม่พบ username
+	User    Users `gorm:"foreignKey:User_Id;references:Id;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
+
+	Success    bool   `gorm:"index:idx_login_history_success"`
+	Reason     string `gorm:"size:30"`
+	Session_Id *uint
+	Ip         string `gorm:"size:64;index:idx_login_history_ip"`
+	User_Agent string `gorm:"size:255"`
+
+	Unusual_Hour bool // สำเร็จนอกเวลาทำการ
+
+	CreatedAt time.Time `gorm:"autoCreateTime;index:idx_login_history_created_at"`
+}
+
+// การแก้ข้อมูลที่ต้องทำครั้งเดียว (ชื่อ migration ที่รันไปแล้ว)
+type Schema_Migration struct {
+	Name       string    `gorm:"primaryKey;size:100"`
+	Applied_At time.Time `gorm:"autoCreateTime"`
+}
@@ -0,0 +1,22 @@
+package path
+
+import (
+	"rrmobile/handler"
+	"rrmobile/middleware"
+	"rrmobile/model"
+	"rrmobile/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func ApiKeyPath(app *fiber.App, h handler.ApiKeyRequestHandler, authSvc service.AuthService, usersSvc service.UsersService) {
+	api := app.Group("/api-keys")
+	v1 := api.Group("/v1")
+	protected := v1.Use(middleware.JWTMiddleware(authSvc, usersSvc))
+
+	protected.Get("/scopes", middleware.RequirePermission(authSvc, usersSvc, model.PermApiKeyManage), h.GetApiScopes)
+	protected.Get("/all", middleware.RequirePermission(authSvc, usersSvc, model.PermApiKeyManage), h.GetApiKeys)
+	protected.Post("/create", middleware.RequirePermission(authSvc, usersSvc, model.PermApiKeyManage), h.CreateApiKey)
+	protected.Post("/:id/rotate", middleware.RequirePermission(authSvc, usersSvc, model.PermApiKeyManage), h.RotateApiKey)
+	protected.Delete("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermApiKeyManage), h.RevokeApiKey)
+}
@@ -0,0 +1,24 @@
+package path
+
+import (
+	"rrmobile/handler"
+	"rrmobile/middleware"
+	"rrmobile/model"
+	"rrmobile/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func AppraisalPath(app *fiber.App, h handler.AppraisalRequestHandler, authSvc service.AuthService, usersSvc service.UsersService) {
+	api := app.Group("/appraisal")
+	v1 := api.Group("/v1")
+	protected := v1.Use(middleware.JWTMiddleware(authSvc, usersSvc))
+
+	protected.Get("/ltv", middleware.RequirePermission(authSvc, usersSvc, model.PermAppraisalView), h.GetLtvPolicies)
+	protected.Put("/ltv", middleware.RequirePermission(authSvc, usersSvc, model.PermAppraisalLtv), h.UpsertLtvPolicy)
+	protected.Delete("/ltv/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermAppraisalLtv), h.DeleteLtvPolicy)
+
+	protected.Post("/create", middleware.RequirePermission(authSvc, usersSvc, model.PermAppraisalNew), h.CreateAppraisal)
+	protected.Get("/all", middleware.RequirePermission(authSvc, usersSvc, model.PermAppraisalView), h.GetAppraisals)
+	protected.Get("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermAppraisalView), h.GetAppraisalById)
+}
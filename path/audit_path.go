@@ -0,0 +1,18 @@
+package path
+
+import (
+	"rrmobile/handler"
+	"rrmobile/middleware"
+	"rrmobile/model"
+	"rrmobile/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func AuditPath(app *fiber.App, h handler.AuditRequestHandler, authSvc service.AuthService, usersSvc service.UsersService) {
+	api := app.Group("/audit")
+	v1 := api.Group("/v1")
+	protected := v1.Use(middleware.JWTMiddleware(authSvc, usersSvc))
+
+	protected.Get("/logs", middleware.RequirePermission(authSvc, usersSvc, model.PermAuditView), h.GetAuditLogs)
+}
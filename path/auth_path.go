@@ -2,6 +2,8 @@ package path
 
 import (
 	"rrmobile/handler"
+	"rrmobile/middleware"
+	"rrmobile/model"
 	"rrmobile/service"
 
 	"github.com/gofiber/fiber/v2"
@@ -13,5 +15,43 @@ func AuthPath(app *fiber.App, h handler.AuthRequestHandler, authSvc service.Auth
 	v1.Post("/login", h.Login)
 	v1.Post("/logout", h.Logout)
 	v1.Post("/refresh", h.Refresh)
+	v1.Post("/login/2fa/setup", h.SetupLoginTwoFactor)
+	v1.Post("/login/2fa", h.VerifyLoginTwoFactor)
 
+	// เซสชันของตัวเอง
+	v1.Get("/sessions", middleware.JWTMiddleware(authSvc, usersSvc), h.GetMySessions)
+	v1.Delete("/sessions/:id", middleware.JWTMiddleware(authSvc, usersSvc), h.RevokeMySession)
+
+	// 2FA ของตัวเอง
+	v1.Get("/2fa", middleware.JWTMiddleware(authSvc, usersSvc), h.GetTwoFactorStatus)
+	v1.Post("/2fa/setup", middleware.JWTMiddleware(authSvc, usersSvc), h.SetupTwoFactor)
+	v1.Post("/2fa/enable", middleware.JWTMiddleware(authSvc, usersSvc), h.EnableTwoFactor)
+	v1.Post("/2fa/disable", middleware.JWTMiddleware(authSvc, usersSvc), h.DisableTwoFactor)
+	v1.Post("/2fa/recovery-codes", middleware.JWTMiddleware(authSvc, usersSvc), h.RegenerateRecoveryCodes)
+	v1.Get("/me/permissions", middleware.JWTMiddleware(authSvc, usersSvc), h.GetMyPermissions)
+
+	// เปลี่ยนรหัสผ่านของตัวเอง (ใช้ได้ตอนถูกบังคับเปลี่ยนรหัสผ่าน)
+	v1.Get("/password-policy", h.GetPasswordPolicy)
+	v1.Post("/me/password", middleware.AllowPasswordChange, middleware.JWTMiddleware(authSvc, usersSvc), h.ChangePassword)
+
+	// ผู้ดูแลระบบ: เซสชันของผู้ใช้ทุกคน + นโยบายเซสชันต่อ role
+	v1.Get("/users/:id/sessions", middleware.RequirePermission(authSvc, usersSvc, model.PermUserManage), h.GetUserSessions)
+	v1.Delete("/users/:id/sessions", middleware.RequirePermission(authSvc, usersSvc, model.PermUserManage), h.RevokeUserSessions)
+	v1.Delete("/users/:id/sessions/:session_id", middleware.RequirePermission(authSvc, usersSvc, model.PermUserManage), h.RevokeUserSession)
+	v1.Delete("/users/:id/2fa", middleware.RequirePermission(authSvc, usersSvc, model.PermUserManage), h.ResetUserTwoFactor)
+	v1.Get("/session-policy", middleware.RequirePermission(authSvc, usersSvc, model.PermSecurityManage), h.GetSessionPolicies)
+	v1.Put("/session-policy/:role_id", middleware.RequirePermission(authSvc, usersSvc, model.PermSecurityManage), h.UpdateSessionPolicy)
+	v1.Get("/security-events", middleware.RequirePermission(authSvc, usersSvc, model.PermSecurityManage), h.GetSecurityEvents)
+
+	// ผู้ดูแลระบบ: การล็อกจากเข้าสู่ระบบผิด
+	v1.Get("/login-locks", middleware.RequirePermission(authSvc, usersSvc, model.PermSecurityManage), h.GetLoginLocks)
+	v1.Delete("/login-locks/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermSecurityManage), h.UnlockLogin)
+	v1.Delete("/users/:id/lock", middleware.RequirePermission(authSvc, usersSvc, model.PermUserManage), h.UnlockUserLogin)
+	v1.Get("/login-failures", middleware.RequirePermission(authSvc, usersSvc, model.PermSecurityManage), h.GetLoginFailures)
+	v1.Get("/users/:id/login-history", middleware.RequirePermission(authSvc, usersSvc, model.PermUserManage), h.GetUserLoginHistory)
+
+	// ผู้ดูแลระบบ: สิทธิ์ของแต่ละ role
+	v1.Get("/permissions", middleware.RequirePermission(authSvc, usersSvc, model.PermRoleManage), h.GetPermissions)
+	v1.Get("/roles/:role_id/permissions", middleware.RequirePermission(authSvc, usersSvc, model.PermRoleManage), h.GetRolePermissions)
+	v1.Put("/roles/:role_id/permissions", middleware.RequirePermission(authSvc, usersSvc, model.PermRoleManage), h.UpdateRolePermissions)
 }
@@ -3,54 +3,94 @@ package path
 import (
 	"rrmobile/handler"
 	"rrmobile/middleware"
+	"rrmobile/model"
 	"rrmobile/service"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func BillPath(app *fiber.App, h handler.BillRequestHandler, authSvc service.AuthService, usersSvc service.UsersService) {
+func BillPath(app *fiber.App, h handler.BillRequestHandler, authSvc service.AuthService, usersSvc service.UsersService, apiKeySvc service.ApiKeyService) {
 
 	api := app.Group("/bill")
 	v1 := api.Group("/v1")
-	v1.Get("/all/unpaid", middleware.RoleMiddleware(authSvc, 1, 2), h.GetAllBillsUnpay)
+	v1.Get("/all/unpaid", middleware.RequirePermission(authSvc, usersSvc, model.PermBillView), h.GetAllBillsUnpay)
 	// protected := v1.Use(middleware.JWTMiddleware(authSvc, usersSvc))
-	v1.Post("renew/:id",middleware.RoleMiddleware(authSvc, 1, 2), h.RenewInterest)
+	v1.Post("renew/:id",middleware.RequirePermission(authSvc, usersSvc, model.PermBillPay), h.RenewInterest)
+	v1.Get("/renew/:id/history", middleware.RequirePermission(authSvc, usersSvc, model.PermBillView), h.GetPawnRenewalHistory)
+	v1.Post("/principal/:id/reduce", middleware.RequirePermission(authSvc, usersSvc, model.PermBillPrincipal), h.ReducePawnPrincipal)
+	v1.Post("/principal/:id/topup", middleware.RequirePermission(authSvc, usersSvc, model.PermBillPrincipal), h.TopUpPawnPrincipal)
+	v1.Get("/principal/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermBillView), h.GetPawnPrincipalChanges)
 
-	v1.Get("/all", middleware.RoleMiddleware(authSvc, 1, 2), h.GetAllBills)
-	v1.Get("/all/in", middleware.RoleMiddleware(authSvc, 1, 2), h.GetAllInstallmentBills)
+	v1.Get("/forfeit/policy", middleware.RequirePermission(authSvc, usersSvc, model.PermBillView), h.GetPawnForfeitPolicy)
+	v1.Put("/forfeit/policy", middleware.RequirePermission(authSvc, usersSvc, model.PermForfeitManage), h.UpdatePawnForfeitPolicy)
+	v1.Get("/forfeit/report", middleware.RequirePermission(authSvc, usersSvc, model.PermReportView), h.GetPawnForfeitReport)
+	v1.Post("/forfeit/run", middleware.RequirePermission(authSvc, usersSvc, model.PermForfeitManage), h.RunPawnForfeitures)
+	v1.Post("/forfeit/notices/:id/sent", middleware.RequirePermission(authSvc, usersSvc, model.PermForfeitNotify), h.MarkForfeitNoticeSent)
+
+	v1.Get("/states", middleware.RequirePermission(authSvc, usersSvc, model.PermBillView), h.GetBillStateMachine)
+	v1.Get("/:id/status", middleware.RequirePermission(authSvc, usersSvc, model.PermBillView), h.GetBillStatusHistory)
+	v1.Get("/:id/in/status", middleware.RequirePermission(authSvc, usersSvc, model.PermBillView), h.GetInstallmentBillStatusHistory)
+	v1.Post("/:id/transition", middleware.RequirePermission(authSvc, usersSvc, model.PermBillTransition), h.TransitionBill)
+	v1.Post("/:id/in/transition", middleware.RequirePermission(authSvc, usersSvc, model.PermBillTransition), h.TransitionInstallmentBill)
+	v1.Get("/:id/restructures", middleware.RequirePermission(authSvc, usersSvc, model.PermBillView), h.GetBillRestructures)
+	v1.Post("/:id/restructure", middleware.RequirePermission(authSvc, usersSvc, model.PermBillRestruct), h.RestructureBill)
+
+	// ยกเลิกสัญญา / กลับรายการใบเสร็จ (admin เท่านั้น)
+	v1.Post("/payment/:id/reverse", middleware.RequirePermission(authSvc, usersSvc, model.PermPaymentReverse), h.ReverseBillPayment)
+	v1.Post("/:id/cancel", middleware.RequirePermission(authSvc, usersSvc, model.PermBillCancel), h.CancelBill)
+	v1.Post("/:id/in/cancel", middleware.RequirePermission(authSvc, usersSvc, model.PermBillCancel), h.CancelInstallmentBill)
+	v1.Get("/:id/cancellation", middleware.RequirePermission(authSvc, usersSvc, model.PermBillView), h.GetBillCancellation)
+	v1.Get("/:id/in/cancellation", middleware.RequirePermission(authSvc, usersSvc, model.PermBillView), h.GetInstallmentBillCancellation)
+
+	// เครดิตคงเหลือ: ประวัติ / คืนเงิน / โอนไปสัญญาอื่นของสมาชิกคนเดียวกัน
+	v1.Get("/:id/credit", middleware.RequirePermission(authSvc, usersSvc, model.PermBillView), h.GetCreditLedger)
+	v1.Get("/:id/in/credit", middleware.RequirePermission(authSvc, usersSvc, model.PermBillView), h.GetInstallmentCreditLedger)
+	v1.Post("/:id/credit/refund", middleware.RequirePermission(authSvc, usersSvc, model.PermBillCredit), h.RefundCredit)
+	v1.Post("/:id/in/credit/refund", middleware.RequirePermission(authSvc, usersSvc, model.PermBillCredit), h.RefundInstallmentCredit)
+	v1.Post("/:id/credit/transfer", middleware.RequirePermission(authSvc, usersSvc, model.PermBillCredit), h.TransferCredit)
+	v1.Post("/:id/in/credit/transfer", middleware.RequirePermission(authSvc, usersSvc, model.PermBillCredit), h.TransferInstallmentCredit)
+
+	v1.Get("/all", middleware.RequirePermission(authSvc, usersSvc, model.PermBillView), h.GetAllBills)
+	v1.Get("/all/in", middleware.RequirePermission(authSvc, usersSvc, model.PermBillView), h.GetAllInstallmentBills)
 
 	// protected.Get("/all/unpaid", middleware.RoleMiddleware(authSvc, 1), h.GetAllBillsUnpay)
-	v1.Get("/all/in/unpaid", middleware.RoleMiddleware(authSvc, 1, 2), h.GetAllInstallmentBillsUnpay)
+	v1.Get("/all/in/unpaid", middleware.RequirePermission(authSvc, usersSvc, model.PermBillView), h.GetAllInstallmentBillsUnpay)
 
-	v1.Get("/:id", middleware.RoleMiddleware(authSvc, 1, 2), h.GetBillByID)
-	v1.Put("/:id", middleware.RoleMiddleware(authSvc, 1, 2), h.UpdateBill)
-	v1.Put("/:id/in", middleware.RoleMiddleware(authSvc, 1, 2), h.UpdateBill_Installments)
+	v1.Get("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermBillView), h.GetBillByID)
+	v1.Put("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermBillEdit), h.UpdateBill)
+	v1.Put("/:id/in", middleware.RequirePermission(authSvc, usersSvc, model.PermBillEdit), h.UpdateBill_Installments)
 
-	v1.Get("/detail/:id", middleware.RoleMiddleware(authSvc, 1, 2), h.GetBillDetailByID)
-	v1.Get("all/detail/:id", middleware.RoleMiddleware(authSvc, 1, 2), h.GetDetailBillByBillID)
-	v1.Get("all/detail/in/:id", middleware.RoleMiddleware(authSvc, 1, 2), h.GetInstallmentBillByIdUnpaid)
+	v1.Get("/detail/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermBillView), h.GetBillDetailByID)
+	v1.Get("all/detail/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermBillView), h.GetDetailBillByBillID)
+	v1.Get("all/detail/in/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermBillView), h.GetInstallmentBillByIdUnpaid)
 
-	v1.Get("/detail/:id/in", middleware.RoleMiddleware(authSvc, 1, 2), h.GetInstallmentBillDetailByID)
+	v1.Get("/detail/:id/in", middleware.RequirePermission(authSvc, usersSvc, model.PermBillView), h.GetInstallmentBillDetailByID)
 
-	v1.Post("/create", middleware.RoleMiddleware(authSvc, 1, 2), h.CreateBill)
+	v1.Post("/create", middleware.RequirePermission(authSvc, usersSvc, model.PermBillCreate), h.CreateBill)
 	// v1.Post("/refresh", h.Refresh)
-	v1.Post("/createst", h.CreateBill)
 
-	v1.Post("/create/in", middleware.RoleMiddleware(authSvc, 1, 2), h.CreateBillInsallments)
-	v1.Get("/:id/in", middleware.RoleMiddleware(authSvc, 1, 2), h.GetInstallmentBillByID)
+	v1.Post("/create/in", middleware.RequirePermission(authSvc, usersSvc, model.PermBillCreate), h.CreateBillInsallments)
+	v1.Get("/:id/in", middleware.RequirePermission(authSvc, usersSvc, model.PermBillView), h.GetInstallmentBillByID)
+
+	v1.Post("/extra", middleware.RequirePermission(authSvc, usersSvc, model.PermBillPay), h.AddExtraPayment)
+	v1.Post("/extra/in", middleware.RequirePermission(authSvc, usersSvc, model.PermBillPay), h.AddInstallmentExtraPayment)
+	// ระบบภายนอก (LINE bot) ใช้ API key ตาม scope
+	private := v1.Group("/")
+	private.Get("/unpaid/today", middleware.RequireScope(apiKeySvc, model.ApiScopeBillsRead), h.GetDueTodayBillsHandler)
+	private.Get("/unpaid/today/in", middleware.RequireScope(apiKeySvc, model.ApiScopeBillsRead), h.GetDueTodayInstallmentBillsHandler)
+	private.Post("/all/unpaid/bill", middleware.RequireScope(apiKeySvc, model.ApiScopeBillsRead), h.GetUnpaidBillByIdHandler)
+	private.Post("/all/unpaid/bill/in", middleware.RequireScope(apiKeySvc, model.ApiScopeBillsRead), h.GetUnpaidInstallmentBillByIdHandler)
+
+	private.Post("/paid/bill", middleware.RequireScope(apiKeySvc, model.ApiScopeBillsRead), h.GetpaidBillByIdHandler)
+	private.Post("/paid/bill/in", middleware.RequireScope(apiKeySvc, model.ApiScopeBillsRead), h.GetpaidInstallBillByIdHandler)
 
-	v1.Post("/extra", h.AddExtraPayment, middleware.RoleMiddleware(authSvc, 1, 2))
-	v1.Post("/extra/in", h.AddInstallmentExtraPayment, middleware.RoleMiddleware(authSvc, 1, 2))
-	private := v1.Group("/", middleware.RequireBillAuth())
-	private.Get("/unpaid/today", h.GetDueTodayBillsHandler)
-	private.Get("/unpaid/today/in", h.GetDueTodayInstallmentBillsHandler)
-	private.Post("/all/unpaid/bill", h.GetUnpaidBillByIdHandler)
-	private.Post("/all/unpaid/bill/in", h.GetUnpaidInstallmentBillByIdHandler)
+	private.Post("/pay", middleware.RequireScope(apiKeySvc, model.ApiScopePaymentsWrite), h.PayInstallment)
+	private.Post("/pay/in", middleware.RequireScope(apiKeySvc, model.ApiScopePaymentsWrite), h.PayInstallmentBill)
 
-	private.Post("/paid/bill", h.GetpaidBillByIdHandler)
-	private.Post("/paid/bill/in", h.GetpaidInstallBillByIdHandler)
+	// LINE bot ดึงรายการที่ต้องแจ้งหลุดจำนำ แล้วยืนยันเมื่อส่งแล้ว
+	private.Get("/forfeit/notices", middleware.RequireScope(apiKeySvc, model.ApiScopeBillsRead), h.GetPendingForfeitNotices)
+	private.Post("/forfeit/notices/:id/sent/bot", middleware.RequireScope(apiKeySvc, model.ApiScopeNoticesWrite), h.MarkForfeitNoticeSent)
 
-	private.Post("/pay", h.PayInstallment)
-	private.Post("/pay/in", h.PayInstallmentBill)
+	private.Post("/renew/history/bot", middleware.RequireScope(apiKeySvc, model.ApiScopeBillsRead), h.GetPawnRenewalHistoryBot)
 
 }
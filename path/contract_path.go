@@ -0,0 +1,29 @@
+package path
+
+import (
+	"rrmobile/handler"
+	"rrmobile/middleware"
+	"rrmobile/model"
+	"rrmobile/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// endpoint รวมของทุกประเภทสัญญา (ผ่อน / จำนำ / ขายสด)
+// route เดิมใน /bill/v1 ยังใช้ได้ โดยเรียกผ่าน contract engine ชุดเดียวกัน
+func ContractPath(app *fiber.App, h handler.ContractRequestHandler, authSvc service.AuthService, usersSvc service.UsersService) {
+	api := app.Group("/contract")
+	v1 := api.Group("/v1")
+	protected := v1.Use(middleware.JWTMiddleware(authSvc, usersSvc))
+
+	protected.Post("/create", middleware.RequirePermission(authSvc, usersSvc, model.PermBillCreate), h.CreateContract)
+	protected.Post("/pay", middleware.RequirePermission(authSvc, usersSvc, model.PermBillPay), h.PayContract)
+	protected.Get("/all", middleware.RequirePermission(authSvc, usersSvc, model.PermBillView), h.GetContracts)
+	protected.Get("/summary", middleware.RequirePermission(authSvc, usersSvc, model.PermReportView), h.GetContractSummary)
+	protected.Get("/report/sales", middleware.RequirePermission(authSvc, usersSvc, model.PermReportView), h.GetSalesReport)
+
+	// หน้าขายสด (POS)
+	protected.Post("/pos/sale", middleware.RequirePermission(authSvc, usersSvc, model.PermSaleCreate), h.CreateCashSale)
+	protected.Get("/pos/:id/receipt", middleware.RequirePermission(authSvc, usersSvc, model.PermSaleView), h.GetCashSaleReceipt)
+	protected.Get("/:type/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermBillView), h.GetContract)
+}
@@ -3,6 +3,7 @@ package path
 import (
 	"rrmobile/handler"
 	"rrmobile/middleware"
+	"rrmobile/model"
 	"rrmobile/service"
 
 	"github.com/gofiber/fiber/v2"
@@ -15,10 +16,10 @@ func FineCategoryPath(app *fiber.App, h handler.FineCategoryRequestHandler, auth
 	// private := v1.Group("/", middleware.RequireBillAuth())
 	protected := v1.Use(middleware.JWTMiddleware(authSvc, usersSvc))
 
-	protected.Get("/all", middleware.RoleMiddleware(authSvc, 1), h.GetFineCategories)
-	protected.Get("/:id", middleware.RoleMiddleware(authSvc, 1), h.GetFineCategoryById)
-	protected.Post("/create", middleware.RoleMiddleware(authSvc, 1), h.CreateFineCategory)
-	protected.Put("/:id", middleware.RoleMiddleware(authSvc, 1), h.UpdateFineCategory)
-	protected.Delete("/:id", middleware.RoleMiddleware(authSvc, 1), h.DeleteFineCategory)
+	protected.Get("/all", middleware.RequirePermission(authSvc, usersSvc, model.PermFineView), h.GetFineCategories)
+	protected.Get("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermFineView), h.GetFineCategoryById)
+	protected.Post("/create", middleware.RequirePermission(authSvc, usersSvc, model.PermFineEdit), h.CreateFineCategory)
+	protected.Put("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermFineEdit), h.UpdateFineCategory)
+	protected.Delete("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermFineEdit), h.DeleteFineCategory)
 
 }
@@ -3,6 +3,7 @@ package path
 import (
 	"rrmobile/handler"
 	"rrmobile/middleware"
+	"rrmobile/model"
 	"rrmobile/service"
 
 	"github.com/gofiber/fiber/v2"
@@ -14,9 +15,9 @@ func FinePath(app *fiber.App, h handler.FineRequestHandler, authSvc service.Auth
 	// private := v1.Group("/", middleware.RequireBillAuth())
 	protected := v1.Use(middleware.JWTMiddleware(authSvc, usersSvc))
 
-	protected.Get("/all", middleware.RoleMiddleware(authSvc, 1), h.GetFines)
-	protected.Get("/:id", middleware.RoleMiddleware(authSvc, 1), h.GetFineById)
-	protected.Post("/create", middleware.RoleMiddleware(authSvc, 1), h.CreateFine)
-	protected.Put("/:id", middleware.RoleMiddleware(authSvc, 1), h.UpdateFine)
-	protected.Delete("/:id", middleware.RoleMiddleware(authSvc, 1), h.DeleteFine)
+	protected.Get("/all", middleware.RequirePermission(authSvc, usersSvc, model.PermFineView), h.GetFines)
+	protected.Get("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermFineView), h.GetFineById)
+	protected.Post("/create", middleware.RequirePermission(authSvc, usersSvc, model.PermFineEdit), h.CreateFine)
+	protected.Put("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermFineEdit), h.UpdateFine)
+	protected.Delete("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermFineEdit), h.DeleteFine)
 }
@@ -3,6 +3,7 @@ package path
 import (
 	"rrmobile/handler"
 	"rrmobile/middleware"
+	"rrmobile/model"
 	"rrmobile/service"
 
 	"github.com/gofiber/fiber/v2"
@@ -14,9 +15,9 @@ func InstallmentPath(app *fiber.App, h handler.InstallmentRequestHandler, authSv
 	// private := v1.Group("/", middleware.RequireBillAuth())
 	protected := v1.Use(middleware.JWTMiddleware(authSvc, usersSvc))
 
-	protected.Get("/all", middleware.RoleMiddleware(authSvc, 1, 2), h.GetInstallments)
-	protected.Get("/:id", middleware.RoleMiddleware(authSvc, 1), h.GetInstallmentById)
-	protected.Post("/create", middleware.RoleMiddleware(authSvc, 1), h.CreateInstallment)
-	protected.Put("/:id", middleware.RoleMiddleware(authSvc, 1), h.UpdateInstallment)
-	protected.Delete("/:id", middleware.RoleMiddleware(authSvc, 1), h.DeleteInstallment)
+	protected.Get("/all", middleware.RequirePermission(authSvc, usersSvc, model.PermInstallView), h.GetInstallments)
+	protected.Get("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermInstallView), h.GetInstallmentById)
+	protected.Post("/create", middleware.RequirePermission(authSvc, usersSvc, model.PermInstallEdit), h.CreateInstallment)
+	protected.Put("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermInstallEdit), h.UpdateInstallment)
+	protected.Delete("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermInstallEdit), h.DeleteInstallment)
 }
@@ -0,0 +1,23 @@
+package path
+
+import (
+	"rrmobile/handler"
+	"rrmobile/middleware"
+	"rrmobile/model"
+	"rrmobile/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func InventoryPath(app *fiber.App, h handler.InventoryRequestHandler, authSvc service.AuthService, usersSvc service.UsersService) {
+	api := app.Group("/inventory")
+	v1 := api.Group("/v1")
+	protected := v1.Use(middleware.JWTMiddleware(authSvc, usersSvc))
+
+	protected.Get("/all", middleware.RequirePermission(authSvc, usersSvc, model.PermInventoryView), h.GetInventoryUnits)
+	protected.Post("/create", middleware.RequirePermission(authSvc, usersSvc, model.PermInventoryEdit), h.CreateInventoryUnit)
+	protected.Get("/stock", middleware.RequirePermission(authSvc, usersSvc, model.PermInventoryView), h.GetProductStocks)
+	protected.Post("/stock/:product_id/adjust", middleware.RequirePermission(authSvc, usersSvc, model.PermInventoryEdit), h.AdjustProductStock)
+	protected.Get("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermInventoryView), h.GetInventoryUnitById)
+	protected.Put("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermInventoryEdit), h.UpdateInventoryUnit)
+}
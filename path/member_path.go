@@ -3,22 +3,23 @@ package path
 import (
 	"rrmobile/handler"
 	"rrmobile/middleware"
+	"rrmobile/model"
 	"rrmobile/service"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func MemberPath(app *fiber.App, h handler.MemberRequestHandler, authSvc service.AuthService, usersSvc service.UsersService) {
+func MemberPath(app *fiber.App, h handler.MemberRequestHandler, authSvc service.AuthService, usersSvc service.UsersService, apiKeySvc service.ApiKeyService) {
 	api := app.Group("/members")
 	v1 := api.Group("/v1")
-	v1.Get("/all", middleware.RoleMiddleware(authSvc, 1, 2), h.GetMembers)
-	v1.Get("/:id", middleware.RoleMiddleware(authSvc, 1, 2), h.GetMemberById)
-	v1.Post("/create", middleware.RoleMiddleware(authSvc, 1, 2), h.CreateMember)
-	v1.Put("/:id", middleware.RoleMiddleware(authSvc, 1, 2), h.UpdateMember)
-	v1.Delete("/:id", middleware.RoleMiddleware(authSvc, 1), h.DeleteInstallment)
-	private := v1.Group("/", middleware.RequireBillAuth())
-	private.Post("/checking", h.GetMemberByUserId)
-	private.Post("/link", h.LinkUserByTel)
+	v1.Get("/all", middleware.RequirePermission(authSvc, usersSvc, model.PermMemberView), h.GetMembers)
+	v1.Get("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermMemberView), h.GetMemberById)
+	v1.Post("/create", middleware.RequirePermission(authSvc, usersSvc, model.PermMemberEdit), h.CreateMember)
+	v1.Put("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermMemberEdit), h.UpdateMember)
+	v1.Delete("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermMemberDelete), h.DeleteInstallment)
+	private := v1.Group("/")
+	private.Post("/checking", middleware.RequireScope(apiKeySvc, model.ApiScopeMembersRead), h.GetMemberByUserId)
+	private.Post("/link", middleware.RequireScope(apiKeySvc, model.ApiScopeMembersLink), h.LinkUserByTel)
 	// protected := v1.Use(middleware.JWTMiddleware(authSvc, usersSvc))
 
 }
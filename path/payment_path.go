@@ -0,0 +1,21 @@
+package path
+
+import (
+	"rrmobile/handler"
+	"rrmobile/middleware"
+	"rrmobile/model"
+	"rrmobile/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func PaymentPath(app *fiber.App, h handler.PaymentRequestHandler, authSvc service.AuthService, usersSvc service.UsersService) {
+	api := app.Group("/payment")
+	v1 := api.Group("/v1")
+	protected := v1.Use(middleware.JWTMiddleware(authSvc, usersSvc))
+
+	protected.Get("/policy", middleware.RequirePermission(authSvc, usersSvc, model.PermBillView), h.GetAllocationPolicy)
+	protected.Put("/policy", middleware.RequirePermission(authSvc, usersSvc, model.PermPaymentPolicy), h.UpdateAllocationPolicy)
+	protected.Get("/ledger/:bill_type/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermBillView), h.GetBillLedger)
+	protected.Get("/:id/receipt", middleware.RequirePermission(authSvc, usersSvc, model.PermBillView), h.GetPaymentReceipt)
+}
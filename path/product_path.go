@@ -3,6 +3,7 @@ package path
 import (
 	"rrmobile/handler"
 	"rrmobile/middleware"
+	"rrmobile/model"
 	"rrmobile/service"
 
 	"github.com/gofiber/fiber/v2"
@@ -14,13 +15,13 @@ func ProductPath(app *fiber.App, h handler.ProductRequestHandler, authSvc servic
 	// private := v1.Group("/", middleware.RequireBillAuth())
 	protected := v1.Use(middleware.JWTMiddleware(authSvc, usersSvc))
 
-	protected.Get("/all", middleware.RoleMiddleware(authSvc, 1, 2), h.GetAllProducts)
-	protected.Get("/:id", middleware.RoleMiddleware(authSvc, 1, 2), h.GetProductByID)
-	protected.Get("/:id/detail", middleware.RoleMiddleware(authSvc, 1, 2), h.GetProductByIDDetail)
+	protected.Get("/all", middleware.RequirePermission(authSvc, usersSvc, model.PermProductView), h.GetAllProducts)
+	protected.Get("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermProductView), h.GetProductByID)
+	protected.Get("/:id/detail", middleware.RequirePermission(authSvc, usersSvc, model.PermProductView), h.GetProductByIDDetail)
 
-	protected.Post("/create", middleware.RoleMiddleware(authSvc, 1, 2), h.AddProduct)
-	protected.Put("/:id", middleware.RoleMiddleware(authSvc, 1), h.UpdateProduct)
-	protected.Delete("/:id", middleware.RoleMiddleware(authSvc, 1), h.DeleteProduct)
+	protected.Post("/create", middleware.RequirePermission(authSvc, usersSvc, model.PermProductCreate), h.AddProduct)
+	protected.Put("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermProductEdit), h.UpdateProduct)
+	protected.Delete("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermProductEdit), h.DeleteProduct)
 	// v1.Get("/category/:categoryId", h.GetProductsByCategory)
 	// v1.Get("/name/:name", h.GetProductsByName)
 	// v1.Get("/description/:description", h.GetProductsByDescription)
@@ -32,10 +33,10 @@ func ProductCategoryPath(app *fiber.App, h handler.ProductCategoryHandler, authS
 	protected := v1.Use(middleware.JWTMiddleware(authSvc, usersSvc))
 	// v1.Use(middleware.MiddlewareJWT())
 	// v1.Use(middleware.AuthMiddleware(1))
-	protected.Get("/all", middleware.RoleMiddleware(authSvc, 1, 2), h.GetAllCategories)
-	protected.Get("/:id", middleware.RoleMiddleware(authSvc, 1, 2), h.GetCategoryByID)
-	protected.Post("/create", middleware.RoleMiddleware(authSvc, 1, 2), h.CreateCategory)
-	protected.Put("/:id", middleware.RoleMiddleware(authSvc, 1, 2), h.UpdateCategory)
-	protected.Delete("/:id", middleware.RoleMiddleware(authSvc, 1, 2), h.DeleteCategory)
+	protected.Get("/all", middleware.RequirePermission(authSvc, usersSvc, model.PermProductView), h.GetAllCategories)
+	protected.Get("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermProductView), h.GetCategoryByID)
+	protected.Post("/create", middleware.RequirePermission(authSvc, usersSvc, model.PermProductCat), h.CreateCategory)
+	protected.Put("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermProductCat), h.UpdateCategory)
+	protected.Delete("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermProductCat), h.DeleteCategory)
 	// v1.Get("/name/:name", h.GetCategoriesByName)
 }
@@ -3,6 +3,7 @@ package path
 import (
 	"rrmobile/handler"
 	"rrmobile/middleware"
+	"rrmobile/model"
 	"rrmobile/service"
 
 	"github.com/gofiber/fiber/v2"
@@ -16,9 +17,9 @@ func RolesPath(app *fiber.App, h handler.RolesRequestHandler, authSvc service.Au
 	// v1.Use(middleware.MiddlewareJWT(authSvc))     // ✅ ส่ง authSvc
 	// v1.Use(middleware.AuthMiddleware(authSvc, 1)) // ✅ ส่ง authSvc ก่อน แล้ว roleId
 
-	protected.Get("/all", middleware.RoleMiddleware(authSvc, 1), h.GetAllRoles)
-	protected.Get("/:id", middleware.RoleMiddleware(authSvc, 1), h.GetRoleByID)
-	protected.Put("/:id", middleware.RoleMiddleware(authSvc, 1), h.UpdateRole)
-	protected.Delete("/:id", middleware.RoleMiddleware(authSvc, 1), h.DeleteRole)
-	protected.Post("/create", middleware.RoleMiddleware(authSvc, 1), h.CreateRoles)
+	protected.Get("/all", middleware.RequirePermission(authSvc, usersSvc, model.PermRoleManage), h.GetAllRoles)
+	protected.Get("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermRoleManage), h.GetRoleByID)
+	protected.Put("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermRoleManage), h.UpdateRole)
+	protected.Delete("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermRoleManage), h.DeleteRole)
+	protected.Post("/create", middleware.RequirePermission(authSvc, usersSvc, model.PermRoleManage), h.CreateRoles)
 }
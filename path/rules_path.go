@@ -3,6 +3,7 @@ package path
 import (
 	"rrmobile/handler"
 	"rrmobile/middleware"
+	"rrmobile/model"
 	"rrmobile/service"
 
 	"github.com/gofiber/fiber/v2"
@@ -14,9 +15,9 @@ func RulesPath(app *fiber.App, h handler.RulesRequestHandler, authSvc service.Au
 	protected := v1.Use(middleware.JWTMiddleware(authSvc, usersSvc))
 	// v1.Use(middleware.MiddlewareJWT())
 	// v1.Use(middleware.AuthMiddleware(1))
-	protected.Get("/all", middleware.RoleMiddleware(authSvc, 1), h.GetAllRules)
-	protected.Get("/:id", middleware.RoleMiddleware(authSvc, 1), h.GetRuleByID)
-	protected.Post("/create", middleware.RoleMiddleware(authSvc, 1), h.CreateRule)
-	protected.Put("/:id", middleware.RoleMiddleware(authSvc, 1), h.EditRule)
-	protected.Delete("/:id", middleware.RoleMiddleware(authSvc, 1), h.DeleteRule)
+	protected.Get("/all", middleware.RequirePermission(authSvc, usersSvc, model.PermRuleManage), h.GetAllRules)
+	protected.Get("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermRuleManage), h.GetRuleByID)
+	protected.Post("/create", middleware.RequirePermission(authSvc, usersSvc, model.PermRuleManage), h.CreateRule)
+	protected.Put("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermRuleManage), h.EditRule)
+	protected.Delete("/:id", middleware.RequirePermission(authSvc, usersSvc, model.PermRuleManage), h.DeleteRule)
 }
@@ -0,0 +1,14 @@
+package respository
+
+import "rrmobile/model"
+
+type ApiKeyRepository interface {
+	CreateApiKey(key *model.Api_Key) error
+	GetApiKeys(includeRevoked bool) ([]model.Api_Key, error)
+	GetApiKeyByID(id uint) (*model.Api_Key, error)
+	FindApiKeyByHash(hash string) (*model.Api_Key, error)
+	CountApiKeys() (int64, error)
+	RotateApiKey(old *model.Api_Key, next *model.Api_Key) error
+	RevokeApiKey(id uint, revokedBy *int) error
+	TouchApiKey(id uint, ip string) error
+}
@@ -0,0 +1,100 @@
+package respository
+
+import (
+	"errors"
+	"rrmobile/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type apiKeyRepositoryDB struct {
+	db *gorm.DB
+}
+
+func NewApiKeyRepositoryDB(db *gorm.DB) ApiKeyRepository {
+	return &apiKeyRepositoryDB{db: db}
+}
+
+func (r *apiKeyRepositoryDB) CreateApiKey(key *model.Api_Key) error {
+	return r.db.Create(key).Error
+}
+
+func (r *apiKeyRepositoryDB) GetApiKeys(includeRevoked bool) ([]model.Api_Key, error) {
+	query := r.db.Preload("Creator")
+	if !includeRevoked {
+		query = query.Where("revoked_at IS NULL")
+	}
+	var keys []model.Api_Key
+	err := query.Order("name ASC, id DESC").Find(&keys).Error
+	return keys, err
+}
+
+func (r *apiKeyRepositoryDB) GetApiKeyByID(id uint) (*model.Api_Key, error) {
+	var key model.Api_Key
+	if err := r.db.Preload("Creator").First(&key, id).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// ไม่พบคืน nil, nil
+func (r *apiKeyRepositoryDB) FindApiKeyByHash(hash string) (*model.Api_Key, error) {
+	var key model.Api_Key
+	if err := r.db.Where("key_hash = ?", hash).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *apiKeyRepositoryDB) CountApiKeys() (int64, error) {
+	var count int64
+	err := r.db.Model(&model.Api_Key{}).Count(&count).Error
+	return count, err
+}
+
+// ออก key ใหม่ และย่นอายุ key เดิมให้เหลือแค่ช่วงเปลี่ยนผ่าน
+func (r *apiKeyRepositoryDB) RotateApiKey(old *model.Api_Key, next *model.Api_Key) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Api_Key{}).
+			Where("id = ? AND revoked_at IS NULL", old.Id).
+			Update("expires_at", old.Expires_At)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("API key ถูกยกเลิกไปแล้ว")
+		}
+		return tx.Create(next).Error
+	})
+}
+
+func (r *apiKeyRepositoryDB) RevokeApiKey(id uint, revokedBy *int) error {
+	result := r.db.Model(&model.Api_Key{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"revoked_at": time.Now(),
+			"revoked_by": revokedBy,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("API key ถูกยกเลิกไปแล้ว")
+	}
+	return nil
+}
+
+// บันทึกการใช้งานล่าสุด อย่างมากนาทีละครั้ง (ไม่เขียน DB ทุก request)
+func (r *apiKeyRepositoryDB) TouchApiKey(id uint, ip string) error {
+	now := time.Now()
+	return r.db.Model(&model.Api_Key{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, now.Add(-time.Minute)).
+		Updates(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": ip,
+		}).Error
+}
@@ -0,0 +1,30 @@
+package respository
+
+import (
+	"errors"
+	"rrmobile/model"
+)
+
+// ใบประเมินถูกผูกกับบิลอื่นไปก่อนแล้ว (ใช้ซ้ำไม่ได้)
+var ErrAppraisalInUse = errors.New("ใบประเมินนี้ถูกใช้กับบิลอื่นแล้ว")
+
+type AppraisalFilter struct {
+	ProductId *uint
+	Grade     string
+	Unused    bool // เฉพาะใบประเมินที่ยังไม่ผูกบิล
+}
+
+type AppraisalRepository interface {
+	GetAppraisals(filter AppraisalFilter, limit, offset int) ([]model.Pawn_Appraisal, error)
+	CountAppraisals(filter AppraisalFilter) (int64, error)
+	GetAppraisalById(id uint) (*model.Pawn_Appraisal, error)
+	GetAppraisalByBillId(billID uint) (*model.Pawn_Appraisal, error)
+	IsAppraisalTradedIn(id uint) (bool, error)
+	CreateAppraisal(appraisal *model.Pawn_Appraisal) error
+
+	GetLtvPolicies() ([]model.Pawn_Ltv_Policy, error)
+	FindLtvPolicy(categoryID uint, grade string) (*model.Pawn_Ltv_Policy, error)
+	UpsertLtvPolicy(policy *model.Pawn_Ltv_Policy) error
+	DeleteLtvPolicy(id uint) error
+	SeedLtvPolicies(policies []model.Pawn_Ltv_Policy) (int, error)
+}
@@ -0,0 +1,149 @@
+package respository
+
+import (
+	"rrmobile/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type appraisalRepositoryDB struct {
+	db *gorm.DB
+}
+
+func NewAppraisalRepositoryDB(db *gorm.DB) AppraisalRepository {
+	return &appraisalRepositoryDB{db: db}
+}
+
+func (r *appraisalRepositoryDB) applyFilter(query *gorm.DB, filter AppraisalFilter) *gorm.DB {
+	if filter.ProductId != nil {
+		query = query.Where("product_id = ?", *filter.ProductId)
+	}
+	if filter.Grade != "" {
+		query = query.Where("condition_grade = ?", filter.Grade)
+	}
+	if filter.Unused {
+		query = query.Where("bill_header_installment_id IS NULL").
+			Where("id NOT IN (SELECT pawn_appraisal_id FROM trade_ins WHERE pawn_appraisal_id IS NOT NULL)")
+	}
+	return query
+}
+
+func (r *appraisalRepositoryDB) GetAppraisals(filter AppraisalFilter, limit, offset int) ([]model.Pawn_Appraisal, error) {
+	var appraisals []model.Pawn_Appraisal
+	query := r.applyFilter(r.db.Model(&model.Pawn_Appraisal{}), filter).
+		Preload("Product").
+		Preload("Product.Category").
+		Preload("Appraiser").
+		Preload("Photos").
+		Order("created_at DESC")
+	if limit > 0 {
+		query = query.Limit(limit).Offset(offset)
+	}
+	if err := query.Find(&appraisals).Error; err != nil {
+		return nil, err
+	}
+	return appraisals, nil
+}
+
+func (r *appraisalRepositoryDB) CountAppraisals(filter AppraisalFilter) (int64, error) {
+	var count int64
+	if err := r.applyFilter(r.db.Model(&model.Pawn_Appraisal{}), filter).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *appraisalRepositoryDB) GetAppraisalById(id uint) (*model.Pawn_Appraisal, error) {
+	var appraisal model.Pawn_Appraisal
+	if err := r.db.
+		Preload("Product").
+		Preload("Product.Category").
+		Preload("Appraiser").
+		Preload("Photos").
+		First(&appraisal, id).Error; err != nil {
+		return nil, err
+	}
+	return &appraisal, nil
+}
+
+func (r *appraisalRepositoryDB) GetAppraisalByBillId(billID uint) (*model.Pawn_Appraisal, error) {
+	var appraisal model.Pawn_Appraisal
+	if err := r.db.
+		Preload("Product").
+		Preload("Product.Category").
+		Preload("Appraiser").
+		Preload("Photos").
+		Where("bill_header_installment_id = ?", billID).
+		First(&appraisal).Error; err != nil {
+		return nil, err
+	}
+	return &appraisal, nil
+}
+
+// ใบประเมินถูกใช้เป็นเครื่องเทิร์นไปแล้วหรือไม่
+func (r *appraisalRepositoryDB) IsAppraisalTradedIn(id uint) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.Trade_In{}).Where("pawn_appraisal_id = ?", id).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *appraisalRepositoryDB) CreateAppraisal(appraisal *model.Pawn_Appraisal) error {
+	return r.db.Create(appraisal).Error
+}
+
+// ผูกใบประเมินกับบิล (กันไม่ให้ใบเดียวใช้ซ้ำหลายบิล) เรียกภายใน transaction สร้างบิล
+func linkAppraisalToBill(tx *gorm.DB, appraisalID, billID uint) error {
+	result := tx.Model(&model.Pawn_Appraisal{}).
+		Where("id = ? AND bill_header_installment_id IS NULL", appraisalID).
+		Where("NOT EXISTS (SELECT 1 FROM trade_ins WHERE pawn_appraisal_id = ?)", appraisalID).
+		Update("bill_header_installment_id", billID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAppraisalInUse
+	}
+	return nil
+}
+
+func (r *appraisalRepositoryDB) GetLtvPolicies() ([]model.Pawn_Ltv_Policy, error) {
+	var policies []model.Pawn_Ltv_Policy
+	if err := r.db.Order("category_id ASC, condition_grade ASC").Find(&policies).Error; err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// หา LTV ของหมวดสินค้า+เกรด ถ้าไม่มีใช้ค่ากลาง (category_id = 0)
+func (r *appraisalRepositoryDB) FindLtvPolicy(categoryID uint, grade string) (*model.Pawn_Ltv_Policy, error) {
+	var policy model.Pawn_Ltv_Policy
+	err := r.db.
+		Where("condition_grade = ? AND category_id IN ?", grade, []uint{categoryID, 0}).
+		Order("category_id DESC").
+		First(&policy).Error
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (r *appraisalRepositoryDB) UpsertLtvPolicy(policy *model.Pawn_Ltv_Policy) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "category_id"}, {Name: "condition_grade"}},
+		DoUpdates: clause.AssignmentColumns([]string{"max_ltv_percent", "updated_at"}),
+	}).Create(policy).Error
+}
+
+func (r *appraisalRepositoryDB) DeleteLtvPolicy(id uint) error {
+	return r.db.Delete(&model.Pawn_Ltv_Policy{}, id).Error
+}
+
+// เพิ่มเพดาน LTV ค่าเริ่มต้นที่ยังไม่มี (ไม่ทับค่าที่ผู้ดูแลตั้งไว้แล้ว) คืนจำนวนที่เพิ่ม
+func (r *appraisalRepositoryDB) SeedLtvPolicies(policies []model.Pawn_Ltv_Policy) (int, error) {
+	if len(policies) == 0 {
+		return 0, nil
+	}
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&policies)
+	return int(result.RowsAffected), result.Error
+}
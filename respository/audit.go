@@ -0,0 +1,22 @@
+package respository
+
+import (
+	"rrmobile/model"
+	"time"
+)
+
+type AuditLogFilter struct {
+	Entity    string
+	Entity_Id *int
+	Actor_Id  *int
+	Action    string
+	DateFrom  *time.Time
+	DateTo    *time.Time
+	Limit     int
+	Offset    int
+}
+
+type AuditRepository interface {
+	CreateAuditLog(entry *model.Audit_Log) error
+	GetAuditLogs(filter AuditLogFilter) ([]model.Audit_Log, int64, error)
+}
@@ -0,0 +1,58 @@
+package respository
+
+import (
+	"rrmobile/model"
+
+	"gorm.io/gorm"
+)
+
+type auditRepositoryDB struct {
+	db *gorm.DB
+}
+
+func NewAuditRepositoryDB(db *gorm.DB) AuditRepository {
+	return &auditRepositoryDB{db: db}
+}
+
+func (r *auditRepositoryDB) CreateAuditLog(entry *model.Audit_Log) error {
+	return r.db.Create(entry).Error
+}
+
+func (r *auditRepositoryDB) GetAuditLogs(filter AuditLogFilter) ([]model.Audit_Log, int64, error) {
+	query := r.db.Model(&model.Audit_Log{})
+	if filter.Entity != "" {
+		query = query.Where("entity = ?", filter.Entity)
+	}
+	if filter.Entity_Id != nil {
+		query = query.Where("entity_id = ?", *filter.Entity_Id)
+	}
+	if filter.Actor_Id != nil {
+		query = query.Where("actor_id = ?", *filter.Actor_Id)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.DateFrom != nil {
+		query = query.Where("created_at >= ?", *filter.DateFrom)
+	}
+	if filter.DateTo != nil {
+		query = query.Where("created_at < ?", filter.DateTo.AddDate(0, 0, 1))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+
+	var logs []model.Audit_Log
+	err := query.
+		Preload("Actor").
+		Order("created_at DESC, id DESC").
+		Limit(filter.Limit).
+		Offset(filter.Offset).
+		Find(&logs).Error
+	return logs, total, err
+}
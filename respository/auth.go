@@ -2,29 +2,79 @@ package respository
 
 import (
 	"context"
+	"errors"
+	"rrmobile/model"
 	"time"
 )
 
+// refresh token ถูกแลกไปแล้ว (ส่งมาซ้ำ / แลกพร้อมกันสองที่)
+var ErrRefreshTokenReused = errors.New("refresh token already rotated")
+
+// ไม่พบ username หรือบัญชีถูกปิดใช้งาน (ไม่แยกให้ client รู้)
+var ErrInvalidCredentials = errors.New("invalid login credentials")
+
 type Auths struct {
 	Username string `db:"username"`
 	Password string `db:"password"`
 }
 type RefreshToken struct {
-	Id        uint      `db:"id"`
-	User_Id   int       `db:"user_id"`
-	TokenHash string    `db:"token_hash"`
-	ExpiresAt time.Time `db:"expires_at"`
-	CreatedAt time.Time `db:"created_at"`
-	IsRevoked bool      `db:"is_revoked"`
+	Id          uint       `db:"id"`
+	User_Id     int        `db:"user_id"`
+	Session_Id  *uint      `db:"session_id"`
+	Family_Id   string     `db:"family_id"`
+	TokenHash   string     `db:"token_hash"`
+	ExpiresAt   time.Time  `db:"expires_at"`
+	CreatedAt   time.Time  `db:"created_at"`
+	IsRevoked   bool       `db:"is_revoked"`
+	Rotated_At  *time.Time `db:"rotated_at"`
+	Replaced_By *uint      `db:"replaced_by"`
+}
+
+type SecurityEventFilter struct {
+	Event_Type string
+	User_Id    *int
+	DateFrom   *time.Time
+	DateTo     *time.Time
+	Limit      int
+	Offset     int
+}
+
+// เกณฑ์ล็อกการเข้าสู่ระบบ: ผิดครบ Max_Failures ครั้งภายใน Window จะล็อก Lock_For
+// ล็อกซ้ำแต่ละครั้งนานขึ้นเท่าตัว ไม่เกิน Max_Lock_For
+type LoginThrottleRule struct {
+	Window       time.Duration
+	Max_Failures int
+	Lock_For     time.Duration
+	Max_Lock_For time.Duration
+}
+
+type LoginFailureFilter struct {
+	Username string
+	Ip       string
+	User_Id  *int
+	DateFrom *time.Time
+	DateTo   *time.Time
+	Limit    int
+	Offset   int
+}
+
+type LoginHistoryFilter struct {
+	User_Id  int
+	Success  *bool
+	DateFrom *time.Time
+	DateTo   *time.Time
+	Limit    int
+	Offset   int
 }
 
 type AccessToken struct {
-	Id        uint      `db:"id"`
-	User_Id   uint      `db:"user_id"`
-	Token     string    `db:"token_hash"`
-	ExpiresAt time.Time `db:"expires_at"`
-	IsRevoked bool      `db:"is_revoked"`
-	CreatedAt time.Time `db:"created_at"`
+	Id         uint      `db:"id"`
+	User_Id    uint      `db:"user_id"`
+	Session_Id *uint     `db:"session_id"`
+	Token      string    `db:"token_hash"`
+	ExpiresAt  time.Time `db:"expires_at"`
+	IsRevoked  bool      `db:"is_revoked"`
+	CreatedAt  time.Time `db:"created_at"`
 }
 type Users struct {
 	Id        uint      `db:"id"`
@@ -35,6 +85,9 @@ type Users struct {
 	CreatedAt time.Time `db:"created_at"`
 	UpdatedAt time.Time `db:"updated_at"`
 	Is_active bool      `db:"is_active"`
+
+	Must_Change_Password bool       `db:"must_change_password"`
+	Password_Changed_At  *time.Time `db:"password_changed_at"`
 }
 
 type AuthRepository interface {
@@ -58,4 +111,57 @@ type AuthRepository interface {
 
 	FindValidRefreshTokenByHashACC(hashed string) (*AccessToken, error)
 	RevokeTokenACC(tokenID uint) error
+
+	CreateSession(ctx context.Context, session *model.User_Session) error
+	GetSessionByID(sessionID uint) (*model.User_Session, error)
+	GetActiveSessions(userID uint) ([]model.User_Session, error)
+	GetSessionsForList(userID uint, compromisedSince time.Time) ([]model.User_Session, error)
+	TouchSession(sessionID uint, seenAt time.Time) error
+	RevokeSessions(sessionIDs []uint, revokedBy *int, reason string) error
+	RevokeSessionACC(sessionID uint) error
+
+	FindRefreshTokenByHash(hashed string) (*RefreshToken, error)
+	RotateRefreshToken(old *RefreshToken, next *RefreshToken) error
+	RevokeRefreshFamily(token *RefreshToken, reason string) error
+	CreateSecurityEvent(event *model.Security_Event) error
+	GetSecurityEvents(filter SecurityEventFilter) ([]model.Security_Event, int64, error)
+
+	GetTwoFactor(userID uint) (*model.User_Two_Factor, error)
+	SaveTwoFactor(tf *model.User_Two_Factor) error
+	EnableTwoFactor(tf *model.User_Two_Factor, codes []model.User_Recovery_Code) error
+	DeleteTwoFactor(userID uint) error
+	MarkTwoFactorStep(userID uint, step int64) (bool, error)
+	ReplaceRecoveryCodes(userID uint, codes []model.User_Recovery_Code) error
+	UseRecoveryCode(userID uint, codeHash string) (bool, error)
+	CountRecoveryCodes(userID uint) (int64, error)
+
+	CreateLoginChallenge(challenge *model.Login_Challenge) error
+	GetLoginChallenge(tokenHash string) (*model.Login_Challenge, error)
+	IncrementChallengeAttempts(challengeID uint) error
+	ConsumeLoginChallenge(challengeID uint) error
+
+	GetActiveLoginLocks(username string, ip string) ([]model.Login_Throttle, error)
+	RegisterLoginFailure(scope string, key string, rule LoginThrottleRule) (*model.Login_Throttle, bool, error)
+	ClearLoginThrottle(scope string, key string) error
+	GetLockedLoginThrottles() ([]model.Login_Throttle, error)
+	GetLoginThrottleByID(id uint) (*model.Login_Throttle, error)
+	DeleteLoginThrottle(id uint) error
+	PurgeLoginThrottles(before time.Time) (int64, error)
+	GetLoginFailures(filter LoginFailureFilter) ([]model.Login_History, int64, error)
+
+	SetPassword(userID uint, passwordHash string, mustChange bool, keepHistory int) error
+	GetPasswordHistory(userID uint, limit int) ([]model.Password_History, error)
+
+	CreateLoginHistory(history *model.Login_History) error
+	GetLoginHistory(filter LoginHistoryFilter) ([]model.Login_History, int64, error)
+
+	SyncPermissions(catalog []model.PermissionDef) (int, error)
+	GetPermissions() ([]model.Permission, error)
+	RoleExists(roleID int) (bool, error)
+	GetRolePermissionCodes(roleID int) ([]string, error)
+	SetRolePermissions(roleID int, codes []string) error
+
+	GetSessionPolicy(roleID int) (*model.Session_Policy, error)
+	GetSessionPolicies() ([]model.Session_Policy, error)
+	SaveSessionPolicy(policy *model.Session_Policy) error
 }
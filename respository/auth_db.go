@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"rrmobile/model"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type authRepositoryDB struct {
@@ -122,14 +124,14 @@ func (r *authRepositoryDB) Login(ctx context.Context, username string) (*Users,
 	}
 
 	err := r.db.WithContext(ctx).
-		Select("id", "username", "password", "role_id", "is_active").
+		Select("id", "username", "password", "role_id", "is_active", "created_at", "must_change_password", "password_changed_at").
 		Where("username = ? AND is_active = ?", username, true).
 		First(&user).Error
 
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			// ไม่เปิดเผยว่าผู้ใช้ไม่มีอยู่ หรือ inactive
-			return nil, errors.New("invalid login credentials")
+			return nil, ErrInvalidCredentials
 		}
 		// log actual error (อย่า return error จริงให้ client)
 		return nil, fmt.Errorf("database error:", err)
@@ -192,3 +194,640 @@ func (r *authRepositoryDB) FindActiveAccessToken(token string) (*AccessToken, er
 func (r *authRepositoryDB) RevokeAllTokensACC(userID uint) error {
 	return r.db.Where("user_id = ?", userID).Delete(&AccessToken{}).Error
 }
+
+func (r *authRepositoryDB) CreateSession(ctx context.Context, session *model.User_Session) error {
+	return r.db.WithContext(ctx).Create(session).Error
+}
+
+func (r *authRepositoryDB) GetSessionByID(sessionID uint) (*model.User_Session, error) {
+	var session model.User_Session
+	if err := r.db.First(&session, sessionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("session not found")
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// เซสชันที่ยังใช้งานได้ ล่าสุดก่อน
+func (r *authRepositoryDB) GetActiveSessions(userID uint) ([]model.User_Session, error) {
+	var sessions []model.User_Session
+	err := r.db.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC, id DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// เซสชันที่แสดงให้ผู้ใช้เห็น: ที่ยังใช้งานได้ + ที่ถูกปิดเพราะ token ถูกขโมยหลัง compromisedSince
+func (r *authRepositoryDB) GetSessionsForList(userID uint, compromisedSince time.Time) ([]model.User_Session, error) {
+	var sessions []model.User_Session
+	err := r.db.
+		Where("user_id = ?", userID).
+		Where("(revoked_at IS NULL AND expires_at > ?) OR compromised_at >= ?", time.Now(), compromisedSince).
+		Order("last_seen_at DESC, id DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// อัปเดตเวลาใช้งานล่าสุด ไม่เกินนาทีละครั้ง เพื่อไม่ให้เขียน DB ทุก request
+func (r *authRepositoryDB) TouchSession(sessionID uint, seenAt time.Time) error {
+	return r.db.Model(&model.User_Session{}).
+		Where("id = ? AND revoked_at IS NULL AND last_seen_at < ?", sessionID, seenAt.Add(-time.Minute)).
+		Update("last_seen_at", seenAt).Error
+}
+
+// ปิดเซสชัน พร้อม revoke refresh / access token ของเซสชันนั้นทั้งหมด
+func (r *authRepositoryDB) RevokeSessions(sessionIDs []uint, revokedBy *int, reason string) error {
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.User_Session{}).
+			Where("id IN ? AND revoked_at IS NULL", sessionIDs).
+			Updates(map[string]interface{}{
+				"revoked_at":    time.Now(),
+				"revoked_by":    revokedBy,
+				"revoke_reason": reason,
+			}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&RefreshToken{}).
+			Where("session_id IN ? AND is_revoked = false", sessionIDs).
+			Update("is_revoked", true).Error; err != nil {
+			return err
+		}
+		return tx.Model(&AccessToken{}).
+			Where("session_id IN ? AND is_revoked = false", sessionIDs).
+			Update("is_revoked", true).Error
+	})
+}
+
+func (r *authRepositoryDB) RevokeSessionACC(sessionID uint) error {
+	return r.db.Model(&AccessToken{}).
+		Where("session_id = ? AND is_revoked = ?", sessionID, false).
+		Update("is_revoked", true).Error
+}
+
+func (r *authRepositoryDB) GetSessionPolicy(roleID int) (*model.Session_Policy, error) {
+	var policy model.Session_Policy
+	err := r.db.Where("role_id = ?", roleID).First(&policy).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// ยังไม่ได้ตั้งค่า → หลายเครื่องไม่จำกัด
+			return &model.Session_Policy{RoleID: roleID, Mode: model.SessionModeMulti}, nil
+		}
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (r *authRepositoryDB) GetSessionPolicies() ([]model.Session_Policy, error) {
+	var policies []model.Session_Policy
+	err := r.db.Order("role_id ASC").Find(&policies).Error
+	return policies, err
+}
+
+func (r *authRepositoryDB) SaveSessionPolicy(policy *model.Session_Policy) error {
+	if policy.Id == 0 {
+		return r.db.Create(policy).Error
+	}
+	return r.db.Model(&model.Session_Policy{}).
+		Where("id = ?", policy.Id).
+		Updates(map[string]interface{}{
+			"mode":               policy.Mode,
+			"max_sessions":       policy.Max_Sessions,
+			"require_two_factor": policy.Require_Two_Factor,
+		}).Error
+}
+
+// ค้นหา refresh token ทุกสถานะ (ใช้ตรวจการใช้ token ที่หมุนไปแล้วซ้ำ)
+func (r *authRepositoryDB) FindRefreshTokenByHash(hashed string) (*RefreshToken, error) {
+	var token RefreshToken
+	if err := r.db.Where("token_hash = ?", hashed).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// แลก refresh token: ปิด token เดิม (เฉพาะที่ยังไม่เคยถูกแลก) แล้วออก token ใหม่ใน family เดียวกัน
+func (r *authRepositoryDB) RotateRefreshToken(old *RefreshToken, next *RefreshToken) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(next).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		result := tx.Model(&RefreshToken{}).
+			Where("id = ? AND rotated_at IS NULL AND is_revoked = false", old.Id).
+			Updates(map[string]interface{}{
+				"is_revoked":  true,
+				"rotated_at":  now,
+				"replaced_by": next.Id,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefreshTokenReused
+		}
+		old.Rotated_At = &now
+		return nil
+	})
+}
+
+// ปิดทั้ง family ของ token ที่ถูกใช้ซ้ำ รวมถึงเซสชันและ access token ของเซสชันนั้น
+func (r *authRepositoryDB) RevokeRefreshFamily(token *RefreshToken, reason string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&RefreshToken{}).Where("is_revoked = false")
+		if token.Family_Id != "" {
+			query = query.Where("family_id = ?", token.Family_Id)
+		} else {
+			query = query.Where("id = ?", token.Id)
+		}
+		if err := query.Update("is_revoked", true).Error; err != nil {
+			return err
+		}
+		if token.Session_Id == nil {
+			return nil
+		}
+
+		now := time.Now()
+		if err := tx.Model(&model.User_Session{}).
+			Where("id = ?", *token.Session_Id).
+			Updates(map[string]interface{}{
+				"revoked_at":     gorm.Expr("COALESCE(revoked_at, ?)", now),
+				"revoke_reason":  reason,
+				"compromised_at": now,
+			}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&RefreshToken{}).
+			Where("session_id = ? AND is_revoked = false", *token.Session_Id).
+			Update("is_revoked", true).Error; err != nil {
+			return err
+		}
+		return tx.Model(&AccessToken{}).
+			Where("session_id = ? AND is_revoked = false", *token.Session_Id).
+			Update("is_revoked", true).Error
+	})
+}
+
+func (r *authRepositoryDB) CreateSecurityEvent(event *model.Security_Event) error {
+	return r.db.Create(event).Error
+}
+
+func (r *authRepositoryDB) GetSecurityEvents(filter SecurityEventFilter) ([]model.Security_Event, int64, error) {
+	query := r.db.Model(&model.Security_Event{})
+	if filter.Event_Type != "" {
+		query = query.Where("event_type = ?", filter.Event_Type)
+	}
+	if filter.User_Id != nil {
+		query = query.Where("user_id = ?", *filter.User_Id)
+	}
+	if filter.DateFrom != nil {
+		query = query.Where("created_at >= ?", *filter.DateFrom)
+	}
+	if filter.DateTo != nil {
+		query = query.Where("created_at < ?", filter.DateTo.AddDate(0, 0, 1))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+
+	var events []model.Security_Event
+	err := query.
+		Preload("User").
+		Order("created_at DESC, id DESC").
+		Limit(filter.Limit).
+		Offset(filter.Offset).
+		Find(&events).Error
+	return events, total, err
+}
+
+func (r *authRepositoryDB) GetTwoFactor(userID uint) (*model.User_Two_Factor, error) {
+	var tf model.User_Two_Factor
+	if err := r.db.Where("user_id = ?", userID).First(&tf).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &tf, nil
+}
+
+func (r *authRepositoryDB) SaveTwoFactor(tf *model.User_Two_Factor) error {
+	return r.db.Save(tf).Error
+}
+
+// เปิดใช้ 2FA พร้อมออกรหัสกู้คืนชุดใหม่
+func (r *authRepositoryDB) EnableTwoFactor(tf *model.User_Two_Factor, codes []model.User_Recovery_Code) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(tf).Error; err != nil {
+			return err
+		}
+		return replaceRecoveryCodes(tx, uint(tf.User_Id), codes)
+	})
+}
+
+func (r *authRepositoryDB) DeleteTwoFactor(userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&model.User_Recovery_Code{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&model.User_Two_Factor{}).Error
+	})
+}
+
+// บันทึกรอบเวลาของรหัสที่ใช้ ถ้ารหัสรอบนี้ (หรือใหม่กว่า) ถูกใช้ไปแล้วคืน false
+func (r *authRepositoryDB) MarkTwoFactorStep(userID uint, step int64) (bool, error) {
+	result := r.db.Model(&model.User_Two_Factor{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *authRepositoryDB) ReplaceRecoveryCodes(userID uint, codes []model.User_Recovery_Code) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codes)
+	})
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint, codes []model.User_Recovery_Code) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&model.User_Recovery_Code{}).Error; err != nil {
+		return err
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	return tx.Create(&codes).Error
+}
+
+// ใช้รหัสกู้คืน (ได้ครั้งเดียว)
+func (r *authRepositoryDB) UseRecoveryCode(userID uint, codeHash string) (bool, error) {
+	result := r.db.Model(&model.User_Recovery_Code{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *authRepositoryDB) CountRecoveryCodes(userID uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.User_Recovery_Code{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *authRepositoryDB) CreateLoginChallenge(challenge *model.Login_Challenge) error {
+	return r.db.Create(challenge).Error
+}
+
+func (r *authRepositoryDB) GetLoginChallenge(tokenHash string) (*model.Login_Challenge, error) {
+	var challenge model.Login_Challenge
+	if err := r.db.Where("token_hash = ?", tokenHash).First(&challenge).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+func (r *authRepositoryDB) IncrementChallengeAttempts(challengeID uint) error {
+	return r.db.Model(&model.Login_Challenge{}).
+		Where("id = ?", challengeID).
+		Update("attempts", gorm.Expr("attempts + 1")).Error
+}
+
+// ใช้ challenge ได้ครั้งเดียว (กันแลก token ซ้อนกัน)
+func (r *authRepositoryDB) ConsumeLoginChallenge(challengeID uint) error {
+	result := r.db.Model(&model.Login_Challenge{}).
+		Where("id = ? AND used_at IS NULL", challengeID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("challenge already used")
+	}
+	return nil
+}
+
+// ล็อกที่ยังไม่หมดเวลาของ username / IP นี้
+func (r *authRepositoryDB) GetActiveLoginLocks(username string, ip string) ([]model.Login_Throttle, error) {
+	var locks []model.Login_Throttle
+	err := r.db.
+		Where("locked_until > ?", time.Now()).
+		Where("(scope = ? AND throttle_key = ?) OR (scope = ? AND throttle_key = ?)",
+			model.LoginThrottleUsername, username, model.LoginThrottleIp, ip).
+		Order("locked_until DESC").
+		Find(&locks).Error
+	return locks, err
+}
+
+// นับการเข้าสู่ระบบผิด ครบเกณฑ์จะล็อก (คืน true เมื่อเพิ่งถูกล็อกในครั้งนี้)
+func (r *authRepositoryDB) RegisterLoginFailure(scope string, key string, rule LoginThrottleRule) (*model.Login_Throttle, bool, error) {
+	var throttle model.Login_Throttle
+	locked := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&model.Login_Throttle{Scope: scope, Throttle_Key: key, Window_Start_At: now, Last_Failure_At: now}).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("scope = ? AND throttle_key = ?", scope, key).
+			First(&throttle).Error; err != nil {
+			return err
+		}
+
+		// ล็อกอยู่แล้ว ไม่นับเพิ่ม (ไม่ให้ล็อกยืดออกไปเรื่อยๆ)
+		if throttle.Locked_Until != nil && throttle.Locked_Until.After(now) {
+			return nil
+		}
+		if now.Sub(throttle.Window_Start_At) > rule.Window {
+			throttle.Failures = 0
+			throttle.Window_Start_At = now
+		}
+		throttle.Failures++
+		throttle.Last_Failure_At = now
+
+		if rule.Max_Failures > 0 && throttle.Failures >= rule.Max_Failures {
+			lockFor := rule.Lock_For
+			for i := 0; i < throttle.Lock_Count && lockFor < rule.Max_Lock_For; i++ {
+				lockFor *= 2
+			}
+			if rule.Max_Lock_For > 0 && lockFor > rule.Max_Lock_For {
+				lockFor = rule.Max_Lock_For
+			}
+			until := now.Add(lockFor)
+			throttle.Locked_Until = &until
+			throttle.Lock_Count++
+			throttle.Failures = 0
+			throttle.Window_Start_At = now
+			locked = true
+		}
+		return tx.Model(&throttle).Updates(map[string]interface{}{
+			"failures":        throttle.Failures,
+			"window_start_at": throttle.Window_Start_At,
+			"last_failure_at": throttle.Last_Failure_At,
+			"locked_until":    throttle.Locked_Until,
+			"lock_count":      throttle.Lock_Count,
+		}).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return &throttle, locked, nil
+}
+
+// เข้าสู่ระบบสำเร็จ ล้างตัวนับ (รวมถึงจำนวนครั้งที่เคยถูกล็อก)
+func (r *authRepositoryDB) ClearLoginThrottle(scope string, key string) error {
+	return r.db.Where("scope = ? AND throttle_key = ?", scope, key).Delete(&model.Login_Throttle{}).Error
+}
+
+func (r *authRepositoryDB) GetLockedLoginThrottles() ([]model.Login_Throttle, error) {
+	var locks []model.Login_Throttle
+	err := r.db.
+		Where("locked_until > ?", time.Now()).
+		Order("locked_until DESC").
+		Find(&locks).Error
+	return locks, err
+}
+
+func (r *authRepositoryDB) GetLoginThrottleByID(id uint) (*model.Login_Throttle, error) {
+	var throttle model.Login_Throttle
+	if err := r.db.First(&throttle, id).Error; err != nil {
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+func (r *authRepositoryDB) DeleteLoginThrottle(id uint) error {
+	return r.db.Delete(&model.Login_Throttle{}, id).Error
+}
+
+// ลบตัวนับที่ไม่มีการเข้าสู่ระบบผิดมานานและไม่ได้ล็อกอยู่
+func (r *authRepositoryDB) PurgeLoginThrottles(before time.Time) (int64, error) {
+	result := r.db.
+		Where("last_failure_at < ?", before).
+		Where("locked_until IS NULL OR locked_until < ?", time.Now()).
+		Delete(&model.Login_Throttle{})
+	return result.RowsAffected, result.Error
+}
+
+// รายการเข้าสู่ระบบผิดอ่านจาก login_histories แถวที่ไม่สำเร็จ
+func (r *authRepositoryDB) GetLoginFailures(filter LoginFailureFilter) ([]model.Login_History, int64, error) {
+	query := r.db.Model(&model.Login_History{}).Where("success = ?", false)
+	if filter.Username != "" {
+		query = query.Where("LOWER(username) = LOWER(?)", filter.Username)
+	}
+	if filter.Ip != "" {
+		query = query.Where("ip = ?", filter.Ip)
+	}
+	if filter.User_Id != nil {
+		query = query.Where("user_id = ?", *filter.User_Id)
+	}
+	if filter.DateFrom != nil {
+		query = query.Where("created_at >= ?", *filter.DateFrom)
+	}
+	if filter.DateTo != nil {
+		query = query.Where("created_at < ?", filter.DateTo.AddDate(0, 0, 1))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+
+	var failures []model.Login_History
+	err := query.
+		Order("created_at DESC, id DESC").
+		Limit(filter.Limit).
+		Offset(filter.Offset).
+		Find(&failures).Error
+	return failures, total, err
+}
+
+func (r *authRepositoryDB) SetPassword(userID uint, passwordHash string, mustChange bool, keepHistory int) error {
+	return savePassword(r.db, userID, passwordHash, mustChange, keepHistory)
+}
+
+func (r *authRepositoryDB) GetPasswordHistory(userID uint, limit int) ([]model.Password_History, error) {
+	var history []model.Password_History
+	err := r.db.Where("user_id = ?", userID).Order("id DESC").Limit(limit).Find(&history).Error
+	return history, err
+}
+
+// บันทึกประวัติการเข้าสู่ระบบ ถ้าสำเร็จอัปเดตเวลาเข้าสู่ระบบล่าสุดของผู้ใช้ไปพร้อมกัน
+func (r *authRepositoryDB) CreateLoginHistory(history *model.Login_History) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(history).Error; err != nil {
+			return err
+		}
+		if !history.Success || history.User_Id == nil {
+			return nil
+		}
+		return tx.Model(&model.Users{}).Where("id = ?", *history.User_Id).
+			UpdateColumn("last_login_at", history.CreatedAt).Error
+	})
+}
+
+func (r *authRepositoryDB) GetLoginHistory(filter LoginHistoryFilter) ([]model.Login_History, int64, error) {
+	query := r.db.Model(&model.Login_History{}).Where("user_id = ?", filter.User_Id)
+	if filter.Success != nil {
+		query = query.Where("success = ?", *filter.Success)
+	}
+	if filter.DateFrom != nil {
+		query = query.Where("created_at >= ?", *filter.DateFrom)
+	}
+	if filter.DateTo != nil {
+		query = query.Where("created_at < ?", filter.DateTo.AddDate(0, 0, 1))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = 50
+	}
+
+	var history []model.Login_History
+	err := query.
+		Order("created_at DESC, id DESC").
+		Limit(filter.Limit).
+		Offset(filter.Offset).
+		Find(&history).Error
+	return history, total, err
+}
+
+// เพิ่มสิทธิ์ใหม่จากแคตตาล็อกในโค้ด สิทธิ์ที่เพิ่งเพิ่มจะให้ role เริ่มต้นอัตโนมัติ
+// สิทธิ์ที่มีอยู่แล้วอัปเดตแค่ชื่อ ไม่แตะการกำหนดสิทธิ์ที่ผู้ดูแลแก้ไว้ (คืนจำนวนสิทธิ์ที่เพิ่มใหม่)
+// role เริ่มต้นที่ยังไม่มีสิทธิ์เลย (เช่น เพิ่งสร้าง role หลังเพิ่มสิทธิ์) จะได้สิทธิ์เริ่มต้นครบชุด
+func (r *authRepositoryDB) SyncPermissions(catalog []model.PermissionDef) (int, error) {
+	added := 0
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		for _, def := range catalog {
+			var perm model.Permission
+			err := tx.Where("code = ?", def.Code).First(&perm).Error
+			if err == nil {
+				if perm.Name != def.Name || perm.Group_Name != def.Group {
+					if err := tx.Model(&perm).Updates(map[string]interface{}{
+						"name":       def.Name,
+						"group_name": def.Group,
+					}).Error; err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+
+			perm = model.Permission{Code: def.Code, Name: def.Name, Group_Name: def.Group}
+			if err := tx.Create(&perm).Error; err != nil {
+				return err
+			}
+			for _, roleID := range def.Default_Roles {
+				var count int64
+				if err := tx.Model(&model.Role{}).Where("id = ?", roleID).Count(&count).Error; err != nil {
+					return err
+				}
+				if count == 0 {
+					continue
+				}
+				if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+					Create(&model.Role_Permission{RoleID: roleID, Permission_Code: def.Code}).Error; err != nil {
+					return err
+				}
+			}
+			added++
+		}
+
+		defaults := map[int][]string{}
+		for _, def := range catalog {
+			for _, roleID := range def.Default_Roles {
+				defaults[roleID] = append(defaults[roleID], def.Code)
+			}
+		}
+		for roleID, codes := range defaults {
+			var roles, granted int64
+			if err := tx.Model(&model.Role{}).Where("id = ?", roleID).Count(&roles).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&model.Role_Permission{}).Where("role_id = ?", roleID).Count(&granted).Error; err != nil {
+				return err
+			}
+			if roles == 0 || granted > 0 {
+				continue
+			}
+			rows := make([]model.Role_Permission, 0, len(codes))
+			for _, code := range codes {
+				rows = append(rows, model.Role_Permission{RoleID: roleID, Permission_Code: code})
+			}
+			if err := tx.Create(&rows).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return added, err
+}
+
+func (r *authRepositoryDB) GetPermissions() ([]model.Permission, error) {
+	var perms []model.Permission
+	err := r.db.Order("id ASC").Find(&perms).Error
+	return perms, err
+}
+
+func (r *authRepositoryDB) RoleExists(roleID int) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.Role{}).Where("id = ?", roleID).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *authRepositoryDB) GetRolePermissionCodes(roleID int) ([]string, error) {
+	var codes []string
+	err := r.db.Model(&model.Role_Permission{}).
+		Where("role_id = ?", roleID).
+		Order("permission_code ASC").
+		Pluck("permission_code", &codes).Error
+	return codes, err
+}
+
+// แทนที่สิทธิ์ทั้งหมดของ role
+func (r *authRepositoryDB) SetRolePermissions(roleID int, codes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", roleID).Delete(&model.Role_Permission{}).Error; err != nil {
+			return err
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		rows := make([]model.Role_Permission, 0, len(codes))
+		for _, code := range codes {
+			rows = append(rows, model.Role_Permission{RoleID: roleID, Permission_Code: code})
+		}
+		return tx.Create(&rows).Error
+	})
+}
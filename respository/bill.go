@@ -3,6 +3,8 @@ package respository
 import (
 	"rrmobile/model"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type Bill_Header struct {
@@ -10,7 +12,7 @@ type Bill_Header struct {
 	Invoice   string    `db:"invoice"`
 	CreatedAt time.Time `db:"created_at"`
 	UpdatedAt time.Time `db:"updated_at"`
-	DeletedAt time.Time `db:"deleted_at"`
+	DeletedAt gorm.DeletedAt `db:"deleted_at"`
 	MemberId  uint      `db:"member_id"`
 	User_Id   int       `db:"user_id"`
 	ProductId uint      `db:"product_id"`
@@ -34,11 +36,16 @@ type Bill_Header struct {
 	Status int    `db:"status"`
 	Note   string `db:"note"`
 
-	Credit_Balance float64        `db:"credit_balance"`
-	BillDetails    []Bill_Details `db:"bill_details"`
-	Member         Member         `db:"members"`
-	Product        Product        `db:"products"`
-	User           User           `db:"users"`
+	Schedule_Version int `db:"schedule_version"`
+
+	Credit_Balance  float64        `db:"credit_balance"`
+	Down_Payment    float64        `db:"down_payment"`
+	Trade_In_Amount float64        `db:"trade_in_amount"`
+	Upfront_Amount  float64        `db:"upfront_amount"`
+	BillDetails     []Bill_Details `db:"bill_details"`
+	Member          Member         `db:"members"`
+	Product         Product        `db:"products"`
+	User            User           `db:"users"`
 }
 
 type Bill_Details struct {
@@ -55,6 +62,13 @@ type Bill_Details struct {
 
 	Credit_Balance float64 `db:"credit_balance"`
 	Payment_No     string  `db:"payment_no"`
+
+	Schedule_Version int `db:"schedule_version"`
+
+	Interest_Amount float64 `db:"interest_amount"`
+	Fee_Paid        float64 `db:"fee_paid"`
+	Interest_Paid   float64 `db:"interest_paid"`
+	Principal_Paid  float64 `db:"principal_paid"`
 }
 type Bill_Details1 struct {
 	Id                uint    `db:"id"`
@@ -122,6 +136,7 @@ type BillRepository interface {
 	GetBestSellingProducts(limit int) ([]BestSellingProduct, error)
 	GetLastHpcByYear(yearSuffix string) (string, error)
 	CreateInstallmentBill(bill *model.Bill_Header_Installment) (*model.Bill_Header_Installment, error)
+	CreateInstallmentBillContract(bill *model.Bill_Header_Installment, details []model.Bill_Details_Installment, appraisalID uint) error
 	CreateInstallmentBillDetails(details []model.Bill_Details_Installment) error
 	GetUnpaidBillInstallments(billID uint) ([]model.Bill_Details_Installment, error)
 	UpdateInstallmentBillDetail(installments []model.Bill_Details_Installment) error
@@ -204,4 +219,36 @@ type BillRepository interface {
 
 
 	GetUnpaidBillInstallments3(billID uint) ([]model.Bill_Details_Installment, error)
+
+	GetPawnForfeitPolicy() (*model.Pawn_Forfeit_Policy, error)
+	UpdatePawnForfeitPolicy(policy *model.Pawn_Forfeit_Policy) error
+	GetActiveForfeitNotice(billID uint) (*model.Pawn_Forfeit_Notice, error)
+	GetForfeitNoticeById(id uint) (*model.Pawn_Forfeit_Notice, error)
+	GetPendingForfeitNotices() ([]model.Pawn_Forfeit_Notice, error)
+	CreateForfeitNotice(notice *model.Pawn_Forfeit_Notice) error
+	UpdateForfeitNotice(notice *model.Pawn_Forfeit_Notice) error
+	ForfeitInstallmentBill(bill *model.Bill_Header_Installment, notice *model.Pawn_Forfeit_Notice, unit *model.Inventory_Unit) error
+
+	RenewPawnBill(bill *model.Bill_Header_Installment, closed []model.Bill_Details_Installment, newDetail *model.Bill_Details_Installment, renewal *model.Pawn_Renewal, payment *model.Payment) error
+	GetPawnRenewalsByBillId(billID uint) ([]model.Pawn_Renewal, error)
+
+	CreatePrincipalChange(bill *model.Bill_Header_Installment, change *model.Pawn_Principal_Change, payment *model.Payment) error
+	GetPrincipalChangesByBillId(billID uint) ([]model.Pawn_Principal_Change, error)
+
+	SetBillStatus(billType int, billID uint, from, to int) error
+	CreateBillTransition(transition *model.Bill_Status_Transition) error
+	GetBillTransitions(billType int, billID uint) ([]model.Bill_Status_Transition, error)
+
+	GetOverdueStatusChanges(billType int, today time.Time) (toOverdue []uint, toActive []uint, err error)
+
+	CreateBillContract(bill *Bill_Header, details []Bill_Details, lines []model.Bill_Line, tradeIn *model.Trade_In, tradeInUnit *model.Inventory_Unit, downPayment *model.Payment) error
+	GetTradeIn(billType int, billID uint) (*model.Trade_In, error)
+
+	GetBillLines(billType int, billID uint) ([]model.Bill_Line, error)
+
+	RestructureBill(bill *Bill_Header, restructure *model.Bill_Restructure, closeIDs []uint, details []Bill_Details) error
+	GetBillRestructures(billType int, billID uint) ([]model.Bill_Restructure, error)
+
+	CancelBill(cancellation *model.Bill_Cancellation, transition *model.Bill_Status_Transition, unitNote string, downPayment *model.Payment, reversal *model.Payment, downPaid int) error
+	GetBillCancellation(billType int, billID uint) (*model.Bill_Cancellation, error)
 }
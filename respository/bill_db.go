@@ -285,7 +285,7 @@ func (r *billRepositoryDB) GetAllUnpaidBills() ([]model.Bill_Header, error) {
 	// ใช้ Join กับ Bill_Details เพื่อตรวจสอบงวดที่ยัง unpaid
 	err := r.db.
 		Joins("JOIN bill_details ON bill_details.bill_header_id = bill_headers.id").
-		Where("bill_details.status = ? AND bill_headers.status = ?  ", 0, 1). // 0 = ยังไม่จ่าย
+		Where("bill_details.status = ? AND bill_headers.status IN ?", 0, model.OpenBillStatuses). // 0 = ยังไม่จ่าย
 		Order("id ASC").
 		Group("bill_headers.id").
 		Find(&bills).Error
@@ -487,7 +487,7 @@ func (r *billRepositoryDB) GetBestSellingProducts(limit int) ([]BestSellingProdu
 
 	err := r.db.Model(&Bill_Header{}).
 		Select("product_id, COUNT(*) as total_sold").
-		Where("status = ?", 1). // สมมติ status=1 คือจ่ายแล้ว
+		Where("status IN ?", model.OpenBillStatuses). // สัญญาที่ยังผ่อนอยู่
 		Group("product_id").
 		Order("total_sold DESC").
 		Limit(limit).
@@ -522,9 +522,9 @@ func (r *billRepositoryDB) SumPaidAndUnpaidCounts(filter BillFilter) (paidCount
 		return
 	}
 
-	// ✅ Unpaid (status=1)
+	// ✅ Unpaid (active / overdue / defaulted)
 	if err = applyFilters(r.db.Model(&Bill_Header{})).
-		Where("status = ?", 1).
+		Where("status IN ?", model.OpenBillStatuses).
 		Count(&unpaidCount).Error; err != nil {
 		return
 	}
@@ -552,9 +552,9 @@ func (r *billRepositoryDB) GetBillSummary(filter BillFilter) (summary BillSummar
 
 	err = query.Select(`
 		COALESCE(SUM(CASE WHEN status = 2 THEN paid_amount ELSE 0 END),0) AS paid_total,
-		COALESCE(SUM(CASE WHEN status = 1 THEN remaining_amount ELSE 0 END),0) AS unpaid_total,
+		COALESCE(SUM(CASE WHEN status IN (1, 4, 5) THEN remaining_amount ELSE 0 END),0) AS unpaid_total,
 		COUNT(CASE WHEN status = 2 THEN 1 END) AS paid_count,
-		COUNT(CASE WHEN status = 1 THEN 1 END) AS unpaid_count
+		COUNT(CASE WHEN status IN (1, 4, 5) THEN 1 END) AS unpaid_count
 	`).Scan(&summary).Error
 
 	return
@@ -618,6 +618,37 @@ func (r *billRepositoryDB) CreateInstallmentBill(bill *model.Bill_Header_Install
 	return bill, nil
 }
 
+// สร้างสัญญา installment: หัวบิล + ตารางงวด + ผูกใบประเมิน (appraisalID = 0 ไม่ผูก) ใน transaction เดียว
+// ใบประเมินถูกผูกกับบิลอื่นไปก่อน → ErrAppraisalInUse และไม่สร้างบิล
+func (r *billRepositoryDB) CreateInstallmentBillContract(bill *model.Bill_Header_Installment, details []model.Bill_Details_Installment, appraisalID uint) error {
+	if len(details) == 0 {
+		return errors.New("no bill details provided")
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var existing model.Bill_Header_Installment
+		if res := tx.Where("invoice = ?", bill.Invoice).Limit(1).Find(&existing); res.Error != nil {
+			return res.Error
+		} else if res.RowsAffected > 0 {
+			return gorm.ErrDuplicatedKey
+		}
+		if err := tx.Create(bill).Error; err != nil {
+			return err
+		}
+
+		for i := range details {
+			details[i].Bill_Header_InstallmentId = bill.Id
+		}
+		if err := tx.Create(&details).Error; err != nil {
+			return err
+		}
+
+		if appraisalID == 0 {
+			return nil
+		}
+		return linkAppraisalToBill(tx, appraisalID, bill.Id)
+	})
+}
+
 func (r *billRepositoryDB) CreateInstallmentBillDetails(details []model.Bill_Details_Installment) error {
 	if len(details) == 0 {
 		return errors.New("no bill details provided")
@@ -938,9 +969,9 @@ func (r *billRepositoryDB) SumInstallmentPaidAndUnpaidCounts(filter BillFilter)
 		return
 	}
 
-	// ✅ Unpaid (status=1)
+	// ✅ Unpaid (active / overdue / defaulted)
 	if err = applyFilters(r.db.Model(&model.Bill_Header_Installment{})).
-		Where("status = ?", 1).
+		Where("status IN ?", model.OpenBillStatuses).
 		Count(&unpaidCount).Error; err != nil {
 		return
 	}
@@ -968,9 +999,9 @@ func (r *billRepositoryDB) GetInstallmentBillSummary(filter BillFilter) (summary
 
 	err = query.Select(`
 		COALESCE(SUM(CASE WHEN status = 2 THEN paid_amount ELSE 0 END),0) AS paid_total,
-		COALESCE(SUM(CASE WHEN status = 1 THEN remaining_amount ELSE 0 END),0) AS unpaid_total,
+		COALESCE(SUM(CASE WHEN status IN (1, 4, 5) THEN remaining_amount ELSE 0 END),0) AS unpaid_total,
 		COUNT(CASE WHEN status = 2 THEN 1 END) AS paid_count,
-		COUNT(CASE WHEN status = 1 THEN 1 END) AS unpaid_count
+		COUNT(CASE WHEN status IN (1, 4, 5) THEN 1 END) AS unpaid_count
 	`).Scan(&summary).Error
 
 	return
@@ -1020,7 +1051,7 @@ func (r *billRepositoryDB) GetUnPayAllBill(filter BillFilter, limit, offset int,
 		Preload("Product.Category").
 		Preload("User")
 
-	query = query.Where("bill_headers.status in ?", []int{0, 1})
+	query = query.Where("bill_headers.status in ?", append([]int{model.BillStatusDraft}, model.OpenBillStatuses...))
 	order := "DESC"
 	if sortOrder == 2 {
 		order = "ASC"
@@ -1079,7 +1110,7 @@ func (r *billRepositoryDB) CountUnpayBills(filter BillFilter) (int64, error) {
 	query := r.db.Model(&model.Bill_Header{})
 
 	// ✅ ใส่เงื่อนไขให้เหมือน GetUnPayAllBill
-	query = query.Where("status IN ?", []int{0, 1})
+	query = query.Where("status IN ?", append([]int{model.BillStatusDraft}, model.OpenBillStatuses...))
 	if len(filter.NameOrPhones) > 0 {
 		query = query.Joins("JOIN members as m ON bill_headers.member_id = m.id")
 		for i, val := range filter.NameOrPhones {
@@ -1147,7 +1178,7 @@ func (r *billRepositoryDB) GetInstallmentAllBillUnpay(
 		Preload("Product").
 		Preload("Product.Category").
 		Preload("User")
-	query = query.Where("bill_header_installments.status in ?", []int{0, 1})
+	query = query.Where("bill_header_installments.status in ?", append([]int{model.BillStatusDraft}, model.OpenBillStatuses...))
 
 	// query = query.Where("bill_header_installments.status = ?", 1)
 	order := "DESC"
@@ -1218,7 +1249,7 @@ func (r *billRepositoryDB) CountInstallmentBillsUnpay(filter BillFilter) (int64,
 	query := r.db.Model(&model.Bill_Header_Installment{})
 
 	// ✅ ต้องใส่ status เหมือน GetInstallmentAllBillUnpay
-	query = query.Where("status IN ?", []int{0, 1})
+	query = query.Where("status IN ?", append([]int{model.BillStatusDraft}, model.OpenBillStatuses...))
 	if len(filter.NameOrPhones) > 0 {
 		query = query.Joins("JOIN members as m ON bill_header_installments.member_id = m.id")
 		for i, val := range filter.NameOrPhones {
@@ -1326,7 +1357,7 @@ func (r *billRepositoryDB) GetUnpaidBill(userId string) ([]Bill_Details1, error)
 		Joins("JOIN members as m ON bh.member_id = m.id").
 		Where("m.user_id = ?", userId).
 		Where("bd.status = 0").
-		Where("bh.status = 1").
+		Where("bh.status IN ?", model.OpenBillStatuses).
 		// Where("bd.payment_date <= NOW() - INTERVAL '1 minute'").
 		Order("bd.payment_date ASC, bd.id ASC").
 		Preload("BillHeader").
@@ -1350,7 +1381,7 @@ func (r *billRepositoryDB) GetUnpaidInstallmentBill(userId string) ([]model.Bill
 		Where("m.user_id = ?", userId).
 		// Where("bd.bill_header_installment_id = ?", billID).
 		Where("bd.status = 0").
-		Where("bh.status = 1").
+		Where("bh.status IN ?", model.OpenBillStatuses).
 		// Where("bd.payment_date <= NOW() - INTERVAL '1 minute'").
 		Order("bd.payment_date ASC, bd.id ASC").
 		Preload("Bill_Header_Installment").
@@ -1608,7 +1639,7 @@ func (r *billRepositoryDB) SumPaidAmountByStatus1(filter BillFilter) (float64, e
 		Select("COALESCE(SUM(paid_amount), 0)")
 
 	// ✅ ใส่เงื่อนไขให้เหมือน filter
-	query = query.Where("status = ?", 1)
+	query = query.Where("status IN ?", model.OpenBillStatuses)
 	if len(filter.NameOrPhones) > 0 {
 		query = query.Joins("JOIN members as m ON bill_headers.member_id = m.id")
 		for i, val := range filter.NameOrPhones {
@@ -1662,7 +1693,7 @@ func (r *billRepositoryDB) SumPaidAmountByInstallmentStatus1(filter BillFilter)
 		Select("COALESCE(SUM(paid_amount), 0)")
 
 	// ✅ ใส่เงื่อนไขให้เหมือน filter
-	query = query.Where("status = ?", 1)
+	query = query.Where("status IN ?", model.OpenBillStatuses)
 	if len(filter.NameOrPhones) > 0 {
 		query = query.Joins("JOIN members as m ON bill_header_installments.member_id = m.id")
 		for i, val := range filter.NameOrPhones {
@@ -1817,7 +1848,7 @@ func (r *billRepositoryDB) SumFeeInstallmentByStatus2(filter BillFilter) (float6
 func (r *billRepositoryDB) GetAllUnpaid10DayBills() ([]model.Bill_Header_Installment, error) {
 	var bills []model.Bill_Header_Installment
 	err := r.db.Preload("BillDetailsInstallment").
-		Where("installment_day = ? AND status = ? AND term_type = ?", 10, 1, 1).
+		Where("installment_day = ? AND status IN ? AND term_type = ?", 10, model.OpenBillStatuses, 1).
 		Find(&bills).Error
 	if err != nil {
 		return nil, err
@@ -1869,3 +1900,466 @@ func (r *billRepositoryDB) CloseOldInterestInstallments(billID uint) error {
 		Where("bill_header_installment_id = ? AND is_interest_only = ? AND status != ?", billID, true, 2).
 		Update("status", 2).Error
 }
+
+func (r *billRepositoryDB) GetPawnForfeitPolicy() (*model.Pawn_Forfeit_Policy, error) {
+	policy := model.Pawn_Forfeit_Policy{Id: 1}
+	// ยังไม่เคยตั้งค่า → สร้างค่าเริ่มต้นให้
+	if err := r.db.FirstOrCreate(&policy, model.Pawn_Forfeit_Policy{Id: 1}).Error; err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (r *billRepositoryDB) UpdatePawnForfeitPolicy(policy *model.Pawn_Forfeit_Policy) error {
+	return r.db.Model(&model.Pawn_Forfeit_Policy{}).
+		Where("id = ?", policy.Id).
+		Updates(map[string]interface{}{
+			"missed_cycles":     policy.Missed_Cycles,
+			"notice_days":       policy.Notice_Days,
+			"valuation_base":    policy.Valuation_Base,
+			"valuation_percent": policy.Valuation_Percent,
+		}).Error
+}
+
+// หนังสือแจ้งที่ยังไม่ปิด (รอแจ้ง / แจ้งแล้ว) ของบิล
+func (r *billRepositoryDB) GetActiveForfeitNotice(billID uint) (*model.Pawn_Forfeit_Notice, error) {
+	var notice model.Pawn_Forfeit_Notice
+	err := r.db.
+		Where("bill_header_installment_id = ? AND status IN ?", billID, []int{0, 1}).
+		Order("id DESC").
+		First(&notice).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &notice, nil
+}
+
+func (r *billRepositoryDB) GetForfeitNoticeById(id uint) (*model.Pawn_Forfeit_Notice, error) {
+	var notice model.Pawn_Forfeit_Notice
+	err := r.db.
+		Preload("Member").
+		Preload("Bill_Header_Installment").
+		Preload("Bill_Header_Installment.Product").
+		First(&notice, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &notice, nil
+}
+
+func (r *billRepositoryDB) GetPendingForfeitNotices() ([]model.Pawn_Forfeit_Notice, error) {
+	var notices []model.Pawn_Forfeit_Notice
+	err := r.db.
+		Preload("Member").
+		Preload("Bill_Header_Installment").
+		Preload("Bill_Header_Installment.Product").
+		Where("status = ?", 0).
+		Order("forfeit_date ASC, id ASC").
+		Find(&notices).Error
+	return notices, err
+}
+
+func (r *billRepositoryDB) CreateForfeitNotice(notice *model.Pawn_Forfeit_Notice) error {
+	return r.db.Create(notice).Error
+}
+
+func (r *billRepositoryDB) UpdateForfeitNotice(notice *model.Pawn_Forfeit_Notice) error {
+	return r.db.Model(&model.Pawn_Forfeit_Notice{}).
+		Where("id = ?", notice.Id).
+		Updates(map[string]interface{}{
+			"missed_cycles": notice.Missed_Cycles,
+			"forfeit_date":  notice.Forfeit_Date,
+			"sent_at":       notice.Sent_At,
+			"status":        notice.Status,
+		}).Error
+}
+
+// ปิดบิลจำนำเป็นหลุดจำนำ + ปิดงวดค้าง + ย้ายสินค้าเข้าสต็อกขายต่อ (ทำใน transaction เดียว)
+func (r *billRepositoryDB) ForfeitInstallmentBill(bill *model.Bill_Header_Installment, notice *model.Pawn_Forfeit_Notice, unit *model.Inventory_Unit) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Bill_Header_Installment{}).
+			Where("id = ?", bill.Id).
+			Updates(map[string]interface{}{
+				"status":           bill.Status,
+				"forfeited_at":     bill.Forfeited_At,
+				"remaining_amount": bill.Remaining_Amount,
+				"note":             bill.Note,
+			}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&model.Bill_Details_Installment{}).
+			Where("bill_header_installment_id = ? AND status = ?", bill.Id, 0).
+			Update("status", 2).Error; err != nil {
+			return err
+		}
+
+		if notice != nil {
+			if err := tx.Model(&model.Pawn_Forfeit_Notice{}).
+				Where("id = ?", notice.Id).
+				Update("status", notice.Status).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(unit).Error
+	})
+}
+
+// ต่อดอกบิลจำนำใน transaction เดียว: ปิดงวดเก่า สร้างงวดใหม่ อัปเดตหัวบิล
+// บันทึกประวัติต่อดอก (นับ RenewCount ใหม่จาก ledger) เปลี่ยนฐานเงินต้นที่รอไว้ และออกใบเสร็จ
+func (r *billRepositoryDB) RenewPawnBill(bill *model.Bill_Header_Installment, closed []model.Bill_Details_Installment, newDetail *model.Bill_Details_Installment, renewal *model.Pawn_Renewal, payment *model.Payment) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, d := range closed {
+			if err := tx.Model(&model.Bill_Details_Installment{}).
+				Where("id = ?", d.Id).
+				Updates(map[string]interface{}{
+					"status":            d.Status,
+					"fee_amount":        d.Fee_Amount,
+					"installment_price": d.Installment_Price,
+					"paid_amount":       d.Paid_Amount,
+				}).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(newDetail).Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&model.Pawn_Renewal{}).
+			Where("bill_header_installment_id = ?", bill.Id).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		renewal.Renewal_No = int(count) + 1
+		if err := tx.Create(renewal).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&model.Bill_Header_Installment{}).
+			Where("id = ?", bill.Id).
+			Updates(map[string]interface{}{
+				"loan_amount":         bill.Loan_Amount,
+				"pending_loan_amount": nil,
+				"interest_amount":     bill.Interest_Amount,
+				"net_installment":     bill.Net_installment,
+				"remaining_amount":    bill.Remaining_Amount,
+				"next_due_date":       bill.NextDueDate,
+				"last_renew_date":     bill.LastRenewDate,
+				"late_day":            bill.Late_Day,
+				"fee_amount":          bill.Fee_Amount,
+				"renew_count":         renewal.Renewal_No,
+			}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.Pawn_Principal_Change{}).
+			Where("bill_header_installment_id = ? AND applied_at IS NULL", bill.Id).
+			Updates(map[string]interface{}{
+				"applied_at":      time.Now(),
+				"pawn_renewal_id": renewal.Id,
+			}).Error; err != nil {
+			return err
+		}
+
+		if payment == nil {
+			return nil
+		}
+		payment.Note = fmt.Sprintf("ต่อดอกครั้งที่ %d", renewal.Renewal_No)
+		return tx.Create(payment).Error
+	})
+}
+
+func (r *billRepositoryDB) GetPawnRenewalsByBillId(billID uint) ([]model.Pawn_Renewal, error) {
+	var renewals []model.Pawn_Renewal
+	err := r.db.
+		Preload("User").
+		Where("bill_header_installment_id = ?", billID).
+		Order("renewal_no ASC").
+		Find(&renewals).Error
+	return renewals, err
+}
+
+// บันทึกการลด/เพิ่มเงินต้น พร้อมใบเสร็จรับ/จ่ายเงิน ตั้งเงินต้นรอบถัดไปและยอดคงเหลือบนหัวบิล
+func (r *billRepositoryDB) CreatePrincipalChange(bill *model.Bill_Header_Installment, change *model.Pawn_Principal_Change, payment *model.Payment) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(payment).Error; err != nil {
+			return err
+		}
+		change.PaymentId = &payment.Id
+		if err := tx.Create(change).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.Bill_Header_Installment{}).
+			Where("id = ?", bill.Id).
+			Updates(map[string]interface{}{
+				"pending_loan_amount": bill.Pending_Loan_Amount,
+				"remaining_amount":    bill.Remaining_Amount,
+			}).Error
+	})
+}
+
+func (r *billRepositoryDB) GetPrincipalChangesByBillId(billID uint) ([]model.Pawn_Principal_Change, error) {
+	var changes []model.Pawn_Principal_Change
+	err := r.db.
+		Preload("User").
+		Where("bill_header_installment_id = ?", billID).
+		Order("created_at ASC").
+		Find(&changes).Error
+	return changes, err
+}
+
+func billHeaderTable(billType int) (header string, detail string, fk string) {
+	if billType == model.BillTypeHirePurchase {
+		return "bill_headers", "bill_details", "bill_header_id"
+	}
+	return "bill_header_installments", "bill_details_installments", "bill_header_installment_id"
+}
+
+// เปลี่ยนสถานะเฉพาะเมื่อสถานะปัจจุบันยังเป็น from (กันเปลี่ยนซ้อนกัน)
+func (r *billRepositoryDB) SetBillStatus(billType int, billID uint, from, to int) error {
+	return setBillStatus(r.db, billType, billID, from, to)
+}
+
+func setBillStatus(tx *gorm.DB, billType int, billID uint, from, to int) error {
+	header, _, _ := billHeaderTable(billType)
+	result := tx.Table(header).
+		Where("id = ? AND status = ?", billID, from).
+		Updates(map[string]interface{}{"status": to, "updated_at": time.Now()})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("สถานะบิลถูกเปลี่ยนไปแล้ว กรุณาลองใหม่")
+	}
+	return nil
+}
+
+func (r *billRepositoryDB) CreateBillTransition(transition *model.Bill_Status_Transition) error {
+	return r.db.Create(transition).Error
+}
+
+func (r *billRepositoryDB) GetBillTransitions(billType int, billID uint) ([]model.Bill_Status_Transition, error) {
+	var transitions []model.Bill_Status_Transition
+	err := r.db.
+		Preload("Actor").
+		Where("bill_type = ? AND bill_id = ?", billType, billID).
+		Order("created_at ASC, id ASC").
+		Find(&transitions).Error
+	return transitions, err
+}
+
+// หาบิลที่ต้องเปลี่ยนเป็นค้างชำระ (มีงวดเลยกำหนด) และบิลค้างชำระที่จ่ายครบงวดแล้ว
+func (r *billRepositoryDB) GetOverdueStatusChanges(billType int, today time.Time) (toOverdue []uint, toActive []uint, err error) {
+	header, detail, fk := billHeaderTable(billType)
+	overdueDetail := fmt.Sprintf("EXISTS (SELECT 1 FROM %s d WHERE d.%s = %s.id AND d.status = 0 AND DATE(d.payment_date) < ?)", detail, fk, header)
+
+	base := func() *gorm.DB {
+		q := r.db.Table(header)
+		if billType == model.BillTypeInstallment {
+			q = q.Where("deleted_at IS NULL")
+		}
+		return q
+	}
+
+	if err = base().
+		Where("status = ?", model.BillStatusActive).
+		Where(overdueDetail, today).
+		Pluck("id", &toOverdue).Error; err != nil {
+		return
+	}
+
+	err = base().
+		Where("status = ?", model.BillStatusOverdue).
+		Where("NOT "+overdueDetail, today).
+		Pluck("id", &toActive).Error
+	return
+}
+
+// สร้างสัญญาผ่อน: หัวบิล + ตารางงวด + รายการสินค้า (ตัดสต็อกอุปกรณ์เสริม) + เครื่องเทิร์น + ใบเสร็จเงินดาวน์ ใน transaction เดียว
+func (r *billRepositoryDB) CreateBillContract(bill *Bill_Header, details []Bill_Details, lines []model.Bill_Line, tradeIn *model.Trade_In, tradeInUnit *model.Inventory_Unit, downPayment *model.Payment) error {
+	if len(details) == 0 {
+		return errors.New("no bill details provided")
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var existing Bill_Header
+		if res := tx.Where("invoice = ?", bill.Invoice).Limit(1).Find(&existing); res.Error != nil {
+			return res.Error
+		} else if res.RowsAffected > 0 {
+			return gorm.ErrDuplicatedKey
+		}
+		if err := tx.Create(bill).Error; err != nil {
+			return err
+		}
+
+		for i := range details {
+			details[i].Bill_HeaderId = bill.Id
+		}
+		if err := tx.Create(&details).Error; err != nil {
+			return err
+		}
+
+		if len(lines) > 0 {
+			for i := range lines {
+				lines[i].Bill_Id = bill.Id
+			}
+			if err := tx.Create(&lines).Error; err != nil {
+				return err
+			}
+		}
+		// อุปกรณ์เสริม (ทั้งรวมในยอดผ่อนและจ่ายสด) ตัดสต็อกเหมือนขายสด
+		for _, line := range lines {
+			if line.Line_Type != model.SaleLineAccessory {
+				continue
+			}
+			if err := deductProductStock(tx, line.ProductId, line.Quantity); err != nil {
+				return err
+			}
+		}
+
+		if tradeIn != nil {
+			tradeIn.Bill_Id = bill.Id
+			if err := createTradeIn(tx, tradeIn, tradeInUnit); err != nil {
+				return err
+			}
+		}
+
+		if downPayment != nil {
+			downPayment.Bill_Id = bill.Id
+			return tx.Create(downPayment).Error
+		}
+		return nil
+	})
+}
+
+func (r *billRepositoryDB) GetTradeIn(billType int, billID uint) (*model.Trade_In, error) {
+	return findTradeIn(r.db, billType, billID)
+}
+
+func (r *billRepositoryDB) GetBillLines(billType int, billID uint) ([]model.Bill_Line, error) {
+	var lines []model.Bill_Line
+	err := r.db.
+		Preload("Product").
+		Where("bill_type = ? AND bill_id = ?", billType, billID).
+		Order("line_type ASC, id ASC").
+		Find(&lines).Error
+	return lines, err
+}
+
+// ปิดงวดที่ยังค้างของตารางเดิม สร้างตารางผ่อนชุดใหม่ และอัปเดตหัวบิลใน transaction เดียว
+func (r *billRepositoryDB) RestructureBill(bill *Bill_Header, restructure *model.Bill_Restructure, closeIDs []uint, details []Bill_Details) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&Bill_Details{}).
+			Where("bill_header_id = ? AND id IN ? AND status = ?", bill.Id, closeIDs, model.BillDetailStatusUnpaid).
+			Update("status", model.BillDetailStatusRestructured)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected != int64(len(closeIDs)) {
+			return fmt.Errorf("งวดของบิล %d มีการเปลี่ยนแปลงระหว่างปรับโครงสร้าง กรุณาลองใหม่", bill.Id)
+		}
+
+		if err := tx.Create(&details).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&Bill_Header{}).
+			Where("id = ?", bill.Id).
+			Updates(map[string]interface{}{
+				"net_installment":        bill.Net_installment,
+				"installments_month":     bill.Installments_Month,
+				"total_price":            bill.Total_Price,
+				"remaining_amount":       bill.Remaining_Amount,
+				"total_installments":     bill.Total_Installments,
+				"remaining_installments": bill.Remaining_Installments,
+				"late_day":               0,
+				"schedule_version":       bill.Schedule_Version,
+				"status":                 bill.Status,
+			}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(restructure).Error
+	})
+}
+
+func (r *billRepositoryDB) GetBillRestructures(billType int, billID uint) ([]model.Bill_Restructure, error) {
+	var restructures []model.Bill_Restructure
+	err := r.db.
+		Preload("Approver").
+		Where("bill_type = ? AND bill_id = ?", billType, billID).
+		Order("created_at ASC").
+		Find(&restructures).Error
+	return restructures, err
+}
+
+// ยกเลิกสัญญาใน transaction เดียว: คืนเครื่องเทิร์น + เปลี่ยนสถานะ + กลับรายการใบเสร็จเงินดาวน์ + บันทึกการยกเลิก
+// ถ้าขั้นใดไม่สำเร็จ (เช่น เครื่องเทิร์นถูกขายออกไปแล้ว) สัญญาจะไม่ถูกยกเลิก
+// downPaid = เงินดาวน์ที่นับอยู่ใน paid_amount ของหัวบิล หักออกเมื่อคืนเงินดาวน์
+func (r *billRepositoryDB) CancelBill(cancellation *model.Bill_Cancellation, transition *model.Bill_Status_Transition, unitNote string, downPayment *model.Payment, reversal *model.Payment, downPaid int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if cancellation.Inventory_UnitId != nil {
+			if err := returnInventoryUnit(tx, *cancellation.Inventory_UnitId, unitNote); err != nil {
+				return err
+			}
+		}
+
+		if err := setBillStatus(tx, transition.Bill_Type, transition.Bill_Id, transition.From_Status, transition.To_Status); err != nil {
+			return err
+		}
+		if err := tx.Create(transition).Error; err != nil {
+			return err
+		}
+
+		if reversal != nil {
+			if err := markPaymentReversed(tx, downPayment); err != nil {
+				return err
+			}
+			if err := tx.Create(reversal).Error; err != nil {
+				return err
+			}
+		}
+		if downPaid > 0 {
+			if err := tx.Model(&Bill_Header{}).
+				Where("id = ?", transition.Bill_Id).
+				Update("paid_amount", gorm.Expr("GREATEST(paid_amount - ?, 0)", downPaid)).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(cancellation).Error
+	})
+}
+
+func (r *billRepositoryDB) GetBillCancellation(billType int, billID uint) (*model.Bill_Cancellation, error) {
+	var cancellation model.Bill_Cancellation
+	err := r.db.
+		Preload("Actor").
+		Where("bill_type = ? AND bill_id = ?", billType, billID).
+		First(&cancellation).Error
+	if err != nil {
+		return nil, err
+	}
+	return &cancellation, nil
+}
+
+// คืนเครื่องให้เจ้าของเดิม ได้เฉพาะเครื่องที่ยังอยู่ในสต็อก (ยังไม่ถูกขายออก)
+func returnInventoryUnit(tx *gorm.DB, unitID uint, note string) error {
+	result := tx.Model(&model.Inventory_Unit{}).
+		Where("id = ? AND status = ?", unitID, model.InventoryUnitAvailable).
+		Updates(map[string]interface{}{
+			"status":     model.InventoryUnitReturned,
+			"note":       note,
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("เครื่อง #%d ไม่อยู่ในสต็อกแล้ว (อาจถูกขายไปแล้ว)", unitID)
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+package respository
+
+import "rrmobile/model"
+
+type CashSaleRepository interface {
+	GetLastCashSaleInvByYear(yearSuffix string) (string, error)
+	CreateCashSale(sale *model.Cash_Sale, tradeIn *model.Trade_In, tradeInUnit *model.Inventory_Unit) error
+	GetCashSaleById(id uint) (*model.Cash_Sale, error)
+	GetCashSaleTradeIn(saleID uint) (*model.Trade_In, error)
+}
@@ -0,0 +1,139 @@
+package respository
+
+import (
+	"errors"
+	"fmt"
+	"rrmobile/model"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type cashSaleRepositoryDB struct {
+	db *gorm.DB
+}
+
+func NewCashSaleRepositoryDB(db *gorm.DB) CashSaleRepository {
+	return &cashSaleRepositoryDB{db: db}
+}
+
+func (r *cashSaleRepositoryDB) GetLastCashSaleInvByYear(yearSuffix string) (string, error) {
+	var lastInv string
+	err := r.db.Model(&model.Cash_Sale{}).
+		Select("invoice").
+		Where("invoice LIKE ?", fmt.Sprintf("CS/%%-%s-%%", yearSuffix)).
+		Order("invoice DESC").
+		Limit(1).
+		Scan(&lastInv).Error
+
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	return lastInv, nil
+}
+
+func GenerateCashSaleInv(r CashSaleRepository) (string, error) {
+	now := time.Now()
+	month := fmt.Sprintf("%02d", int(now.Month()))
+	year := now.Year() + 543
+	yearSuffix := fmt.Sprintf("%02d", year%100)
+
+	lastInv, err := r.GetLastCashSaleInvByYear(yearSuffix)
+	if err != nil {
+		return "", err
+	}
+
+	nextSeq := 1
+	if lastInv != "" {
+		// ตัวอย่าง lastInv = "CS/09-68-0001"
+		parts := strings.Split(lastInv, "-")
+		if len(parts) < 3 {
+			return "", fmt.Errorf("invalid invoice format: %s", lastInv)
+		}
+		num, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return "", fmt.Errorf("invalid sequence number in invoice: %s", lastInv)
+		}
+		nextSeq = num + 1
+	}
+
+	return fmt.Sprintf("CS/%s-%s-%04d", month, yearSuffix, nextSeq), nil
+}
+
+func (r *cashSaleRepositoryDB) CreateCashSale(sale *model.Cash_Sale, tradeIn *model.Trade_In, tradeInUnit *model.Inventory_Unit) error {
+	// สร้างบิล + รายการ + การรับเงิน แล้วตัดสต็อกใน transaction เดียว
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(sale).Error; err != nil {
+			return err
+		}
+
+		for _, line := range sale.Lines {
+			if line.Inventory_UnitId != nil {
+				res := tx.Model(&model.Inventory_Unit{}).
+					Where("id = ? AND status = 0", *line.Inventory_UnitId).
+					Updates(map[string]interface{}{
+						"status":       1,
+						"cash_sale_id": sale.Id,
+					})
+				if res.Error != nil {
+					return res.Error
+				}
+				if res.RowsAffected == 0 {
+					return fmt.Errorf("เครื่อง #%d ไม่อยู่ในสถานะพร้อมขาย", *line.Inventory_UnitId)
+				}
+				continue
+			}
+			// ประกัน / บริการ ไม่มีสต็อกให้ตัด
+			if line.Line_Type != model.SaleLineAccessory {
+				continue
+			}
+
+			if err := deductProductStock(tx, line.ProductId, line.Quantity); err != nil {
+				return err
+			}
+		}
+
+		if tradeIn != nil {
+			tradeIn.Bill_Id = sale.Id
+			if err := createTradeIn(tx, tradeIn, tradeInUnit); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ตัดสต็อกอุปกรณ์เสริม (เรียกภายใน transaction) สต็อกไม่พอให้ยกเลิกทั้งรายการ
+func deductProductStock(tx *gorm.DB, productID uint, quantity int) error {
+	res := tx.Model(&model.Product_Stock{}).
+		Where("product_id = ? AND quantity >= ?", productID, quantity).
+		Update("quantity", gorm.Expr("quantity - ?", quantity))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("สต็อกสินค้า #%d ไม่พอขาย", productID)
+	}
+	return nil
+}
+
+func (r *cashSaleRepositoryDB) GetCashSaleById(id uint) (*model.Cash_Sale, error) {
+	var sale model.Cash_Sale
+	err := r.db.
+		Preload("Member").
+		Preload("User").
+		Preload("Lines.Product").
+		Preload("Lines.Inventory_Unit").
+		Preload("Tenders").
+		First(&sale, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &sale, nil
+}
+
+func (r *cashSaleRepositoryDB) GetCashSaleTradeIn(saleID uint) (*model.Trade_In, error) {
+	return findTradeIn(r.db, model.BillTypeCashSale, saleID)
+}
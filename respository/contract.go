@@ -0,0 +1,72 @@
+package respository
+
+import "time"
+
+// ฟิลเตอร์รายการสัญญารวมทุกประเภท (ผ่อน / จำนำ / ขายสด)
+type ContractFilter struct {
+	Bill_Type int // 0 = ทุกประเภท
+	Status    *int
+	MemberId  *uint
+	Search    string // เลขบิล / ชื่อ / เบอร์โทร
+	DateFrom  *time.Time
+	DateTo    *time.Time
+}
+
+type ContractRow struct {
+	Bill_Type          int
+	Id                 uint
+	Invoice            string
+	CreatedAt          time.Time
+	MemberId           uint
+	Member_Full_Name   string
+	User_Id            int
+	ProductId          uint
+	Product_Name       string
+	Total_Price        float64
+	Paid_Amount        float64
+	Remaining_Amount   float64
+	Total_Installments int
+	Paid_Installments  int
+	Status             int
+	Note               string
+}
+
+type ContractSummaryRow struct {
+	Bill_Type          int
+	Contract_Count     int64
+	Open_Count         int64
+	Restructured_Count int64
+	Total_Price        float64
+	Paid_Amount        float64
+	Remaining_Amount   float64
+}
+
+type DailySalesRow struct {
+	Day            time.Time
+	Bill_Type      int
+	Contract_Count int64
+	Sales_Amount   float64
+}
+
+type DailyTakingsRow struct {
+	Day    time.Time
+	Method string
+	Amount float64
+}
+
+type DailyLineRevenueRow struct {
+	Day       time.Time
+	Line_Type int
+	Quantity  int64
+	Amount    float64
+}
+
+type ContractRepository interface {
+	GetContracts(filter ContractFilter, limit, offset int) ([]ContractRow, error)
+	CountContracts(filter ContractFilter) (int64, error)
+	GetContractSummary(filter ContractFilter) ([]ContractSummaryRow, error)
+
+	GetDailySales(dateFrom, dateTo *time.Time) ([]DailySalesRow, error)
+	GetDailyTakings(dateFrom, dateTo *time.Time) ([]DailyTakingsRow, error)
+	GetDailyLineRevenue(dateFrom, dateTo *time.Time) ([]DailyLineRevenueRow, error)
+}
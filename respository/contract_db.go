@@ -0,0 +1,212 @@
+package respository
+
+import (
+	"rrmobile/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// รวมหัวบิลทั้ง 3 ตารางให้อยู่ในรูปแบบเดียวกัน
+const contractUnionSQL = `(
+	SELECT 1 AS bill_type, bh.id, bh.invoice, bh.created_at, bh.member_id, bh.user_id, bh.product_id,
+		bh.total_price, bh.paid_amount::float8 AS paid_amount, bh.remaining_amount,
+		bh.total_installments, bh.paid_installments, bh.status, bh.note
+	FROM bill_headers bh
+	WHERE bh.deleted_at IS NULL
+	UNION ALL
+	SELECT 2, bi.id, bi.invoice, bi.created_at, bi.member_id, bi.user_id, bi.product_id,
+		bi.total_price, bi.paid_amount::float8, bi.remaining_amount,
+		bi.total_installments, bi.paid_installments, bi.status, bi.note
+	FROM bill_header_installments bi
+	WHERE bi.deleted_at IS NULL
+	UNION ALL
+	SELECT 3, cs.id, cs.invoice, cs.created_at, COALESCE(cs.member_id, 0), cs.user_id, 0,
+		cs.total_price::float8, cs.paid_amount::float8, 0,
+		0, 0, cs.status, cs.note
+	FROM cash_sales cs
+) AS c`
+
+type contractRepositoryDB struct {
+	db *gorm.DB
+}
+
+func NewContractRepositoryDB(db *gorm.DB) ContractRepository {
+	return &contractRepositoryDB{db: db}
+}
+
+func (r *contractRepositoryDB) baseQuery(filter ContractFilter) *gorm.DB {
+	query := r.db.Table(contractUnionSQL).
+		Joins("LEFT JOIN members m ON m.id = c.member_id").
+		Joins("LEFT JOIN products p ON p.id = c.product_id")
+
+	if filter.Bill_Type > 0 {
+		query = query.Where("c.bill_type = ?", filter.Bill_Type)
+	}
+	if filter.Status != nil {
+		query = query.Where("c.status = ?", *filter.Status)
+	}
+	if filter.MemberId != nil {
+		query = query.Where("c.member_id = ?", *filter.MemberId)
+	}
+	if filter.Search != "" {
+		pattern := "%" + filter.Search + "%"
+		query = query.Where("c.invoice ILIKE ? OR m.full_name ILIKE ? OR m.tel ILIKE ?", pattern, pattern, pattern)
+	}
+	if filter.DateFrom != nil {
+		query = query.Where("c.created_at >= ?", *filter.DateFrom)
+	}
+	if filter.DateTo != nil {
+		query = query.Where("c.created_at < ?", filter.DateTo.AddDate(0, 0, 1))
+	}
+	return query
+}
+
+func (r *contractRepositoryDB) GetContracts(filter ContractFilter, limit, offset int) ([]ContractRow, error) {
+	var rows []ContractRow
+	query := r.baseQuery(filter).
+		Select(`c.bill_type, c.id, c.invoice, c.created_at, c.member_id,
+			COALESCE(m.full_name, '') AS member_full_name, c.user_id, c.product_id,
+			COALESCE(p.name, '') AS product_name, c.total_price, c.paid_amount, c.remaining_amount,
+			c.total_installments, c.paid_installments, c.status, COALESCE(c.note, '') AS note`).
+		Order("c.created_at DESC, c.bill_type ASC, c.id DESC")
+	if limit > 0 {
+		query = query.Limit(limit).Offset(offset)
+	}
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (r *contractRepositoryDB) CountContracts(filter ContractFilter) (int64, error) {
+	var count int64
+	if err := r.baseQuery(filter).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *contractRepositoryDB) GetContractSummary(filter ContractFilter) ([]ContractSummaryRow, error) {
+	var rows []ContractSummaryRow
+	err := r.baseQuery(filter).
+		Select(`c.bill_type,
+			COUNT(*) AS contract_count,
+			COUNT(CASE WHEN c.status IN ? THEN 1 END) AS open_count,
+			COUNT(CASE WHEN EXISTS (
+				SELECT 1 FROM bill_restructures br WHERE br.bill_type = c.bill_type AND br.bill_id = c.id
+			) THEN 1 END) AS restructured_count,
+			COALESCE(SUM(c.total_price), 0) AS total_price,
+			COALESCE(SUM(c.paid_amount), 0) AS paid_amount,
+			COALESCE(SUM(CASE WHEN c.status IN ? THEN c.remaining_amount ELSE 0 END), 0) AS remaining_amount`,
+			model.OpenBillStatuses, model.OpenBillStatuses).
+		Group("c.bill_type").
+		Order("c.bill_type ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// ยอดขายรายวันแยกตามประเภทสัญญา (ไม่นับบิลร่าง/ยกเลิก)
+func (r *contractRepositoryDB) GetDailySales(dateFrom, dateTo *time.Time) ([]DailySalesRow, error) {
+	var rows []DailySalesRow
+	err := r.baseQuery(ContractFilter{DateFrom: dateFrom, DateTo: dateTo}).
+		Where("c.status NOT IN ?", []int{model.BillStatusDraft, model.BillStatusCancelled}).
+		Select(`DATE(c.created_at) AS day, c.bill_type,
+			COUNT(*) AS contract_count,
+			COALESCE(SUM(c.total_price), 0) AS sales_amount`).
+		Group("DATE(c.created_at), c.bill_type").
+		Order("day ASC, c.bill_type ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// เงินที่รับเข้าร้านรายวันแยกตามช่องทาง
+func (r *contractRepositoryDB) GetDailyTakings(dateFrom, dateTo *time.Time) ([]DailyTakingsRow, error) {
+	var rows []DailyTakingsRow
+	query := r.db.Table("("+takingsUnionSQL+") AS t", model.BillStatusCancelled)
+	if dateFrom != nil {
+		query = query.Where("t.created_at >= ?", *dateFrom)
+	}
+	if dateTo != nil {
+		query = query.Where("t.created_at < ?", dateTo.AddDate(0, 0, 1))
+	}
+	err := query.
+		Select("DATE(t.created_at) AS day, t.method, COALESCE(SUM(t.amount), 0) AS amount").
+		Group("DATE(t.created_at), t.method").
+		Order("day ASC, t.method ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// เงินเข้าร้าน: ช่องทางชำระของบิลขายสด + ช่องทางรับเงินของใบเสร็จสัญญา (เงินดาวน์ ค่างวด ต่อดอก)
+// ใบเสร็จที่ถูกกลับรายการยังนับในวันเดิม ส่วนรายการคืนเงิน / คืนเครดิต (ยอดติดลบ) หักในวันที่คืน
+// ใบเสร็จเก่าที่ยังไม่มี payment_tenders ใช้ช่องทางที่หัวใบเสร็จ
+const takingsUnionSQL = `
+	SELECT t.created_at, t.method, t.amount
+	FROM cash_sale_tenders t
+	JOIN cash_sales cs ON cs.id = t.cash_sale_id
+	WHERE cs.status <> ?
+	UNION ALL
+	SELECT p.created_at, pt.method, pt.amount
+	FROM payment_tenders pt
+	JOIN payments p ON p.id = pt.payment_id
+	UNION ALL
+	SELECT p.created_at, p.method, p.amount
+	FROM payments p
+	WHERE NOT EXISTS (SELECT 1 FROM payment_tenders pt WHERE pt.payment_id = p.id)
+`
+
+// รายการสินค้าของสัญญาผ่อนและบิลขายสด (ไม่รวมจำนำ เพราะเป็นเงินกู้ ไม่ใช่ยอดขาย)
+// สัญญาผ่อนเก่าที่ยังไม่มี bill_lines นับทั้งสัญญาเป็นรายการเครื่อง
+const lineRevenueUnionSQL = `
+	SELECT bh.created_at, bl.line_type, bl.quantity, bl.amount
+	FROM bill_lines bl
+	JOIN bill_headers bh ON bh.id = bl.bill_id AND bl.bill_type = ?
+	WHERE bh.status NOT IN ?
+	UNION ALL
+	SELECT bh.created_at, 1 AS line_type, 1 AS quantity, bh.total_price AS amount
+	FROM bill_headers bh
+	WHERE bh.status NOT IN ?
+		AND NOT EXISTS (SELECT 1 FROM bill_lines bl WHERE bl.bill_type = ? AND bl.bill_id = bh.id)
+	UNION ALL
+	SELECT cs.created_at, csl.line_type, csl.quantity, csl.amount
+	FROM cash_sale_lines csl
+	JOIN cash_sales cs ON cs.id = csl.cash_sale_id
+	WHERE cs.status NOT IN ?
+`
+
+// รายได้รายวันแยกตามประเภทรายการ (เครื่อง / อุปกรณ์เสริม / ประกัน / บริการ)
+func (r *contractRepositoryDB) GetDailyLineRevenue(dateFrom, dateTo *time.Time) ([]DailyLineRevenueRow, error) {
+	var rows []DailyLineRevenueRow
+	closed := []int{model.BillStatusDraft, model.BillStatusCancelled}
+	query := r.db.Table("("+lineRevenueUnionSQL+") AS l",
+		model.BillTypeHirePurchase, closed,
+		closed, model.BillTypeHirePurchase,
+		closed)
+	if dateFrom != nil {
+		query = query.Where("l.created_at >= ?", *dateFrom)
+	}
+	if dateTo != nil {
+		query = query.Where("l.created_at < ?", dateTo.AddDate(0, 0, 1))
+	}
+	err := query.
+		Select(`DATE(l.created_at) AS day, l.line_type,
+			COALESCE(SUM(l.quantity), 0) AS quantity,
+			COALESCE(SUM(l.amount), 0) AS amount`).
+		Group("DATE(l.created_at), l.line_type").
+		Order("day ASC, l.line_type ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
@@ -0,0 +1,20 @@
+package respository
+
+import "rrmobile/model"
+
+type InventoryFilter struct {
+	Source    *int
+	Status    *int
+	ProductId *uint
+}
+
+type InventoryRepository interface {
+	GetInventoryUnits(filter InventoryFilter, limit, offset int) ([]model.Inventory_Unit, error)
+	CountInventoryUnits(filter InventoryFilter) (int64, error)
+	GetInventoryUnitById(id uint) (*model.Inventory_Unit, error)
+	UpdateInventoryUnit(unit *model.Inventory_Unit) error
+	CreateInventoryUnit(unit *model.Inventory_Unit) error
+
+	GetProductStocks() ([]model.Product_Stock, error)
+	AdjustProductStock(productID uint, delta int) (*model.Product_Stock, error)
+}
@@ -0,0 +1,113 @@
+package respository
+
+import (
+	"errors"
+	"rrmobile/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type inventoryRepositoryDB struct {
+	db *gorm.DB
+}
+
+func NewInventoryRepositoryDB(db *gorm.DB) InventoryRepository {
+	return &inventoryRepositoryDB{db: db}
+}
+
+func (r *inventoryRepositoryDB) applyFilter(query *gorm.DB, filter InventoryFilter) *gorm.DB {
+	if filter.Source != nil {
+		query = query.Where("source = ?", *filter.Source)
+	}
+	if filter.Status != nil {
+		query = query.Where("status = ?", *filter.Status)
+	}
+	if filter.ProductId != nil {
+		query = query.Where("product_id = ?", *filter.ProductId)
+	}
+	return query
+}
+
+func (r *inventoryRepositoryDB) GetInventoryUnits(filter InventoryFilter, limit, offset int) ([]model.Inventory_Unit, error) {
+	var units []model.Inventory_Unit
+	query := r.applyFilter(r.db.Model(&model.Inventory_Unit{}), filter).
+		Preload("Product").
+		Preload("Product.Category").
+		Order("created_at DESC")
+	if limit > 0 {
+		query = query.Limit(limit).Offset(offset)
+	}
+	if err := query.Find(&units).Error; err != nil {
+		return nil, err
+	}
+	return units, nil
+}
+
+func (r *inventoryRepositoryDB) CountInventoryUnits(filter InventoryFilter) (int64, error) {
+	var count int64
+	if err := r.applyFilter(r.db.Model(&model.Inventory_Unit{}), filter).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *inventoryRepositoryDB) GetInventoryUnitById(id uint) (*model.Inventory_Unit, error) {
+	var unit model.Inventory_Unit
+	if err := r.db.Preload("Product").Preload("Product.Category").First(&unit, id).Error; err != nil {
+		return nil, err
+	}
+	return &unit, nil
+}
+
+func (r *inventoryRepositoryDB) UpdateInventoryUnit(unit *model.Inventory_Unit) error {
+	return r.db.Model(&model.Inventory_Unit{}).
+		Where("id = ?", unit.Id).
+		Updates(map[string]interface{}{
+			"valuation": unit.Valuation,
+			"status":    unit.Status,
+			"note":      unit.Note,
+		}).Error
+}
+
+func (r *inventoryRepositoryDB) CreateInventoryUnit(unit *model.Inventory_Unit) error {
+	return r.db.Create(unit).Error
+}
+
+func (r *inventoryRepositoryDB) GetProductStocks() ([]model.Product_Stock, error) {
+	var stocks []model.Product_Stock
+	err := r.db.
+		Preload("Product").
+		Preload("Product.Category").
+		Order("product_id ASC").
+		Find(&stocks).Error
+	if err != nil {
+		return nil, err
+	}
+	return stocks, nil
+}
+
+// เพิ่ม/ลดจำนวนสต็อก (delta ติดลบ = ตัดออก) ไม่ให้ติดลบ
+func (r *inventoryRepositoryDB) AdjustProductStock(productID uint, delta int) (*model.Product_Stock, error) {
+	var stock model.Product_Stock
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&model.Product_Stock{ProductId: productID}).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("product_id = ?", productID).
+			First(&stock).Error; err != nil {
+			return err
+		}
+		if stock.Quantity+delta < 0 {
+			return errors.New("สต็อกไม่พอ")
+		}
+		stock.Quantity += delta
+		return tx.Model(&stock).Update("quantity", stock.Quantity).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &stock, nil
+}
@@ -0,0 +1,22 @@
+package respository
+
+import "rrmobile/model"
+
+type PaymentRepository interface {
+	GetLastReceiptByYear(yearSuffix string) (string, error)
+	GetAllocationPolicy() (*model.Payment_Allocation_Policy, error)
+	UpdateAllocationPolicy(policy *model.Payment_Allocation_Policy) error
+
+	ApplyBillPayment(bill *Bill_Header, details []Bill_Details, payment *model.Payment) error
+	ApplyInstallmentBillPayment(bill *model.Bill_Header_Installment, details []model.Bill_Details_Installment, payment *model.Payment) error
+	CreatePayment(payment *model.Payment) error
+	ReverseBillPayment(bill *Bill_Header, details []Bill_Details, original *model.Payment, reversal *model.Payment) error
+	ReverseInstallmentBillPayment(bill *model.Bill_Header_Installment, details []model.Bill_Details_Installment, original *model.Payment, reversal *model.Payment) error
+	GetPaymentById(id uint) (*model.Payment, error)
+	GetPaymentsByBill(billType int, billID uint) ([]model.Payment, error)
+
+	RefundCredit(refund *model.Payment, movement *model.Credit_Movement) error
+	TransferCredit(out *model.Credit_Movement, in *model.Credit_Movement) error
+	CreateCreditMovement(movement *model.Credit_Movement) error
+	GetCreditMovements(billType int, billID uint) ([]model.Credit_Movement, error)
+}
@@ -0,0 +1,303 @@
+package respository
+
+import (
+	"errors"
+	"fmt"
+	"rrmobile/model"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type paymentRepositoryDB struct {
+	db *gorm.DB
+}
+
+func NewPaymentRepositoryDB(db *gorm.DB) PaymentRepository {
+	return &paymentRepositoryDB{db: db}
+}
+
+func (r *paymentRepositoryDB) GetLastReceiptByYear(yearSuffix string) (string, error) {
+	var lastReceipt string
+	err := r.db.Model(&model.Payment{}).
+		Select("receipt_no").
+		Where("receipt_no LIKE ?", fmt.Sprintf("RC/%%-%s-%%", yearSuffix)).
+		Order("receipt_no DESC").
+		Limit(1).
+		Scan(&lastReceipt).Error
+
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	return lastReceipt, nil
+}
+
+func GeneratePaymentReceipt(r PaymentRepository) (string, error) {
+	now := time.Now()
+	month := fmt.Sprintf("%02d", int(now.Month()))
+	year := now.Year() + 543
+	yearSuffix := fmt.Sprintf("%02d", year%100)
+
+	lastReceipt, err := r.GetLastReceiptByYear(yearSuffix)
+	if err != nil {
+		return "", err
+	}
+
+	nextSeq := 1
+	if lastReceipt != "" {
+		// ตัวอย่าง lastReceipt = "RC/09-68-0001"
+		parts := strings.Split(lastReceipt, "-")
+		if len(parts) < 3 {
+			return "", fmt.Errorf("invalid receipt format: %s", lastReceipt)
+		}
+		num, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return "", fmt.Errorf("invalid sequence number in receipt: %s", lastReceipt)
+		}
+		nextSeq = num + 1
+	}
+
+	return fmt.Sprintf("RC/%s-%s-%04d", month, yearSuffix, nextSeq), nil
+}
+
+func (r *paymentRepositoryDB) GetAllocationPolicy() (*model.Payment_Allocation_Policy, error) {
+	policy := model.Payment_Allocation_Policy{Id: 1}
+	// ยังไม่เคยตั้งค่า → สร้างค่าเริ่มต้นให้
+	if err := r.db.FirstOrCreate(&policy, model.Payment_Allocation_Policy{Id: 1}).Error; err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (r *paymentRepositoryDB) UpdateAllocationPolicy(policy *model.Payment_Allocation_Policy) error {
+	return r.db.Model(&model.Payment_Allocation_Policy{}).
+		Where("id = ?", policy.Id).
+		Updates(map[string]interface{}{
+			"component_order":   policy.Component_Order,
+			"scope":             policy.Scope,
+			"installment_order": policy.Installment_Order,
+		}).Error
+}
+
+// บันทึกผลการตัดเงิน: งวดที่ถูกตัด + หัวบิล + ใบเสร็จพร้อมรายการตัดเงิน ใน transaction เดียว
+func (r *paymentRepositoryDB) ApplyBillPayment(bill *Bill_Header, details []Bill_Details, payment *model.Payment) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := saveBillPaymentState(tx, bill, details); err != nil {
+			return err
+		}
+		return tx.Create(payment).Error
+	})
+}
+
+// กลับรายการใบเสร็จค่างวด: คืนสถานะงวด + หัวบิล แล้วปิดใบเสร็จเดิมพร้อมบันทึกรายการคืนเงิน
+func (r *paymentRepositoryDB) ReverseBillPayment(bill *Bill_Header, details []Bill_Details, original *model.Payment, reversal *model.Payment) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := saveBillPaymentState(tx, bill, details); err != nil {
+			return err
+		}
+		if err := markPaymentReversed(tx, original); err != nil {
+			return err
+		}
+		return tx.Create(reversal).Error
+	})
+}
+
+func saveBillPaymentState(tx *gorm.DB, bill *Bill_Header, details []Bill_Details) error {
+	for _, d := range details {
+		if err := tx.Model(&Bill_Details{}).
+			Where("id = ?", d.Id).
+			Updates(map[string]interface{}{
+				"paid_amount":     d.Paid_Amount,
+				"status":          d.Status,
+				"credit_balance":  d.Credit_Balance,
+				"interest_amount": d.Interest_Amount,
+				"fee_paid":        d.Fee_Paid,
+				"interest_paid":   d.Interest_Paid,
+				"principal_paid":  d.Principal_Paid,
+				"updated_at":      time.Now(),
+			}).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.Model(&Bill_Header{}).
+		Where("id = ?", bill.Id).
+		Updates(map[string]interface{}{
+			"paid_amount":            bill.Paid_Amount,
+			"remaining_amount":       bill.Remaining_Amount,
+			"credit_balance":         bill.Credit_Balance,
+			"paid_installments":      bill.Paid_Installments,
+			"remaining_installments": bill.Remaining_Installments,
+			"status":                 bill.Status,
+		}).Error
+}
+
+// บันทึกผลการตัดเงินของสัญญาจำนำ / ผ่อนซื้อ (installment) เหมือน ApplyBillPayment
+func (r *paymentRepositoryDB) ApplyInstallmentBillPayment(bill *model.Bill_Header_Installment, details []model.Bill_Details_Installment, payment *model.Payment) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := saveInstallmentBillPaymentState(tx, bill, details); err != nil {
+			return err
+		}
+		return tx.Create(payment).Error
+	})
+}
+
+func (r *paymentRepositoryDB) ReverseInstallmentBillPayment(bill *model.Bill_Header_Installment, details []model.Bill_Details_Installment, original *model.Payment, reversal *model.Payment) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := saveInstallmentBillPaymentState(tx, bill, details); err != nil {
+			return err
+		}
+		if err := markPaymentReversed(tx, original); err != nil {
+			return err
+		}
+		return tx.Create(reversal).Error
+	})
+}
+
+func saveInstallmentBillPaymentState(tx *gorm.DB, bill *model.Bill_Header_Installment, details []model.Bill_Details_Installment) error {
+	for _, d := range details {
+		if err := tx.Model(&model.Bill_Details_Installment{}).
+			Where("id = ?", d.Id).
+			Updates(map[string]interface{}{
+				"installment_price": d.Installment_Price,
+				"paid_amount":       d.Paid_Amount,
+				"status":            d.Status,
+				"credit_balance":    d.Credit_Balance,
+				"updated_at":        time.Now(),
+			}).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.Model(&model.Bill_Header_Installment{}).
+		Where("id = ?", bill.Id).
+		Updates(map[string]interface{}{
+			"paid_amount":            bill.Paid_Amount,
+			"remaining_amount":       bill.Remaining_Amount,
+			"credit_balance":         bill.Credit_Balance,
+			"paid_installments":      bill.Paid_Installments,
+			"remaining_installments": bill.Remaining_Installments,
+			"status":                 bill.Status,
+		}).Error
+}
+
+func markPaymentReversed(tx *gorm.DB, original *model.Payment) error {
+	now := time.Now()
+	res := tx.Model(&model.Payment{}).
+		Where("id = ? AND status = ?", original.Id, model.PaymentStatusPosted).
+		Updates(map[string]interface{}{
+			"status":      model.PaymentStatusReversed,
+			"reversed_at": now,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("ใบเสร็จ %s ถูกกลับรายการไปแล้ว", original.Receipt_No)
+	}
+	original.Status = model.PaymentStatusReversed
+	original.Reversed_At = &now
+	return nil
+}
+
+func (r *paymentRepositoryDB) GetPaymentById(id uint) (*model.Payment, error) {
+	var payment model.Payment
+	err := r.db.
+		Preload("User").
+		Preload("Allocations", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Preload("Tenders", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		First(&payment, id).Error
+	return &payment, err
+}
+
+func (r *paymentRepositoryDB) GetPaymentsByBill(billType int, billID uint) ([]model.Payment, error) {
+	var payments []model.Payment
+	err := r.db.
+		Preload("User").
+		Preload("Allocations", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Preload("Tenders", func(db *gorm.DB) *gorm.DB { return db.Order("id ASC") }).
+		Where("bill_type = ? AND bill_id = ?", billType, billID).
+		Order("created_at ASC, id ASC").
+		Find(&payments).Error
+	return payments, err
+}
+
+func (r *paymentRepositoryDB) CreatePayment(payment *model.Payment) error {
+	return r.db.Create(payment).Error
+}
+
+// ปรับเครดิตคงเหลือของหัวสัญญา ไม่ยอมให้ติดลบ (กันคืน / โอนซ้อนกัน) แล้วคืนยอดหลังปรับ
+func adjustBillCredit(tx *gorm.DB, billType int, billID uint, delta float64) (float64, error) {
+	header, _, _ := billHeaderTable(billType)
+	result := tx.Table(header).
+		Where("id = ? AND credit_balance + ? >= -0.005", billID, delta).
+		Updates(map[string]interface{}{
+			"credit_balance": gorm.Expr("ROUND(CAST(credit_balance + ? AS numeric), 2)", delta),
+			"updated_at":     time.Now(),
+		})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, errors.New("เครดิตคงเหลือไม่พอ หรือถูกใช้ไปแล้ว กรุณาลองใหม่")
+	}
+	var balance float64
+	if err := tx.Table(header).Select("credit_balance").Where("id = ?", billID).Scan(&balance).Error; err != nil {
+		return 0, err
+	}
+	return balance, nil
+}
+
+// คืนเครดิต: หักเครดิตหัวสัญญา + ออกใบเสร็จคืนเงิน + บันทึกความเคลื่อนไหว ใน transaction เดียว
+func (r *paymentRepositoryDB) RefundCredit(refund *model.Payment, movement *model.Credit_Movement) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		balance, err := adjustBillCredit(tx, movement.Bill_Type, movement.Bill_Id, movement.Amount)
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(refund).Error; err != nil {
+			return err
+		}
+		movement.Balance_After = balance
+		movement.PaymentId = &refund.Id
+		return tx.Create(movement).Error
+	})
+}
+
+// โอนเครดิตระหว่างสัญญา: out ยอดติดลบ, in ยอดบวก
+func (r *paymentRepositoryDB) TransferCredit(out *model.Credit_Movement, in *model.Credit_Movement) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		balance, err := adjustBillCredit(tx, out.Bill_Type, out.Bill_Id, out.Amount)
+		if err != nil {
+			return err
+		}
+		out.Balance_After = balance
+
+		balance, err = adjustBillCredit(tx, in.Bill_Type, in.Bill_Id, in.Amount)
+		if err != nil {
+			return err
+		}
+		in.Balance_After = balance
+
+		if err := tx.Create(out).Error; err != nil {
+			return err
+		}
+		return tx.Create(in).Error
+	})
+}
+
+func (r *paymentRepositoryDB) CreateCreditMovement(movement *model.Credit_Movement) error {
+	return r.db.Create(movement).Error
+}
+
+func (r *paymentRepositoryDB) GetCreditMovements(billType int, billID uint) ([]model.Credit_Movement, error) {
+	var movements []model.Credit_Movement
+	err := r.db.
+		Preload("Actor").
+		Where("bill_type = ? AND bill_id = ?", billType, billID).
+		Order("created_at ASC, id ASC").
+		Find(&movements).Error
+	return movements, err
+}
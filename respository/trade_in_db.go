@@ -0,0 +1,29 @@
+package respository
+
+import (
+	"rrmobile/model"
+
+	"gorm.io/gorm"
+)
+
+// บันทึกเครื่องเทิร์นพร้อมนำเข้าสต็อกมือสอง (เรียกภายใน transaction)
+func createTradeIn(tx *gorm.DB, tradeIn *model.Trade_In, unit *model.Inventory_Unit) error {
+	if err := tx.Create(unit).Error; err != nil {
+		return err
+	}
+	unitID := unit.Id
+	tradeIn.Inventory_UnitId = &unitID
+	return tx.Create(tradeIn).Error
+}
+
+func findTradeIn(db *gorm.DB, billType int, billID uint) (*model.Trade_In, error) {
+	var tradeIn model.Trade_In
+	err := db.
+		Preload("Product").
+		Where("bill_type = ? AND bill_id = ?", billType, billID).
+		First(&tradeIn).Error
+	if err != nil {
+		return nil, err
+	}
+	return &tradeIn, nil
+}
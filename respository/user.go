@@ -12,6 +12,9 @@ type User struct {
 	UpdatedAt time.Time `db:"updated_at"`
 	Is_active *bool     `db:"is_active"` // ✅ ใช้ pointer
 
+	Must_Change_Password bool       `db:"must_change_password"`
+	Password_Changed_At  *time.Time `db:"password_changed_at"`
+	Last_Login_At        *time.Time `db:"last_login_at"`
 }
 
 type UserRepository interface {
@@ -22,4 +25,5 @@ type UserRepository interface {
 	UpdateUser(id uint, user User) (*User, error)
 	DeleteUser(id uint) error
 	UpdatePassword(id uint, newPassword string) (*User, error)
+	SetPassword(id uint, passwordHash string, mustChange bool, keepHistory int) error
 }
@@ -2,7 +2,9 @@ package respository
 
 import (
 	"fmt"
+	"rrmobile/model"
 	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -154,3 +156,36 @@ func (r *userRespositoryDB) UpdatePassword(id uint, newPassword string) (*User,
 
 	return &user, nil
 }
+
+func (r *userRespositoryDB) SetPassword(id uint, passwordHash string, mustChange bool, keepHistory int) error {
+	return savePassword(r.db, id, passwordHash, mustChange, keepHistory)
+}
+
+// เปลี่ยนรหัสผ่าน: เก็บ hash เดิมลงประวัติ (เหลือแค่ keepHistory รายการล่าสุด) แล้วบันทึกรหัสใหม่
+// ใช้ร่วมกันทั้งผู้ดูแลระบบตั้งให้ และผู้ใช้เปลี่ยนเอง
+func savePassword(db *gorm.DB, id uint, passwordHash string, mustChange bool, keepHistory int) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var current User
+		if err := tx.Select("id", "password").Where("id = ?", id).First(&current).Error; err != nil {
+			return err
+		}
+		if keepHistory > 0 && current.Password != "" {
+			if err := tx.Create(&model.Password_History{User_Id: id, Password_Hash: current.Password}).Error; err != nil {
+				return err
+			}
+			var keep []uint
+			if err := tx.Model(&model.Password_History{}).Where("user_id = ?", id).
+				Order("id DESC").Limit(keepHistory).Pluck("id", &keep).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("user_id = ? AND id NOT IN ?", id, keep).Delete(&model.Password_History{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&User{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"password":             passwordHash,
+			"must_change_password": mustChange,
+			"password_changed_at":  time.Now(),
+		}).Error
+	})
+}
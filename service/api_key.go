@@ -0,0 +1,66 @@
+package service
+
+import "time"
+
+type CreateApiKeyRequest struct {
+	Name            string   `json:"name"`
+	Scopes          []string `json:"scopes"`          // เช่น ["bills:read","payments:write"]
+	Expires_In_Days int      `json:"expires_in_days"` // 0 = ไม่หมดอายุ
+}
+
+type RotateApiKeyRequest struct {
+	Overlap_Hours *int `json:"overlap_hours"` // key เดิมใช้ต่อได้อีกกี่ชั่วโมง (ไม่ส่ง = 24)
+}
+
+type ApiKeyResponse struct {
+	Id           uint       `json:"id"`
+	Name         string     `json:"name"`
+	Prefix       string     `json:"prefix"`
+	Scopes       []string   `json:"scopes"`
+	Status       string     `json:"status"` // active, expired, revoked
+	Expires_At   *time.Time `json:"expires_at"`
+	Last_Used_At *time.Time `json:"last_used_at"`
+	Last_Used_Ip string     `json:"last_used_ip"`
+	Rotated_From *uint      `json:"rotated_from"`
+	Revoked_At   *time.Time `json:"revoked_at"`
+	Created_By   *int       `json:"created_by"`
+	Creator_Name string     `json:"creator_name"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// key จริงแสดงครั้งเดียวตอนสร้าง / หมุน
+type ApiKeyCreatedResponse struct {
+	Key     string         `json:"key"`
+	Api_Key ApiKeyResponse `json:"api_key"`
+}
+
+type ApiScopeResponse struct {
+	Scope string `json:"scope"`
+	Name  string `json:"name"`
+}
+
+// ระบบภายนอกที่ยืนยันตัวตนด้วย API key แล้ว
+type ApiKeyPrincipal struct {
+	Id     uint
+	Name   string
+	Scopes []string
+}
+
+func (p *ApiKeyPrincipal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+type ApiKeyService interface {
+	GetApiScopes() []ApiScopeResponse
+	GetApiKeys(includeRevoked bool) ([]ApiKeyResponse, error)
+	CreateApiKey(actorID uint, request CreateApiKeyRequest) (*ApiKeyCreatedResponse, error)
+	RotateApiKey(actorID uint, id uint, request RotateApiKeyRequest) (*ApiKeyCreatedResponse, error)
+	RevokeApiKey(actorID uint, id uint) error
+	Authenticate(key string, ip string) (*ApiKeyPrincipal, error)
+	ImportLegacyToken(token string) error
+}
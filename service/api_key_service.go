@@ -0,0 +1,285 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"rrmobile/model"
+	"rrmobile/respository"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	apiKeyPrefix          = "rrk_"
+	defaultApiKeyOverlap  = 24
+	maxApiKeyOverlapHours = 30 * 24
+)
+
+var errApiKeyInvalid = errors.New("API key ไม่ถูกต้อง")
+
+type apiKeyService struct {
+	apiKeyRepository respository.ApiKeyRepository
+}
+
+func NewApiKeyService(apiKeyRepository respository.ApiKeyRepository) ApiKeyService {
+	return &apiKeyService{apiKeyRepository: apiKeyRepository}
+}
+
+func (s *apiKeyService) GetApiScopes() []ApiScopeResponse {
+	scopes := make([]ApiScopeResponse, 0, len(model.ApiScopeNames))
+	for scope, name := range model.ApiScopeNames {
+		scopes = append(scopes, ApiScopeResponse{Scope: scope, Name: name})
+	}
+	sort.Slice(scopes, func(i, j int) bool { return scopes[i].Scope < scopes[j].Scope })
+	return scopes
+}
+
+func (s *apiKeyService) GetApiKeys(includeRevoked bool) ([]ApiKeyResponse, error) {
+	keys, err := s.apiKeyRepository.GetApiKeys(includeRevoked)
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]ApiKeyResponse, 0, len(keys))
+	for _, k := range keys {
+		resp = append(resp, toApiKeyResponse(k))
+	}
+	return resp, nil
+}
+
+func (s *apiKeyService) CreateApiKey(actorID uint, request CreateApiKeyRequest) (*ApiKeyCreatedResponse, error) {
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		return nil, errors.New("กรุณาระบุชื่อ API key")
+	}
+	scopes, err := parseApiScopes(request.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	if request.Expires_In_Days < 0 {
+		return nil, errors.New("expires_in_days ต้องไม่ติดลบ")
+	}
+
+	key := &model.Api_Key{
+		Name:   truncateText(name, 100),
+		Scopes: strings.Join(scopes, ","),
+	}
+	if request.Expires_In_Days > 0 {
+		expiresAt := time.Now().AddDate(0, 0, request.Expires_In_Days)
+		key.Expires_At = &expiresAt
+	}
+	if actorID > 0 {
+		createdBy := int(actorID)
+		key.Created_By = &createdBy
+	}
+	plain, err := assignApiKeySecret(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.apiKeyRepository.CreateApiKey(key); err != nil {
+		return nil, err
+	}
+	log.Printf("🔑 สร้าง API key %s (%s) scopes=%s โดย %d", key.Name, key.Prefix, key.Scopes, actorID)
+
+	return &ApiKeyCreatedResponse{Key: plain, Api_Key: toApiKeyResponse(*key)}, nil
+}
+
+// ออก key ใหม่ชื่อและสิทธิ์เดิม key เดิมยังใช้ได้อีก overlap_hours ชั่วโมงให้ระบบภายนอกเปลี่ยนทัน
+func (s *apiKeyService) RotateApiKey(actorID uint, id uint, request RotateApiKeyRequest) (*ApiKeyCreatedResponse, error) {
+	old, err := s.apiKeyRepository.GetApiKeyByID(id)
+	if err != nil {
+		return nil, errors.New("ไม่พบ API key")
+	}
+	if status := apiKeyStatus(*old, time.Now()); status != "active" {
+		return nil, fmt.Errorf("API key นี้ %s แล้ว หมุนไม่ได้", apiKeyStatusNames[status])
+	}
+	overlap := defaultApiKeyOverlap
+	if request.Overlap_Hours != nil {
+		overlap = *request.Overlap_Hours
+	}
+	if overlap < 0 || overlap > maxApiKeyOverlapHours {
+		return nil, fmt.Errorf("overlap_hours ต้องอยู่ระหว่าง 0 ถึง %d", maxApiKeyOverlapHours)
+	}
+
+	now := time.Now()
+	next := &model.Api_Key{
+		Name:         old.Name,
+		Scopes:       old.Scopes,
+		Rotated_From: &old.Id,
+	}
+	// key ใหม่มีอายุเท่ากับ key เดิม นับจากวันนี้
+	if old.Expires_At != nil {
+		expiresAt := now.Add(old.Expires_At.Sub(old.CreatedAt))
+		next.Expires_At = &expiresAt
+	}
+	if actorID > 0 {
+		createdBy := int(actorID)
+		next.Created_By = &createdBy
+	}
+	plain, err := assignApiKeySecret(next)
+	if err != nil {
+		return nil, err
+	}
+
+	oldUntil := now.Add(time.Duration(overlap) * time.Hour)
+	if old.Expires_At == nil || oldUntil.Before(*old.Expires_At) {
+		old.Expires_At = &oldUntil
+	}
+	if err := s.apiKeyRepository.RotateApiKey(old, next); err != nil {
+		return nil, err
+	}
+	log.Printf("🔁 หมุน API key %s: %s → %s key เดิมใช้ได้ถึง %s โดย %d",
+		old.Name, old.Prefix, next.Prefix, old.Expires_At.Format("2006-01-02 15:04"), actorID)
+
+	return &ApiKeyCreatedResponse{Key: plain, Api_Key: toApiKeyResponse(*next)}, nil
+}
+
+func (s *apiKeyService) RevokeApiKey(actorID uint, id uint) error {
+	key, err := s.apiKeyRepository.GetApiKeyByID(id)
+	if err != nil {
+		return errors.New("ไม่พบ API key")
+	}
+	var revokedBy *int
+	if actorID > 0 {
+		actor := int(actorID)
+		revokedBy = &actor
+	}
+	if err := s.apiKeyRepository.RevokeApiKey(key.Id, revokedBy); err != nil {
+		return err
+	}
+	log.Printf("🛑 ยกเลิก API key %s (%s) โดย %d", key.Name, key.Prefix, actorID)
+	return nil
+}
+
+func (s *apiKeyService) Authenticate(plain string, ip string) (*ApiKeyPrincipal, error) {
+	plain = strings.TrimSpace(plain)
+	if plain == "" {
+		return nil, errApiKeyInvalid
+	}
+	key, err := s.apiKeyRepository.FindApiKeyByHash(HashToken(plain))
+	if err != nil {
+		return nil, err
+	}
+	if key == nil || apiKeyStatus(*key, time.Now()) != "active" {
+		return nil, errApiKeyInvalid
+	}
+	if err := s.apiKeyRepository.TouchApiKey(key.Id, truncateText(ip, 64)); err != nil {
+		log.Printf("❌ บันทึกการใช้งาน API key %d ไม่สำเร็จ: %v", key.Id, err)
+	}
+	return &ApiKeyPrincipal{Id: key.Id, Name: key.Name, Scopes: splitApiScopes(key.Scopes)}, nil
+}
+
+// ย้าย BILL_API_TOKEN เดิมเข้าเป็น API key (ครั้งแรกที่ยังไม่มี key ในระบบ) ให้ LINE bot ใช้ต่อได้ระหว่างเปลี่ยน
+func (s *apiKeyService) ImportLegacyToken(token string) error {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil
+	}
+	count, err := s.apiKeyRepository.CountApiKeys()
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	scopes := make([]string, 0, len(model.ApiScopeNames))
+	for scope := range model.ApiScopeNames {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+	key := &model.Api_Key{
+		Name:     "BILL_API_TOKEN (เดิม)",
+		Prefix:   "legacy",
+		Key_Hash: HashToken(token),
+		Scopes:   strings.Join(scopes, ","),
+	}
+	if err := s.apiKeyRepository.CreateApiKey(key); err != nil {
+		return err
+	}
+	log.Printf("🔑 ย้าย BILL_API_TOKEN เป็น API key #%d แล้ว กรุณาหมุน key และลบ BILL_API_TOKEN ออกจาก .env", key.Id)
+	return nil
+}
+
+func assignApiKeySecret(key *model.Api_Key) (string, error) {
+	token, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	plain := apiKeyPrefix + strings.TrimRight(token, "=")
+	key.Prefix = apiKeyDisplayPrefix(plain)
+	key.Key_Hash = HashToken(plain)
+	return plain, nil
+}
+
+func apiKeyDisplayPrefix(plain string) string {
+	if len(plain) > 12 {
+		return plain[:12]
+	}
+	return plain
+}
+
+func parseApiScopes(requested []string) ([]string, error) {
+	seen := map[string]bool{}
+	scopes := make([]string, 0, len(requested))
+	for _, raw := range requested {
+		scope := strings.ToLower(strings.TrimSpace(raw))
+		if _, ok := model.ApiScopeNames[scope]; !ok {
+			return nil, fmt.Errorf("ไม่รู้จักสิทธิ์ %q", raw)
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, errors.New("กรุณาระบุสิทธิ์ของ API key อย่างน้อย 1 รายการ")
+	}
+	sort.Strings(scopes)
+	return scopes, nil
+}
+
+func splitApiScopes(raw string) []string {
+	scopes := []string{}
+	for _, s := range strings.Split(raw, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+var apiKeyStatusNames = map[string]string{
+	"active":  "ใช้งานได้",
+	"expired": "หมดอายุ",
+	"revoked": "ถูกยกเลิก",
+}
+
+func apiKeyStatus(k model.Api_Key, now time.Time) string {
+	switch {
+	case k.Revoked_At != nil:
+		return "revoked"
+	case k.Expires_At != nil && !now.Before(*k.Expires_At):
+		return "expired"
+	}
+	return "active"
+}
+
+func toApiKeyResponse(k model.Api_Key) ApiKeyResponse {
+	return ApiKeyResponse{
+		Id:           k.Id,
+		Name:         k.Name,
+		Prefix:       k.Prefix,
+		Scopes:       splitApiScopes(k.Scopes),
+		Status:       apiKeyStatus(k, time.Now()),
+		Expires_At:   k.Expires_At,
+		Last_Used_At: k.Last_Used_At,
+		Last_Used_Ip: k.Last_Used_Ip,
+		Rotated_From: k.Rotated_From,
+		Revoked_At:   k.Revoked_At,
+		Created_By:   k.Created_By,
+		Creator_Name: k.Creator.FullName,
+		CreatedAt:    k.CreatedAt,
+	}
+}
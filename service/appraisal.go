@@ -0,0 +1,71 @@
+package service
+
+import "time"
+
+type AppraisalResponse struct {
+	Id              uint            `json:"id"`
+	ProductId       uint            `json:"product_id"`
+	ProductName     string          `json:"product_name"`
+	CategoryId      uint            `json:"category_id"`
+	CategoryName    string          `json:"category_name"`
+	Imei            string          `json:"imei"`
+	Condition_Grade string          `json:"condition_grade"`
+	Battery_Health  int             `json:"battery_health"`
+	Checklist       map[string]bool `json:"checklist"`
+	Appraised_Value float64         `json:"appraised_value"`
+	Max_Ltv_Percent *float64        `json:"max_ltv_percent"`
+	Max_Loan_Amount *float64        `json:"max_loan_amount"`
+	Note            string          `json:"note"`
+	AppraiserId     int             `json:"appraiser_id"`
+	AppraiserName   string          `json:"appraiser_name"`
+	BillId          *uint           `json:"bill_header_installment_id"`
+	Photos          []string        `json:"photos"`
+	CreatedAt       time.Time       `json:"created_at"`
+}
+
+type PaginationResponseAppraisal struct {
+	Total       int64               `json:"total"`
+	TotalPages  int                 `json:"total_pages"`
+	CurrentPage int                 `json:"current_page"`
+	HasNext     bool                `json:"has_next"`
+	HasPrev     bool                `json:"has_prev"`
+	Limit       int                 `json:"limit"`
+	Appraisals  []AppraisalResponse `json:"data"`
+}
+
+type NewAppraisalRequest struct {
+	ProductId       uint            `json:"product_id"`
+	Imei            string          `json:"imei"`
+	Condition_Grade string          `json:"condition_grade"`
+	Battery_Health  int             `json:"battery_health"`
+	Checklist       map[string]bool `json:"checklist"`
+	Appraised_Value float64         `json:"appraised_value"`
+	Note            string          `json:"note"`
+	AppraiserId     int             `json:"-"`
+	Photos          []string        `json:"-"`
+}
+
+type LtvPolicyResponse struct {
+	Id              uint      `json:"id"`
+	CategoryId      uint      `json:"category_id"`
+	Condition_Grade string    `json:"condition_grade"`
+	Max_Ltv_Percent float64   `json:"max_ltv_percent"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type UpsertLtvPolicyRequest struct {
+	CategoryId      uint    `json:"category_id"` // 0 = ทุกหมวด
+	Condition_Grade string  `json:"condition_grade"`
+	Max_Ltv_Percent float64 `json:"max_ltv_percent"`
+}
+
+type AppraisalService interface {
+	CreateAppraisal(request NewAppraisalRequest) (*AppraisalResponse, error)
+	GetAllAppraisals(grade string, unused bool, page, limit int) (*PaginationResponseAppraisal, error)
+	GetAppraisalById(id uint) (*AppraisalResponse, error)
+
+	GetLtvPolicies() ([]LtvPolicyResponse, error)
+	UpsertLtvPolicy(request UpsertLtvPolicyRequest) (*LtvPolicyResponse, error)
+	DeleteLtvPolicy(id uint) error
+	SeedDefaultLtvPolicies() error
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"rrmobile/model"
+	"rrmobile/respository"
+	"strings"
+)
+
+var validConditionGrades = map[string]bool{"A": true, "B": true, "C": true, "D": true}
+
+// เพดาน LTV ค่ากลาง (ทุกหมวดสินค้า) ต่อเกรดสภาพ ใช้ตั้งต้นตอนเริ่มระบบ ให้รับจำนำได้ทันที
+var defaultLtvPercents = map[string]float64{"A": 70, "B": 60, "C": 50, "D": 40}
+
+var ErrAppraisalInUse = respository.ErrAppraisalInUse
+
+type appraisalService struct {
+	appraisalRepository respository.AppraisalRepository
+	productRepository   respository.ProductRepository
+}
+
+func NewAppraisalService(appraisalRepository respository.AppraisalRepository, productRepository respository.ProductRepository) AppraisalService {
+	return &appraisalService{appraisalRepository: appraisalRepository, productRepository: productRepository}
+}
+
+func (s *appraisalService) toAppraisalResponse(a model.Pawn_Appraisal) AppraisalResponse {
+	checklist := map[string]bool{}
+	if a.Checklist != "" {
+		_ = json.Unmarshal([]byte(a.Checklist), &checklist)
+	}
+
+	photos := make([]string, 0, len(a.Photos))
+	for _, p := range a.Photos {
+		photos = append(photos, p.ImageUrl)
+	}
+
+	resp := AppraisalResponse{
+		Id:              a.Id,
+		ProductId:       a.ProductId,
+		ProductName:     a.Product.Name,
+		CategoryId:      a.Product.CategoryId,
+		CategoryName:    a.Product.Category.Name,
+		Imei:            a.Imei,
+		Condition_Grade: a.Condition_Grade,
+		Battery_Health:  a.Battery_Health,
+		Checklist:       checklist,
+		Appraised_Value: a.Appraised_Value,
+		Note:            a.Note,
+		AppraiserId:     a.Appraiser_Id,
+		AppraiserName:   a.Appraiser.FullName,
+		BillId:          a.Bill_Header_InstallmentId,
+		Photos:          photos,
+		CreatedAt:       a.CreatedAt,
+	}
+
+	// ยอดยืมสูงสุดตาม LTV ของหมวดสินค้า+เกรด
+	if policy, err := s.appraisalRepository.FindLtvPolicy(a.Product.CategoryId, a.Condition_Grade); err == nil {
+		percent := policy.Max_Ltv_Percent
+		maxLoan := round2(a.Appraised_Value * percent / 100)
+		resp.Max_Ltv_Percent = &percent
+		resp.Max_Loan_Amount = &maxLoan
+	}
+	return resp
+}
+
+func (s *appraisalService) CreateAppraisal(request NewAppraisalRequest) (*AppraisalResponse, error) {
+	grade := strings.ToUpper(strings.TrimSpace(request.Condition_Grade))
+	if !validConditionGrades[grade] {
+		return nil, errors.New("เกรดสภาพเครื่องต้องเป็น A, B, C หรือ D")
+	}
+	if request.Battery_Health < 0 || request.Battery_Health > 100 {
+		return nil, errors.New("สุขภาพแบตเตอรี่ต้องอยู่ระหว่าง 0-100")
+	}
+	if request.Appraised_Value <= 0 {
+		return nil, errors.New("ราคาประเมินต้องมากกว่า 0")
+	}
+	if request.AppraiserId == 0 {
+		return nil, errors.New("ไม่พบผู้ประเมิน")
+	}
+	if _, err := s.productRepository.GetProductByID(request.ProductId); err != nil {
+		return nil, errors.New("product not found")
+	}
+
+	checklist := "{}"
+	if len(request.Checklist) > 0 {
+		raw, err := json.Marshal(request.Checklist)
+		if err != nil {
+			return nil, errors.New("checklist ไม่ถูกต้อง")
+		}
+		checklist = string(raw)
+	}
+
+	photos := make([]model.Pawn_Appraisal_Photo, 0, len(request.Photos))
+	for _, url := range request.Photos {
+		photos = append(photos, model.Pawn_Appraisal_Photo{ImageUrl: url})
+	}
+
+	appraisal := &model.Pawn_Appraisal{
+		ProductId:       request.ProductId,
+		Imei:            strings.TrimSpace(request.Imei),
+		Condition_Grade: grade,
+		Battery_Health:  request.Battery_Health,
+		Checklist:       checklist,
+		Appraised_Value: round2(request.Appraised_Value),
+		Note:            request.Note,
+		Appraiser_Id:    request.AppraiserId,
+		Photos:          photos,
+	}
+	if err := s.appraisalRepository.CreateAppraisal(appraisal); err != nil {
+		return nil, fmt.Errorf("failed to create appraisal: %w", err)
+	}
+
+	return s.GetAppraisalById(appraisal.Id)
+}
+
+func (s *appraisalService) GetAllAppraisals(grade string, unused bool, page, limit int) (*PaginationResponseAppraisal, error) {
+	if page < 1 {
+		page = 1
+	}
+	offset := 0
+	if limit > 0 {
+		offset = (page - 1) * limit
+	}
+
+	filter := respository.AppraisalFilter{Grade: strings.ToUpper(grade), Unused: unused}
+
+	total, err := s.appraisalRepository.CountAppraisals(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count appraisals: %w", err)
+	}
+	appraisals, err := s.appraisalRepository.GetAppraisals(filter, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch appraisals: %w", err)
+	}
+
+	responses := make([]AppraisalResponse, 0, len(appraisals))
+	for _, a := range appraisals {
+		responses = append(responses, s.toAppraisalResponse(a))
+	}
+
+	totalPages := 1
+	if limit > 0 {
+		totalPages = int((total + int64(limit) - 1) / int64(limit))
+	}
+
+	return &PaginationResponseAppraisal{
+		Total:       total,
+		TotalPages:  totalPages,
+		CurrentPage: page,
+		HasNext:     page < totalPages,
+		HasPrev:     page > 1,
+		Limit:       limit,
+		Appraisals:  responses,
+	}, nil
+}
+
+func (s *appraisalService) GetAppraisalById(id uint) (*AppraisalResponse, error) {
+	appraisal, err := s.appraisalRepository.GetAppraisalById(id)
+	if err != nil {
+		return nil, errors.New("ไม่พบใบประเมิน")
+	}
+	resp := s.toAppraisalResponse(*appraisal)
+	return &resp, nil
+}
+
+func (s *appraisalService) GetLtvPolicies() ([]LtvPolicyResponse, error) {
+	policies, err := s.appraisalRepository.GetLtvPolicies()
+	if err != nil {
+		return nil, err
+	}
+	responses := make([]LtvPolicyResponse, 0, len(policies))
+	for _, p := range policies {
+		responses = append(responses, LtvPolicyResponse{
+			Id:              p.Id,
+			CategoryId:      p.CategoryId,
+			Condition_Grade: p.Condition_Grade,
+			Max_Ltv_Percent: p.Max_Ltv_Percent,
+			UpdatedAt:       p.UpdatedAt,
+		})
+	}
+	return responses, nil
+}
+
+func (s *appraisalService) UpsertLtvPolicy(request UpsertLtvPolicyRequest) (*LtvPolicyResponse, error) {
+	grade := strings.ToUpper(strings.TrimSpace(request.Condition_Grade))
+	if !validConditionGrades[grade] {
+		return nil, errors.New("เกรดสภาพเครื่องต้องเป็น A, B, C หรือ D")
+	}
+	if request.Max_Ltv_Percent <= 0 || request.Max_Ltv_Percent > 100 {
+		return nil, errors.New("max_ltv_percent ต้องอยู่ระหว่าง 0-100")
+	}
+
+	policy := &model.Pawn_Ltv_Policy{
+		CategoryId:      request.CategoryId,
+		Condition_Grade: grade,
+		Max_Ltv_Percent: request.Max_Ltv_Percent,
+	}
+	if err := s.appraisalRepository.UpsertLtvPolicy(policy); err != nil {
+		return nil, err
+	}
+
+	saved, err := s.appraisalRepository.FindLtvPolicy(request.CategoryId, grade)
+	if err != nil {
+		return nil, err
+	}
+	return &LtvPolicyResponse{
+		Id:              saved.Id,
+		CategoryId:      saved.CategoryId,
+		Condition_Grade: saved.Condition_Grade,
+		Max_Ltv_Percent: saved.Max_Ltv_Percent,
+		UpdatedAt:       saved.UpdatedAt,
+	}, nil
+}
+
+func (s *appraisalService) DeleteLtvPolicy(id uint) error {
+	return s.appraisalRepository.DeleteLtvPolicy(id)
+}
+
+// เพิ่มเพดาน LTV ค่ากลางของเกรดที่ยังไม่ได้ตั้ง (เรียกตอนเริ่มระบบ)
+func (s *appraisalService) SeedDefaultLtvPolicies() error {
+	policies := make([]model.Pawn_Ltv_Policy, 0, len(defaultLtvPercents))
+	for grade, percent := range defaultLtvPercents {
+		policies = append(policies, model.Pawn_Ltv_Policy{Condition_Grade: grade, Max_Ltv_Percent: percent})
+	}
+	added, err := s.appraisalRepository.SeedLtvPolicies(policies)
+	if err != nil {
+		return err
+	}
+	if added > 0 {
+		log.Printf("📐 เพิ่มเพดาน LTV ค่าเริ่มต้น %d เกรด", added)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ผู้ที่สั่งแก้ไขข้อมูล (handler สร้างจาก request แล้วส่งต่อให้ service)
+type AuditActor struct {
+	User_Id    int   // 0 = ไม่ใช่ผู้ใช้ที่เข้าสู่ระบบ เช่น ระบบภายนอกผ่าน API key
+	Api_Key_Id *uint // ทำผ่าน API key
+	Ip         string
+	Request_Id string
+}
+
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+type AuditLogQuery struct {
+	Entity    string
+	Entity_Id *int
+	Actor_Id  *int
+	Action    string
+	DateFrom  *time.Time
+	DateTo    *time.Time
+	Page      int
+	Limit     int
+}
+
+type AuditLogResponse struct {
+	Id          uint            `json:"id"`
+	Actor_Id    *int            `json:"actor_id"`
+	Actor_Name  string          `json:"actor_name"`
+	Api_Key_Id  *uint           `json:"api_key_id"`
+	Action      string          `json:"action"`
+	Entity      string          `json:"entity"`
+	Entity_Name string          `json:"entity_name"`
+	Entity_Id   uint            `json:"entity_id"`
+	Changes     json.RawMessage `json:"changes"`
+	Ip          string          `json:"ip"`
+	Request_Id  string          `json:"request_id"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+type PaginatedAuditLogResponse struct {
+	Logs        []AuditLogResponse `json:"logs"`
+	Total       int64              `json:"total"`
+	CurrentPage int                `json:"current_page"`
+	TotalPages  int                `json:"total_pages"`
+}
+
+type AuditService interface {
+	GetAuditLogs(query AuditLogQuery) (*PaginatedAuditLogResponse, error)
+}
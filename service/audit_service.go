@@ -0,0 +1,147 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+	"reflect"
+	"rrmobile/model"
+	"rrmobile/respository"
+	"strings"
+)
+
+// ฟิลด์ที่เปลี่ยนทุกครั้งที่บันทึก ไม่ต้องเก็บใน diff
+var auditIgnoredFields = map[string]bool{
+	"updated_at": true,
+}
+
+type auditService struct {
+	auditRepository respository.AuditRepository
+}
+
+func NewAuditService(auditRepository respository.AuditRepository) AuditService {
+	return &auditService{auditRepository: auditRepository}
+}
+
+func (s *auditService) GetAuditLogs(query AuditLogQuery) (*PaginatedAuditLogResponse, error) {
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.Limit < 1 {
+		query.Limit = 50
+	}
+	logs, total, err := s.auditRepository.GetAuditLogs(respository.AuditLogFilter{
+		Entity:    strings.TrimSpace(query.Entity),
+		Entity_Id: query.Entity_Id,
+		Actor_Id:  query.Actor_Id,
+		Action:    strings.TrimSpace(query.Action),
+		DateFrom:  query.DateFrom,
+		DateTo:    query.DateTo,
+		Limit:     query.Limit,
+		Offset:    (query.Page - 1) * query.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &PaginatedAuditLogResponse{
+		Logs:        make([]AuditLogResponse, 0, len(logs)),
+		Total:       total,
+		CurrentPage: query.Page,
+		TotalPages:  int((total + int64(query.Limit) - 1) / int64(query.Limit)),
+	}
+	for _, l := range logs {
+		changes := json.RawMessage(l.Changes)
+		if len(changes) == 0 {
+			changes = json.RawMessage("{}")
+		}
+		resp.Logs = append(resp.Logs, AuditLogResponse{
+			Id:          l.Id,
+			Actor_Id:    l.Actor_Id,
+			Actor_Name:  l.Actor.FullName,
+			Api_Key_Id:  l.Api_Key_Id,
+			Action:      l.Action,
+			Entity:      l.Entity,
+			Entity_Name: model.AuditEntityNames[l.Entity],
+			Entity_Id:   l.Entity_Id,
+			Changes:     changes,
+			Ip:          l.Ip,
+			Request_Id:  l.Request_Id,
+			CreatedAt:   l.CreatedAt,
+		})
+	}
+	return resp, nil
+}
+
+// บันทึกการแก้ไขข้อมูล before / after เป็นข้อมูลก่อนและหลังแก้ (nil ตอนสร้าง / ลบ)
+// ไม่ให้การแก้ไขล้มเพราะบันทึกประวัติไม่สำเร็จ
+func recordAudit(repo respository.AuditRepository, actor AuditActor, action, entity string, entityID uint, before, after interface{}) {
+	recordAuditChanges(repo, actor, action, entity, entityID, auditDiff(before, after))
+}
+
+func recordAuditChanges(repo respository.AuditRepository, actor AuditActor, action, entity string, entityID uint, changes map[string]AuditChange) {
+	if action == model.AuditActionUpdate && len(changes) == 0 {
+		return
+	}
+	raw, err := json.Marshal(changes)
+	if err != nil {
+		log.Printf("❌ แปลงประวัติการแก้ไข %s %d ไม่สำเร็จ: %v", entity, entityID, err)
+		return
+	}
+	entry := &model.Audit_Log{
+		Api_Key_Id: actor.Api_Key_Id,
+		Action:     action,
+		Entity:     entity,
+		Entity_Id:  entityID,
+		Changes:    string(raw),
+		Ip:         truncateText(actor.Ip, 64),
+		Request_Id: truncateText(actor.Request_Id, 64),
+	}
+	if actor.User_Id > 0 {
+		actorID := actor.User_Id
+		entry.Actor_Id = &actorID
+	}
+	if err := repo.CreateAuditLog(entry); err != nil {
+		log.Printf("❌ บันทึกประวัติการแก้ไข %s %d ไม่สำเร็จ: %v", entity, entityID, err)
+	}
+}
+
+// เทียบข้อมูลรายฟิลด์ตามชื่อ json คืนเฉพาะฟิลด์ที่ต่างกัน
+func auditDiff(before, after interface{}) map[string]AuditChange {
+	b, a := auditFields(before), auditFields(after)
+	changes := map[string]AuditChange{}
+	for key, value := range b {
+		if auditIgnoredFields[key] {
+			continue
+		}
+		if next, ok := a[key]; !ok || !reflect.DeepEqual(value, next) {
+			changes[key] = AuditChange{Before: value, After: a[key]}
+		}
+	}
+	for key, value := range a {
+		if auditIgnoredFields[key] {
+			continue
+		}
+		if _, ok := b[key]; !ok {
+			changes[key] = AuditChange{After: value}
+		}
+	}
+	return changes
+}
+
+func auditFields(v interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if v == nil {
+		return fields
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return fields
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fields
+	}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return map[string]interface{}{}
+	}
+	return fields
+}
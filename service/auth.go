@@ -13,6 +13,16 @@ type AuthResponse struct {
 	Token        string `json:"token"`
 	RefreshToken string `json:"refresh_token"`
 	RoleID       int    `json:"role_id"`
+	SessionID    uint   `json:"session_id"`
+
+	// ต้องยืนยันรหัส 2FA ก่อน: แลก Challenge_Token พร้อมรหัสที่ /auth/v1/login/2fa
+	Two_Factor_Required bool     `json:"two_factor_required,omitempty"`
+	Two_Factor_Setup    bool     `json:"two_factor_setup,omitempty"` // role บังคับ 2FA แต่ยังไม่ได้ลงทะเบียน
+	Challenge_Token     string   `json:"challenge_token,omitempty"`
+	Recovery_Codes      []string `json:"recovery_codes,omitempty"` // แสดงครั้งเดียวหลังลงทะเบียนสำเร็จ
+
+	// ต้องเปลี่ยนรหัสผ่านที่ /auth/v1/me/password ก่อนใช้งานอื่น (ผู้ดูแลระบบตั้งให้ / รหัสหมดอายุ)
+	Password_Change_Required bool `json:"password_change_required,omitempty"`
 }
 
 type AuthRegisterResponse struct {
@@ -26,6 +36,11 @@ type AuthLoginRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
+type ChangePasswordRequest struct {
+	Old_Password string `json:"old_password" validate:"required"`
+	New_Password string `json:"new_password" validate:"required"`
+}
+
 type NewAuthRequest struct {
 	Username        string `json:"username" validate:"required"`
 	Password        string `json:"password" validate:"required"`
@@ -35,20 +50,243 @@ type NewAuthRequest struct {
 }
 
 type Claims struct {
-	UserID   uint   `json:"user_id"`
-	Username string `json:"username"`
-	RoleId   int    `json:"role_id"`
+	UserID    uint   `json:"user_id"`
+	Username  string `json:"username"`
+	RoleId    int    `json:"role_id"`
+	SessionID uint   `json:"sid,omitempty"`
 
 	jwt.RegisteredClaims
 }
 
+// ข้อมูลอุปกรณ์ที่เข้าสู่ระบบ
+type LoginDevice struct {
+	Name      string
+	Ip        string
+	UserAgent string
+}
+
+type SessionResponse struct {
+	Id            uint       `json:"id"`
+	User_Id       int        `json:"user_id"`
+	Device_Name   string     `json:"device_name"`
+	Ip            string     `json:"ip"`
+	User_Agent    string     `json:"user_agent"`
+	CreatedAt     time.Time  `json:"created_at"`
+	Last_Seen_At  time.Time  `json:"last_seen_at"`
+	Expires_At    time.Time  `json:"expires_at"`
+	Revoked_At    *time.Time `json:"revoked_at,omitempty"`
+	Revoke_Reason string     `json:"revoke_reason,omitempty"`
+	Current       bool       `json:"current"`
+
+	// refresh token ของเซสชันนี้ถูกใช้ซ้ำ (อาจถูกขโมย) เซสชันถูกปิดแล้ว
+	Compromised    bool       `json:"compromised"`
+	Compromised_At *time.Time `json:"compromised_at,omitempty"`
+}
+
+type SessionPolicyResponse struct {
+	RoleID       int       `json:"role_id"`
+	Mode         int       `json:"mode"` // 1 = เครื่องเดียว, 2 = หลายเครื่อง
+	Mode_Name    string    `json:"mode_name"`
+	Max_Sessions int       `json:"max_sessions"` // 0 = ไม่จำกัด
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	Require_Two_Factor bool `json:"require_two_factor"`
+}
+
+type SecurityEventQuery struct {
+	Event_Type string
+	User_Id    *int
+	DateFrom   *time.Time
+	DateTo     *time.Time
+	Page       int
+	Limit      int
+}
+
+type SecurityEventResponse struct {
+	Id         uint      `json:"id"`
+	Event_Type string    `json:"event_type"`
+	Event_Name string    `json:"event_name"`
+	User_Id    *int      `json:"user_id"`
+	Username   string    `json:"username"`
+	Session_Id *uint     `json:"session_id"`
+	Ip         string    `json:"ip"`
+	User_Agent string    `json:"user_agent"`
+	Detail     string    `json:"detail"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type PaginatedSecurityEventResponse struct {
+	Events      []SecurityEventResponse `json:"events"`
+	Total       int64                   `json:"total"`
+	CurrentPage int                     `json:"current_page"`
+	TotalPages  int                     `json:"total_pages"`
+}
+
+type LoginLockResponse struct {
+	Id              uint       `json:"id"`
+	Scope           string     `json:"scope"` // username / ip
+	Key             string     `json:"key"`
+	Locked_Until    *time.Time `json:"locked_until"`
+	Lock_Count      int        `json:"lock_count"`
+	Last_Failure_At time.Time  `json:"last_failure_at"`
+}
+
+type LoginFailureQuery struct {
+	Username string
+	Ip       string
+	User_Id  *int
+	DateFrom *time.Time
+	DateTo   *time.Time
+	Page     int
+	Limit    int
+}
+
+type LoginFailureResponse struct {
+	Id          uint      `json:"id"`
+	Username    string    `json:"username"`
+	User_Id     *int      `json:"user_id"`
+	Reason      string    `json:"reason"`
+	Reason_Name string    `json:"reason_name"`
+	Ip          string    `json:"ip"`
+	User_Agent  string    `json:"user_agent"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type PaginatedLoginFailureResponse struct {
+	Failures    []LoginFailureResponse `json:"failures"`
+	Total       int64                  `json:"total"`
+	CurrentPage int                    `json:"current_page"`
+	TotalPages  int                    `json:"total_pages"`
+}
+
+type LoginHistoryQuery struct {
+	Success  *bool
+	DateFrom *time.Time
+	DateTo   *time.Time
+	Page     int
+	Limit    int
+}
+
+type LoginHistoryResponse struct {
+	Id           uint      `json:"id"`
+	Username     string    `json:"username"`
+	Success      bool      `json:"success"`
+	Reason       string    `json:"reason,omitempty"`
+	Reason_Name  string    `json:"reason_name,omitempty"`
+	Session_Id   *uint     `json:"session_id"`
+	Ip           string    `json:"ip"`
+	User_Agent   string    `json:"user_agent"`
+	Unusual_Hour bool      `json:"unusual_hour"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type PaginatedLoginHistoryResponse struct {
+	History     []LoginHistoryResponse `json:"history"`
+	Total       int64                  `json:"total"`
+	CurrentPage int                    `json:"current_page"`
+	TotalPages  int                    `json:"total_pages"`
+}
+
+// แจ้งเตือนเมื่อพนักงานเข้าสู่ระบบนอกเวลาทำการ
+type LoginAlert struct {
+	User_Id    uint
+	Username   string
+	Ip         string
+	User_Agent string
+	At         time.Time
+}
+
+type LoginAlertHook func(alert LoginAlert)
+
+type UpdateSessionPolicyRequest struct {
+	Mode               int   `json:"mode"`
+	Max_Sessions       int   `json:"max_sessions"`
+	Require_Two_Factor *bool `json:"require_two_factor"` // ไม่ส่ง = คงเดิม
+}
+
+type TwoFactorSetupResponse struct {
+	Secret           string `json:"secret"`
+	Provisioning_Uri string `json:"provisioning_uri"` // otpauth://... ใช้สร้าง QR
+	Issuer           string `json:"issuer"`
+	Account          string `json:"account"`
+}
+
+type TwoFactorStatusResponse struct {
+	Enabled             bool       `json:"enabled"`
+	Enabled_At          *time.Time `json:"enabled_at"`
+	Required            bool       `json:"required"` // role บังคับใช้ 2FA
+	Recovery_Codes_Left int64      `json:"recovery_codes_left"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type LoginTwoFactorRequest struct {
+	Challenge_Token string `json:"challenge_token"`
+	Code            string `json:"code"` // รหัส 6 หลักจากแอป หรือรหัสกู้คืน
+}
+
+type PermissionResponse struct {
+	Code          string `json:"code"`
+	Name          string `json:"name"`
+	Group         string `json:"group"`
+	Default_Roles []int  `json:"default_roles"`
+}
+
+type RolePermissionsResponse struct {
+	Role_Id     int      `json:"role_id"`
+	Permissions []string `json:"permissions"`
+}
+
+type UpdateRolePermissionsRequest struct {
+	Permissions []string `json:"permissions"`
+}
+
 type AuthService interface {
-	Login(ctx context.Context, username string, password string) (*AuthResponse, error)
+	Login(ctx context.Context, username string, password string, device LoginDevice) (*AuthResponse, error)
 	SaveRefreshToken(ctx context.Context, userID uint, token string, expiresAt time.Time) error
 	// Register(request NewAuthRequest) (*AuthRegisterResponse, error)
 	RevokeAllTokensForUser(userID uint) error
 	// RefreshTokens(ctx context.Context, oldToken string) (string, string, error)
-	RefreshTokens(ctx context.Context, oldRefreshToken string) (string, string, error)
+	RefreshTokens(ctx context.Context, oldRefreshToken string, device LoginDevice) (string, string, error)
 	LogoutAndRevokeAll(userID uint) error
 	ValidateToken(tokenString string) (*Claims, error)
+
+	Logout(userID uint, sessionID uint) error
+	LogoutByRefreshToken(refreshToken string) error
+	ChangePassword(userID uint, sessionID uint, request ChangePasswordRequest, device LoginDevice) error
+	GetPasswordPolicy() PasswordPolicyResponse
+	RevokeSessionsForPasswordReset(actorID uint, userID uint, ip string) error
+	GetMySessions(userID uint, currentSessionID uint) ([]SessionResponse, error)
+	RevokeMySession(userID uint, sessionID uint) error
+	GetUserSessions(userID uint) ([]SessionResponse, error)
+	RevokeUserSession(actorID uint, sessionID uint) error
+	RevokeUserSessions(actorID uint, userID uint) error
+	GetSessionPolicies() ([]SessionPolicyResponse, error)
+	UpdateSessionPolicy(roleID int, request UpdateSessionPolicyRequest) (*SessionPolicyResponse, error)
+	GetSecurityEvents(query SecurityEventQuery) (*PaginatedSecurityEventResponse, error)
+
+	SetupLoginTwoFactor(challengeToken string) (*TwoFactorSetupResponse, error)
+	VerifyLoginChallenge(ctx context.Context, challengeToken string, code string) (*AuthResponse, error)
+	GetTwoFactorStatus(userID uint) (*TwoFactorStatusResponse, error)
+	SetupTwoFactor(userID uint) (*TwoFactorSetupResponse, error)
+	EnableTwoFactor(userID uint, code string) ([]string, error)
+	DisableTwoFactor(userID uint, code string) error
+	RegenerateRecoveryCodes(userID uint, code string) ([]string, error)
+	ResetTwoFactor(actorID uint, userID uint) error
+
+	GetLoginLocks() ([]LoginLockResponse, error)
+	UnlockLogin(actorID uint, lockID uint) error
+	UnlockUserLogin(actorID uint, userID uint) error
+	GetLoginFailures(query LoginFailureQuery) (*PaginatedLoginFailureResponse, error)
+	GetLoginHistory(userID uint, query LoginHistoryQuery) (*PaginatedLoginHistoryResponse, error)
+	AddLoginAlertHook(hook LoginAlertHook)
+	PurgeLoginThrottles() error
+
+	SyncPermissionCatalog() error
+	GetPermissionCatalog() ([]PermissionResponse, error)
+	GetRolePermissions(roleID int) (*RolePermissionsResponse, error)
+	UpdateRolePermissions(actorRoleID int, roleID int, request UpdateRolePermissionsRequest) (*RolePermissionsResponse, error)
+	RoleHasPermissions(roleID int, codes ...string) (bool, error)
 }
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"rrmobile/model"
+	"rrmobile/respository"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// นอกเวลาทำการตั้งจาก .env เป็นชั่วโมงเวลาไทย: LOGIN_ALERT_FROM (ค่าเริ่มต้น 22) ถึงก่อน LOGIN_ALERT_TO (ค่าเริ่มต้น 6)
+// ตั้งสองค่าเท่ากันเพื่อปิดการแจ้งเตือน
+func isUnusualLoginHour(at time.Time) bool {
+	from, to := 22, 6
+	if viper.IsSet("LOGIN_ALERT_FROM") {
+		from = viper.GetInt("LOGIN_ALERT_FROM")
+	}
+	if viper.IsSet("LOGIN_ALERT_TO") {
+		to = viper.GetInt("LOGIN_ALERT_TO")
+	}
+	if from == to {
+		return false
+	}
+	loc, _ := time.LoadLocation("Asia/Bangkok")
+	hour := at.In(loc).Hour()
+	if from < to {
+		return hour >= from && hour < to
+	}
+	return hour >= from || hour < to
+}
+
+// เพิ่มตัวรับแจ้งเตือนเข้าสู่ระบบนอกเวลาทำการ (ตั้งตอนเริ่มระบบ) ถูกเรียกแยก goroutine ไม่หน่วงการเข้าสู่ระบบ
+func (s *authService) AddLoginAlertHook(hook LoginAlertHook) {
+	s.loginAlertHooks = append(s.loginAlertHooks, hook)
+}
+
+func (s *authService) logLoginHistory(history *model.Login_History) {
+	if err := s.authRepository.CreateLoginHistory(history); err != nil {
+		log.Printf("❌ บันทึกประวัติการเข้าสู่ระบบไม่สำเร็จ: %v", err)
+	}
+}
+
+// เข้าสู่ระบบสำเร็จ: บันทึกประวัติ + เวลาเข้าสู่ระบบล่าสุด ถ้านอกเวลาทำการบันทึกเหตุการณ์และแจ้งเตือน
+func (s *authService) recordLoginSuccess(auth *respository.Users, sessionID uint, device LoginDevice) {
+	now := time.Now()
+	userID := int(auth.Id)
+	history := &model.Login_History{
+		Username:     auth.Username,
+		User_Id:      &userID,
+		Success:      true,
+		Session_Id:   &sessionID,
+		Ip:           truncateText(device.Ip, 64),
+		User_Agent:   truncateText(device.UserAgent, 255),
+		Unusual_Hour: isUnusualLoginHour(now),
+	}
+	s.logLoginHistory(history)
+	if !history.Unusual_Hour {
+		return
+	}
+
+	detail := fmt.Sprintf("%s เข้าสู่ระบบนอกเวลาทำการ %s", auth.Username, now.Format("2006-01-02 15:04:05"))
+	event := &model.Security_Event{
+		Event_Type: model.SecurityEventLoginUnusualHour,
+		User_Id:    &userID,
+		Session_Id: &sessionID,
+		Ip:         history.Ip,
+		User_Agent: history.User_Agent,
+		Detail:     detail,
+	}
+	if err := s.authRepository.CreateSecurityEvent(event); err != nil {
+		log.Printf("❌ บันทึกเหตุการณ์ความปลอดภัยไม่สำเร็จ: %v", err)
+	}
+	log.Printf("🌙 %s", detail)
+
+	alert := LoginAlert{
+		User_Id:    auth.Id,
+		Username:   auth.Username,
+		Ip:         device.Ip,
+		User_Agent: device.UserAgent,
+		At:         now,
+	}
+	for _, hook := range s.loginAlertHooks {
+		go hook(alert)
+	}
+}
+
+func (s *authService) GetLoginHistory(userID uint, query LoginHistoryQuery) (*PaginatedLoginHistoryResponse, error) {
+	if _, err := s.authRepository.GetUserByID(userID); err != nil {
+		return nil, errors.New("ไม่พบผู้ใช้")
+	}
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.Limit < 1 {
+		query.Limit = 50
+	}
+	history, total, err := s.authRepository.GetLoginHistory(respository.LoginHistoryFilter{
+		User_Id:  int(userID),
+		Success:  query.Success,
+		DateFrom: query.DateFrom,
+		DateTo:   query.DateTo,
+		Limit:    query.Limit,
+		Offset:   (query.Page - 1) * query.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &PaginatedLoginHistoryResponse{
+		History:     make([]LoginHistoryResponse, 0, len(history)),
+		Total:       total,
+		CurrentPage: query.Page,
+		TotalPages:  int((total + int64(query.Limit) - 1) / int64(query.Limit)),
+	}
+	for _, h := range history {
+		resp.History = append(resp.History, LoginHistoryResponse{
+			Id:           h.Id,
+			Username:     h.Username,
+			Success:      h.Success,
+			Reason:       h.Reason,
+			Reason_Name:  model.LoginFailureNames[h.Reason],
+			Session_Id:   h.Session_Id,
+			Ip:           h.Ip,
+			User_Agent:   h.User_Agent,
+			Unusual_Hour: h.Unusual_Hour,
+			CreatedAt:    h.CreatedAt,
+		})
+	}
+	return resp, nil
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"rrmobile/model"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func (s *authService) GetPasswordPolicy() PasswordPolicyResponse {
+	return currentPasswordPolicy()
+}
+
+// ผู้ใช้เปลี่ยนรหัสผ่านเอง ต้องยืนยันรหัสเดิม รหัสใหม่ต้องไม่ซ้ำกับที่เคยใช้
+// เปลี่ยนสำเร็จแล้วปิดเซสชันอื่นทั้งหมด เหลือเครื่องที่ใช้เปลี่ยนอยู่
+func (s *authService) ChangePassword(userID uint, sessionID uint, request ChangePasswordRequest, device LoginDevice) error {
+	user, err := s.authRepository.GetUserByID(userID)
+	if err != nil || user == nil {
+		return errors.New("ไม่พบผู้ใช้")
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.Old_Password)) != nil {
+		return &PasswordPolicyError{Message: "รหัสผ่านเดิมไม่ถูกต้อง"}
+	}
+
+	policy := currentPasswordPolicy()
+	if err := policy.validate(request.New_Password); err != nil {
+		return err
+	}
+	if request.New_Password == request.Old_Password {
+		return &PasswordPolicyError{Message: "รหัสผ่านใหม่ต้องไม่ซ้ำกับรหัสผ่านเดิม"}
+	}
+	if policy.History > 0 {
+		history, err := s.authRepository.GetPasswordHistory(userID, policy.History)
+		if err != nil {
+			return err
+		}
+		for _, h := range history {
+			if bcrypt.CompareHashAndPassword([]byte(h.Password_Hash), []byte(request.New_Password)) == nil {
+				return &PasswordPolicyError{Message: fmt.Sprintf("รหัสผ่านใหม่ต้องไม่ซ้ำกับ %d รหัสผ่านล่าสุด", policy.History)}
+			}
+		}
+	}
+
+	passwordHash, err := hashPassword(request.New_Password)
+	if err != nil {
+		return err
+	}
+	if err := s.authRepository.SetPassword(userID, passwordHash, false, policy.History); err != nil {
+		return err
+	}
+
+	revoked, err := s.revokeOtherSessions(userID, sessionID)
+	if err != nil {
+		return fmt.Errorf("เปลี่ยนรหัสผ่านแล้ว แต่ปิดเซสชันอื่นไม่สำเร็จ: %w", err)
+	}
+	uid := int(userID)
+	event := &model.Security_Event{
+		Event_Type: model.SecurityEventPasswordChanged,
+		User_Id:    &uid,
+		Ip:         truncateText(device.Ip, 64),
+		User_Agent: truncateText(device.UserAgent, 255),
+		Detail:     fmt.Sprintf("ปิดเซสชันอื่น %d เครื่อง", revoked),
+	}
+	if sessionID != 0 {
+		event.Session_Id = &sessionID
+	}
+	if err := s.authRepository.CreateSecurityEvent(event); err != nil {
+		log.Printf("❌ บันทึกเหตุการณ์ความปลอดภัยไม่สำเร็จ: %v", err)
+	}
+	log.Printf("🔑 ผู้ใช้ %d เปลี่ยนรหัสผ่าน ปิดเซสชันอื่น %d เครื่อง", userID, revoked)
+	return nil
+}
+
+// ผู้ดูแลระบบตั้งรหัสผ่านใหม่ให้: ปิดทุกเซสชันและ token ของผู้ใช้ คนที่ถือรหัสเดิมต้องเข้าสู่ระบบใหม่
+func (s *authService) RevokeSessionsForPasswordReset(actorID uint, userID uint, ip string) error {
+	revoked, err := s.revokeOtherSessions(userID, 0)
+	if err != nil {
+		return err
+	}
+	uid := int(userID)
+	event := &model.Security_Event{
+		Event_Type: model.SecurityEventPasswordReset,
+		User_Id:    &uid,
+		Ip:         truncateText(ip, 64),
+		Detail:     fmt.Sprintf("ผู้ดูแลระบบ %d ตั้งรหัสผ่านใหม่ ปิดเซสชัน %d เครื่อง", actorID, revoked),
+	}
+	if err := s.authRepository.CreateSecurityEvent(event); err != nil {
+		log.Printf("❌ บันทึกเหตุการณ์ความปลอดภัยไม่สำเร็จ: %v", err)
+	}
+	log.Printf("🔑 ผู้ดูแล %d ตั้งรหัสผ่านใหม่ให้ผู้ใช้ %d ปิดเซสชัน %d เครื่อง", actorID, userID, revoked)
+	return nil
+}
+
+// ปิดทุกเซสชันของผู้ใช้ยกเว้น keepSessionID (token เก่าที่ไม่มีเซสชันถูก revoke ทั้งหมด)
+func (s *authService) revokeOtherSessions(userID uint, keepSessionID uint) (int, error) {
+	sessions, err := s.authRepository.GetActiveSessions(userID)
+	if err != nil {
+		return 0, err
+	}
+	others := make([]uint, 0, len(sessions))
+	for _, session := range sessions {
+		if session.Id != keepSessionID {
+			others = append(others, session.Id)
+		}
+	}
+	if len(others) > 0 {
+		if err := s.authRepository.RevokeSessions(others, nil, sessionRevokePassword); err != nil {
+			return 0, err
+		}
+	}
+	if keepSessionID == 0 {
+		if err := s.authRepository.RevokeAllTokensForUser(userID); err != nil {
+			return 0, err
+		}
+		if err := s.authRepository.RevokeAllACCForUser(userID); err != nil {
+			return 0, err
+		}
+	}
+	return len(others), nil
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"rrmobile/model"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// สิทธิ์ของแต่ละ role ถูกเช็กทุก request จึงแคชไว้สั้น ๆ แก้สิทธิ์แล้วล้างแคชทันที
+const permissionCacheTTL = 30 * time.Second
+
+type permissionCache struct {
+	mu    sync.RWMutex
+	roles map[int]cachedRolePermissions
+}
+
+type cachedRolePermissions struct {
+	codes     map[string]bool
+	expiresAt time.Time
+}
+
+func newPermissionCache() *permissionCache {
+	return &permissionCache{roles: map[int]cachedRolePermissions{}}
+}
+
+func (c *permissionCache) get(roleID int) (map[string]bool, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, ok := c.roles[roleID]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.codes, true
+}
+
+func (c *permissionCache) set(roleID int, codes []string) map[string]bool {
+	set := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		set[code] = true
+	}
+	c.mu.Lock()
+	c.roles[roleID] = cachedRolePermissions{codes: set, expiresAt: time.Now().Add(permissionCacheTTL)}
+	c.mu.Unlock()
+	return set
+}
+
+func (c *permissionCache) invalidate(roleID int) {
+	c.mu.Lock()
+	delete(c.roles, roleID)
+	c.mu.Unlock()
+}
+
+// เพิ่มสิทธิ์ใหม่จาก model.PermissionCatalog ลง DB (เรียกตอนเริ่มระบบ)
+func (s *authService) SyncPermissionCatalog() error {
+	added, err := s.authRepository.SyncPermissions(model.PermissionCatalog)
+	if err != nil {
+		return err
+	}
+	if added > 0 {
+		log.Printf("🔑 เพิ่มสิทธิ์ใหม่ %d รายการ", added)
+	}
+	return nil
+}
+
+func (s *authService) GetPermissionCatalog() ([]PermissionResponse, error) {
+	perms, err := s.authRepository.GetPermissions()
+	if err != nil {
+		return nil, err
+	}
+	defaults := map[string][]int{}
+	for _, def := range model.PermissionCatalog {
+		defaults[def.Code] = def.Default_Roles
+	}
+	resp := make([]PermissionResponse, 0, len(perms))
+	for _, p := range perms {
+		resp = append(resp, PermissionResponse{
+			Code:          p.Code,
+			Name:          p.Name,
+			Group:         p.Group_Name,
+			Default_Roles: defaults[p.Code],
+		})
+	}
+	return resp, nil
+}
+
+func (s *authService) GetRolePermissions(roleID int) (*RolePermissionsResponse, error) {
+	exists, err := s.authRepository.RoleExists(roleID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New("ไม่พบ role")
+	}
+	codes, err := s.authRepository.GetRolePermissionCodes(roleID)
+	if err != nil {
+		return nil, err
+	}
+	return &RolePermissionsResponse{Role_Id: roleID, Permissions: codes}, nil
+}
+
+// แทนที่สิทธิ์ทั้งหมดของ role ห้ามถอดสิทธิ์จัดการ role ออกจาก role ของตัวเอง กันล็อกตัวเองออกจากระบบ
+func (s *authService) UpdateRolePermissions(actorRoleID int, roleID int, request UpdateRolePermissionsRequest) (*RolePermissionsResponse, error) {
+	exists, err := s.authRepository.RoleExists(roleID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New("ไม่พบ role")
+	}
+
+	known := make(map[string]bool, len(model.PermissionCatalog))
+	for _, def := range model.PermissionCatalog {
+		known[def.Code] = true
+	}
+	seen := map[string]bool{}
+	codes := make([]string, 0, len(request.Permissions))
+	for _, code := range request.Permissions {
+		code = strings.TrimSpace(code)
+		if !known[code] {
+			return nil, fmt.Errorf("ไม่รู้จักสิทธิ์ %q", code)
+		}
+		if !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+	if roleID == actorRoleID && !seen[model.PermRoleManage] {
+		return nil, errors.New("ไม่สามารถถอดสิทธิ์จัดการ role ออกจาก role ของตัวเองได้")
+	}
+	sort.Strings(codes)
+
+	if err := s.authRepository.SetRolePermissions(roleID, codes); err != nil {
+		return nil, err
+	}
+	s.permissions.invalidate(roleID)
+	log.Printf("🔑 role %d แก้สิทธิ์ของ role %d เป็น %v", actorRoleID, roleID, codes)
+	return &RolePermissionsResponse{Role_Id: roleID, Permissions: codes}, nil
+}
+
+// role มีสิทธิ์อย่างน้อยหนึ่งรายการที่ระบุหรือไม่
+func (s *authService) RoleHasPermissions(roleID int, codes ...string) (bool, error) {
+	granted, ok := s.permissions.get(roleID)
+	if !ok {
+		list, err := s.authRepository.GetRolePermissionCodes(roleID)
+		if err != nil {
+			return false, err
+		}
+		granted = s.permissions.set(roleID, list)
+	}
+	for _, code := range codes {
+		if granted[code] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
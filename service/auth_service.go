@@ -8,6 +8,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"rrmobile/model"
 	"rrmobile/respository"
 	"time"
 
@@ -21,21 +22,24 @@ import (
 // var limiter = rate.NewLimiter(rate.Every(time.Minute), 100) // 5 requests per minute
 
 type authService struct {
-	authRepository respository.AuthRepository
+	authRepository  respository.AuthRepository
+	permissions     *permissionCache
+	loginAlertHooks []LoginAlertHook
 }
 
 func NewAuthService(authRepository respository.AuthRepository) AuthService {
-	return &authService{authRepository: authRepository}
+	return &authService{authRepository: authRepository, permissions: newPermissionCache()}
 }
 
-func GenerateToken(userID uint, username string, roleID int) (string, error) {
+func GenerateToken(userID uint, username string, roleID int, sessionID uint) (string, error) {
 	loc, _ := time.LoadLocation("Asia/Bangkok")
 	now := time.Now().In(loc)
 
 	claims := Claims{
-		UserID:   userID,
-		Username: username,
-		RoleId:   roleID,
+		UserID:    userID,
+		Username:  username,
+		RoleId:    roleID,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
@@ -81,6 +85,9 @@ func (s *authService) ValidateToken(tokenString string) (*Claims, error) {
 	if err != nil || acct == nil || acct.IsRevoked {
 		return nil, errors.New("token revoked")
 	}
+	if acct.Session_Id != nil {
+		_ = s.authRepository.TouchSession(*acct.Session_Id, time.Now())
+	}
 
 	return claims, nil
 }
@@ -124,34 +131,44 @@ func (s *authService) SaveRefreshToken(ctx context.Context, userID uint, token s
 
 	return err
 }
-func (s *authService) SaveACCToken(ctx context.Context, userID uint, token string, expires time.Time) error {
+func (s *authService) SaveACCToken(ctx context.Context, userID uint, sessionID *uint, token string, expires time.Time) error {
 	if userID == 0 || token == "" {
 		return errors.New("invalid input")
 	}
 
 	hashed := HashToken(token) // SHA256
 	acct := respository.AccessToken{
-		User_Id:   userID,
-		Token:     hashed,
-		ExpiresAt: expires,
-		IsRevoked: false,
-		CreatedAt: time.Now(),
+		User_Id:    userID,
+		Session_Id: sessionID,
+		Token:      hashed,
+		ExpiresAt:  expires,
+		IsRevoked:  false,
+		CreatedAt:  time.Now(),
 	}
 
 	return s.authRepository.Save(ctx, &acct)
 }
-func (s *authService) RefreshTokens(ctx context.Context, oldToken string) (string, string, error) {
+func (s *authService) RefreshTokens(ctx context.Context, oldToken string, device LoginDevice) (string, string, error) {
 	if oldToken == "" {
 		return "", "", errors.New("refresh token cannot be empty")
 	}
 
 	// แปลง token เป็น hash เพื่อค้นหาใน DB
 	hashed := HashToken(oldToken)
-	rt, err := s.authRepository.FindValidRefreshTokenByHash(hashed)
+	rt, err := s.authRepository.FindRefreshTokenByHash(hashed)
 	if err != nil || rt == nil {
 		return "", "", ErrTokenExpiredOrInvalid
 	}
 
+	// token ที่แลกไปแล้วถูกส่งมาอีก = มีคนถือ token ชุดเดียวกันสองที่ → ปิดทั้ง family
+	if rt.Rotated_At != nil {
+		s.handleRefreshReuse(rt, device)
+		return "", "", ErrTokenExpiredOrInvalid
+	}
+	if rt.IsRevoked {
+		return "", "", ErrTokenExpiredOrInvalid
+	}
+
 	// ใช้เวลาไทย
 	loc, _ := time.LoadLocation("Asia/Bangkok")
 	now := time.Now().In(loc)
@@ -162,29 +179,73 @@ func (s *authService) RefreshTokens(ctx context.Context, oldToken string) (strin
 		return "", "", errors.New("refresh token expired, please login again")
 	}
 
+	// เซสชันถูกยกเลิกไปแล้ว (ออกจากระบบ / ถูกเตะออก) → ใช้ refresh token นี้ต่อไม่ได้
+	var sessionID uint
+	if rt.Session_Id != nil {
+		session, err := s.authRepository.GetSessionByID(*rt.Session_Id)
+		if err != nil || session.Revoked_At != nil {
+			_ = s.authRepository.RevokeToken(rt.Id)
+			return "", "", ErrTokenExpiredOrInvalid
+		}
+		sessionID = session.Id
+	}
+
 	// ดึงข้อมูล user
 	user, err := s.authRepository.GetUserByID(uint(rt.User_Id))
 	if err != nil {
 		return "", "", fmt.Errorf("failed to retrieve user information: ", err)
 	}
 
-	// Revoke access token เก่าทั้งหมด
-	if err := s.authRepository.RevokeAllACCForUser(user.Id); err != nil {
+	// แลกเป็น refresh token ใหม่ใน family เดิม อายุเท่าเดิม (ไม่ต่ออายุเซสชัน)
+	refreshToken, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+	familyID := rt.Family_Id
+	if familyID == "" {
+		familyID = uuid.NewString() // token ที่ออกก่อนมี family
+	}
+	next := respository.RefreshToken{
+		User_Id:    rt.User_Id,
+		Session_Id: rt.Session_Id,
+		Family_Id:  familyID,
+		TokenHash:  HashToken(refreshToken),
+		ExpiresAt:  rt.ExpiresAt,
+		CreatedAt:  time.Now(),
+	}
+	if err := s.authRepository.RotateRefreshToken(rt, &next); err != nil {
+		if errors.Is(err, respository.ErrRefreshTokenReused) {
+			s.handleRefreshReuse(rt, device)
+			return "", "", ErrTokenExpiredOrInvalid
+		}
+		return "", "", fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	// Revoke access token เก่าของเซสชันนี้ (token เก่าที่ไม่มีเซสชัน revoke ทั้งหมดแบบเดิม)
+	if sessionID != 0 {
+		err = s.authRepository.RevokeSessionACC(sessionID)
+	} else {
+		err = s.authRepository.RevokeAllACCForUser(user.Id)
+	}
+	if err != nil {
 		return "", "", fmt.Errorf("failed to revoke existing tokens: ", err)
 	}
 
 	// สร้าง access token ใหม่
-	accessToken, err := GenerateToken(user.Id, user.Username, user.RoleID)
+	accessToken, err := GenerateToken(user.Id, user.Username, user.RoleID, sessionID)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to generate access token: ", err)
 	}
 
 	// บันทึก access token ใหม่ (อายุ 15 นาที)
-	if err := s.SaveACCToken(ctx, user.Id, accessToken, now.Add(5*time.Minute)); err != nil {
+	if err := s.SaveACCToken(ctx, user.Id, rt.Session_Id, accessToken, now.Add(5*time.Minute)); err != nil {
 		return "", "", fmt.Errorf("failed to save access token: ", err)
 	}
+	if sessionID != 0 {
+		_ = s.authRepository.TouchSession(sessionID, time.Now())
+	}
 
-	return accessToken, oldToken, nil
+	return accessToken, refreshToken, nil
 }
 
 func (s *authService) RevokeAllTokensForUser(userID uint) error {
@@ -225,7 +286,7 @@ func (s *authService) LogoutAndRevokeAll(userID uint) error {
 	return nil
 }
 
-func (s *authService) Login(ctx context.Context, username string, password string) (*AuthResponse, error) {
+func (s *authService) Login(ctx context.Context, username string, password string, device LoginDevice) (*AuthResponse, error) {
 	validate := validator.New()
 	if err := validate.Var(username, "required"); err != nil {
 
@@ -236,7 +297,20 @@ func (s *authService) Login(ctx context.Context, username string, password strin
 		return nil, errors.New("password is required")
 	}
 
+	// username / IP ที่ผิดเกินกำหนดถูกล็อกชั่วคราว ไม่ตรวจรหัสผ่านเลย
+	if err := s.checkLoginLock(username, device); err != nil {
+		var locked *LoginLockedError
+		if errors.As(err, &locked) {
+			s.logLoginFailure(username, nil, model.LoginFailureLocked, device)
+		}
+		return nil, err
+	}
+
 	auth, err := s.authRepository.Login(ctx, username)
+	if errors.Is(err, respository.ErrInvalidCredentials) {
+		s.registerLoginFailure(username, nil, model.LoginFailureUnknownUser, device)
+		return nil, errors.New("invalid username or password")
+	}
 	if err != nil {
 
 		return nil, fmt.Errorf("failed to find user:", err)
@@ -246,30 +320,50 @@ func (s *authService) Login(ctx context.Context, username string, password strin
 		return nil, errors.New("invalid username or password")
 	}
 	if err := bcrypt.CompareHashAndPassword([]byte(auth.Password), []byte(password)); err != nil {
-
+		userID := int(auth.Id)
+		s.registerLoginFailure(username, &userID, model.LoginFailurePassword, device)
 		return nil, errors.New("invalid password")
 	}
 
-	// Revoke token เดิม
-	if err := s.authRepository.RevokeAllTokensForUser(auth.Id); err != nil {
-		return nil, fmt.Errorf("failed to revoke existing tokens: ", err)
+	// บัญชีที่เปิด 2FA (หรือ role บังคับ) ได้แค่ challenge token ต้องยืนยันรหัสก่อนออก token จริง
+	challenge, err := s.startLoginChallenge(auth, device)
+	if err != nil {
+		return nil, err
 	}
-	if err := s.authRepository.RevokeAllACCForUser(auth.Id); err != nil {
-		return nil, fmt.Errorf("failed to revoke existing tokens: ", err)
+	if challenge != nil {
+		return challenge, nil
 	}
 
-	accessToken, err := GenerateToken(auth.Id, auth.Username, auth.RoleID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate access token")
+	s.clearLoginFailures(username)
+	return s.issueSession(ctx, auth, device)
+}
+
+// ออกเซสชันใหม่พร้อม access / refresh token หลังยืนยันตัวตนครบแล้ว
+func (s *authService) issueSession(ctx context.Context, auth *respository.Users, device LoginDevice) (*AuthResponse, error) {
+	// ปิดเซสชันเดิมตามนโยบายของ role (เครื่องเดียว / จำกัดจำนวนเครื่อง)
+	if err := s.enforceSessionPolicy(auth.Id, auth.RoleID); err != nil {
+		return nil, fmt.Errorf("failed to revoke existing tokens: %w", err)
 	}
 
 	// ตั้งค่าเวลาไทย
 	loc, _ := time.LoadLocation("Asia/Bangkok")
 	now := time.Now().In(loc)
 
+	// หมดอายุ refresh token 7 วัน (เซสชันหมดอายุพร้อมกัน)
+	expiresAt_Refresh := now.Add(7 * 24 * time.Hour)
+	session, err := s.createSession(ctx, auth.Id, device, expiresAt_Refresh)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	accessToken, err := GenerateToken(auth.Id, auth.Username, auth.RoleID, session.Id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token")
+	}
+
 	// บันทึก access token ลง DB หมดอายุ 15 นาที (ตัวอย่าง)
 	expiresAt_Acc := now.Add(5 * time.Minute)
-	if err := s.SaveACCToken(ctx, auth.Id, accessToken, expiresAt_Acc); err != nil {
+	if err := s.SaveACCToken(ctx, auth.Id, &session.Id, accessToken, expiresAt_Acc); err != nil {
 		return nil, fmt.Errorf("failed to save access token:", err)
 	}
 
@@ -278,12 +372,18 @@ func (s *authService) Login(ctx context.Context, username string, password strin
 		return nil, fmt.Errorf("failed to generate token: ", err)
 	}
 
-	// หมดอายุ refresh token 7 วัน
-	expiresAt_Refresh := now.Add(7 * 24 * time.Hour)
-	// expiresAt_Refresh := now.Add(5 * time.Minute)
-	if err := s.SaveRefreshToken(ctx, auth.Id, refreshToken, expiresAt_Refresh); err != nil {
+	rt := respository.RefreshToken{
+		User_Id:    int(auth.Id),
+		Session_Id: &session.Id,
+		Family_Id:  uuid.NewString(),
+		TokenHash:  HashToken(refreshToken),
+		ExpiresAt:  expiresAt_Refresh,
+		CreatedAt:  time.Now(),
+	}
+	if err := s.authRepository.SaveRefreshToken(ctx, &rt); err != nil {
 		return nil, fmt.Errorf("failed to save refresh token: ", err)
 	}
+	s.recordLoginSuccess(auth, session.Id, device)
 
 	return &AuthResponse{
 		Id:           auth.Id,
@@ -291,5 +391,8 @@ func (s *authService) Login(ctx context.Context, username string, password strin
 		Token:        accessToken,
 		RoleID:       auth.RoleID,
 		RefreshToken: refreshToken,
+		SessionID:    session.Id,
+
+		Password_Change_Required: passwordChangeRequired(auth.Must_Change_Password, auth.Password_Changed_At, auth.CreatedAt),
 	}, nil
 }
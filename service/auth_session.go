@@ -0,0 +1,328 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"rrmobile/model"
+	"rrmobile/respository"
+	"strings"
+	"time"
+)
+
+// เหตุผลที่เซสชันถูกปิด
+const (
+	sessionRevokeLogout   = "ออกจากระบบ"
+	sessionRevokeSelf     = "ผู้ใช้ยกเลิกเซสชัน"
+	sessionRevokeAdmin    = "ผู้ดูแลระบบยกเลิกเซสชัน"
+	sessionRevokeSingle   = "เข้าสู่ระบบจากเครื่องอื่น (เครื่องเดียว)"
+	sessionRevokeLimited  = "เกินจำนวนเครื่องที่กำหนด"
+	sessionRevokeReuse    = "ตรวจพบ refresh token ถูกใช้ซ้ำ"
+	sessionRevokePassword = "เปลี่ยนรหัสผ่าน"
+)
+
+// เซสชันที่ถูกปิดเพราะ token ถูกขโมย ยังแสดงในรายการเซสชันอีก 7 วัน
+const compromisedSessionVisibleFor = 7 * 24 * time.Hour
+
+func (s *authService) createSession(ctx context.Context, userID uint, device LoginDevice, expiresAt time.Time) (*model.User_Session, error) {
+	name := strings.TrimSpace(device.Name)
+	if name == "" {
+		name = "ไม่ระบุอุปกรณ์"
+	}
+	now := time.Now()
+	session := &model.User_Session{
+		User_Id:      int(userID),
+		Device_Name:  truncateText(name, 100),
+		Ip:           truncateText(device.Ip, 64),
+		User_Agent:   truncateText(device.UserAgent, 255),
+		Last_Seen_At: now,
+		Expires_At:   expiresAt,
+	}
+	if err := s.authRepository.CreateSession(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// ปิดเซสชันเดิมก่อนเข้าสู่ระบบใหม่ตามนโยบายของ role
+func (s *authService) enforceSessionPolicy(userID uint, roleID int) error {
+	policy, err := s.authRepository.GetSessionPolicy(roleID)
+	if err != nil {
+		return err
+	}
+	sessions, err := s.authRepository.GetActiveSessions(userID)
+	if err != nil {
+		return err
+	}
+
+	if policy.Mode == model.SessionModeSingle {
+		if err := s.authRepository.RevokeSessions(sessionIDs(sessions), nil, sessionRevokeSingle); err != nil {
+			return err
+		}
+		// token เก่าที่ออกก่อนมีเซสชัน
+		if err := s.authRepository.RevokeAllTokensForUser(userID); err != nil {
+			return err
+		}
+		return s.authRepository.RevokeAllACCForUser(userID)
+	}
+
+	// หลายเครื่องแบบจำกัดจำนวน: เตะเครื่องที่ไม่ได้ใช้นานที่สุดออกให้เหลือที่ว่างหนึ่งเครื่อง
+	if policy.Max_Sessions > 0 && len(sessions) >= policy.Max_Sessions {
+		return s.authRepository.RevokeSessions(sessionIDs(sessions[policy.Max_Sessions-1:]), nil, sessionRevokeLimited)
+	}
+	return nil
+}
+
+// ออกจากระบบเฉพาะเครื่องนี้ (token เก่าที่ไม่มีเซสชัน ออกทุกเครื่องแบบเดิม)
+func (s *authService) Logout(userID uint, sessionID uint) error {
+	if sessionID == 0 {
+		return s.LogoutAndRevokeAll(userID)
+	}
+	session, err := s.authRepository.GetSessionByID(sessionID)
+	if err != nil {
+		return err
+	}
+	if uint(session.User_Id) != userID {
+		return errors.New("session not found")
+	}
+	return s.authRepository.RevokeSessions([]uint{sessionID}, nil, sessionRevokeLogout)
+}
+
+// ออกจากระบบด้วย refresh token (โหมด cookie ที่ไม่มี access token แล้ว) token ที่ใช้ไม่ได้แล้วถือว่าออกไปแล้ว
+func (s *authService) LogoutByRefreshToken(refreshToken string) error {
+	rt, err := s.authRepository.FindRefreshTokenByHash(HashToken(refreshToken))
+	if err != nil || rt == nil || rt.IsRevoked || rt.Rotated_At != nil {
+		return nil
+	}
+	if rt.Session_Id != nil {
+		return s.authRepository.RevokeSessions([]uint{*rt.Session_Id}, nil, sessionRevokeLogout)
+	}
+	return s.authRepository.RevokeToken(rt.Id)
+}
+
+func (s *authService) GetMySessions(userID uint, currentSessionID uint) ([]SessionResponse, error) {
+	sessions, err := s.GetUserSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].Id == currentSessionID
+	}
+	return sessions, nil
+}
+
+func (s *authService) RevokeMySession(userID uint, sessionID uint) error {
+	session, err := s.authRepository.GetSessionByID(sessionID)
+	if err != nil || uint(session.User_Id) != userID {
+		return errors.New("ไม่พบเซสชัน")
+	}
+	if session.Revoked_At != nil {
+		return errors.New("เซสชันนี้ถูกยกเลิกไปแล้ว")
+	}
+	return s.authRepository.RevokeSessions([]uint{sessionID}, nil, sessionRevokeSelf)
+}
+
+func (s *authService) GetUserSessions(userID uint) ([]SessionResponse, error) {
+	sessions, err := s.authRepository.GetSessionsForList(userID, time.Now().Add(-compromisedSessionVisibleFor))
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		resp = append(resp, toSessionResponse(session))
+	}
+	return resp, nil
+}
+
+// refresh token ที่แลกไปแล้วถูกส่งมาอีก: ปิดทั้ง family + เซสชัน แล้วบันทึกเหตุการณ์ให้ผู้ดูแลตรวจ
+func (s *authService) handleRefreshReuse(rt *respository.RefreshToken, device LoginDevice) {
+	if err := s.authRepository.RevokeRefreshFamily(rt, sessionRevokeReuse); err != nil {
+		log.Printf("❌ ปิด refresh token family %s ไม่สำเร็จ: %v", rt.Family_Id, err)
+	}
+
+	userID := rt.User_Id
+	detail := fmt.Sprintf("refresh token #%d ถูกส่งมาอีกหลังจากถูกแลกไปแล้ว", rt.Id)
+	if rt.Rotated_At != nil {
+		detail = fmt.Sprintf("refresh token #%d ถูกแลกไปแล้วเมื่อ %s แต่ถูกส่งมาอีก", rt.Id, rt.Rotated_At.Format("2006-01-02 15:04:05"))
+	}
+	event := &model.Security_Event{
+		Event_Type: model.SecurityEventRefreshReuse,
+		User_Id:    &userID,
+		Session_Id: rt.Session_Id,
+		Family_Id:  rt.Family_Id,
+		Ip:         truncateText(device.Ip, 64),
+		User_Agent: truncateText(device.UserAgent, 255),
+		Detail:     detail,
+	}
+	if err := s.authRepository.CreateSecurityEvent(event); err != nil {
+		log.Printf("❌ บันทึกเหตุการณ์ความปลอดภัยไม่สำเร็จ: %v", err)
+	}
+	log.Printf("🚨 ตรวจพบ refresh token ถูกใช้ซ้ำ ผู้ใช้ %d family %s จาก %s", rt.User_Id, rt.Family_Id, device.Ip)
+}
+
+func (s *authService) GetSecurityEvents(query SecurityEventQuery) (*PaginatedSecurityEventResponse, error) {
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.Limit < 1 {
+		query.Limit = 50
+	}
+	events, total, err := s.authRepository.GetSecurityEvents(respository.SecurityEventFilter{
+		Event_Type: query.Event_Type,
+		User_Id:    query.User_Id,
+		DateFrom:   query.DateFrom,
+		DateTo:     query.DateTo,
+		Limit:      query.Limit,
+		Offset:     (query.Page - 1) * query.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &PaginatedSecurityEventResponse{
+		Events:      make([]SecurityEventResponse, 0, len(events)),
+		Total:       total,
+		CurrentPage: query.Page,
+		TotalPages:  int((total + int64(query.Limit) - 1) / int64(query.Limit)),
+	}
+	for _, e := range events {
+		resp.Events = append(resp.Events, SecurityEventResponse{
+			Id:         e.Id,
+			Event_Type: e.Event_Type,
+			Event_Name: model.SecurityEventNames[e.Event_Type],
+			User_Id:    e.User_Id,
+			Username:   e.User.Username,
+			Session_Id: e.Session_Id,
+			Ip:         e.Ip,
+			User_Agent: e.User_Agent,
+			Detail:     e.Detail,
+			CreatedAt:  e.CreatedAt,
+		})
+	}
+	return resp, nil
+}
+
+func (s *authService) RevokeUserSession(actorID uint, sessionID uint) error {
+	session, err := s.authRepository.GetSessionByID(sessionID)
+	if err != nil {
+		return errors.New("ไม่พบเซสชัน")
+	}
+	if session.Revoked_At != nil {
+		return errors.New("เซสชันนี้ถูกยกเลิกไปแล้ว")
+	}
+	actor := int(actorID)
+	if err := s.authRepository.RevokeSessions([]uint{sessionID}, &actor, sessionRevokeAdmin); err != nil {
+		return err
+	}
+	log.Printf("🔒 ผู้ดูแล %d ยกเลิกเซสชัน %d ของผู้ใช้ %d", actorID, sessionID, session.User_Id)
+	return nil
+}
+
+// ผู้ดูแลเตะผู้ใช้ออกจากทุกเครื่อง
+func (s *authService) RevokeUserSessions(actorID uint, userID uint) error {
+	if _, err := s.authRepository.GetUserByID(userID); err != nil {
+		return err
+	}
+	sessions, err := s.authRepository.GetActiveSessions(userID)
+	if err != nil {
+		return err
+	}
+	actor := int(actorID)
+	if err := s.authRepository.RevokeSessions(sessionIDs(sessions), &actor, sessionRevokeAdmin); err != nil {
+		return err
+	}
+	if err := s.authRepository.RevokeAllTokensForUser(userID); err != nil {
+		return err
+	}
+	if err := s.authRepository.RevokeAllACCForUser(userID); err != nil {
+		return err
+	}
+	log.Printf("🔒 ผู้ดูแล %d ยกเลิกเซสชันทั้งหมดของผู้ใช้ %d (%d เครื่อง)", actorID, userID, len(sessions))
+	return nil
+}
+
+func (s *authService) GetSessionPolicies() ([]SessionPolicyResponse, error) {
+	policies, err := s.authRepository.GetSessionPolicies()
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]SessionPolicyResponse, 0, len(policies))
+	for _, p := range policies {
+		resp = append(resp, toSessionPolicyResponse(p))
+	}
+	return resp, nil
+}
+
+func (s *authService) UpdateSessionPolicy(roleID int, request UpdateSessionPolicyRequest) (*SessionPolicyResponse, error) {
+	if _, ok := model.SessionModeNames[request.Mode]; !ok {
+		return nil, errors.New("mode ต้องเป็น 1 (เครื่องเดียว) หรือ 2 (หลายเครื่อง)")
+	}
+	if request.Max_Sessions < 0 {
+		return nil, errors.New("max_sessions ต้องไม่ติดลบ")
+	}
+
+	policy, err := s.authRepository.GetSessionPolicy(roleID)
+	if err != nil {
+		return nil, err
+	}
+	policy.Mode = request.Mode
+	policy.Max_Sessions = request.Max_Sessions
+	if request.Require_Two_Factor != nil {
+		policy.Require_Two_Factor = *request.Require_Two_Factor
+	}
+	if policy.Mode == model.SessionModeSingle {
+		policy.Max_Sessions = 1
+	}
+	if err := s.authRepository.SaveSessionPolicy(policy); err != nil {
+		return nil, fmt.Errorf("failed to save session policy: %w", err)
+	}
+	resp := toSessionPolicyResponse(*policy)
+	return &resp, nil
+}
+
+func sessionIDs(sessions []model.User_Session) []uint {
+	ids := make([]uint, 0, len(sessions))
+	for _, session := range sessions {
+		ids = append(ids, session.Id)
+	}
+	return ids
+}
+
+// ตัดข้อความให้ไม่เกินขนาดคอลัมน์ (นับเป็นตัวอักษร ไม่ตัดกลางตัวอักษรไทย)
+func truncateText(value string, size int) string {
+	runes := []rune(strings.TrimSpace(value))
+	if len(runes) > size {
+		return string(runes[:size])
+	}
+	return string(runes)
+}
+
+func toSessionResponse(session model.User_Session) SessionResponse {
+	return SessionResponse{
+		Id:             session.Id,
+		User_Id:        session.User_Id,
+		Device_Name:    session.Device_Name,
+		Ip:             session.Ip,
+		User_Agent:     session.User_Agent,
+		CreatedAt:      session.CreatedAt,
+		Last_Seen_At:   session.Last_Seen_At,
+		Expires_At:     session.Expires_At,
+		Revoked_At:     session.Revoked_At,
+		Revoke_Reason:  session.Revoke_Reason,
+		Compromised:    session.Compromised_At != nil,
+		Compromised_At: session.Compromised_At,
+	}
+}
+
+func toSessionPolicyResponse(p model.Session_Policy) SessionPolicyResponse {
+	return SessionPolicyResponse{
+		RoleID:       p.RoleID,
+		Mode:         p.Mode,
+		Mode_Name:    model.SessionModeNames[p.Mode],
+		Max_Sessions: p.Max_Sessions,
+		UpdatedAt:    p.UpdatedAt,
+
+		Require_Two_Factor: p.Require_Two_Factor,
+	}
+}
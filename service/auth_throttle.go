@@ -0,0 +1,231 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"rrmobile/model"
+	"rrmobile/respository"
+	"strings"
+	"time"
+)
+
+// ผิดครบ 5 ครั้งใน 15 นาทีล็อกบัญชี 15 นาที ล็อกซ้ำนานขึ้นเท่าตัวจนถึง 1 วัน
+var loginUsernameRule = respository.LoginThrottleRule{
+	Window:       15 * time.Minute,
+	Max_Failures: 5,
+	Lock_For:     15 * time.Minute,
+	Max_Lock_For: 24 * time.Hour,
+}
+
+// ต่อ IP ให้หลวมกว่า เพราะพนักงานทั้งร้านมักออกเน็ตผ่าน IP เดียวกัน
+var loginIpRule = respository.LoginThrottleRule{
+	Window:       15 * time.Minute,
+	Max_Failures: 30,
+	Lock_For:     15 * time.Minute,
+	Max_Lock_For: 6 * time.Hour,
+}
+
+// ตัวนับที่ไม่มีการเข้าสู่ระบบผิดเกิน 1 วันจะถูกลบทิ้ง
+const loginThrottleRetention = 24 * time.Hour
+
+// เข้าสู่ระบบไม่ได้เพราะถูกล็อกชั่วคราว
+type LoginLockedError struct {
+	Until time.Time
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("เข้าสู่ระบบผิดหลายครั้ง ถูกล็อกชั่วคราวถึง %s", e.Until.Format("2006-01-02 15:04"))
+}
+
+func loginThrottleKey(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// ตรวจว่า username / IP ถูกล็อกอยู่หรือไม่ (ถ้าล็อกทั้งคู่ใช้เวลาที่ปลดช้าที่สุด)
+func (s *authService) checkLoginLock(username string, device LoginDevice) error {
+	locks, err := s.authRepository.GetActiveLoginLocks(loginThrottleKey(username), device.Ip)
+	if err != nil {
+		return err
+	}
+	if len(locks) == 0 {
+		return nil
+	}
+	return &LoginLockedError{Until: *locks[0].Locked_Until}
+}
+
+// บันทึกการเข้าสู่ระบบผิดและนับเข้าตัวนับของ username / IP
+func (s *authService) registerLoginFailure(username string, userID *int, reason string, device LoginDevice) {
+	s.logLoginFailure(username, userID, reason, device)
+
+	key := loginThrottleKey(username)
+	if key != "" {
+		throttle, locked, err := s.authRepository.RegisterLoginFailure(model.LoginThrottleUsername, key, loginUsernameRule)
+		if err != nil {
+			log.Printf("❌ นับการเข้าสู่ระบบผิดของ %s ไม่สำเร็จ: %v", key, err)
+		} else if locked {
+			s.logLoginLocked(throttle, userID, device)
+		}
+	}
+	if device.Ip != "" {
+		throttle, locked, err := s.authRepository.RegisterLoginFailure(model.LoginThrottleIp, device.Ip, loginIpRule)
+		if err != nil {
+			log.Printf("❌ นับการเข้าสู่ระบบผิดของ IP %s ไม่สำเร็จ: %v", device.Ip, err)
+		} else if locked {
+			s.logLoginLocked(throttle, nil, device)
+		}
+	}
+}
+
+func (s *authService) logLoginFailure(username string, userID *int, reason string, device LoginDevice) {
+	s.logLoginHistory(&model.Login_History{
+		Username:   truncateText(strings.TrimSpace(username), 255),
+		User_Id:    userID,
+		Reason:     reason,
+		Ip:         truncateText(device.Ip, 64),
+		User_Agent: truncateText(device.UserAgent, 255),
+	})
+}
+
+func (s *authService) logLoginLocked(throttle *model.Login_Throttle, userID *int, device LoginDevice) {
+	detail := fmt.Sprintf("%s %s เข้าสู่ระบบผิดครบ %d ครั้ง ล็อกถึง %s (ครั้งที่ %d)",
+		throttle.Scope, throttle.Throttle_Key, loginRuleFor(throttle.Scope).Max_Failures,
+		throttle.Locked_Until.Format("2006-01-02 15:04:05"), throttle.Lock_Count)
+	event := &model.Security_Event{
+		Event_Type: model.SecurityEventLoginLocked,
+		User_Id:    userID,
+		Ip:         truncateText(device.Ip, 64),
+		User_Agent: truncateText(device.UserAgent, 255),
+		Detail:     detail,
+	}
+	if err := s.authRepository.CreateSecurityEvent(event); err != nil {
+		log.Printf("❌ บันทึกเหตุการณ์ความปลอดภัยไม่สำเร็จ: %v", err)
+	}
+	log.Printf("🔒 %s", detail)
+}
+
+// เข้าสู่ระบบสำเร็จครบทุกขั้นตอน ล้างตัวนับของ username (ตัวนับ IP ปล่อยให้หมดรอบเอง)
+func (s *authService) clearLoginFailures(username string) {
+	if err := s.authRepository.ClearLoginThrottle(model.LoginThrottleUsername, loginThrottleKey(username)); err != nil {
+		log.Printf("❌ ล้างตัวนับการเข้าสู่ระบบผิดของ %s ไม่สำเร็จ: %v", username, err)
+	}
+}
+
+func loginRuleFor(scope string) respository.LoginThrottleRule {
+	if scope == model.LoginThrottleIp {
+		return loginIpRule
+	}
+	return loginUsernameRule
+}
+
+func (s *authService) GetLoginLocks() ([]LoginLockResponse, error) {
+	locks, err := s.authRepository.GetLockedLoginThrottles()
+	if err != nil {
+		return nil, err
+	}
+	resp := make([]LoginLockResponse, 0, len(locks))
+	for _, l := range locks {
+		resp = append(resp, LoginLockResponse{
+			Id:              l.Id,
+			Scope:           l.Scope,
+			Key:             l.Throttle_Key,
+			Locked_Until:    l.Locked_Until,
+			Lock_Count:      l.Lock_Count,
+			Last_Failure_At: l.Last_Failure_At,
+		})
+	}
+	return resp, nil
+}
+
+func (s *authService) UnlockLogin(actorID uint, lockID uint) error {
+	throttle, err := s.authRepository.GetLoginThrottleByID(lockID)
+	if err != nil {
+		return errors.New("ไม่พบรายการล็อก")
+	}
+	if err := s.authRepository.DeleteLoginThrottle(throttle.Id); err != nil {
+		return err
+	}
+	s.logLoginUnlock(actorID, nil, throttle.Scope, throttle.Throttle_Key)
+	return nil
+}
+
+// ปลดล็อกบัญชีผู้ใช้ (เฉพาะตัวนับของ username ถ้า IP ถูกล็อกต้องปลดแยก)
+func (s *authService) UnlockUserLogin(actorID uint, userID uint) error {
+	user, err := s.authRepository.GetUserByID(userID)
+	if err != nil || user == nil {
+		return errors.New("ไม่พบผู้ใช้")
+	}
+	key := loginThrottleKey(user.Username)
+	if err := s.authRepository.ClearLoginThrottle(model.LoginThrottleUsername, key); err != nil {
+		return err
+	}
+	uid := int(userID)
+	s.logLoginUnlock(actorID, &uid, model.LoginThrottleUsername, key)
+	return nil
+}
+
+func (s *authService) logLoginUnlock(actorID uint, userID *int, scope, key string) {
+	detail := fmt.Sprintf("ผู้ดูแลระบบ %d ปลดล็อก %s %s", actorID, scope, key)
+	event := &model.Security_Event{
+		Event_Type: model.SecurityEventLoginUnlock,
+		User_Id:    userID,
+		Detail:     detail,
+	}
+	if err := s.authRepository.CreateSecurityEvent(event); err != nil {
+		log.Printf("❌ บันทึกเหตุการณ์ความปลอดภัยไม่สำเร็จ: %v", err)
+	}
+	log.Printf("🔓 %s", detail)
+}
+
+func (s *authService) GetLoginFailures(query LoginFailureQuery) (*PaginatedLoginFailureResponse, error) {
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.Limit < 1 {
+		query.Limit = 50
+	}
+	failures, total, err := s.authRepository.GetLoginFailures(respository.LoginFailureFilter{
+		Username: strings.TrimSpace(query.Username),
+		Ip:       strings.TrimSpace(query.Ip),
+		User_Id:  query.User_Id,
+		DateFrom: query.DateFrom,
+		DateTo:   query.DateTo,
+		Limit:    query.Limit,
+		Offset:   (query.Page - 1) * query.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &PaginatedLoginFailureResponse{
+		Failures:    make([]LoginFailureResponse, 0, len(failures)),
+		Total:       total,
+		CurrentPage: query.Page,
+		TotalPages:  int((total + int64(query.Limit) - 1) / int64(query.Limit)),
+	}
+	for _, f := range failures {
+		resp.Failures = append(resp.Failures, LoginFailureResponse{
+			Id:          f.Id,
+			Username:    f.Username,
+			User_Id:     f.User_Id,
+			Reason:      f.Reason,
+			Reason_Name: model.LoginFailureNames[f.Reason],
+			Ip:          f.Ip,
+			User_Agent:  f.User_Agent,
+			CreatedAt:   f.CreatedAt,
+		})
+	}
+	return resp, nil
+}
+
+// ลบตัวนับเก่า (เรียกจาก cron รายวัน)
+func (s *authService) PurgeLoginThrottles() error {
+	purged, err := s.authRepository.PurgeLoginThrottles(time.Now().Add(-loginThrottleRetention))
+	if err != nil {
+		return err
+	}
+	if purged > 0 {
+		log.Printf("🧹 ลบตัวนับการเข้าสู่ระบบผิดที่หมดอายุ %d รายการ", purged)
+	}
+	return nil
+}
@@ -0,0 +1,421 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"log"
+	"rrmobile/model"
+	"rrmobile/respository"
+	"rrmobile/util"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	loginChallengeTTL         = 5 * time.Minute
+	loginChallengeMaxAttempts = 5
+	recoveryCodeCount         = 10
+)
+
+var (
+	errChallengeInvalid   = errors.New("challenge token ไม่ถูกต้องหรือหมดอายุ กรุณาเข้าสู่ระบบใหม่")
+	errTwoFactorCode      = errors.New("รหัสยืนยันไม่ถูกต้อง")
+	errTwoFactorNotSet    = errors.New("บัญชีนี้ยังไม่ได้เปิดใช้ 2FA")
+	errTwoFactorCodeReuse = errors.New("รหัสนี้ถูกใช้ไปแล้ว กรุณารอรหัสถัดไป")
+)
+
+func twoFactorIssuer() string {
+	if issuer := strings.TrimSpace(viper.GetString("TOTP_ISSUER")); issuer != "" {
+		return issuer
+	}
+	return "RR Mobile"
+}
+
+// หลังตรวจรหัสผ่านถูก: ถ้าต้องยืนยัน 2FA คืน challenge token แทน token จริง (nil = ไม่ต้องยืนยัน)
+func (s *authService) startLoginChallenge(auth *respository.Users, device LoginDevice) (*AuthResponse, error) {
+	tf, err := s.authRepository.GetTwoFactor(auth.Id)
+	if err != nil {
+		return nil, err
+	}
+	setup := false
+	if tf == nil || tf.Enabled_At == nil {
+		policy, err := s.authRepository.GetSessionPolicy(auth.RoleID)
+		if err != nil {
+			return nil, err
+		}
+		if !policy.Require_Two_Factor {
+			return nil, nil
+		}
+		setup = true
+	}
+
+	token, err := GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	challenge := &model.Login_Challenge{
+		User_Id:     int(auth.Id),
+		Token_Hash:  HashToken(token),
+		Setup:       setup,
+		Device_Name: truncateText(strings.TrimSpace(device.Name), 100),
+		Ip:          truncateText(device.Ip, 64),
+		User_Agent:  truncateText(device.UserAgent, 255),
+		Expires_At:  time.Now().Add(loginChallengeTTL),
+	}
+	if err := s.authRepository.CreateLoginChallenge(challenge); err != nil {
+		return nil, err
+	}
+
+	return &AuthResponse{
+		Id:                  auth.Id,
+		Username:            auth.Username,
+		RoleID:              auth.RoleID,
+		Two_Factor_Required: true,
+		Two_Factor_Setup:    setup,
+		Challenge_Token:     token,
+	}, nil
+}
+
+func (s *authService) loadLoginChallenge(challengeToken string) (*model.Login_Challenge, error) {
+	challengeToken = strings.TrimSpace(challengeToken)
+	if challengeToken == "" {
+		return nil, errChallengeInvalid
+	}
+	challenge, err := s.authRepository.GetLoginChallenge(HashToken(challengeToken))
+	if err != nil {
+		return nil, err
+	}
+	if challenge == nil || challenge.Used_At != nil || time.Now().After(challenge.Expires_At) {
+		return nil, errChallengeInvalid
+	}
+	if challenge.Attempts >= loginChallengeMaxAttempts {
+		return nil, errors.New("ยืนยันรหัสผิดเกินจำนวนครั้ง กรุณาเข้าสู่ระบบใหม่")
+	}
+	return challenge, nil
+}
+
+// ลงทะเบียน 2FA ระหว่างเข้าสู่ระบบ (role บังคับใช้แต่ผู้ใช้ยังไม่เคยลงทะเบียน)
+func (s *authService) SetupLoginTwoFactor(challengeToken string) (*TwoFactorSetupResponse, error) {
+	challenge, err := s.loadLoginChallenge(challengeToken)
+	if err != nil {
+		return nil, err
+	}
+	if !challenge.Setup {
+		return nil, errors.New("บัญชีนี้เปิดใช้ 2FA แล้ว กรุณากรอกรหัสจากแอป")
+	}
+	return s.newPendingTwoFactor(uint(challenge.User_Id))
+}
+
+// แลก challenge token + รหัส 2FA เป็นเซสชันจริง
+func (s *authService) VerifyLoginChallenge(ctx context.Context, challengeToken string, code string) (*AuthResponse, error) {
+	challenge, err := s.loadLoginChallenge(challengeToken)
+	if err != nil {
+		return nil, err
+	}
+	userID := uint(challenge.User_Id)
+	user, err := s.authRepository.GetUserByID(userID)
+	if err != nil || user == nil {
+		return nil, errors.New("ไม่พบผู้ใช้")
+	}
+	device := LoginDevice{Name: challenge.Device_Name, Ip: challenge.Ip, UserAgent: challenge.User_Agent}
+	if err := s.checkLoginLock(user.Username, device); err != nil {
+		return nil, err
+	}
+
+	tf, err := s.authRepository.GetTwoFactor(userID)
+	if err != nil {
+		return nil, err
+	}
+	if tf == nil || (challenge.Setup && tf.Enabled_At != nil) || (!challenge.Setup && tf.Enabled_At == nil) {
+		if challenge.Setup {
+			return nil, errors.New("กรุณาขอ secret สำหรับลงทะเบียน 2FA ก่อน")
+		}
+		return nil, errChallengeInvalid
+	}
+
+	// ลงทะเบียนครั้งแรกต้องใช้รหัสจากแอปเท่านั้น (ยังไม่มีรหัสกู้คืน)
+	if err := s.verifyTwoFactorCode(tf, code, !challenge.Setup); err != nil {
+		if incErr := s.authRepository.IncrementChallengeAttempts(challenge.Id); incErr != nil {
+			log.Printf("❌ บันทึกจำนวนครั้งที่ยืนยัน 2FA ผิดไม่สำเร็จ: %v", incErr)
+		}
+		if errors.Is(err, errTwoFactorCode) {
+			s.registerLoginFailure(user.Username, &challenge.User_Id, model.LoginFailureTwoFactor, device)
+		}
+		return nil, err
+	}
+	if err := s.authRepository.ConsumeLoginChallenge(challenge.Id); err != nil {
+		return nil, errChallengeInvalid
+	}
+
+	var recoveryCodes []string
+	if challenge.Setup {
+		if recoveryCodes, err = s.enableTwoFactor(tf); err != nil {
+			return nil, err
+		}
+	}
+
+	s.clearLoginFailures(user.Username)
+	resp, err := s.issueSession(ctx, user, device)
+	if err != nil {
+		return nil, err
+	}
+	resp.Recovery_Codes = recoveryCodes
+	return resp, nil
+}
+
+func (s *authService) GetTwoFactorStatus(userID uint) (*TwoFactorStatusResponse, error) {
+	user, err := s.authRepository.GetUserByID(userID)
+	if err != nil || user == nil {
+		return nil, errors.New("ไม่พบผู้ใช้")
+	}
+	policy, err := s.authRepository.GetSessionPolicy(user.RoleID)
+	if err != nil {
+		return nil, err
+	}
+	status := &TwoFactorStatusResponse{Required: policy.Require_Two_Factor}
+
+	tf, err := s.authRepository.GetTwoFactor(userID)
+	if err != nil {
+		return nil, err
+	}
+	if tf != nil && tf.Enabled_At != nil {
+		status.Enabled = true
+		status.Enabled_At = tf.Enabled_At
+		if status.Recovery_Codes_Left, err = s.authRepository.CountRecoveryCodes(userID); err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// เริ่มลงทะเบียน 2FA ด้วยตัวเอง ต้องยืนยันรหัสที่ /2fa/enable ก่อนจึงจะมีผล
+func (s *authService) SetupTwoFactor(userID uint) (*TwoFactorSetupResponse, error) {
+	tf, err := s.authRepository.GetTwoFactor(userID)
+	if err != nil {
+		return nil, err
+	}
+	if tf != nil && tf.Enabled_At != nil {
+		return nil, errors.New("บัญชีนี้เปิดใช้ 2FA อยู่แล้ว")
+	}
+	return s.newPendingTwoFactor(userID)
+}
+
+func (s *authService) EnableTwoFactor(userID uint, code string) ([]string, error) {
+	tf, err := s.authRepository.GetTwoFactor(userID)
+	if err != nil {
+		return nil, err
+	}
+	if tf == nil {
+		return nil, errors.New("กรุณาขอ secret สำหรับลงทะเบียน 2FA ก่อน")
+	}
+	if tf.Enabled_At != nil {
+		return nil, errors.New("บัญชีนี้เปิดใช้ 2FA อยู่แล้ว")
+	}
+	if err := s.verifyTwoFactorCode(tf, code, false); err != nil {
+		return nil, err
+	}
+	return s.enableTwoFactor(tf)
+}
+
+func (s *authService) DisableTwoFactor(userID uint, code string) error {
+	tf, err := s.enabledTwoFactor(userID)
+	if err != nil {
+		return err
+	}
+	user, err := s.authRepository.GetUserByID(userID)
+	if err != nil || user == nil {
+		return errors.New("ไม่พบผู้ใช้")
+	}
+	policy, err := s.authRepository.GetSessionPolicy(user.RoleID)
+	if err != nil {
+		return err
+	}
+	if policy.Require_Two_Factor {
+		return errors.New("role นี้บังคับใช้ 2FA ไม่สามารถปิดได้")
+	}
+	if err := s.verifyTwoFactorCode(tf, code, true); err != nil {
+		return err
+	}
+	if err := s.authRepository.DeleteTwoFactor(userID); err != nil {
+		return err
+	}
+	log.Printf("🔓 ผู้ใช้ %d ปิดใช้ 2FA", userID)
+	return nil
+}
+
+// ออกรหัสกู้คืนชุดใหม่ (ชุดเดิมใช้ไม่ได้ทันที)
+func (s *authService) RegenerateRecoveryCodes(userID uint, code string) ([]string, error) {
+	tf, err := s.enabledTwoFactor(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifyTwoFactorCode(tf, code, false); err != nil {
+		return nil, err
+	}
+	plain, codes, err := newRecoveryCodes(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.authRepository.ReplaceRecoveryCodes(userID, codes); err != nil {
+		return nil, err
+	}
+	return plain, nil
+}
+
+// ผู้ดูแลระบบล้าง 2FA ให้ผู้ใช้ที่ทำเครื่องหาย (ถ้า role บังคับ จะต้องลงทะเบียนใหม่ตอนเข้าสู่ระบบครั้งถัดไป)
+func (s *authService) ResetTwoFactor(actorID uint, userID uint) error {
+	tf, err := s.authRepository.GetTwoFactor(userID)
+	if err != nil {
+		return err
+	}
+	if tf == nil {
+		return errTwoFactorNotSet
+	}
+	if err := s.authRepository.DeleteTwoFactor(userID); err != nil {
+		return err
+	}
+	log.Printf("🔓 ผู้ดูแลระบบ %d ล้าง 2FA ของผู้ใช้ %d", actorID, userID)
+	return nil
+}
+
+func (s *authService) enabledTwoFactor(userID uint) (*model.User_Two_Factor, error) {
+	tf, err := s.authRepository.GetTwoFactor(userID)
+	if err != nil {
+		return nil, err
+	}
+	if tf == nil || tf.Enabled_At == nil {
+		return nil, errTwoFactorNotSet
+	}
+	return tf, nil
+}
+
+// สร้าง secret ใหม่ที่ยังไม่เปิดใช้ (ขอซ้ำได้ secret เดิมจะถูกแทนที่)
+func (s *authService) newPendingTwoFactor(userID uint) (*TwoFactorSetupResponse, error) {
+	user, err := s.authRepository.GetUserByID(userID)
+	if err != nil || user == nil {
+		return nil, errors.New("ไม่พบผู้ใช้")
+	}
+	tf, err := s.authRepository.GetTwoFactor(userID)
+	if err != nil {
+		return nil, err
+	}
+	if tf == nil {
+		tf = &model.User_Two_Factor{User_Id: int(userID)}
+	}
+
+	secret, err := util.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := util.EncryptSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+	tf.Secret = encrypted
+	tf.Enabled_At = nil
+	tf.Last_Used_Step = 0
+	if err := s.authRepository.SaveTwoFactor(tf); err != nil {
+		return nil, err
+	}
+
+	issuer := twoFactorIssuer()
+	return &TwoFactorSetupResponse{
+		Secret:           secret,
+		Provisioning_Uri: util.TOTPProvisioningURI(issuer, user.Username, secret),
+		Issuer:           issuer,
+		Account:          user.Username,
+	}, nil
+}
+
+func (s *authService) enableTwoFactor(tf *model.User_Two_Factor) ([]string, error) {
+	plain, codes, err := newRecoveryCodes(uint(tf.User_Id))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	tf.Enabled_At = &now
+	if err := s.authRepository.EnableTwoFactor(tf, codes); err != nil {
+		return nil, err
+	}
+	log.Printf("🔐 ผู้ใช้ %d เปิดใช้ 2FA", tf.User_Id)
+	return plain, nil
+}
+
+// ตรวจรหัส 6 หลักจากแอป (ใช้รหัสเดิมซ้ำไม่ได้) หรือรหัสกู้คืนถ้าอนุญาต
+func (s *authService) verifyTwoFactorCode(tf *model.User_Two_Factor, code string, allowRecovery bool) error {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return errors.New("กรุณากรอกรหัสยืนยัน")
+	}
+	userID := uint(tf.User_Id)
+
+	if isDigits(code) {
+		secret, err := util.DecryptSecret(tf.Secret)
+		if err != nil {
+			return err
+		}
+		step, ok := util.ValidateTOTP(secret, code, time.Now())
+		if !ok {
+			return errTwoFactorCode
+		}
+		fresh, err := s.authRepository.MarkTwoFactorStep(userID, step)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return errTwoFactorCodeReuse
+		}
+		return nil
+	}
+
+	if !allowRecovery {
+		return errTwoFactorCode
+	}
+	used, err := s.authRepository.UseRecoveryCode(userID, HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return errTwoFactorCode
+	}
+	log.Printf("🔑 ผู้ใช้ %d ยืนยัน 2FA ด้วยรหัสกู้คืน", userID)
+	return nil
+}
+
+// รหัสกู้คืนรูปแบบ XXXX-XXXX (base32) เก็บเฉพาะ hash
+func newRecoveryCodes(userID uint) ([]string, []model.User_Recovery_Code, error) {
+	plain := make([]string, 0, recoveryCodeCount)
+	codes := make([]model.User_Recovery_Code, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := base32.StdEncoding.EncodeToString(b)
+		plain = append(plain, raw[:4]+"-"+raw[4:])
+		codes = append(codes, model.User_Recovery_Code{
+			User_Id:   int(userID),
+			Code_Hash: HashToken(raw),
+		})
+	}
+	return plain, codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
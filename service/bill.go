@@ -48,6 +48,17 @@ type Bill_HeaderResponse struct {
 
 	Credit_Balance float64                `json:"credit_balance"`
 	BillDetails    []Bill_DetailsResponse `json:"bill_details"`
+
+	Down_Payment    float64            `json:"down_payment"`
+	Trade_In_Amount float64            `json:"trade_in_amount"`
+	Trade_In        *TradeInResponse   `json:"trade_in,omitempty"`
+	Upfront_Amount  float64            `json:"upfront_amount"`
+	Lines           []ContractLineItem `json:"lines,omitempty"`
+
+	Schedule_Version int                       `json:"schedule_version"`
+	Restructures     []BillRestructureResponse `json:"restructures,omitempty"`
+
+	Down_Payment_Receipt *PaymentReceiptResponse `json:"down_payment_receipt,omitempty"`
 }
 
 type Bill_DetailsResponse struct {
@@ -207,12 +218,20 @@ type NewBillHeader struct {
 	Fee_Amount float64 `json:"fee_amount"`
 
 	Status int `json:"status"`
+
+	Trade_In *TradeInRequest   `json:"trade_in"` // เครื่องเก่าที่นำมาเทิร์นเป็นเงินดาวน์
+	Lines    []BillLineRequest `json:"lines"`    // รายการเสริม เช่น เคส ฟิล์ม ประกัน
+
+	Down_Payment_Method    string `json:"down_payment_method"`    // cash, transfer, card, promptpay (ไม่ระบุ = cash)
+	Down_Payment_Reference string `json:"down_payment_reference"` // เลขอ้างอิงสลิปโอน / บัตร
 }
 
 type UpdateAddExtraRequest struct {
 	BillID        uint    `json:"bill_id"`
 	InstallmentID uint    `json:"installment_id"`
 	Paid_Amount   float64 `json:"paid_amount"`
+
+	Tenders []PaymentTenderRequest `json:"tenders"` // แบ่งจ่ายหลายช่องทาง (ไม่ระบุ = เงินสดเต็มจำนวน)
 }
 type PaginationResponseBill struct {
 	Total       int64                 `json:"total"`
@@ -271,6 +290,8 @@ type NewInstallmentBillHeader struct {
 
 	// Installmen	TermType        int
 	TermType int `json:"term_type"`
+
+	AppraisalId uint `json:"appraisal_id"` // ใบประเมินสภาพเครื่อง (บังคับ)
 }
 
 type Bill_HeaderResponse_Installment struct {
@@ -322,6 +343,8 @@ type UpdateAddExtraRequest_Installment struct {
 	BillID        uint    `json:"bill_id"`
 	InstallmentID uint    `json:"installment_id"`
 	Paid_Amount   float64 `json:"paid_amount"`
+
+	Tenders []PaymentTenderRequest `json:"tenders"` // แบ่งจ่ายหลายช่องทาง (ไม่ระบุ = เงินสดเต็มจำนวน)
 }
 type BillHeaderSummary struct {
 	PaidBillCount   int64 `json:"paid_bill_count"`
@@ -341,6 +364,13 @@ type InstallmentPayResult struct {
 	Message       string  `json:"message"`
 	CreditLeft    float64 `json:"credit_left"`
 	PaidAmount    float64 `json:"paid_amount"`
+
+	Detail_Id      uint    `json:"detail_id"`
+	Fee_Paid       float64 `json:"fee_paid"`
+	Interest_Paid  float64 `json:"interest_paid"`
+	Principal_Paid float64 `json:"principal_paid"`
+	Payment_Id     uint    `json:"payment_id"`
+	Receipt_No     string  `json:"receipt_no"`
 }
 type Bill_Details_Installment struct {
 	Id uint `json:"id"`
@@ -619,9 +649,9 @@ type Bill_HeaderResponse_Installment3 struct {
 
 }
 type BillService interface {
-	CreateBill(request NewBillHeader) (*Bill_HeaderResponse, error)
-	AddExtraPayment(billID uint, installmentID uint, request UpdateAddExtraRequest) error
-	PayInstallment(billID uint, detailID uint, amount float64) ([]InstallmentPayResult, error)
+	CreateBill(request NewBillHeader, actor AuditActor) (*Bill_HeaderResponse, error)
+	AddExtraPayment(billID uint, installmentID uint, request UpdateAddExtraRequest, actorID int) error
+	PayInstallment(billID uint, detailID uint, amount float64, tenders []PaymentTenderRequest, actorID int) ([]InstallmentPayResult, error)
 	AutoApplyLateFees() error
 	GetAllBill(
 		invs []string,
@@ -631,21 +661,21 @@ type BillService interface {
 	) (*PaginationResponseBill, error)
 	GetBillById(id uint) (*Bill_HeaderResponse, error)
 	GetBillDetailById(id uint) (*Bill_DetailsResponse, error)
-	CreateInstallmentBill(request NewInstallmentBillHeader, installMentId uint) (*Bill_HeaderResponse_Installment, error)
+	CreateInstallmentBill(request NewInstallmentBillHeader, installMentId uint, actor AuditActor) (*Bill_HeaderResponse_Installment, error)
 	GetInstallmentBillById(id uint) (*Bill_HeaderResponse_Installment, error)
 	GetInstallmentBillDetailById(id uint) (*Bill_Details_Installment, error)
-	PayPurchaseInstallment(billID uint, detailID uint, amount float64) ([]InstallmentPayResult, error)
+	PayPurchaseInstallment(billID uint, detailID uint, amount float64, tenders []PaymentTenderRequest, actorID int) ([]InstallmentPayResult, error)
 	AutoApplyInstallementLateFees() error
 	//  AutoApplyInstallmentLateFees() error
-	AddInstallmentExtraPayment(billID uint, installmentID uint, request UpdateAddExtraRequest_Installment) error
+	AddInstallmentExtraPayment(billID uint, installmentID uint, request UpdateAddExtraRequest_Installment, actorID int) error
 	GetAllInstallmentBill(
 		invs []string,
 		dateFrom, dateTo *time.Time,
 		page, limit int,
 		sortOrder int, // <-- เพิ่มตรงนี้
 	) (*PaginationResponseBillInstallment, error)
-	UpdateBill(id uint, request Update_Installment) (*Bill_HeaderResponse, error)
-	UpdateBill_Installment(id uint, request Update_Installment) (*Bill_HeaderResponse_Installment, error)
+	UpdateBill(id uint, request Update_Installment, actor AuditActor) (*Bill_HeaderResponse, error)
+	UpdateBill_Installment(id uint, request Update_Installment, actor AuditActor) (*Bill_HeaderResponse_Installment, error)
 	GetAllBillUnpay(
 		invs []string,
 		dateFrom, dateTo *time.Time,
@@ -672,9 +702,287 @@ type BillService interface {
 
 	UpdateDailyInterest() error
 	UpdateDailyInterestSingle(testDate ...time.Time) error
-	RenewInterest(billID uint, payAmount float64, payDate time.Time ) (*model.Bill_Header_Installment, error)
+	RenewInterest(billID uint, payAmount float64, payDate time.Time, userID int, paymentRef string, tenders []PaymentTenderRequest) (*model.Bill_Header_Installment, error)
+	GetPawnRenewalHistory(billID uint) (*PawnRenewalHistoryResponse, error)
+	GetPawnRenewalHistoryForMember(userId string, billID uint) (*PawnRenewalHistoryResponse, error)
+	ReducePawnPrincipal(billID uint, request PawnPrincipalChangeRequest, userID int) (*PawnPrincipalChangeResponse, error)
+	TopUpPawnPrincipal(billID uint, request PawnPrincipalChangeRequest, userID int) (*PawnPrincipalChangeResponse, error)
+	GetPawnPrincipalChanges(billID uint) ([]PawnPrincipalChangeResponse, error)
+
+	TransitionBill(billType int, billID uint, transition string, actorID int, reason string) (*BillTransitionResponse, error)
+	GetBillStatusHistory(billType int, billID uint) (*BillStatusHistoryResponse, error)
+	GetBillStateMachine() []BillStateTransitionInfo
+	UpdateOverdueStatuses(testDate ...time.Time) error
+
+	RestructureBill(billID uint, request RestructureBillRequest, approverID int) (*BillRestructureResponse, error)
+	GetBillRestructures(billID uint) ([]BillRestructureResponse, error)
+
+	CancelBill(billType int, billID uint, request CancelBillRequest, actorID int) (*BillCancellationResponse, error)
+	GetBillCancellation(billType int, billID uint) (*BillCancellationResponse, error)
+	ReverseBillPayment(paymentID uint, reason string, actorID int) (*PaymentReceiptResponse, error)
+
+	GetCreditLedger(billType int, billID uint) (*CreditLedgerResponse, error)
+	RefundCredit(billType int, billID uint, request RefundCreditRequest, actorID int) (*CreditRefundResponse, error)
+	TransferCredit(billType int, billID uint, request TransferCreditRequest, actorID int) (*CreditTransferResponse, error)
 
 	ApplyLateFeeToSingleBill(billID uint, today time.Time) error
 		// UpdateDailyInterest1() error
 
+	GetPawnForfeitPolicy() (*PawnForfeitPolicyResponse, error)
+	UpdatePawnForfeitPolicy(request UpdatePawnForfeitPolicyRequest) (*PawnForfeitPolicyResponse, error)
+	ProcessPawnForfeitures(testDate ...time.Time) error
+	GetPawnForfeitReport() ([]PawnForfeitReportItem, error)
+	GetPendingForfeitNotices() ([]PawnForfeitNoticeResponse, error)
+	MarkForfeitNoticeSent(id uint) (*PawnForfeitNoticeResponse, error)
+}
+
+type PawnForfeitPolicyResponse struct {
+	Missed_Cycles     int       `json:"missed_cycles"`
+	Notice_Days       int       `json:"notice_days"`
+	Valuation_Base    int       `json:"valuation_base"` // 1 = ยอดเงินต้น, 2 = ราคาสินค้า
+	Valuation_Percent float64   `json:"valuation_percent"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+type UpdatePawnForfeitPolicyRequest struct {
+	Missed_Cycles     int     `json:"missed_cycles"`
+	Notice_Days       int     `json:"notice_days"`
+	Valuation_Base    int     `json:"valuation_base"`
+	Valuation_Percent float64 `json:"valuation_percent"`
+}
+
+// รายการบิลจำนำที่ใกล้หลุด
+type PawnForfeitReportItem struct {
+	BillId         uint    `json:"bill_id"`
+	Invoice        string  `json:"invoice"`
+	MemberId       uint    `json:"member_id"`
+	MemberFullName string  `json:"member_full_name"`
+	MemberTel      string  `json:"member_tel"`
+	ProductId      uint    `json:"product_id"`
+	ProductName    string  `json:"product_name"`
+	Loan_Amount    float64 `json:"loan_amount"`
+
+	NextDueDate          time.Time `json:"next_due_date"`
+	Missed_Cycles        int       `json:"missed_cycles"`
+	Cycles_Before_Notice int       `json:"cycles_before_notice"`
+
+	NoticeId     *uint      `json:"notice_id"`
+	NoticeStatus *int       `json:"notice_status"`
+	Forfeit_Date *time.Time `json:"forfeit_date"`
+	Days_Left    *int       `json:"days_left"`
+}
+
+type PawnForfeitNoticeResponse struct {
+	Id            uint       `json:"id"`
+	BillId        uint       `json:"bill_id"`
+	Invoice       string     `json:"invoice"`
+	MemberId      uint       `json:"member_id"`
+	MemberName    string     `json:"member_full_name"`
+	MemberUser_id string     `json:"member_user_id"`
+	ProductName   string     `json:"product_name"`
+	Loan_Amount   float64    `json:"loan_amount"`
+	Missed_Cycles int        `json:"missed_cycles"`
+	Notice_Date   time.Time  `json:"notice_date"`
+	Forfeit_Date  time.Time  `json:"forfeit_date"`
+	Sent_At       *time.Time `json:"sent_at"`
+	Status        int        `json:"status"`
+}
+
+type PawnRenewalResponse struct {
+	Id               uint      `json:"id"`
+	Renewal_No       int       `json:"renewal_no"`
+	Cycles_Covered   int       `json:"cycles_covered"`
+	Interest_Paid    float64   `json:"interest_paid"`
+	Fee_Paid         float64   `json:"fee_paid"`
+	Principal        float64   `json:"principal"`
+	Principal_Change float64   `json:"principal_change"`
+	Old_Due_Date     time.Time `json:"old_due_date"`
+	New_Due_Date     time.Time `json:"new_due_date"`
+	Pay_Date         time.Time `json:"pay_date"`
+	UserId           int       `json:"user_id"`
+	UserFullName     string    `json:"user_full_name"`
+	Payment_Ref      string    `json:"payment_ref"`
+}
+
+type PawnRenewalHistoryResponse struct {
+	BillId         uint                  `json:"bill_id"`
+	Invoice        string                `json:"invoice"`
+	MemberFullName string                `json:"member_full_name"`
+	RenewCount     int                   `json:"renew_count"`
+	Total_Interest float64               `json:"total_interest_paid"`
+	Total_Fee      float64               `json:"total_fee_paid"`
+	Renewals       []PawnRenewalResponse `json:"renewals"`
+}
+
+type PawnPrincipalChangeRequest struct {
+	Amount      float64 `json:"amount"`
+	Payment_Ref string  `json:"payment_ref"`
+	Note        string  `json:"note"`
+
+	Tenders []PaymentTenderRequest `json:"tenders"` // ช่องทางรับ/จ่ายเงิน (ไม่ระบุ = เงินสดเต็มจำนวน อ้างอิง payment_ref)
+}
+
+type PawnPrincipalChangeResponse struct {
+	Id               uint       `json:"id"`
+	BillId           uint       `json:"bill_id"`
+	Type             int        `json:"type"` // 1 = ลดเงินต้น, 2 = เพิ่มเงินต้น
+	Amount           float64    `json:"amount"`
+	Principal_Before float64    `json:"principal_before"`
+	Principal_After  float64    `json:"principal_after"`
+	Effective_Date   time.Time  `json:"effective_date"`
+	Applied_At       *time.Time `json:"applied_at"`
+	Pawn_RenewalId   *uint      `json:"pawn_renewal_id"`
+	UserId           int        `json:"user_id"`
+	UserFullName     string     `json:"user_full_name"`
+	Payment_Ref      string     `json:"payment_ref"`
+	Note             string     `json:"note"`
+	PaymentId        *uint      `json:"payment_id"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+type BillTransitionRequest struct {
+	Transition string `json:"transition"`
+	Reason     string `json:"reason"`
+}
+
+type BillTransitionResponse struct {
+	Id          uint      `json:"id"`
+	Bill_Type   int       `json:"bill_type"`
+	Bill_Id     uint      `json:"bill_id"`
+	From_Status int       `json:"from_status"`
+	From_Name   string    `json:"from_name"`
+	To_Status   int       `json:"to_status"`
+	To_Name     string    `json:"to_name"`
+	Transition  string    `json:"transition"`
+	Actor_Id    *int      `json:"actor_id"`
+	Actor_Name  string    `json:"actor_name"`
+	Reason      string    `json:"reason"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type BillStatusHistoryResponse struct {
+	Bill_Type   int                      `json:"bill_type"`
+	Bill_Id     uint                     `json:"bill_id"`
+	Status      int                      `json:"status"`
+	Status_Name string                   `json:"status_name"`
+	Allowed     []string                 `json:"allowed_transitions"`
+	History     []BillTransitionResponse `json:"history"`
+}
+
+type BillStateTransitionInfo struct {
+	Name string   `json:"name"`
+	From []string `json:"from"`
+	To   string   `json:"to"`
+}
+
+type RestructureBillRequest struct {
+	Installments_Month int        `json:"installments_month"` // จำนวนงวดใหม่ของยอดค้าง
+	Extra_Percent      float64    `json:"extra_percent"`      // % ส่วนเพิ่มคิดจากยอดค้าง
+	First_Due_Date     *time.Time `json:"first_due_date"`     // ไม่ระบุ = อีก 1 เดือน
+	Requested_By       *int       `json:"requested_by"`
+	Reason             string     `json:"reason"`
+}
+
+type BillRestructureResponse struct {
+	Id           uint `json:"id"`
+	Bill_Id      uint `json:"bill_id"`
+	From_Version int  `json:"from_version"`
+	To_Version   int  `json:"to_version"`
+
+	Closed_Installments    int     `json:"closed_installments"`
+	Outstanding            float64 `json:"outstanding"`
+	Old_Net_installment    float64 `json:"old_net_installment"`
+	Old_Installments_Count int     `json:"old_installments_count"`
+
+	New_Installments_Month int       `json:"new_installments_month"`
+	New_Extra_Percent      float64   `json:"new_extra_percent"`
+	Interest_Amount        float64   `json:"interest_amount"`
+	New_Total              float64   `json:"new_total"`
+	New_Net_installment    float64   `json:"new_net_installment"`
+	First_Due_Date         time.Time `json:"first_due_date"`
+
+	Requested_By  *int      `json:"requested_by"`
+	Approved_By   int       `json:"approved_by"`
+	Approver_Name string    `json:"approver_name"`
+	Reason        string    `json:"reason"`
+	CreatedAt     time.Time `json:"created_at"`
+
+	Schedule []ContractScheduleItem `json:"schedule,omitempty"`
+}
+
+type CancelBillRequest struct {
+	Reason       string `json:"reason"`
+	Down_Payment string `json:"down_payment"` // refund (ค่าเริ่มต้น) หรือ forfeit
+}
+
+type BillCancellationResponse struct {
+	Id          uint   `json:"id"`
+	Bill_Type   int    `json:"bill_type"`
+	Bill_Id     uint   `json:"bill_id"`
+	From_Status int    `json:"from_status"`
+	Reason      string `json:"reason"`
+
+	Down_Payment_Action      int     `json:"down_payment_action"`
+	Down_Payment_Action_Name string  `json:"down_payment_action_name"`
+	Refunded_Amount          float64 `json:"refunded_amount"`
+	Forfeited_Amount         float64 `json:"forfeited_amount"`
+	Inventory_UnitId         *uint   `json:"inventory_unit_id"`
+
+	Actor_Id   int       `json:"actor_id"`
+	Actor_Name string    `json:"actor_name"`
+	CreatedAt  time.Time `json:"created_at"`
+
+	Refunds []PaymentReceiptResponse `json:"refunds,omitempty"`
+}
+
+type ReversePaymentRequest struct {
+	Reason string `json:"reason"`
+}
+
+// คืนเครดิตคงเหลือเป็นเงินสด / โอน (amount = 0 คืนทั้งหมด)
+type RefundCreditRequest struct {
+	Amount  float64                `json:"amount"`
+	Tenders []PaymentTenderRequest `json:"tenders"` // cash หรือ transfer เท่านั้น ไม่ระบุ = เงินสด
+	Reason  string                 `json:"reason"`
+}
+
+// โอนเครดิตไปสัญญาอื่นที่ยังเปิดอยู่ของสมาชิกคนเดียวกัน (amount = 0 โอนทั้งหมด)
+type TransferCreditRequest struct {
+	To_Bill_Type int     `json:"to_bill_type"`
+	To_Bill_Id   uint    `json:"to_bill_id"`
+	Amount       float64 `json:"amount"`
+	Reason       string  `json:"reason"`
+}
+
+type CreditMovementResponse struct {
+	Id                    uint      `json:"id"`
+	Bill_Type             int       `json:"bill_type"`
+	Bill_Id               uint      `json:"bill_id"`
+	Kind                  int       `json:"kind"`
+	Kind_Name             string    `json:"kind_name"`
+	Amount                float64   `json:"amount"`
+	Balance_After         float64   `json:"balance_after"`
+	PaymentId             *uint     `json:"payment_id"`
+	Counterpart_Bill_Type int       `json:"counterpart_bill_type,omitempty"`
+	Counterpart_Bill_Id   *uint     `json:"counterpart_bill_id,omitempty"`
+	Actor_Id              *int      `json:"actor_id"`
+	Actor_Name            string    `json:"actor_name"`
+	Reason                string    `json:"reason"`
+	CreatedAt             time.Time `json:"created_at"`
+}
+
+type CreditLedgerResponse struct {
+	Bill_Type      int                      `json:"bill_type"`
+	Bill_Id        uint                     `json:"bill_id"`
+	Credit_Balance float64                  `json:"credit_balance"`
+	Movements      []CreditMovementResponse `json:"movements"`
+}
+
+type CreditRefundResponse struct {
+	Receipt  PaymentReceiptResponse `json:"receipt"`
+	Movement CreditMovementResponse `json:"movement"`
+}
+
+type CreditTransferResponse struct {
+	Out CreditMovementResponse `json:"out"`
+	In  CreditMovementResponse `json:"in"`
 }
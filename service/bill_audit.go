@@ -0,0 +1,60 @@
+package service
+
+import (
+	"rrmobile/model"
+	"rrmobile/respository"
+)
+
+// ข้อมูลหัวสัญญาที่เก็บในประวัติการแก้ไข (งวด / ใบเสร็จมีประวัติของตัวเองอยู่แล้ว)
+type billAudit struct {
+	Invoice            string  `json:"invoice"`
+	MemberId           uint    `json:"member_id"`
+	ProductId          uint    `json:"product_id"`
+	Loan_Amount        float64 `json:"loan_amount,omitempty"`
+	Total_Price        float64 `json:"total_price"`
+	Remaining_Amount   float64 `json:"remaining_amount"`
+	Total_Installments int     `json:"total_installments"`
+	Status             int     `json:"status"`
+	Note               string  `json:"note"`
+}
+
+func toHirePurchaseBillAudit(b *respository.Bill_Header) *billAudit {
+	if b == nil {
+		return nil
+	}
+	return &billAudit{
+		Invoice:            b.Invoice,
+		MemberId:           b.MemberId,
+		ProductId:          b.ProductId,
+		Total_Price:        b.Total_Price,
+		Remaining_Amount:   b.Remaining_Amount,
+		Total_Installments: b.Total_Installments,
+		Status:             b.Status,
+		Note:               b.Note,
+	}
+}
+
+func toInstallmentBillAudit(b *model.Bill_Header_Installment) *billAudit {
+	if b == nil {
+		return nil
+	}
+	return &billAudit{
+		Invoice:            b.Invoice,
+		MemberId:           b.MemberId,
+		ProductId:          b.ProductId,
+		Loan_Amount:        b.Loan_Amount,
+		Total_Price:        b.Total_Price,
+		Remaining_Amount:   b.Remaining_Amount,
+		Total_Installments: b.Total_Installments,
+		Status:             b.Status,
+		Note:               b.Note,
+	}
+}
+
+// สัญญาที่เปิดจากระบบอื่น (ไม่มีผู้ใช้ใน request) ให้ถือว่าพนักงานในสัญญาเป็นผู้ทำรายการ
+func billCreateActor(actor AuditActor, userID int) AuditActor {
+	if actor.User_Id == 0 && actor.Api_Key_Id == nil {
+		actor.User_Id = userID
+	}
+	return actor
+}
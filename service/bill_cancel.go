@@ -0,0 +1,375 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"rrmobile/model"
+	"rrmobile/respository"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ยกเลิกสัญญา (เช่น เปิดบิลผิดสินค้า) พร้อมเลือกคืนหรือยึดเงินดาวน์
+// คืนเงินดาวน์ = กลับรายการใบเสร็จเงินดาวน์ และคืนเครื่องเทิร์นให้ลูกค้า
+// ยึดเงินดาวน์ = ใบเสร็จคงอยู่ เครื่องเทิร์นอยู่ในสต็อกร้านต่อ
+// ตัวเครื่องตามสัญญาไม่มีสต็อกให้คืน: สัญญาผ่อนอ้างแค่รหัสสินค้า (ไม่ผูกเครื่องในสต็อกและไม่ตัดสต็อกตอนทำสัญญา)
+// ส่วนสัญญาจำนำ เครื่องเป็นของลูกค้าเอง
+func (s *billService) CancelBill(billType int, billID uint, request CancelBillRequest, actorID int) (*BillCancellationResponse, error) {
+	reason := strings.TrimSpace(request.Reason)
+	if reason == "" {
+		return nil, errors.New("กรุณาระบุเหตุผลการยกเลิกสัญญา")
+	}
+	action := model.DownPaymentRefund
+	switch strings.ToLower(strings.TrimSpace(request.Down_Payment)) {
+	case "", "refund":
+	case "forfeit":
+		action = model.DownPaymentForfeit
+	default:
+		return nil, errors.New("down_payment ต้องเป็น refund หรือ forfeit")
+	}
+
+	t, err := findBillTransition("cancel")
+	if err != nil {
+		return nil, err
+	}
+	snap, err := s.loadBillState(billType, billID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkBillTransition(t, snap); err != nil {
+		return nil, err
+	}
+
+	cancellation := &model.Bill_Cancellation{
+		Bill_Type:           billType,
+		Bill_Id:             billID,
+		From_Status:         snap.Status,
+		Reason:              reason,
+		Down_Payment_Action: action,
+		Actor_Id:            actorID,
+	}
+	transition := &model.Bill_Status_Transition{
+		Bill_Type:   billType,
+		Bill_Id:     billID,
+		From_Status: snap.Status,
+		To_Status:   t.To,
+		Transition:  t.Name,
+		Reason:      reason,
+	}
+	if actorID > 0 {
+		transition.Actor_Id = &actorID
+	}
+
+	// สัญญาผ่อนมีใบเสร็จเงินดาวน์ได้ใบเดียว (ออกตอนทำสัญญา)
+	var downPayment, reversal *model.Payment
+	downPaid := 0
+	unitNote := fmt.Sprintf("คืนลูกค้า: ยกเลิกสัญญา (%s)", reason)
+	if billType == model.BillTypeHirePurchase {
+		payments, err := s.paymentRepository.GetPaymentsByBill(billType, billID)
+		if err != nil {
+			return nil, err
+		}
+		for i := range payments {
+			if payments[i].Kind == model.PaymentKindDownPayment && payments[i].Status == model.PaymentStatusPosted {
+				downPayment = &payments[i]
+				break
+			}
+		}
+
+		bill, err := s.billRepository.GetBillById(billID)
+		if err != nil {
+			return nil, errors.New("ไม่พบบิล")
+		}
+		downPaid = downPaymentPaidAmount(bill)
+
+		tradeIn, err := s.billRepository.GetTradeIn(billType, billID)
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+		case err != nil:
+			return nil, err
+		case action == model.DownPaymentForfeit:
+			cancellation.Forfeited_Amount += tradeIn.Agreed_Value
+		case tradeIn.Inventory_UnitId != nil:
+			cancellation.Inventory_UnitId = tradeIn.Inventory_UnitId
+		}
+	}
+
+	if downPayment != nil {
+		if action == model.DownPaymentForfeit {
+			cancellation.Forfeited_Amount += downPayment.Amount
+		} else {
+			reversal, err = newReversalPayment(s.paymentRepository, downPayment, actorID, "คืนเงินดาวน์: "+reason)
+			if err != nil {
+				return nil, err
+			}
+			cancellation.Refunded_Amount += downPayment.Amount
+		}
+	}
+	cancellation.Refunded_Amount = round2(cancellation.Refunded_Amount)
+	cancellation.Forfeited_Amount = round2(cancellation.Forfeited_Amount)
+
+	if reversal == nil {
+		downPaid = 0
+	}
+	if err := s.billRepository.CancelBill(cancellation, transition, unitNote, downPayment, reversal, downPaid); err != nil {
+		return nil, err
+	}
+	log.Printf("🛑 ยกเลิกบิล %d (type %d) โดย %d: %s | คืน %.2f ยึด %.2f",
+		billID, billType, actorID, model.DownPaymentActionNames[action], cancellation.Refunded_Amount, cancellation.Forfeited_Amount)
+
+	resp := toBillCancellationResponse(*cancellation)
+	resp.Refunds = []PaymentReceiptResponse{}
+	if reversal != nil {
+		resp.Refunds = append(resp.Refunds, toPaymentReceiptResponse(*reversal))
+	}
+	return &resp, nil
+}
+
+func (s *billService) GetBillCancellation(billType int, billID uint) (*BillCancellationResponse, error) {
+	cancellation, err := s.billRepository.GetBillCancellation(billType, billID)
+	if err != nil {
+		return nil, errors.New("สัญญานี้ยังไม่ถูกยกเลิก")
+	}
+	resp := toBillCancellationResponse(*cancellation)
+
+	payments, err := s.paymentRepository.GetPaymentsByBill(billType, billID)
+	if err != nil {
+		return nil, err
+	}
+	downPaymentIDs := map[uint]bool{}
+	for _, p := range payments {
+		if p.Kind == model.PaymentKindDownPayment {
+			downPaymentIDs[p.Id] = true
+		}
+	}
+	for _, p := range payments {
+		if p.Reversal_Of != nil && downPaymentIDs[*p.Reversal_Of] {
+			resp.Refunds = append(resp.Refunds, toPaymentReceiptResponse(p))
+		}
+	}
+	return &resp, nil
+}
+
+// กลับรายการใบเสร็จค่างวดที่บันทึกผิด: หักยอดออกจากงวดตามรายการตัดเงินเดิม แล้วคืนยอดหัวบิล
+// กลับได้ทีละใบจากใบล่าสุด เพราะใบถัดไปอาจใช้เครดิตที่เกิดจากใบนี้ไปแล้ว (ใช้ได้ทั้งสัญญาผ่อนและสัญญา installment)
+func (s *billService) ReverseBillPayment(paymentID uint, reason string, actorID int) (*PaymentReceiptResponse, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, errors.New("กรุณาระบุเหตุผลการกลับรายการ")
+	}
+	original, err := s.paymentRepository.GetPaymentById(paymentID)
+	if err != nil {
+		return nil, errors.New("ไม่พบใบเสร็จ")
+	}
+	if original.Status != model.PaymentStatusPosted {
+		return nil, fmt.Errorf("ใบเสร็จ %s ถูกกลับรายการไปแล้ว", original.Receipt_No)
+	}
+	switch {
+	case original.Kind == model.PaymentKindDownPayment:
+		return nil, errors.New("ใบเสร็จเงินดาวน์กลับรายการผ่านการยกเลิกสัญญา")
+	case original.Kind != model.PaymentKindInstallment ||
+		original.Bill_Type != model.BillTypeHirePurchase && original.Bill_Type != model.BillTypeInstallment:
+		return nil, fmt.Errorf("ใบเสร็จประเภท %s กลับรายการไม่ได้", model.PaymentKindNames[original.Kind])
+	case len(original.Allocations) == 0:
+		return nil, fmt.Errorf("ใบเสร็จ %s ไม่มีรายการตัดงวด กลับรายการไม่ได้", original.Receipt_No)
+	}
+
+	payments, err := s.paymentRepository.GetPaymentsByBill(original.Bill_Type, original.Bill_Id)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range payments {
+		if p.Id > original.Id && p.Kind == model.PaymentKindInstallment && p.Status == model.PaymentStatusPosted {
+			return nil, fmt.Errorf("ต้องกลับรายการใบเสร็จ %s ที่ออกหลังใบนี้ก่อน", p.Receipt_No)
+		}
+	}
+	if original.Bill_Type == model.BillTypeInstallment {
+		return s.reverseInstallmentBillPayment(original, reason, actorID)
+	}
+
+	bill, err := s.billRepository.GetBillById(original.Bill_Id)
+	if err != nil {
+		return nil, errors.New("ไม่พบสัญญาผ่อน")
+	}
+	if bill.Status == model.BillStatusCancelled {
+		return nil, errors.New("สัญญาถูกยกเลิกแล้ว")
+	}
+	credit := round2(bill.Credit_Balance - original.Credit_Added + original.Credit_Used)
+	if credit < 0 {
+		return nil, errors.New("เครดิตที่เกิดจากใบเสร็จนี้ถูกใช้ไปแล้ว")
+	}
+
+	// 1. คืนยอดเข้างวดตามรายการตัดเงินเดิม
+	index := map[uint]int{}
+	for i, d := range bill.BillDetails {
+		index[d.Id] = i
+	}
+	touched := map[uint]bool{}
+	restored := 0.0
+	for _, a := range original.Allocations {
+		i, ok := index[a.Bill_DetailsId]
+		if !ok {
+			return nil, fmt.Errorf("ไม่พบงวด %s ของใบเสร็จนี้", a.Payment_No)
+		}
+		d := &bill.BillDetails[i]
+		if d.Status == model.BillDetailStatusRestructured {
+			return nil, fmt.Errorf("งวด %s ถูกปิดจากการปรับโครงสร้างหนี้แล้ว กลับรายการไม่ได้", d.Payment_No)
+		}
+		addInstallmentComponentPaid(d, a.Component, -a.Amount)
+		d.Paid_Amount = round2(d.Paid_Amount - a.Amount)
+		if d.Paid_Amount < d.Installment_Price-0.005 {
+			d.Status = model.BillDetailStatusUnpaid
+		}
+		touched[d.Id] = true
+		restored += a.Amount
+	}
+	details := make([]respository.Bill_Details, 0, len(touched))
+	paidCount := 0
+	for _, d := range bill.BillDetails {
+		if touched[d.Id] {
+			details = append(details, d)
+		}
+		if d.Status == model.BillDetailStatusPaid {
+			paidCount++
+		}
+	}
+
+	// 2. คืนยอดหัวบิล
+	bill.Credit_Balance = credit
+	bill.Paid_Installments = paidCount
+	bill.Remaining_Installments = bill.Total_Installments - paidCount
+	if bill.Remaining_Installments < 0 {
+		bill.Remaining_Installments = 0
+	}
+	bill.Paid_Amount -= int(math.Round(original.Amount))
+	if bill.Paid_Amount < 0 {
+		bill.Paid_Amount = 0
+	}
+	bill.Remaining_Amount = round2(bill.Remaining_Amount + restored)
+	statusFrom := bill.Status
+	if statusFrom == model.BillStatusSettled {
+		// สถานะค้างชำระจะถูกคำนวณใหม่โดย cron รอบถัดไป
+		bill.Status = model.BillStatusActive
+	}
+
+	reversal, err := newReversalPayment(s.paymentRepository, original, actorID, reason)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.paymentRepository.ReverseBillPayment(bill, details, original, reversal); err != nil {
+		return nil, err
+	}
+	if statusFrom != bill.Status {
+		s.logBillTransition(model.BillTypeHirePurchase, bill.Id, statusFrom, bill.Status, "reopen", actorID, "กลับรายการ "+original.Receipt_No+": "+reason)
+	}
+	reversalID := reversal.Id
+	s.logCreditMovement(model.BillTypeHirePurchase, bill.Id, bill.MemberId, model.CreditMovementReversal,
+		original.Credit_Used-original.Credit_Added, credit, &reversalID, actorID, "กลับรายการ "+original.Receipt_No)
+	log.Printf("↩️ กลับรายการใบเสร็จ %s → %s บิล %d โดย %d: %s", original.Receipt_No, reversal.Receipt_No, bill.Id, actorID, reason)
+
+	resp := toPaymentReceiptResponse(*reversal)
+	return &resp, nil
+}
+
+// กลับรายการใบเสร็จค่างวดของสัญญา installment (งวดไม่ได้แยกยอดองค์ประกอบ คืนเฉพาะยอดที่จ่าย)
+func (s *billService) reverseInstallmentBillPayment(original *model.Payment, reason string, actorID int) (*PaymentReceiptResponse, error) {
+	bill, err := s.billRepository.GetInstallmentBillById(original.Bill_Id)
+	if err != nil {
+		return nil, errors.New("ไม่พบสัญญา")
+	}
+	if bill.Status == model.BillStatusCancelled {
+		return nil, errors.New("สัญญาถูกยกเลิกแล้ว")
+	}
+	credit := round2(bill.Credit_Balance - original.Credit_Added + original.Credit_Used)
+	if credit < 0 {
+		return nil, errors.New("เครดิตที่เกิดจากใบเสร็จนี้ถูกใช้ไปแล้ว")
+	}
+
+	// 1. คืนยอดเข้างวดตามรายการตัดเงินเดิม
+	index := map[uint]int{}
+	for i, d := range bill.BillDetailsInstallment {
+		index[d.Id] = i
+	}
+	touched := map[uint]bool{}
+	restored := 0.0
+	for _, a := range original.Allocations {
+		i, ok := index[a.Bill_DetailsId]
+		if !ok {
+			return nil, fmt.Errorf("ไม่พบงวด %s ของใบเสร็จนี้", a.Payment_No)
+		}
+		d := &bill.BillDetailsInstallment[i]
+		d.Paid_Amount = round2(d.Paid_Amount - a.Amount)
+		if d.Paid_Amount < d.Installment_Price-0.005 {
+			d.Status = model.BillDetailStatusUnpaid
+		}
+		touched[d.Id] = true
+		restored += a.Amount
+	}
+	details := make([]model.Bill_Details_Installment, 0, len(touched))
+	paidCount := 0
+	for _, d := range bill.BillDetailsInstallment {
+		if touched[d.Id] {
+			details = append(details, d)
+		}
+		if d.Status == model.BillDetailStatusPaid {
+			paidCount++
+		}
+	}
+
+	// 2. คืนยอดหัวบิล
+	bill.Credit_Balance = credit
+	bill.Paid_Installments = paidCount
+	bill.Remaining_Installments = bill.Total_Installments - paidCount
+	if bill.Remaining_Installments < 0 {
+		bill.Remaining_Installments = 0
+	}
+	bill.Paid_Amount -= int(math.Round(original.Amount))
+	if bill.Paid_Amount < 0 {
+		bill.Paid_Amount = 0
+	}
+	bill.Remaining_Amount = round2(bill.Remaining_Amount + restored)
+	statusFrom := bill.Status
+	if statusFrom == model.BillStatusSettled {
+		// สถานะค้างชำระจะถูกคำนวณใหม่โดย cron รอบถัดไป
+		bill.Status = model.BillStatusActive
+	}
+
+	reversal, err := newReversalPayment(s.paymentRepository, original, actorID, reason)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.paymentRepository.ReverseInstallmentBillPayment(bill, details, original, reversal); err != nil {
+		return nil, err
+	}
+	if statusFrom != bill.Status {
+		s.logBillTransition(model.BillTypeInstallment, bill.Id, statusFrom, bill.Status, "reopen", actorID, "กลับรายการ "+original.Receipt_No+": "+reason)
+	}
+	reversalID := reversal.Id
+	s.logCreditMovement(model.BillTypeInstallment, bill.Id, bill.MemberId, model.CreditMovementReversal,
+		original.Credit_Used-original.Credit_Added, credit, &reversalID, actorID, "กลับรายการ "+original.Receipt_No)
+	log.Printf("↩️ กลับรายการใบเสร็จ %s → %s บิล %d โดย %d: %s", original.Receipt_No, reversal.Receipt_No, bill.Id, actorID, reason)
+
+	resp := toPaymentReceiptResponse(*reversal)
+	return &resp, nil
+}
+
+func toBillCancellationResponse(c model.Bill_Cancellation) BillCancellationResponse {
+	return BillCancellationResponse{
+		Id:                       c.Id,
+		Bill_Type:                c.Bill_Type,
+		Bill_Id:                  c.Bill_Id,
+		From_Status:              c.From_Status,
+		Reason:                   c.Reason,
+		Down_Payment_Action:      c.Down_Payment_Action,
+		Down_Payment_Action_Name: model.DownPaymentActionNames[c.Down_Payment_Action],
+		Refunded_Amount:          c.Refunded_Amount,
+		Forfeited_Amount:         c.Forfeited_Amount,
+		Inventory_UnitId:         c.Inventory_UnitId,
+		Actor_Id:                 c.Actor_Id,
+		Actor_Name:               c.Actor.FullName,
+		CreatedAt:                c.CreatedAt,
+	}
+}
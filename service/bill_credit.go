@@ -0,0 +1,266 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"rrmobile/model"
+	"rrmobile/respository"
+	"strings"
+)
+
+// เครดิตคงเหลือบนหัวสัญญา (HP = Bill_Header, จำนำ/ผ่อน = Bill_Header_Installment)
+type billCredit struct {
+	MemberId uint
+	Status   int
+	Balance  float64
+}
+
+func (s *billService) loadBillCredit(billType int, billID uint) (*billCredit, error) {
+	switch billType {
+	case model.BillTypeHirePurchase:
+		bill, err := s.billRepository.GetBillById(billID)
+		if err != nil {
+			return nil, errors.New("ไม่พบบิล")
+		}
+		return &billCredit{MemberId: bill.MemberId, Status: bill.Status, Balance: round2(bill.Credit_Balance)}, nil
+	case model.BillTypeInstallment:
+		bill, err := s.billRepository.GetInstallmentBillById(billID)
+		if err != nil {
+			return nil, errors.New("ไม่พบบิล")
+		}
+		return &billCredit{MemberId: bill.MemberId, Status: bill.Status, Balance: round2(bill.Credit_Balance)}, nil
+	}
+	return nil, errors.New("ประเภทบิลไม่ถูกต้อง")
+}
+
+// บันทึกเครดิตที่เพิ่ม / ลดจากการรับชำระ (ไม่ให้การรับเงินล้มเพราะบันทึกประวัติไม่สำเร็จ)
+func (s *billService) logCreditMovement(billType int, billID uint, memberID uint, kind int, amount, balanceAfter float64, paymentID *uint, actorID int, reason string) {
+	amount = round2(amount)
+	if amount == 0 {
+		return
+	}
+	movement := &model.Credit_Movement{
+		Bill_Type:     billType,
+		Bill_Id:       billID,
+		MemberId:      memberID,
+		Kind:          kind,
+		Amount:        amount,
+		Balance_After: round2(balanceAfter),
+		PaymentId:     paymentID,
+		Reason:        reason,
+	}
+	if actorID > 0 {
+		movement.Actor_Id = &actorID
+	}
+	if err := s.paymentRepository.CreateCreditMovement(movement); err != nil {
+		log.Printf("❌ บันทึกความเคลื่อนไหวเครดิตบิล %d ไม่สำเร็จ: %v", billID, err)
+	}
+}
+
+// บันทึกเครดิตจากใบเสร็จค่างวด: ชำระเกิน = เพิ่มเครดิต, ใช้เครดิตตัดงวด = ลดเครดิต
+func (s *billService) logPaymentCredit(payment *model.Payment, memberID uint, balanceAfter float64, actorID int) {
+	paymentID := payment.Id
+	if payment.Credit_Used > 0 {
+		s.logCreditMovement(payment.Bill_Type, payment.Bill_Id, memberID, model.CreditMovementUsed,
+			-payment.Credit_Used, balanceAfter+payment.Credit_Added, &paymentID, actorID, "ใบเสร็จ "+payment.Receipt_No)
+	}
+	if payment.Credit_Added > 0 {
+		s.logCreditMovement(payment.Bill_Type, payment.Bill_Id, memberID, model.CreditMovementEarned,
+			payment.Credit_Added, balanceAfter, &paymentID, actorID, "ใบเสร็จ "+payment.Receipt_No)
+	}
+}
+
+func (s *billService) GetCreditLedger(billType int, billID uint) (*CreditLedgerResponse, error) {
+	credit, err := s.loadBillCredit(billType, billID)
+	if err != nil {
+		return nil, err
+	}
+	movements, err := s.paymentRepository.GetCreditMovements(billType, billID)
+	if err != nil {
+		return nil, err
+	}
+
+	ledger := &CreditLedgerResponse{
+		Bill_Type:      billType,
+		Bill_Id:        billID,
+		Credit_Balance: credit.Balance,
+		Movements:      make([]CreditMovementResponse, 0, len(movements)),
+	}
+	for _, m := range movements {
+		ledger.Movements = append(ledger.Movements, toCreditMovementResponse(m))
+	}
+	return ledger, nil
+}
+
+// คืนเครดิตคงเหลือให้ลูกค้า ออกใบเสร็จคืนเงิน (ยอดติดลบ) ตามช่องทางที่จ่ายออก
+func (s *billService) RefundCredit(billType int, billID uint, request RefundCreditRequest, actorID int) (*CreditRefundResponse, error) {
+	reason := strings.TrimSpace(request.Reason)
+	if reason == "" {
+		return nil, errors.New("กรุณาระบุเหตุผลการคืนเครดิต")
+	}
+	credit, err := s.loadBillCredit(billType, billID)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := creditAmount(request.Amount, credit.Balance)
+	if err != nil {
+		return nil, err
+	}
+
+	tenders, err := buildPaymentTenders(request.Tenders, amount)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tenders {
+		if t.Method != model.PaymentMethodCash && t.Method != model.PaymentMethodTransfer {
+			return nil, fmt.Errorf("คืนเครดิตได้เฉพาะเงินสดหรือโอนเงิน ไม่รองรับ %s", paymentMethodName(t.Method))
+		}
+	}
+
+	receiptNo, err := respository.GeneratePaymentReceipt(s.paymentRepository)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate receipt: %w", err)
+	}
+	refund := &model.Payment{
+		Receipt_No:  receiptNo,
+		Bill_Type:   billType,
+		Bill_Id:     billID,
+		Kind:        model.PaymentKindCredit,
+		Amount:      -amount,
+		Credit_Used: amount,
+		Note:        reason,
+	}
+	applyPaymentTenders(refund, reverseTenders(tenders))
+	if actorID > 0 {
+		refund.User_Id = &actorID
+	}
+	movement := &model.Credit_Movement{
+		Bill_Type: billType,
+		Bill_Id:   billID,
+		MemberId:  credit.MemberId,
+		Kind:      model.CreditMovementRefund,
+		Amount:    -amount,
+		Reason:    reason,
+	}
+	if actorID > 0 {
+		movement.Actor_Id = &actorID
+	}
+
+	if err := s.paymentRepository.RefundCredit(refund, movement); err != nil {
+		return nil, err
+	}
+	log.Printf("💸 คืนเครดิตบิล %d (type %d) %.2f บาท ใบเสร็จ %s โดย %d", billID, billType, amount, receiptNo, actorID)
+
+	saved, err := s.paymentRepository.GetPaymentById(refund.Id)
+	if err != nil {
+		saved = refund
+	}
+	return &CreditRefundResponse{
+		Receipt:  toPaymentReceiptResponse(*saved),
+		Movement: toCreditMovementResponse(*movement),
+	}, nil
+}
+
+// โอนเครดิตไปสัญญาอื่นของสมาชิกคนเดียวกันที่ยังเปิดอยู่ เครดิตจะถูกใช้ตัดงวดถัดไปของสัญญาปลายทาง
+func (s *billService) TransferCredit(billType int, billID uint, request TransferCreditRequest, actorID int) (*CreditTransferResponse, error) {
+	reason := strings.TrimSpace(request.Reason)
+	if reason == "" {
+		return nil, errors.New("กรุณาระบุเหตุผลการโอนเครดิต")
+	}
+	if request.To_Bill_Type == billType && request.To_Bill_Id == billID {
+		return nil, errors.New("ไม่สามารถโอนเครดิตเข้าสัญญาเดิมได้")
+	}
+	source, err := s.loadBillCredit(billType, billID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.loadBillCredit(request.To_Bill_Type, request.To_Bill_Id)
+	if err != nil {
+		return nil, fmt.Errorf("สัญญาปลายทาง: %w", err)
+	}
+	if target.MemberId != source.MemberId {
+		return nil, errors.New("โอนเครดิตได้เฉพาะสัญญาของสมาชิกคนเดียวกัน")
+	}
+	if !containsStatus(model.OpenBillStatuses, target.Status) {
+		return nil, fmt.Errorf("สัญญาปลายทางมีสถานะ %s ไม่สามารถรับเครดิตได้", billStatusName(target.Status))
+	}
+	amount, err := creditAmount(request.Amount, source.Balance)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceID, targetID := billID, request.To_Bill_Id
+	out := &model.Credit_Movement{
+		Bill_Type:             billType,
+		Bill_Id:               billID,
+		MemberId:              source.MemberId,
+		Kind:                  model.CreditMovementTransferOut,
+		Amount:                -amount,
+		Counterpart_Bill_Type: request.To_Bill_Type,
+		Counterpart_Bill_Id:   &targetID,
+		Reason:                reason,
+	}
+	in := &model.Credit_Movement{
+		Bill_Type:             request.To_Bill_Type,
+		Bill_Id:               request.To_Bill_Id,
+		MemberId:              target.MemberId,
+		Kind:                  model.CreditMovementTransferIn,
+		Amount:                amount,
+		Counterpart_Bill_Type: billType,
+		Counterpart_Bill_Id:   &sourceID,
+		Reason:                reason,
+	}
+	if actorID > 0 {
+		out.Actor_Id = &actorID
+		in.Actor_Id = &actorID
+	}
+
+	if err := s.paymentRepository.TransferCredit(out, in); err != nil {
+		return nil, err
+	}
+	log.Printf("🔁 โอนเครดิต %.2f บาท จากบิล %d (type %d) ไปบิล %d (type %d) โดย %d",
+		amount, billID, billType, request.To_Bill_Id, request.To_Bill_Type, actorID)
+
+	return &CreditTransferResponse{
+		Out: toCreditMovementResponse(*out),
+		In:  toCreditMovementResponse(*in),
+	}, nil
+}
+
+// ยอดที่ขอคืน / โอน (0 = ทั้งหมด) ต้องไม่เกินเครดิตคงเหลือ
+func creditAmount(requested, balance float64) (float64, error) {
+	if balance <= 0 {
+		return 0, errors.New("สัญญานี้ไม่มีเครดิตคงเหลือ")
+	}
+	amount := round2(requested)
+	if amount < 0 {
+		return 0, errors.New("ยอดต้องไม่ติดลบ")
+	}
+	if amount == 0 {
+		return balance, nil
+	}
+	if amount > balance {
+		return 0, fmt.Errorf("เครดิตคงเหลือมีเพียง %.2f", balance)
+	}
+	return amount, nil
+}
+
+func toCreditMovementResponse(m model.Credit_Movement) CreditMovementResponse {
+	return CreditMovementResponse{
+		Id:                    m.Id,
+		Bill_Type:             m.Bill_Type,
+		Bill_Id:               m.Bill_Id,
+		Kind:                  m.Kind,
+		Kind_Name:             model.CreditMovementKindNames[m.Kind],
+		Amount:                m.Amount,
+		Balance_After:         m.Balance_After,
+		PaymentId:             m.PaymentId,
+		Counterpart_Bill_Type: m.Counterpart_Bill_Type,
+		Counterpart_Bill_Id:   m.Counterpart_Bill_Id,
+		Actor_Id:              m.Actor_Id,
+		Actor_Name:            m.Actor.FullName,
+		Reason:                m.Reason,
+		CreatedAt:             m.CreatedAt,
+	}
+}
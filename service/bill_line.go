@@ -0,0 +1,99 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"rrmobile/model"
+	"rrmobile/respository"
+	"strings"
+)
+
+type BillLineRequest struct {
+	Line_Type   int     `json:"line_type"` // 2 = อุปกรณ์เสริม, 3 = ประกัน, 4 = บริการ
+	ProductId   uint    `json:"product_id"`
+	Unit        string  `json:"unit"`
+	Quantity    int     `json:"quantity"`
+	Unit_Price  float64 `json:"unit_price"` // 0 = ใช้ราคาสินค้า
+	Is_Financed bool    `json:"is_financed"`
+}
+
+// ตรวจรายการเสริมของสัญญา คืนยอดที่รวมผ่อนและยอดที่จ่ายสดแยกกัน
+// เครื่องหลัก (ProductId ของบิล) จะถูกเพิ่มเป็นบรรทัดแรกเสมอ
+func newBillLines(products respository.ProductRepository, mainProduct *respository.Product, reqs []BillLineRequest, billType int) ([]model.Bill_Line, float64, float64, error) {
+	lines := []model.Bill_Line{{
+		Bill_Type:   billType,
+		Line_Type:   model.SaleLineHandset,
+		ProductId:   mainProduct.Id,
+		Unit:        "เครื่อง",
+		Quantity:    1,
+		Unit_Price:  mainProduct.Price,
+		Amount:      round2(mainProduct.Price),
+		Is_Financed: true,
+	}}
+
+	financed, upfront := 0.0, 0.0
+	for _, l := range reqs {
+		lineType := l.Line_Type
+		if lineType == 0 {
+			lineType = model.SaleLineAccessory
+		}
+		if lineType == model.SaleLineHandset {
+			return nil, 0, 0, errors.New("สัญญามีเครื่องหลักได้เครื่องเดียว รายการเสริมต้องเป็นอุปกรณ์ ประกัน หรือบริการ")
+		}
+		if _, ok := model.SaleLineTypeNames[lineType]; !ok {
+			return nil, 0, 0, fmt.Errorf("ไม่รู้จักประเภทรายการ %d", lineType)
+		}
+		if l.Quantity <= 0 {
+			return nil, 0, 0, errors.New("จำนวนสินค้าต้องมากกว่า 0")
+		}
+		product, err := products.GetProductByID(l.ProductId)
+		if err != nil {
+			return nil, 0, 0, fmt.Errorf("ไม่พบสินค้า %d", l.ProductId)
+		}
+
+		unitPrice := product.Price
+		if l.Unit_Price > 0 {
+			unitPrice = round2(l.Unit_Price)
+		}
+		unit := strings.TrimSpace(l.Unit)
+		if unit == "" {
+			unit = "ชิ้น"
+		}
+
+		line := model.Bill_Line{
+			Bill_Type:   billType,
+			Line_Type:   lineType,
+			ProductId:   l.ProductId,
+			Unit:        unit,
+			Quantity:    l.Quantity,
+			Unit_Price:  unitPrice,
+			Amount:      round2(unitPrice * float64(l.Quantity)),
+			Is_Financed: l.Is_Financed,
+		}
+		if line.Is_Financed {
+			financed += line.Amount
+		} else {
+			upfront += line.Amount
+		}
+		lines = append(lines, line)
+	}
+	return lines, round2(financed), round2(upfront), nil
+}
+
+func toBillLineItems(lines []model.Bill_Line) []ContractLineItem {
+	items := make([]ContractLineItem, 0, len(lines))
+	for _, l := range lines {
+		items = append(items, ContractLineItem{
+			Line_Type:      l.Line_Type,
+			Line_Type_Name: model.SaleLineTypeNames[l.Line_Type],
+			ProductId:      l.ProductId,
+			ProductName:    l.Product.Name,
+			Unit:           l.Unit,
+			Quantity:       l.Quantity,
+			Unit_Price:     l.Unit_Price,
+			Amount:         l.Amount,
+			Is_Financed:    l.Is_Financed,
+		})
+	}
+	return items
+}
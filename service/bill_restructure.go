@@ -0,0 +1,207 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"rrmobile/model"
+	"rrmobile/respository"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ปรับโครงสร้างหนี้สัญญาผ่อน: ปิดงวดที่ค้างของตารางเดิม แล้วกระจายยอดค้างเป็นตารางผ่อนชุดใหม่
+// งวดเดิมยังอยู่ในฐานข้อมูล (status = ปิดจากการปรับโครงสร้าง) เพื่อดูย้อนหลังได้
+func (s *billService) RestructureBill(billID uint, request RestructureBillRequest, approverID int) (*BillRestructureResponse, error) {
+	if request.Installments_Month <= 0 {
+		return nil, errors.New("จำนวนงวดใหม่ต้องมากกว่า 0")
+	}
+	if request.Extra_Percent < 0 {
+		return nil, errors.New("ส่วนเพิ่มต้องไม่ติดลบ")
+	}
+	reason := strings.TrimSpace(request.Reason)
+	if reason == "" {
+		return nil, errors.New("กรุณาระบุเหตุผลการปรับโครงสร้างหนี้")
+	}
+
+	bill, err := s.billRepository.GetBillById(billID)
+	if err != nil {
+		return nil, errors.New("ไม่พบสัญญาผ่อน")
+	}
+	if !model.IsOpenBillStatus(bill.Status) {
+		return nil, fmt.Errorf("สัญญาสถานะ %s ปรับโครงสร้างหนี้ไม่ได้", billStatusName(bill.Status))
+	}
+
+	var unpaid []respository.Bill_Details
+	paidCount := 0
+	for _, d := range bill.BillDetails {
+		switch d.Status {
+		case model.BillDetailStatusUnpaid:
+			unpaid = append(unpaid, d)
+		case model.BillDetailStatusPaid:
+			paidCount++
+		}
+	}
+	if len(unpaid) == 0 {
+		return nil, errors.New("สัญญานี้ไม่มีงวดค้างให้ปรับโครงสร้าง")
+	}
+	sort.Slice(unpaid, func(i, j int) bool { return unpaid[i].Payment_Date.Before(unpaid[j].Payment_Date) })
+
+	// ยอดค้าง = ส่วนที่ยังไม่ได้จ่ายของทุกงวดที่ค้าง (รวมค่าปรับที่บวกเข้างวดแล้ว)
+	outstanding := 0.0
+	closeIDs := make([]uint, 0, len(unpaid))
+	for _, d := range unpaid {
+		if left := d.Installment_Price - d.Paid_Amount; left > 0 {
+			outstanding += left
+		}
+		closeIDs = append(closeIDs, d.Id)
+	}
+	outstanding = round2(outstanding)
+	if outstanding <= 0 {
+		return nil, errors.New("ไม่มียอดค้างให้ปรับโครงสร้าง")
+	}
+
+	interest := round2(outstanding * request.Extra_Percent / 100)
+	newTotal := round2(outstanding + interest)
+	installmentPrice := math.Round(newTotal / float64(request.Installments_Month))
+
+	loc, _ := time.LoadLocation("Asia/Bangkok")
+	firstDue := time.Now().In(loc).AddDate(0, 1, 0)
+	if request.First_Due_Date != nil {
+		firstDue = request.First_Due_Date.In(loc)
+	}
+
+	fromVersion := bill.Schedule_Version
+	if fromVersion == 0 {
+		fromVersion = 1
+	}
+	toVersion := fromVersion + 1
+
+	details := make([]respository.Bill_Details, 0, request.Installments_Month)
+	allocated := 0.0
+	for i := 1; i <= request.Installments_Month; i++ {
+		price := installmentPrice
+		if i == request.Installments_Month {
+			// งวดสุดท้ายรับเศษจากการปัด ให้ผลรวมเท่ายอดใหม่พอดี
+			price = round2(newTotal - allocated)
+		}
+		allocated += price
+		details = append(details, respository.Bill_Details{
+			Bill_HeaderId:     bill.Id,
+			Installment_Price: price,
+			Interest_Amount:   round2(price * interest / newTotal),
+			Status:            model.BillDetailStatusUnpaid,
+			Payment_Date:      firstDue.AddDate(0, i-1, 0),
+			Payment_No:        fmt.Sprintf("%d", paidCount+i),
+			Schedule_Version:  toVersion,
+		})
+	}
+
+	restructure := &model.Bill_Restructure{
+		Bill_Type:              model.BillTypeHirePurchase,
+		Bill_Id:                bill.Id,
+		From_Version:           fromVersion,
+		To_Version:             toVersion,
+		Closed_Installments:    len(unpaid),
+		Outstanding:            outstanding,
+		Old_Net_installment:    bill.Net_installment,
+		Old_Installments_Count: bill.Total_Installments,
+		New_Installments_Month: request.Installments_Month,
+		New_Extra_Percent:      request.Extra_Percent,
+		Interest_Amount:        interest,
+		New_Total:              newTotal,
+		New_Net_installment:    installmentPrice,
+		First_Due_Date:         firstDue,
+		Requested_By:           request.Requested_By,
+		Approved_By:            approverID,
+		Reason:                 reason,
+	}
+
+	statusFrom := bill.Status
+	bill.Net_installment = installmentPrice
+	bill.Installments_Month = paidCount + request.Installments_Month
+	bill.Total_Installments = paidCount + request.Installments_Month
+	bill.Remaining_Installments = request.Installments_Month
+	bill.Total_Price = round2(bill.Total_Price + interest)
+	bill.Remaining_Amount = newTotal
+	bill.Schedule_Version = toVersion
+	if statusFrom == model.BillStatusOverdue || statusFrom == model.BillStatusDefaulted {
+		bill.Status = model.BillStatusActive
+	}
+
+	if err := s.billRepository.RestructureBill(bill, restructure, closeIDs, details); err != nil {
+		return nil, err
+	}
+	if statusFrom != bill.Status {
+		s.logBillTransition(model.BillTypeHirePurchase, bill.Id, statusFrom, bill.Status, "restructure", approverID, reason)
+	}
+	log.Printf("✅ ปรับโครงสร้างหนี้บิล %d: ยอดค้าง %.2f → %d งวด งวดละ %.2f (ชุดที่ %d)", bill.Id, outstanding, request.Installments_Month, installmentPrice, toVersion)
+
+	resp := toBillRestructureResponse(*restructure)
+	resp.Schedule = toBillScheduleItems(details)
+	return &resp, nil
+}
+
+func (s *billService) GetBillRestructures(billID uint) ([]BillRestructureResponse, error) {
+	restructures, err := s.billRepository.GetBillRestructures(model.BillTypeHirePurchase, billID)
+	if err != nil {
+		return nil, err
+	}
+	responses := make([]BillRestructureResponse, 0, len(restructures))
+	for _, r := range restructures {
+		responses = append(responses, toBillRestructureResponse(r))
+	}
+	return responses, nil
+}
+
+func toBillRestructureResponse(r model.Bill_Restructure) BillRestructureResponse {
+	return BillRestructureResponse{
+		Id:                     r.Id,
+		Bill_Id:                r.Bill_Id,
+		From_Version:           r.From_Version,
+		To_Version:             r.To_Version,
+		Closed_Installments:    r.Closed_Installments,
+		Outstanding:            r.Outstanding,
+		Old_Net_installment:    r.Old_Net_installment,
+		Old_Installments_Count: r.Old_Installments_Count,
+		New_Installments_Month: r.New_Installments_Month,
+		New_Extra_Percent:      r.New_Extra_Percent,
+		Interest_Amount:        r.Interest_Amount,
+		New_Total:              r.New_Total,
+		New_Net_installment:    r.New_Net_installment,
+		First_Due_Date:         r.First_Due_Date,
+		Requested_By:           r.Requested_By,
+		Approved_By:            r.Approved_By,
+		Approver_Name:          r.Approver.FullName,
+		Reason:                 r.Reason,
+		CreatedAt:              r.CreatedAt,
+	}
+}
+
+// ตารางผ่อนเรียงตามชุด แล้วตามวันครบกำหนด (ชุดเก่าที่ถูกปิดยังแสดงอยู่)
+func toBillScheduleItems(details []respository.Bill_Details) []ContractScheduleItem {
+	sorted := append([]respository.Bill_Details(nil), details...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Schedule_Version != sorted[j].Schedule_Version {
+			return sorted[i].Schedule_Version < sorted[j].Schedule_Version
+		}
+		return sorted[i].Payment_Date.Before(sorted[j].Payment_Date)
+	})
+
+	schedule := make([]ContractScheduleItem, 0, len(sorted))
+	for _, d := range sorted {
+		schedule = append(schedule, ContractScheduleItem{
+			Id:                d.Id,
+			Payment_No:        d.Payment_No,
+			Payment_Date:      d.Payment_Date,
+			Installment_Price: d.Installment_Price,
+			Paid_Amount:       d.Paid_Amount,
+			Fee_Amount:        d.Fee_Amount,
+			Status:            d.Status,
+			Schedule_Version:  d.Schedule_Version,
+		})
+	}
+	return schedule
+}
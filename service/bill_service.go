@@ -8,10 +8,13 @@ import (
 	"rrmobile/model"
 	"rrmobile/respository"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type billService struct {
@@ -19,13 +22,16 @@ type billService struct {
 	productRepository     respository.ProductRepository
 	fineRepositoty        respository.FineRepository
 	installmentRepository respository.InstallmentRepository
+	appraisalRepository   respository.AppraisalRepository
+	paymentRepository     respository.PaymentRepository
+	auditRepository       respository.AuditRepository
 }
 
-func NewBillService(billRepository respository.BillRepository, productRepository respository.ProductRepository, fineRepositoty respository.FineRepository, installmentRepository respository.InstallmentRepository) BillService {
-	return &billService{billRepository: billRepository, productRepository: productRepository, fineRepositoty: fineRepositoty, installmentRepository: installmentRepository}
+func NewBillService(billRepository respository.BillRepository, productRepository respository.ProductRepository, fineRepositoty respository.FineRepository, installmentRepository respository.InstallmentRepository, appraisalRepository respository.AppraisalRepository, paymentRepository respository.PaymentRepository, auditRepository respository.AuditRepository) BillService {
+	return &billService{billRepository: billRepository, productRepository: productRepository, fineRepositoty: fineRepositoty, installmentRepository: installmentRepository, appraisalRepository: appraisalRepository, paymentRepository: paymentRepository, auditRepository: auditRepository}
 }
 
-func (s *billService) CreateBill(request NewBillHeader) (*Bill_HeaderResponse, error) {
+func (s *billService) CreateBill(request NewBillHeader, actor AuditActor) (*Bill_HeaderResponse, error) {
 	product, err := s.productRepository.GetProductByID(request.ProductId)
 	if err != nil {
 		return nil, errors.New("product not found")
@@ -33,11 +39,39 @@ func (s *billService) CreateBill(request NewBillHeader) (*Bill_HeaderResponse, e
 	if request.MemberId == 0 {
 		return nil, errors.New("member id is required")
 	}
-	basePrice := product.Price
+	downMethod, err := normalizePaymentMethod(request.Down_Payment_Method)
+	if err != nil {
+		return nil, err
+	}
+	if downMethod == model.PaymentMethodCredit {
+		return nil, errors.New("สัญญาใหม่ยังไม่มีเครดิตคงเหลือให้ใช้เป็นเงินดาวน์")
+	}
+	// รายการเสริมที่เลือกผ่อน รวมเข้าฐานคำนวณงวด ส่วนที่จ่ายสดแยกเก็บไว้
+	lines, financedAddOns, upfrontAmount, err := newBillLines(s.productRepository, product, request.Lines, model.BillTypeHirePurchase)
+	if err != nil {
+		return nil, err
+	}
+	basePrice := product.Price + financedAddOns
 
 	finalPrice := basePrice + (basePrice * float64(request.Extra_Percent) / 100)
 
 	downPayment := finalPrice * float64(request.Down_Percent) / 100
+
+	// เครื่องเทิร์นใช้เป็นเงินดาวน์ ถ้ามูลค่าเกินดาวน์ที่กำหนด ส่วนเกินไปลดยอดผ่อน
+	var tradeIn *model.Trade_In
+	if request.Trade_In != nil {
+		tradeIn, err = newTradeIn(s.appraisalRepository, s.productRepository, *request.Trade_In, model.BillTypeHirePurchase, request.User_Id)
+		if err != nil {
+			return nil, err
+		}
+		if tradeIn.Agreed_Value >= finalPrice {
+			return nil, errors.New("มูลค่าเครื่องเทิร์นต้องน้อยกว่าราคาสินค้า")
+		}
+		if tradeIn.Agreed_Value > downPayment {
+			downPayment = tradeIn.Agreed_Value
+		}
+	}
+
 	remaining := finalPrice - downPayment
 	roundedRemaining := math.Round(remaining)
 
@@ -60,31 +94,51 @@ func (s *billService) CreateBill(request NewBillHeader) (*Bill_HeaderResponse, e
 		Total_Price:        finalPrice,
 		Remaining_Amount:   roundedRemaining,
 		Total_Installments: request.Installments_Month,
-		Status:             1,
+		Down_Payment:       round2(downPayment),
+		Upfront_Amount:     upfrontAmount,
+		Schedule_Version:   1,
+		Status:             model.BillStatusActive,
 	}
-
-	createdBill, err := s.billRepository.CreateBill(billHeader)
-	if err != nil {
-		return nil, err
+	if tradeIn != nil {
+		billHeader.Trade_In_Amount = tradeIn.Agreed_Value
 	}
+	billHeader.Paid_Amount = downPaymentPaidAmount(billHeader)
 
 	loc, _ := time.LoadLocation("Asia/Bangkok")
 	startDate := time.Now().In(loc)
 
+	// ส่วนของดอกเบี้ย (ส่วนเพิ่ม) ในแต่ละงวด ใช้แยกการตัดเงินดอกเบี้ย / เงินต้น
+	installmentInterest := round2(installmentPrice * float64(request.Extra_Percent) / float64(100+request.Extra_Percent))
+
 	var details []respository.Bill_Details
 	for i := 1; i <= request.Installments_Month; i++ {
 		details = append(details, respository.Bill_Details{
-			Bill_HeaderId:     createdBill.Id,
 			Installment_Price: installmentPrice,
+			Interest_Amount:   installmentInterest,
 			Status:            0,
 			Payment_Date:      startDate.AddDate(0, i, 0), // เพิ่มทีละเดือน
 			Payment_No:        fmt.Sprintf("%d", i),
+			Schedule_Version:  1,
 		})
 	}
 
-	if err := s.billRepository.CreateBillDetails(details); err != nil {
+	var tradeInUnit *model.Inventory_Unit
+	if tradeIn != nil {
+		tradeInUnit = tradeInInventoryUnit(tradeIn, billHeader.Invoice)
+	}
+
+	// เงินที่รับจริงตอนทำสัญญา = ดาวน์ส่วนที่ไม่ได้จ่ายด้วยเครื่องเทิร์น + รายการเสริมที่จ่ายสด
+	downReceipt, err := s.newDownPayment(model.BillTypeHirePurchase, billHeader.Down_Payment-billHeader.Trade_In_Amount, upfrontAmount, downMethod, request.Down_Payment_Reference, request.User_Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.billRepository.CreateBillContract(billHeader, details, lines, tradeIn, tradeInUnit, downReceipt); err != nil {
 		return nil, err
 	}
+	createdBill := billHeader
+	s.logBillTransition(model.BillTypeHirePurchase, createdBill.Id, model.BillStatusDraft, model.BillStatusActive, "activate", request.User_Id, "สร้างสัญญา")
+	recordAudit(s.auditRepository, billCreateActor(actor, request.User_Id), model.AuditActionCreate, model.AuditEntityBill, createdBill.Id, nil, toHirePurchaseBillAudit(createdBill))
 
 	resp := &Bill_HeaderResponse{
 		Id:                 createdBill.Id,
@@ -93,181 +147,195 @@ func (s *billService) CreateBill(request NewBillHeader) (*Bill_HeaderResponse, e
 		Total_Price:        createdBill.Total_Price,
 		Net_installment:    createdBill.Net_installment,
 		Total_Installments: createdBill.Total_Installments,
+		Down_Payment:       createdBill.Down_Payment,
+		Trade_In_Amount:    createdBill.Trade_In_Amount,
+		Trade_In:           toTradeInResponse(tradeIn),
+		Upfront_Amount:     createdBill.Upfront_Amount,
+		Lines:              toBillLineItems(lines),
+	}
+	if downReceipt != nil {
+		receipt := toPaymentReceiptResponse(*downReceipt)
+		resp.Down_Payment_Receipt = &receipt
 	}
 
 	return resp, nil
 }
 
-func (s *billService) PayInstallment(billID uint, detailID uint, amount float64) ([]InstallmentPayResult, error) {
-	// 1. ดึง Bill_Header
+func (s *billService) PayInstallment(billID uint, detailID uint, amount float64, reqTenders []PaymentTenderRequest, actorID int) ([]InstallmentPayResult, error) {
+	// 1. ดึง Bill_Header พร้อมงวดทั้งหมด
 	bill, err := s.billRepository.GetBillById(billID)
 	if err != nil {
 		return nil, errors.New("bill not found")
 	}
-
-	maxPayable := float64(bill.Total_Installments)*bill.Net_installment + bill.Fee_Amount + bill.Credit_Balance
-
-	remainingBill := maxPayable - float64(bill.Paid_Amount)
-	if remainingBill < 0 {
-		remainingBill = 0
+	if amount < 0 {
+		return nil, errors.New("ยอดชำระต้องไม่ติดลบ")
 	}
 
-	// 2. ดึงเฉพาะ Bill_Details ที่ยังไม่จ่าย
-	installments, err := s.billRepository.GetUnpaidInstallments1(billID, detailID)
+	carryCredit := bill.Credit_Balance // เครดิตสะสมที่มีจากหัวบิล
+
+	// ช่องทาง credit ไม่ใช่เงินเข้าร้าน เครดิตของหัวบิลถูกใช้ตัดงวดอยู่แล้ว จึงตรวจแค่ว่าไม่เกินที่มี
+	tenders, err := buildPaymentTenders(reqTenders, amount)
 	if err != nil {
-		return nil, errors.New("cannot get installments")
+		return nil, err
 	}
-	if len(installments) == 0 {
-		return nil, errors.New("all installments already paid")
+	tenders, amount, creditTendered := splitCreditTenders(tenders)
+	if creditTendered > round2(carryCredit) {
+		return nil, fmt.Errorf("เครดิตคงเหลือมีเพียง %.2f", carryCredit)
 	}
-
-	remainingAmount := amount
-	carryCredit := bill.Credit_Balance // เครดิตสะสมที่มีจากหัวบิล
-	if remainingAmount > float64(bill.Remaining_Amount)+carryCredit {
-		return nil, fmt.Errorf("ยอดชำระ %.2f เกินยอดคงเหลือของบิลและเครดิต %.2f", remainingAmount, float64(bill.Remaining_Amount)+carryCredit)
+	if amount > bill.Remaining_Amount+carryCredit {
+		return nil, fmt.Errorf("ยอดชำระ %.2f เกินยอดคงเหลือของบิลและเครดิต %.2f", amount, bill.Remaining_Amount+carryCredit)
+	}
+	if amount+carryCredit <= 0 {
+		return nil, errors.New("กรุณาระบุยอดชำระ")
 	}
 
-	// if remainingAmount > float64(bill.Remaining_Amount) {
-	// 	return nil, fmt.Errorf("ยอดชำระ %.2f เกินยอดคงเหลือของบิล %.2f", remainingAmount, float64(bill.Remaining_Amount))
-	// }
-
-	// **ประกาศ results slice ก่อนใช้**
-	results := make([]InstallmentPayResult, 0, len(installments))
-
-	// 3. ใช้เงิน + เครดิต ไปปิดงวดตามลำดับ
-	for i := 0; i < len(installments) && (remainingAmount > 0 || carryCredit > 0); i++ {
-		inst := &installments[i]
-		unpaid := (inst.Installment_Price - inst.Paid_Amount)
-		if unpaid <= 0 {
+	// 2. งวดที่ยังไม่จ่าย (ระบุงวด = ตัดเฉพาะงวดนั้น ส่วนเกินเก็บเป็นเครดิต)
+	var installments []respository.Bill_Details
+	for _, d := range bill.BillDetails {
+		if d.Status != model.BillDetailStatusUnpaid {
 			continue
 		}
-
-		totalAvailable := remainingAmount + carryCredit
-		if totalAvailable <= 0 {
-			break
-		}
-
-		var result InstallmentPayResult
-		result.InstallmentNo = i + 1
-
-		// ---- Case A ----
-		if totalAvailable == unpaid {
-			inst.Paid_Amount = inst.Installment_Price
-			inst.Status = 1
-			inst.UpdatedAt = time.Now()
-			if remainingAmount >= unpaid {
-				remainingAmount -= unpaid
-			} else {
-				carryCredit -= unpaid - remainingAmount
-				remainingAmount = 0
-			}
-			inst.Credit_Balance = 0
-
-			result.Case = "A"
-			result.Message = fmt.Sprintf("จ่ายพอดี งวด %d", i+1)
-			result.CreditLeft = 0
-			result.PaidAmount = inst.Paid_Amount
-
-			// ---- Case B ----
-		} else if totalAvailable > unpaid {
-			inst.Paid_Amount = inst.Installment_Price
-			inst.Status = 1
-			inst.UpdatedAt = time.Now()
-
-			over := totalAvailable - unpaid
-			carryCredit = over
-			remainingAmount = 0
-
-			inst.Credit_Balance = carryCredit
-			result.Case = "B"
-			result.Message = fmt.Sprintf("จ่ายเกิน สร้างเครดิตใหม่ %.2f", carryCredit)
-			result.CreditLeft = carryCredit
-			result.PaidAmount = inst.Paid_Amount
-
-			// ---- Case C ----
-		} else if totalAvailable >= unpaid {
-			inst.Paid_Amount = inst.Installment_Price
-			inst.Status = 1
-			inst.UpdatedAt = time.Now()
-
-			need := unpaid - remainingAmount
-			carryCredit -= need
-			if carryCredit < 0 {
-				carryCredit = 0
-			}
-			remainingAmount = 0
-
-			inst.Credit_Balance = 0
-			result.Case = "C"
-			result.Message = fmt.Sprintf("ใช้เครดิต ปิดงวด เครดิตเหลือ %.2f", carryCredit)
-			result.CreditLeft = carryCredit
-			result.PaidAmount = inst.Paid_Amount
-
-			// ---- Case D ----
-		} else {
-			if remainingAmount+carryCredit >= unpaid {
-				inst.Paid_Amount = inst.Installment_Price
-				inst.Status = 1
-				inst.UpdatedAt = time.Now()
-
-				over := remainingAmount + carryCredit - unpaid
-				carryCredit = over
-				remainingAmount = 0
-				inst.Credit_Balance = 0
-
-				result.Case = "D"
-				result.Message = fmt.Sprintf("ปิดงวดด้วยเครดิตทั้งหมด เครดิตเหลือ %.2f", carryCredit)
-				result.CreditLeft = carryCredit
-				result.PaidAmount = inst.Paid_Amount
-			}
+		if detailID != 0 && d.Id != detailID {
+			continue
 		}
-
-		results = append(results, result)
+		installments = append(installments, d)
+	}
+	if len(installments) == 0 {
+		return nil, errors.New("all installments already paid")
 	}
 
-	if err := s.billRepository.UpdateBillDetail(installments); err != nil {
+	policy, err := s.paymentRepository.GetAllocationPolicy()
+	if err != nil {
 		return nil, err
 	}
-	time.Sleep(100 * time.Millisecond)
-	bill.Credit_Balance = carryCredit
-	paidInstallments, err := s.billRepository.GetPaidInstallments1(billID)
-	if err != nil {
-		return nil, errors.New("cannot get paid installments from DB")
+
+	// 3. ตัดเงิน + เครดิต ตามลำดับค่าปรับ / ดอกเบี้ย / เงินต้น ของนโยบาย
+	allocations, leftover := allocatePayment(installments, toAllocationPolicy(policy), bill.Extra_Percent, amount+carryCredit)
+	if len(allocations) == 0 {
+		return nil, errors.New("ไม่มียอดค้างให้ตัดชำระ")
 	}
-	fmt.Print(len(paidInstallments), "666")
-	// bill.Paid_Installments = len(paidInstallments)
+	allocated := round2(amount + carryCredit - leftover)
 
-	// bill.Remaining_Installments = bill.Total_Installments - bill.Paid_Installments
-	bill.Paid_Installments = len(paidInstallments)
-	fmt.Print("bill.Paid_Installments", bill.Paid_Installments)
-	fmt.Print("bill.Total_Installments", bill.Total_Installments)
+	receiptNo, err := respository.GeneratePaymentReceipt(s.paymentRepository)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate receipt: %w", err)
+	}
+	payment := &model.Payment{
+		Receipt_No:  receiptNo,
+		Bill_Type:   model.BillTypeHirePurchase,
+		Bill_Id:     bill.Id,
+		Kind:        model.PaymentKindInstallment,
+		Amount:      round2(amount),
+		Allocations: allocations,
+	}
+	if actorID > 0 {
+		payment.User_Id = &actorID
+	}
+	if leftover > carryCredit {
+		payment.Credit_Added = round2(leftover - carryCredit)
+	} else {
+		payment.Credit_Used = round2(carryCredit - leftover)
+	}
+	if payment.Credit_Used > 0 {
+		tenders = append(tenders, model.Payment_Tender{Method: model.PaymentMethodCredit, Amount: payment.Credit_Used})
+	}
+	applyPaymentTenders(payment, tenders)
 
-	// bill.Remaining_Installments = bill.Total_Installments - bill.Paid_Installments
-	kkk := bill.Total_Installments - bill.Paid_Installments
-	fmt.Print("nigga how much?", kkk)
-	// bill.Remaining_Installments -= kkk
-	bill.Remaining_Installments = kkk
+	// 4. อัปเดตหัวบิล
+	paidByID := map[uint]respository.Bill_Details{}
+	for _, d := range installments {
+		paidByID[d.Id] = d
+	}
+	paidCount := 0
+	for _, d := range bill.BillDetails {
+		if updated, ok := paidByID[d.Id]; ok {
+			d = updated
+		}
+		if d.Status == model.BillDetailStatusPaid {
+			paidCount++
+		}
+	}
+	bill.Credit_Balance = leftover
+	bill.Paid_Installments = paidCount
+	bill.Remaining_Installments = bill.Total_Installments - paidCount
 	if bill.Remaining_Installments < 0 {
 		bill.Remaining_Installments = 0
 	}
-	fmt.Print("bill.Remaining_Installments", bill.Remaining_Installments)
-	bill.Paid_Amount += int(amount - remainingAmount)
+	bill.Paid_Amount += int(math.Round(amount))
+	bill.Remaining_Amount = round2(bill.Remaining_Amount - allocated)
+	if bill.Remaining_Amount < 0 {
+		bill.Remaining_Amount = 0
+	}
+	settledFrom := bill.Status
 	if bill.Paid_Installments >= bill.Total_Installments {
 		bill.Remaining_Amount = 0
-		bill.Status = 2
+		bill.Status = model.BillStatusSettled
 	}
 
-	// bill.Remaining_Amount -= amount - remainingAmount
-	// if bill.Remaining_Amount <= 0 {
-	// 	bill.Remaining_Amount = 0
-	// 	bill.Status = 2
-	// }
-
-	if err := s.billRepository.UpdateBill(bill); err != nil {
+	if err := s.paymentRepository.ApplyBillPayment(bill, installments, payment); err != nil {
 		return nil, err
 	}
+	if settledFrom != bill.Status {
+		s.logBillTransition(model.BillTypeHirePurchase, bill.Id, settledFrom, bill.Status, "settle", actorID, "ชำระครบ")
+	}
+	s.logPaymentCredit(payment, bill.MemberId, leftover, actorID)
 
-	return results, nil
+	return toInstallmentPayResults(installments, payment, leftover), nil
+}
+
+// สรุปผลการตัดเงินรายงวด (ใช้แสดงผลหลังรับชำระ)
+func toInstallmentPayResults(installments []respository.Bill_Details, payment *model.Payment, creditLeft float64) []InstallmentPayResult {
+	byDetail := map[uint]*InstallmentPayResult{}
+	var order []uint
+	for _, a := range payment.Allocations {
+		r, ok := byDetail[a.Bill_DetailsId]
+		if !ok {
+			r = &InstallmentPayResult{Detail_Id: a.Bill_DetailsId, Payment_Id: payment.Id, Receipt_No: payment.Receipt_No}
+			if no, err := strconv.Atoi(a.Payment_No); err == nil {
+				r.InstallmentNo = no
+			}
+			byDetail[a.Bill_DetailsId] = r
+			order = append(order, a.Bill_DetailsId)
+		}
+		switch a.Component {
+		case model.AllocationFee:
+			r.Fee_Paid = round2(r.Fee_Paid + a.Amount)
+		case model.AllocationInterest:
+			r.Interest_Paid = round2(r.Interest_Paid + a.Amount)
+		default:
+			r.Principal_Paid = round2(r.Principal_Paid + a.Amount)
+		}
+	}
+
+	results := make([]InstallmentPayResult, 0, len(order))
+	for i, id := range order {
+		r := byDetail[id]
+		for _, d := range installments {
+			if d.Id != id {
+				continue
+			}
+			r.PaidAmount = d.Paid_Amount
+			switch {
+			case d.Status != model.BillDetailStatusPaid:
+				r.Case = "D"
+				r.Message = fmt.Sprintf("ชำระบางส่วน งวด %s ค้างอีก %.2f", d.Payment_No, round2(d.Installment_Price-d.Paid_Amount))
+			case payment.Credit_Used > 0:
+				r.Case = "C"
+				r.Message = fmt.Sprintf("ใช้เครดิต ปิดงวด %s เครดิตเหลือ %.2f", d.Payment_No, creditLeft)
+			case creditLeft > 0 && i == len(order)-1:
+				r.Case = "B"
+				r.Message = fmt.Sprintf("จ่ายเกิน สร้างเครดิตใหม่ %.2f", creditLeft)
+			default:
+				r.Case = "A"
+				r.Message = fmt.Sprintf("จ่ายพอดี งวด %s", d.Payment_No)
+			}
+		}
+		if i == len(order)-1 {
+			r.CreditLeft = creditLeft
+		}
+		results = append(results, *r)
+	}
+	return results
 }
 
 func (s *billService) AutoApplyLateFees() error {
@@ -424,13 +492,26 @@ func (s *billService) AutoApplyLateFees() error {
 func round2(val float64) float64 {
 	return math.Round(val*100) / 100
 }
-func (s *billService) AddExtraPayment(billID uint, installmentID uint, request UpdateAddExtraRequest) error {
+func (s *billService) AddExtraPayment(billID uint, installmentID uint, request UpdateAddExtraRequest, actorID int) error {
 	// 1. ดึง Bill_Header
 	bill, err := s.billRepository.GetBillById(billID)
 	if err != nil {
 		return errors.New("bill not found")
 	}
 
+	tenders, err := buildPaymentTenders(request.Tenders, request.Paid_Amount)
+	if err != nil {
+		return err
+	}
+	tenders, cashIn, creditUsed := splitCreditTenders(tenders)
+	if creditUsed > round2(bill.Credit_Balance) {
+		return fmt.Errorf("เครดิตคงเหลือมีเพียง %.2f", bill.Credit_Balance)
+	}
+	request.Paid_Amount = round2(cashIn + creditUsed)
+	if request.Paid_Amount <= 0 {
+		return errors.New("กรุณาระบุยอดชำระ")
+	}
+
 	// 2. ดึง Bill_Detail ตาม installmentID
 	inst, err := s.billRepository.GetBillDetailById(installmentID)
 	if err != nil {
@@ -448,12 +529,19 @@ func (s *billService) AddExtraPayment(billID uint, installmentID uint, request U
 		return fmt.Errorf("payment exceeds remaining amount of the bill: %.2f > %d", request.Paid_Amount, bill.Remaining_Amount)
 	}
 
-	// 5. เพิ่มยอด Paid_Amount ของงวด
-	inst.Paid_Amount += request.Paid_Amount
+	// 5. ตัดเงินเข้างวดนี้ตามลำดับค่าปรับ / ดอกเบี้ย / เงินต้น ของนโยบาย (เก็บรายการตัดไว้กลับรายการได้)
+	policy, err := s.paymentRepository.GetAllocationPolicy()
+	if err != nil {
+		return err
+	}
+	paid := []respository.Bill_Details{*inst}
+	allocations, _ := allocatePayment(paid, toAllocationPolicy(policy), bill.Extra_Percent, request.Paid_Amount)
+	*inst = paid[0]
 
 	// 6. อัปเดตยอดรวมหัวบิล
-	bill.Paid_Amount += int(request.Paid_Amount)
+	bill.Paid_Amount += int(math.Round(cashIn))
 	bill.Remaining_Amount -= request.Paid_Amount
+	bill.Credit_Balance = round2(bill.Credit_Balance - creditUsed)
 
 	// 7. ปรับ Status ของงวดถ้าปิด
 	if inst.Paid_Amount >= inst.Installment_Price {
@@ -472,17 +560,42 @@ func (s *billService) AddExtraPayment(billID uint, installmentID uint, request U
 	bill.Remaining_Installments = bill.Total_Installments - paidInstallments
 
 	// 9. ถ้า bill.Remaining_Amount <= 0 → ปิดบิล
+	settledFrom := bill.Status
 	if bill.Remaining_Amount <= 0 {
-		bill.Status = 2 // ปิดบิล
+		bill.Status = model.BillStatusSettled // ปิดบิล
 	}
 
-	// 10. Save DB
-	if err := s.billRepository.UpdateBill(bill); err != nil {
-		return err
+	// 10. ออกใบเสร็จพร้อมช่องทางรับเงิน
+	receiptNo, err := respository.GeneratePaymentReceipt(s.paymentRepository)
+	if err != nil {
+		return fmt.Errorf("failed to generate receipt: %w", err)
+	}
+	payment := &model.Payment{
+		Receipt_No:  receiptNo,
+		Bill_Type:   model.BillTypeHirePurchase,
+		Bill_Id:     bill.Id,
+		Kind:        model.PaymentKindInstallment,
+		Amount:      cashIn,
+		Credit_Used: creditUsed,
+		Note:        fmt.Sprintf("ชำระเพิ่มงวด %s", inst.Payment_No),
+		Allocations: allocations,
 	}
-	if err := s.billRepository.UpdateSingleBillDetail(inst); err != nil {
+	if creditUsed > 0 {
+		tenders = append(tenders, model.Payment_Tender{Method: model.PaymentMethodCredit, Amount: creditUsed})
+	}
+	applyPaymentTenders(payment, tenders)
+	if actorID > 0 {
+		payment.User_Id = &actorID
+	}
+
+	// 11. Save DB (หัวบิล + งวด + ใบเสร็จ ใน transaction เดียว)
+	if err := s.paymentRepository.ApplyBillPayment(bill, []respository.Bill_Details{*inst}, payment); err != nil {
 		return err
 	}
+	if settledFrom != bill.Status {
+		s.logBillTransition(model.BillTypeHirePurchase, bill.Id, settledFrom, bill.Status, "settle", actorID, "ชำระครบ")
+	}
+	s.logPaymentCredit(payment, bill.MemberId, bill.Credit_Balance, actorID)
 
 	return nil
 }
@@ -661,12 +774,31 @@ func (s *billService) GetBillById(id uint) (*Bill_HeaderResponse, error) {
 		Status:         bill.Status,
 		Note:           bill.Note,
 		Credit_Balance: bill.Credit_Balance,
+
+		Down_Payment:    bill.Down_Payment,
+		Trade_In_Amount: bill.Trade_In_Amount,
+		Upfront_Amount:  bill.Upfront_Amount,
+
+		Schedule_Version: bill.Schedule_Version,
+	}
+	if bill.Schedule_Version > 1 {
+		if restructures, err := s.GetBillRestructures(bill.Id); err == nil {
+			response.Restructures = restructures
+		}
+	}
+	if lines, err := s.billRepository.GetBillLines(model.BillTypeHirePurchase, bill.Id); err == nil {
+		response.Lines = toBillLineItems(lines)
+	}
+	if bill.Trade_In_Amount > 0 {
+		if tradeIn, err := s.billRepository.GetTradeIn(model.BillTypeHirePurchase, bill.Id); err == nil {
+			response.Trade_In = toTradeInResponse(tradeIn)
+		}
 	}
 	return &response, nil
 
 }
 
-func (s *billService) CreateInstallmentBill(request NewInstallmentBillHeader, installMentId uint) (*Bill_HeaderResponse_Installment, error) {
+func (s *billService) CreateInstallmentBill(request NewInstallmentBillHeader, installMentId uint, actor AuditActor) (*Bill_HeaderResponse_Installment, error) {
 	loc, _ := time.LoadLocation("Asia/Bangkok")
 	startDate := time.Now().In(loc)
 	product, err := s.productRepository.GetProductByID(request.ProductId)
@@ -677,10 +809,6 @@ func (s *billService) CreateInstallmentBill(request NewInstallmentBillHeader, in
 		return nil, errors.New("member id is required")
 	}
 
-	if request.Loan_Amount >= product.Price {
-		return nil, fmt.Errorf("ยอดยืม %.2f บาท มากกว่าราคาสินค้า %.2f บาท", request.Loan_Amount, product.Price)
-	}
-
 	// ค่าพื้นฐาน
 	const fixedInterestPercent = 10.0
 	loanAmount := request.Loan_Amount
@@ -750,6 +878,44 @@ func (s *billService) CreateInstallmentBill(request NewInstallmentBillHeader, in
 
 	}
 
+	// ✅ สัญญาจำนำ (รอบ 10 วัน) ต้องมีใบประเมิน และยอดยืมไม่เกินเพดาน LTV (หมวดสินค้า + เกรดสภาพ)
+	var appraisalID uint
+	if intsallDay == 10 && request.TermType == 1 {
+		if request.AppraisalId == 0 {
+			return nil, errors.New("กรุณาประเมินสภาพเครื่องก่อนรับจำนำ")
+		}
+		appraisal, err := s.appraisalRepository.GetAppraisalById(request.AppraisalId)
+		if err != nil {
+			return nil, errors.New("ไม่พบใบประเมิน")
+		}
+		if appraisal.ProductId != request.ProductId {
+			return nil, errors.New("ใบประเมินไม่ตรงกับสินค้า")
+		}
+		if appraisal.Bill_Header_InstallmentId != nil {
+			return nil, ErrAppraisalInUse
+		}
+		// ใบประเมินที่ใช้รับเครื่องเทิร์นไปแล้ว เครื่องเป็นของร้าน รับจำนำซ้ำไม่ได้
+		tradedIn, err := s.appraisalRepository.IsAppraisalTradedIn(appraisal.Id)
+		if err != nil {
+			return nil, err
+		}
+		if tradedIn {
+			return nil, ErrAppraisalInUse
+		}
+		ltv, err := s.appraisalRepository.FindLtvPolicy(product.CategoryId, appraisal.Condition_Grade)
+		if err != nil {
+			return nil, fmt.Errorf("ยังไม่ได้กำหนด LTV สำหรับเกรด %s ของหมวดสินค้านี้", appraisal.Condition_Grade)
+		}
+		maxLoan := round2(appraisal.Appraised_Value * ltv.Max_Ltv_Percent / 100)
+		if request.Loan_Amount > maxLoan {
+			return nil, fmt.Errorf("ยอดยืม %.2f บาท เกินวงเงินสูงสุด %.2f บาท (%.0f%% ของราคาประเมิน %.2f บาท)", request.Loan_Amount, maxLoan, ltv.Max_Ltv_Percent, appraisal.Appraised_Value)
+		}
+		appraisalID = appraisal.Id
+	} else if request.Loan_Amount >= product.Price {
+		// สัญญาผ่อนรายเดือน ไม่มีใบประเมิน ใช้ราคาสินค้าเป็นเพดาน
+		return nil, fmt.Errorf("ยอดยืม %.2f บาท มากกว่าราคาสินค้า %.2f บาท", request.Loan_Amount, product.Price)
+	}
+
 	// Generate invoice
 	billNum, err := respository.GenerateHpc(s.billRepository)
 	if err != nil {
@@ -774,12 +940,7 @@ func (s *billService) CreateInstallmentBill(request NewInstallmentBillHeader, in
 		LastRenewDate:         startDate,
 		NextDueDate:           startDate.AddDate(0, 0, 10),
 
-		Status: 1,
-	}
-
-	createdBill, err := s.billRepository.CreateInstallmentBill(billHeader)
-	if err != nil {
-		return nil, err
+		Status: model.BillStatusActive,
 	}
 
 	// ✅ Create installment details
@@ -796,17 +957,20 @@ func (s *billService) CreateInstallmentBill(request NewInstallmentBillHeader, in
 		}
 
 		details = append(details, model.Bill_Details_Installment{
-			Bill_Header_InstallmentId: createdBill.Id,
-			Installment_Price:         rentPrice,
-			Status:                    0,
-			Payment_Date:              paymentDate,
-			Payment_No:                fmt.Sprintf("%d", i),
+			Installment_Price: rentPrice,
+			Status:            0,
+			Payment_Date:      paymentDate,
+			Payment_No:        fmt.Sprintf("%d", i),
 		})
 	}
 
-	if err := s.billRepository.CreateInstallmentBillDetails(details); err != nil {
+	// หัวบิล + งวด + ผูกใบประเมิน ใน transaction เดียว ถ้าใบประเมินถูกใช้ไปก่อนจะไม่สร้างบิล
+	if err := s.billRepository.CreateInstallmentBillContract(billHeader, details, appraisalID); err != nil {
 		return nil, err
 	}
+	createdBill := billHeader
+	s.logBillTransition(model.BillTypeInstallment, createdBill.Id, model.BillStatusDraft, model.BillStatusActive, "activate", request.User_Id, "สร้างสัญญา")
+	recordAudit(s.auditRepository, billCreateActor(actor, request.User_Id), model.AuditActionCreate, model.AuditEntityInstallmentBill, createdBill.Id, nil, toInstallmentBillAudit(createdBill))
 
 	resp := &Bill_HeaderResponse_Installment{
 		Id:                    createdBill.Id,
@@ -833,7 +997,7 @@ func (s *billService) UpdateDailyInterest() error {
 	}
 
 	for _, bill := range bills {
-		if bill.Installment_Day != 10 || bill.Status != 1 {
+		if bill.Installment_Day != 10 || !model.IsOpenBillStatus(bill.Status) {
 			continue
 		}
 		if len(bill.BillDetailsInstallment) == 0 {
@@ -907,7 +1071,7 @@ func (s *billService) UpdateDailyInterest() error {
 
 		log.Printf("✅ บวกดอกเพิ่ม %.2f (วันละ %.2f x %d วัน) | ดอกเบี้ยรวมตอนนี้ %.2f", additional, interestPerDay, daysToCharge, bill.Interest_Amount)
 
-		total := float64(bill.Loan_Amount) + bill.Interest_Amount + bill.Fee_Amount
+		total := pawnPrincipalOwed(&bill) + bill.Interest_Amount + bill.Fee_Amount
 		bill.Net_installment = math.Round(total / 10.0)
 		bill.Remaining_Amount = math.Round(total - float64(bill.Paid_Amount))
 
@@ -948,7 +1112,7 @@ func (s *billService) UpdateDailyInterestSingle(testDate ...time.Time) error {
 	const termDays = 10
 
 	for _, bill := range bills {
-		if bill.Installment_Day != 10 || bill.Status != 1 {
+		if bill.Installment_Day != 10 || !model.IsOpenBillStatus(bill.Status) {
 			continue
 		}
 
@@ -1035,7 +1199,7 @@ func (s *billService) UpdateDailyInterestSingle(testDate ...time.Time) error {
 		}
 
 		// ✅ อัปเดตยอดรวม
-		total := float64(bill.Loan_Amount) + bill.Interest_Amount
+		total := pawnPrincipalOwed(&bill) + bill.Interest_Amount
 		bill.Net_installment = math.Round(total / float64(termDays))
 		bill.Remaining_Amount = math.Round(total - float64(bill.Paid_Amount))
 		latestDetail.Installment_Price = total
@@ -1060,211 +1224,146 @@ func (s *billService) UpdateDailyInterestSingle(testDate ...time.Time) error {
 }
 
 
-func (s *billService) PayPurchaseInstallment(billID uint, detailID uint, amount float64) ([]InstallmentPayResult, error) {
-	results := []InstallmentPayResult{}
-
-	// 1. ดึง Bill_Header
+func (s *billService) PayPurchaseInstallment(billID uint, detailID uint, amount float64, reqTenders []PaymentTenderRequest, actorID int) ([]InstallmentPayResult, error) {
+	// 1. ดึง Bill_Header พร้อมงวดทั้งหมด
 	bill, err := s.billRepository.GetInstallmentBillById(billID)
 	if err != nil {
 		return nil, errors.New("bill not found")
 	}
-	carryCredit := bill.Credit_Balance
-
-	maxPayable := float64(bill.Total_Installments)*bill.Net_installment + bill.Fee_Amount + carryCredit
-	remainingBill := maxPayable - float64(bill.Paid_Amount)
-	if remainingBill < 0 {
-		remainingBill = 0
+	if amount < 0 {
+		return nil, errors.New("ยอดชำระต้องไม่ติดลบ")
 	}
+	carryCredit := bill.Credit_Balance
 
-	installments, err := s.billRepository.GetUnpaidBillInstallments1(billID, detailID)
+	// ช่องทาง credit ไม่ใช่เงินเข้าร้าน เครดิตของหัวบิลถูกใช้ตัดงวดอยู่แล้ว จึงตรวจแค่ว่าไม่เกินที่มี
+	tenders, err := buildPaymentTenders(reqTenders, amount)
 	if err != nil {
-		return nil, errors.New("cannot get installments")
+		return nil, err
 	}
-	if len(installments) == 0 {
-		return nil, errors.New("all installments already paid")
+	tenders, amount, creditTendered := splitCreditTenders(tenders)
+	if creditTendered > round2(carryCredit) {
+		return nil, fmt.Errorf("เครดิตคงเหลือมีเพียง %.2f", carryCredit)
 	}
 
-	remainingAmount := amount
-	// if remainingAmount > float64(bill.Remaining_Amount) {
-	// 	return nil, fmt.Errorf("ยอดชำระ %.2f เกินยอดคงเหลือของบิล %.2f", remainingAmount, float64(bill.Remaining_Amount))
-	// }
+	// บิล 10 วัน ต้องจ่ายเต็มราคา หรือราคาตามวัน (ยอดคงเหลือที่คิดดอกถึงวันนี้) เพื่อไถ่ถอนทั้งสัญญาเท่านั้น
 	fullPrice := bill.Total_Price
-	dayPrice := float64(bill.Remaining_Amount) // ราคาที่อัพเดตตามวัน (เช่น 2040)
-	if bill.Installment_Day == 10 {
-		// ตรวจสอบว่าจ่ายยอดเท่ากับ fullPrice หรือ dayPrice เท่านั้น
+	dayPrice := bill.Remaining_Amount
+	tenDay := bill.Installment_Day == 10
+	if tenDay {
 		if amount != fullPrice && amount != dayPrice {
 			return nil, fmt.Errorf("บิล 10 วัน ต้องจ่ายเต็มราคา %.2f หรือราคาตามวัน %.2f เท่านั้น", fullPrice, dayPrice)
 		}
-		if amount > bill.Remaining_Amount && amount != fullPrice {
-			return nil, fmt.Errorf("ยอดชำระ1 %.2f เกินยอดคงเหลือของบิล1 %.2f", amount, bill.Remaining_Amount)
-		}
-	} else {
-		// สำหรับบิลแบบอื่น ๆ ตรวจสอบยอดไม่เกิน Remaining_Amount
-		if amount > bill.Remaining_Amount {
-			return nil, fmt.Errorf("ยอดชำระ2 %.2f เกินยอดคงเหลือของบิล2 %.2f", amount, bill.Remaining_Amount)
-		}
+	} else if amount > bill.Remaining_Amount+carryCredit {
+		return nil, fmt.Errorf("ยอดชำระ %.2f เกินยอดคงเหลือของบิลและเครดิต %.2f", amount, bill.Remaining_Amount+carryCredit)
+	}
+	if amount+carryCredit <= 0 {
+		return nil, errors.New("กรุณาระบุยอดชำระ")
 	}
 
-	fmt.Print("666bill.Remaining_Amount+carryCredit//", bill.Remaining_Amount)
-	// if remainingAmount > float64(bill.Remaining_Amount) {
-	// 	return nil, fmt.Errorf("ยอดชำระ %.2f เกินยอดคงเหลือของบิล %.2f", remainingAmount, float64(bill.Remaining_Amount))
-	// }
-
-	for i := 0; i < len(installments) && (remainingAmount > 0 || carryCredit > 0); i++ {
-		inst := &installments[i]
-		unpaid := inst.Installment_Price - inst.Paid_Amount
-		if unpaid <= 0 {
+	// 2. งวดที่ยังไม่จ่าย (ระบุงวด = ตัดเฉพาะงวดนั้น ส่วนเกินเก็บเป็นเครดิต)
+	var installments []model.Bill_Details_Installment
+	for _, d := range bill.BillDetailsInstallment {
+		if d.Status != model.BillDetailStatusUnpaid {
 			continue
 		}
-		totalAvailable := remainingAmount + carryCredit
-		if totalAvailable <= 0 {
-			break
-		}
-
-		var result InstallmentPayResult
-		result.InstallmentNo = i + 1
-
-		// ถ้าเป็นบิล 10 วัน ให้จ่ายเฉพาะ Case A เท่านั้น
-		// if bill.Installment_Day == 10 {
-		// 	if totalAvailable == unpaid {
-
-		// 		inst.Paid_Amount = inst.Installment_Price
-		// 		inst.Status = 1
-		// 		inst.UpdatedAt = time.Now()
-		// 		if remainingAmount >= unpaid {
-		// 			remainingAmount -= unpaid
-		// 		} else {
-		// 			carryCredit -= unpaid - remainingAmount
-		// 			remainingAmount = 0
-		// 		}
-		// 		inst.Credit_Balance = 0
-		// 		result.Case = "A"
-		// 		result.Message = fmt.Sprintf("จ่ายพอดี งวด %d", i+1)
-		// 		result.CreditLeft = 0
-		// 		result.PaidAmount = inst.Paid_Amount
-		// 	} else {
-		// 		return nil, fmt.Errorf("บิล 10 วัน ต้องจ่ายพอดีเท่านั้น (Case A) งวด %d", i+1)
-		// 	}
-		// }
-		if bill.Installment_Day == 10 {
-			if totalAvailable == unpaid || totalAvailable == fullPrice {
-				inst.Paid_Amount = inst.Installment_Price
-				inst.Status = 1
-				inst.UpdatedAt = time.Now()
-				if remainingAmount >= totalAvailable {
-					remainingAmount -= totalAvailable
-				} else {
-					carryCredit -= totalAvailable - remainingAmount
-					remainingAmount = 0
-				}
-				inst.Credit_Balance = 0
-				result.Case = "A"
-				result.Message = fmt.Sprintf("จ่ายพอดี งวด %d", i+1)
-				result.CreditLeft = carryCredit
-				result.PaidAmount = inst.Paid_Amount
-			}
-			//  else {
-			// 	return nil, fmt.Errorf("บิล 10 วัน ต้องจ่ายเต็มราคา %.2f หรือราคาตามวัน %.2f เท่านั้น (งวด %d)", fullPrice, unpaid, i+1)
-			// }
-		} else {
-			fmt.Print("D")
-
-			// Logic ปกติสำหรับบิลอื่น ๆ (ไม่ใช่ 10 วัน)
-			if totalAvailable == unpaid {
-
-				inst.Paid_Amount = inst.Installment_Price
-				inst.Status = 1
-				inst.UpdatedAt = time.Now()
-				if remainingAmount >= unpaid {
-					remainingAmount -= unpaid
-				}
-				inst.Credit_Balance = 0
-				result.Case = "A"
-				result.Message = fmt.Sprintf("จ่ายพอดี งวด %d", i+1)
-				result.CreditLeft = 0
-				result.PaidAmount = inst.Paid_Amount
-
-			} else if totalAvailable > unpaid { // Case B
-				fmt.Println("เข้า Case B: จ่ายเกิน สร้างเครดิตใหม่")
-
-				inst.Paid_Amount = inst.Installment_Price
-				inst.Status = 1
-				inst.UpdatedAt = time.Now()
-				over := totalAvailable - unpaid
-				carryCredit = over
-				remainingAmount = 0
-				inst.Credit_Balance = carryCredit
-				result.Case = "B"
-				result.Message = fmt.Sprintf("จ่ายเกิน สร้างเครดิตใหม่ %.2f", carryCredit)
-				result.CreditLeft = carryCredit
-				result.PaidAmount = inst.Paid_Amount
-				fmt.Print("B")
-
-			} else if totalAvailable >= unpaid { // Case C
-				fmt.Println("เข้า Case C: ใช้เครดิต ปิดงวด")
-
-				inst.Paid_Amount = inst.Installment_Price
-				inst.Status = 1
-				inst.UpdatedAt = time.Now()
-				need := unpaid - remainingAmount
-				carryCredit -= need
-				if carryCredit < 0 {
-					carryCredit = 0
-				}
-				remainingAmount = 0
-				inst.Credit_Balance = 0
-				result.Case = "C"
-				result.Message = fmt.Sprintf("ใช้เครดิต ปิดงวด เครดิตเหลือ %.2f", carryCredit)
-				result.CreditLeft = carryCredit
-				result.PaidAmount = inst.Paid_Amount
-				fmt.Print("C")
-
-			} else { // Case D
-				if remainingAmount+carryCredit >= unpaid {
-					inst.Paid_Amount = inst.Installment_Price
-					inst.Status = 1
-					inst.UpdatedAt = time.Now()
-					over := remainingAmount + carryCredit - unpaid
-					carryCredit = over
-					remainingAmount = 0
-					inst.Credit_Balance = 0
-					result.Case = "D"
-					result.Message = fmt.Sprintf("ปิดงวดด้วยเครดิตทั้งหมด เครดิตเหลือ %.2f", carryCredit)
-					result.CreditLeft = carryCredit
-					result.PaidAmount = inst.Paid_Amount
-				}
-
-			}
+		if detailID != 0 && d.Id != detailID {
+			continue
 		}
-		results = append(results, result)
+		installments = append(installments, d)
+	}
+	if len(installments) == 0 {
+		return nil, errors.New("all installments already paid")
 	}
 
-	// Update database
-	if err := s.billRepository.UpdateInstallmentBillDetail(installments); err != nil {
+	policy, err := s.paymentRepository.GetAllocationPolicy()
+	if err != nil {
 		return nil, err
 	}
 
-	bill.Credit_Balance = carryCredit
-	paidInstallments, err := s.billRepository.GetPaidInstallmentsBill1(billID)
+	// 3. ตัดเงิน + เครดิต ตามลำดับของนโยบาย
+	allocations, view, leftover := allocateInstallmentBillPayment(installments, toAllocationPolicy(policy), amount+carryCredit)
+	if len(allocations) == 0 {
+		return nil, errors.New("ไม่มียอดค้างให้ตัดชำระ")
+	}
+	allocated := round2(amount + carryCredit - leftover)
+
+	receiptNo, err := respository.GeneratePaymentReceipt(s.paymentRepository)
 	if err != nil {
-		return nil, errors.New("cannot get paid installments from DB")
+		return nil, fmt.Errorf("failed to generate receipt: %w", err)
+	}
+	payment := &model.Payment{
+		Receipt_No:  receiptNo,
+		Bill_Type:   model.BillTypeInstallment,
+		Bill_Id:     bill.Id,
+		Kind:        model.PaymentKindInstallment,
+		Amount:      round2(amount),
+		Allocations: allocations,
+	}
+	if actorID > 0 {
+		payment.User_Id = &actorID
+	}
+	if leftover > carryCredit {
+		payment.Credit_Added = round2(leftover - carryCredit)
+	} else {
+		payment.Credit_Used = round2(carryCredit - leftover)
+	}
+	if payment.Credit_Used > 0 {
+		tenders = append(tenders, model.Payment_Tender{Method: model.PaymentMethodCredit, Amount: payment.Credit_Used})
 	}
-	time.Sleep(100 * time.Millisecond)
+	applyPaymentTenders(payment, tenders)
 
-	bill.Paid_Installments = len(paidInstallments)
-	bill.Remaining_Installments = bill.Total_Installments - bill.Paid_Installments
-	bill.Paid_Amount += int(amount - remainingAmount)
-	bill.Remaining_Amount -= amount - remainingAmount
-	if bill.Remaining_Amount <= 0 {
+	// 4. อัปเดตหัวบิล
+	bill.Remaining_Amount = round2(bill.Remaining_Amount - allocated)
+	if tenDay && amount >= dayPrice {
+		// ไถ่ถอนตามราคาตามวัน: ปิดงวดที่เหลือตามยอดที่รับจริง
 		bill.Remaining_Amount = 0
-		bill.Status = 2
+		for i := range installments {
+			if installments[i].Status == model.BillDetailStatusUnpaid {
+				installments[i].Installment_Price = installments[i].Paid_Amount
+				installments[i].Status = model.BillDetailStatusPaid
+				view[i].Installment_Price = installments[i].Paid_Amount
+				view[i].Status = model.BillDetailStatusPaid
+			}
+		}
+	}
+	if bill.Remaining_Amount < 0 {
+		bill.Remaining_Amount = 0
+	}
+
+	paidByID := map[uint]model.Bill_Details_Installment{}
+	for _, d := range installments {
+		paidByID[d.Id] = d
+	}
+	paidCount := 0
+	for _, d := range bill.BillDetailsInstallment {
+		if updated, ok := paidByID[d.Id]; ok {
+			d = updated
+		}
+		if d.Status == model.BillDetailStatusPaid {
+			paidCount++
+		}
+	}
+	bill.Credit_Balance = leftover
+	bill.Paid_Installments = paidCount
+	bill.Remaining_Installments = bill.Total_Installments - paidCount
+	if bill.Remaining_Installments < 0 {
+		bill.Remaining_Installments = 0
+	}
+	bill.Paid_Amount += int(math.Round(amount))
+	settledFrom := bill.Status
+	if bill.Remaining_Amount <= 0 {
+		bill.Status = model.BillStatusSettled
 	}
 
-	if err := s.billRepository.UpdateBillInstallment(bill); err != nil {
+	if err := s.paymentRepository.ApplyInstallmentBillPayment(bill, installments, payment); err != nil {
 		return nil, err
 	}
+	if settledFrom != bill.Status {
+		s.logBillTransition(model.BillTypeInstallment, bill.Id, settledFrom, bill.Status, "settle", actorID, "ชำระครบ")
+	}
+	s.logPaymentCredit(payment, bill.MemberId, leftover, actorID)
 
-	return results, nil
+	return toInstallmentPayResults(view, payment, leftover), nil
 }
 
 func (s *billService) GetInstallmentBillById(id uint) (*Bill_HeaderResponse_Installment, error) {
@@ -1491,73 +1590,100 @@ func (s *billService) AutoApplyInstallementLateFees() error {
 	log.Printf("AutoApplyInstallmentLateFees เสร็จใน %s, อัปเดต %d บิล", elapsed, updatedCount)
 	return nil
 }
-func (s *billService) AddInstallmentExtraPayment(billID uint, installmentID uint, request UpdateAddExtraRequest_Installment) error {
+func (s *billService) AddInstallmentExtraPayment(billID uint, installmentID uint, request UpdateAddExtraRequest_Installment, actorID int) error {
 	// 1. ดึง Bill_Header
 	bill, err := s.billRepository.GetInstallmentBillById(billID)
 	if err != nil {
 		return errors.New("bill not found")
 	}
 
+	tenders, err := buildPaymentTenders(request.Tenders, request.Paid_Amount)
+	if err != nil {
+		return err
+	}
+	tenders, cashIn, creditUsed := splitCreditTenders(tenders)
+	if creditUsed > round2(bill.Credit_Balance) {
+		return fmt.Errorf("เครดิตคงเหลือมีเพียง %.2f", bill.Credit_Balance)
+	}
+	request.Paid_Amount = round2(cashIn + creditUsed)
+	if request.Paid_Amount <= 0 {
+		return errors.New("กรุณาระบุยอดชำระ")
+	}
+
 	// 2. ดึง Bill_Detail ตาม installmentID
 	inst, err := s.billRepository.GetInstallmentBillDetailById(installmentID)
-	if err != nil {
+	if err != nil || inst.Bill_Header_InstallmentId != bill.Id {
 		return errors.New("installment not found")
 	}
 
-	// 3. ตรวจสอบว่ายอดเงินที่จ่ายไม่เกินยอดที่เหลือของงวด
-	// remainingInst := bill.Total_Price - inst.Paid_Amount
-	// if request.Paid_Amount > remainingInst {
-	// 	log.Printf("666Error: Payment exceeds remaining installment amount. Requested: %.2f, Remaining: %.2f", request.Paid_Amount, remainingInst)
-	// 	return fmt.Errorf("66payment exceeds remaining amount of this installment: %.2f > %.2f", request.Paid_Amount, remainingInst)
-	// }
-
-	// 4. ตรวจสอบว่ายอดเงินไม่เกินยอดคงเหลือของบิล
+	// 3. ตรวจสอบว่ายอดเงินไม่เกินยอดคงเหลือของบิล
 	if request.Paid_Amount > bill.Remaining_Amount {
-		return fmt.Errorf("payment exceeds remaining amount of the bill: %.2f > %d", request.Paid_Amount, bill.Remaining_Amount)
+		return fmt.Errorf("payment exceeds remaining amount of the bill: %.2f > %.2f", request.Paid_Amount, bill.Remaining_Amount)
 	}
 
-	// 5. เพิ่มยอด Paid_Amount ของงวด
-	inst.Paid_Amount += request.Paid_Amount
-
-	// 6. อัปเดตยอดรวมหัวบิล
-	bill.Paid_Amount += int(request.Paid_Amount)
-	bill.Remaining_Amount -= request.Paid_Amount
-
-	// 7. ปรับ Status ของงวดถ้าปิด
-	if inst.Paid_Amount >= inst.Installment_Price {
-		if request.Paid_Amount > bill.Remaining_Amount {
-			inst.Status = 1
-
-		} else {
-			inst.Status = 1                                                         // ปิดงวด
-			inst.Credit_Balance = round2(inst.Paid_Amount - inst.Installment_Price) // ถ้ามีเงินเกิน เก็บเป็นเครดิต
-		}
-
+	// 4. ตัดเงินเข้างวดนี้ตามนโยบาย ส่วนที่เกินยอดงวดเก็บเป็นเครดิต
+	policy, err := s.paymentRepository.GetAllocationPolicy()
+	if err != nil {
+		return err
 	}
+	paid := []model.Bill_Details_Installment{*inst}
+	allocations, _, leftover := allocateInstallmentBillPayment(paid, toAllocationPolicy(policy), request.Paid_Amount)
+	*inst = paid[0]
+	allocated := round2(request.Paid_Amount - leftover)
+
+	// 5. อัปเดตยอดรวมหัวบิล
+	bill.Paid_Amount += int(math.Round(cashIn))
+	bill.Remaining_Amount = round2(bill.Remaining_Amount - allocated)
+	bill.Credit_Balance = round2(bill.Credit_Balance - creditUsed + leftover)
 
-	// 8. อัปเดตจำนวนงวดที่ปิดในหัวบิล
 	paidInstallments := 0
 	for _, d := range bill.BillDetailsInstallment {
-		if d.Status == 1 || d.Id == inst.Id && inst.Status == 1 {
+		if d.Id == inst.Id {
+			d = *inst
+		}
+		if d.Status == model.BillDetailStatusPaid {
 			paidInstallments++
 		}
 	}
 	bill.Paid_Installments = paidInstallments
 	bill.Remaining_Installments = bill.Total_Installments - paidInstallments
 
-	// 9. ถ้า bill.Remaining_Amount <= 0 → ปิดบิล
+	settledFrom := bill.Status
 	if bill.Remaining_Amount <= 0 {
-		bill.Status = 2 // ปิดบิล
+		bill.Remaining_Amount = 0
+		bill.Status = model.BillStatusSettled // ปิดบิล
 	}
 
-	// 10. Save DB
-	if err := s.billRepository.UpdateBillInstallment(bill); err != nil {
+	// 6. ออกใบเสร็จพร้อมช่องทางรับเงิน แล้วบันทึกพร้อมงวด / หัวบิล ใน transaction เดียว
+	receiptNo, err := respository.GeneratePaymentReceipt(s.paymentRepository)
+	if err != nil {
+		return fmt.Errorf("failed to generate receipt: %w", err)
+	}
+	payment := &model.Payment{
+		Receipt_No:   receiptNo,
+		Bill_Type:    model.BillTypeInstallment,
+		Bill_Id:      bill.Id,
+		Kind:         model.PaymentKindInstallment,
+		Amount:       cashIn,
+		Credit_Used:  creditUsed,
+		Credit_Added: leftover,
+		Note:         fmt.Sprintf("ชำระเพิ่มงวด %s", inst.Payment_No),
+		Allocations:  allocations,
+	}
+	if creditUsed > 0 {
+		tenders = append(tenders, model.Payment_Tender{Method: model.PaymentMethodCredit, Amount: creditUsed})
+	}
+	applyPaymentTenders(payment, tenders)
+	if actorID > 0 {
+		payment.User_Id = &actorID
+	}
+	if err := s.paymentRepository.ApplyInstallmentBillPayment(bill, []model.Bill_Details_Installment{*inst}, payment); err != nil {
 		return err
 	}
-
-	if err := s.billRepository.UpdateInstallmentSingleBillDetail(inst); err != nil {
-		return err
+	if settledFrom != bill.Status {
+		s.logBillTransition(model.BillTypeInstallment, bill.Id, settledFrom, bill.Status, "settle", actorID, "ชำระครบ")
 	}
+	s.logPaymentCredit(payment, bill.MemberId, bill.Credit_Balance, actorID)
 
 	return nil
 }
@@ -1672,14 +1798,27 @@ func (s *billService) GetAllInstallmentBill(
 	}, nil
 }
 
-func (s *billService) UpdateBill(id uint, request Update_Installment) (*Bill_HeaderResponse, error) {
+func (s *billService) UpdateBill(id uint, request Update_Installment, actor AuditActor) (*Bill_HeaderResponse, error) {
+	actorID := actor.User_Id
+	current, err := s.billRepository.GetBillById(id)
+	if err != nil {
+		return nil, errors.New("ไม่พบบิล")
+	}
+	before := toHirePurchaseBillAudit(current)
+	// ✅ เปลี่ยนสถานะผ่าน state machine เท่านั้น
+	if request.Status != current.Status {
+		if err := s.transitionBillTo(model.BillTypeHirePurchase, id, current.Status, request.Status, actorID, request.Note); err != nil {
+			return nil, err
+		}
+	}
+
 	installment := &respository.Bill_Header{ // ✅ ต้องเป็น pointer
 		Id:     id, // สำคัญ ต้อง set id ไม่งั้น update ไม่รู้ว่าจะ update record ไหน
 		Status: request.Status,
 		Note:   request.Note,
 	}
 
-	err := s.billRepository.UpdateBillStatus(installment)
+	err = s.billRepository.UpdateBillStatus(installment)
 	if err != nil {
 		return nil, err
 	}
@@ -1689,6 +1828,7 @@ func (s *billService) UpdateBill(id uint, request Update_Installment) (*Bill_Hea
 	if err != nil {
 		return nil, err
 	}
+	recordAudit(s.auditRepository, actor, model.AuditActionUpdate, model.AuditEntityBill, id, before, toHirePurchaseBillAudit(updatedInstallment))
 
 	return &Bill_HeaderResponse{
 		Id:      updatedInstallment.Id,
@@ -1697,14 +1837,27 @@ func (s *billService) UpdateBill(id uint, request Update_Installment) (*Bill_Hea
 		Note:    updatedInstallment.Note,
 	}, nil
 }
-func (s *billService) UpdateBill_Installment(id uint, request Update_Installment) (*Bill_HeaderResponse_Installment, error) {
+func (s *billService) UpdateBill_Installment(id uint, request Update_Installment, actor AuditActor) (*Bill_HeaderResponse_Installment, error) {
+	actorID := actor.User_Id
+	current, err := s.billRepository.GetInstallmentBillById(id)
+	if err != nil {
+		return nil, errors.New("ไม่พบบิล")
+	}
+	before := toInstallmentBillAudit(current)
+	// ✅ เปลี่ยนสถานะผ่าน state machine เท่านั้น
+	if request.Status != current.Status {
+		if err := s.transitionBillTo(model.BillTypeInstallment, id, current.Status, request.Status, actorID, request.Note); err != nil {
+			return nil, err
+		}
+	}
+
 	installment := &model.Bill_Header_Installment{ // ✅ ต้องเป็น pointer
 		Id:     id, // สำคัญ ต้อง set id ไม่งั้น update ไม่รู้ว่าจะ update record ไหน
 		Status: request.Status,
 		Note:   request.Note,
 	}
 
-	err := s.billRepository.UpdateInstallmentBillStatus(installment)
+	err = s.billRepository.UpdateInstallmentBillStatus(installment)
 	if err != nil {
 		return nil, err
 	}
@@ -1714,6 +1867,7 @@ func (s *billService) UpdateBill_Installment(id uint, request Update_Installment
 	if err != nil {
 		return nil, err
 	}
+	recordAudit(s.auditRepository, actor, model.AuditActionUpdate, model.AuditEntityInstallmentBill, id, before, toInstallmentBillAudit(updatedInstallment))
 
 	return &Bill_HeaderResponse_Installment{
 		Id:      updatedInstallment.Id,
@@ -2012,7 +2166,7 @@ func (s *billService) GetDueTodayBillsWithInstallments(sortData string) (*BillRe
 			Invoice:   ins.BillHeader.Invoice,
 			CreatedAt: ins.BillHeader.CreatedAt,
 			UpdatedAt: ins.BillHeader.UpdatedAt,
-			DeletedAt: ins.BillHeader.DeletedAt,
+			DeletedAt: ins.BillHeader.DeletedAt.Time,
 
 			MemberId:       ins.BillHeader.MemberId,
 			MemberFullName: ins.BillHeader.Member.FullName,
@@ -2250,7 +2404,7 @@ func (s *billService) GetUnpaidBillById(userId string) ([]Bill_HeaderResponse1,
 
 			CreatedAt: h.CreatedAt,
 			UpdatedAt: h.UpdatedAt,
-			DeletedAt: h.DeletedAt,
+			DeletedAt: h.DeletedAt.Time,
 
 			MemberId:       h.MemberId,
 			MemberFullName: h.Member.FullName,
@@ -2445,7 +2599,7 @@ func (s *billService) GetpaidBillById(billID uint, detailID uint) ([]Bill_Header
 
 			CreatedAt: h.CreatedAt,
 			UpdatedAt: h.UpdatedAt,
-			DeletedAt: h.DeletedAt,
+			DeletedAt: h.DeletedAt.Time,
 
 			MemberId:       h.MemberId,
 			MemberFullName: h.Member.FullName,
@@ -2688,12 +2842,28 @@ func (s *billService) ApplyLateFeeToSingleBill(billID uint, today time.Time) err
 	return nil
 }
 
-func (s *billService) RenewInterest(billID uint, payAmount float64, payDate time.Time) (*model.Bill_Header_Installment, error) {
+func (s *billService) RenewInterest(billID uint, payAmount float64, payDate time.Time, userID int, paymentRef string, reqTenders []PaymentTenderRequest) (*model.Bill_Header_Installment, error) {
 	// loc, _ := time.LoadLocation("Asia/Bangkok")
 	bill, err := s.billRepository.GetInstallmentBillById(billID)
 	if err != nil {
 		return nil, errors.New("ไม่พบบิล")
 	}
+
+	tenders, err := buildPaymentTenders(reqTenders, payAmount)
+	if err != nil {
+		return nil, err
+	}
+	payAmount = 0
+	for _, t := range tenders {
+		if t.Method == model.PaymentMethodCredit {
+			return nil, errors.New("ต่อดอกด้วยเครดิตคงเหลือไม่ได้")
+		}
+		payAmount += t.Amount
+	}
+	payAmount = round2(payAmount)
+	if len(reqTenders) == 0 && len(tenders) == 1 {
+		tenders[0].Reference = strings.TrimSpace(paymentRef)
+	}
 	loc, _ := time.LoadLocation("Asia/Bangkok")
 	// payDate = payDate.In(loc).Truncate(24 * time.Hour)
 	payDate = time.Now().In(loc).Truncate(24 * time.Hour)
@@ -2719,12 +2889,13 @@ func (s *billService) RenewInterest(billID uint, payAmount float64, payDate time
 
 		// 1.1) ปิดสถานะงวดเก่า (ทำให้เป็นเหมือนใบเสร็จ)
 		allDetails, _ := s.billRepository.GetInstallmentDetailsByBillID(billID)
+		var closed []model.Bill_Details_Installment
 		for i := range allDetails {
 			if allDetails[i].Status == 0 {
 				allDetails[i].Status = 2
 				// บันทึกว่ามีการจ่ายเงิน 200 ในงวดนี้ก่อนปิด
 				// allDetails[i].Paid_Amount = payAmount
-				_ = s.billRepository.UpdateInstallmentDetail(&allDetails[i])
+				closed = append(closed, allDetails[i])
 			}
 		}
 
@@ -2735,6 +2906,12 @@ func (s *billService) RenewInterest(billID uint, payAmount float64, payDate time
 		log.Printf("cal1", cal1)
 		log.Printf("payAmount", payAmount)
 
+		// เงินต้นใหม่จากการลด/เพิ่มเงินต้น มีผลตั้งแต่รอบนี้
+		oldPrincipal := bill.Loan_Amount
+		if bill.Pending_Loan_Amount != nil {
+			bill.Loan_Amount = *bill.Pending_Loan_Amount
+		}
+
 		// ยอดใหม่ = เงินต้น (2000) + cal1 (20) = 2020
 		newInstallmentPrice := bill.Loan_Amount + cal1
 		log.Printf("newInstallmentPrice", newInstallmentPrice)
@@ -2763,14 +2940,11 @@ func (s *billService) RenewInterest(billID uint, payAmount float64, payDate time
 			Payment_No:                fmt.Sprintf("INTEREST-%d-%s", bill.Id, payDate.Format("20060102150405")),
 			UpdatedAt:                 payDate,
 		}
-		if err := s.billRepository.CreateInstallmentBillDetails([]model.Bill_Details_Installment{newDetail}); err != nil {
-			return nil, errors.New("สร้างรายการงวดใหม่ไม่สำเร็จ")
-		}
 
-		// 2.3) บันทึก Bill Header ที่อัปเดตแล้ว
+		// 2.3) บันทึกงวดเก่า งวดใหม่ หัวบิล ประวัติต่อดอก และใบเสร็จ ใน transaction เดียว
 		// 💡 หมายเหตุ: เราจะยังไม่รีเซ็ต Interest_Amount ที่นี่ เพราะมันคือดอกเบี้ยของรอบใหม่
-		if err := s.billRepository.UpdateBillInstallment(bill); err != nil {
-			return nil, errors.New("อัปเดตข้อมูลบิลไม่สำเร็จ")
+		if err := s.recordPawnRenewal(bill, closed, &newDetail, oldPrincipal, 1, payAmount, 0, nextDue, payDate, userID, paymentRef, tenders); err != nil {
+			return nil, err
 		}
 		log.Printf("newDetail", newDetail.Payment_Date)
 		log.Printf("lastday", bill.LastRenewDate)
@@ -2839,7 +3013,8 @@ func (s *billService) RenewInterest(billID uint, payAmount float64, payDate time
 		// ✅ ขั้นตอนที่ 2: ปิดงวดเก่า และคำนวณสถานะของ "งวดใหม่"
 		// =================================================================================
 
-		// 2.1) ปิดสถานะงวดเก่า
+		// 2.1) ปิดสถานะงวดเก่า (บันทึกพร้อมหัวบิลตอนต่อดอก)
+		var closed []model.Bill_Details_Installment
 		for i := range allDetails {
 			if allDetails[i].Status == 0 {
 				log.Printf("🧾 กำลังอัปเดตงวดเก่า (ID: %d) ให้เป็นใบเสร็จสรุปยอด...", allDetails[i].Id)
@@ -2849,18 +3024,14 @@ func (s *billService) RenewInterest(billID uint, payAmount float64, payDate time
 				// ยอดรวมของธุรกรรมนี้ = เงินต้น + ยอดค้างชำระทั้งหมด
 				allDetails[i].Installment_Price = bill.Loan_Amount + totalDue
 				allDetails[i].Fee_Amount = totalFeeForOldCycle
-
-				// เรียกใช้ฟังก์ชัน Repository ที่แก้ไขแล้ว
-				if err := s.billRepository.UpdateInstallmentDetail(&allDetails[i]); err != nil {
-					log.Printf("❌ เกิดข้อผิดพลาดในการอัปเดตงวดเก่า ID %d: %v", allDetails[i].Id, err)
-					// เพื่อความปลอดภัย ควร return error ออกไป
-					return nil, fmt.Errorf("ไม่สามารถอัปเดตงวดเก่า ID %d ได้: %w", allDetails[i].Id, err)
-				} else {
-					log.Printf("✅ อัปเดตงวดเก่า (ID: %d) สำเร็จ", allDetails[i].Id)
-				}
+				closed = append(closed, allDetails[i])
 			}
 		}
-		// 2.2) เงินต้นสำหรับรอบใหม่ คือเงินต้นเดิม
+		// 2.2) เงินต้นสำหรับรอบใหม่ คือเงินต้นเดิม (หรือเงินต้นใหม่ถ้ามีการลด/เพิ่มเงินต้น)
+		oldPrincipal := bill.Loan_Amount
+		if bill.Pending_Loan_Amount != nil {
+			bill.Loan_Amount = *bill.Pending_Loan_Amount
+		}
 		newPrincipal := bill.Loan_Amount
 
 		// 2.3) 💡 [แก้ไข] คำนวณดอกเบี้ยที่เกิดขึ้นแล้วสำหรับ "รอบใหม่" (Pro-rata)
@@ -2931,10 +3102,6 @@ func (s *billService) RenewInterest(billID uint, payAmount float64, payDate time
 		// 	return nil, errors.New("สร้างรายการงวดใหม่ไม่สำเร็จ")
 		// }
 
-		if err := s.billRepository.CreateInstallmentBillDetails([]model.Bill_Details_Installment{newDetail}); err != nil {
-			return nil, errors.New("สร้างรายการงวดใหม่ไม่สำเร็จ")
-		}
-
 		// =================================================================================
 		// ✅ ขั้นตอนที่ 3: ✨ ตั้งค่า Bill Header สำหรับ "สถานะปัจจุบันของรอบใหม่" ✨
 		// =================================================================================
@@ -2945,8 +3112,9 @@ func (s *billService) RenewInterest(billID uint, payAmount float64, payDate time
 		bill.Late_Day = 0
 		log.Printf("newDetail", newDetail.Payment_Date)
 
-		if err := s.billRepository.UpdateBillInstallment(bill); err != nil {
-			return nil, errors.New("อัปเดตข้อมูลบิลไม่สำเร็จ")
+		// ✅ ขั้นตอนที่ 4: บันทึกงวดเก่า งวดใหม่ หัวบิล ประวัติต่อดอก และใบเสร็จ ใน transaction เดียว
+		if err := s.recordPawnRenewal(bill, closed, &newDetail, oldPrincipal, numberOfCycles, totalInterestForOldCycle, totalFeeForOldCycle, nextDue, payDate, userID, paymentRef, tenders); err != nil {
+			return nil, err
 		}
 
 		log.Printf("✅ ต่ออายุและเริ่มรอบใหม่สำเร็จ BillID:%d | New Remaining=%.2f",
@@ -2954,3 +3122,596 @@ func (s *billService) RenewInterest(billID uint, payAmount float64, payDate time
 	}
 	return bill, nil
 }
+
+func (s *billService) recordPawnRenewal(bill *model.Bill_Header_Installment, closed []model.Bill_Details_Installment, newDetail *model.Bill_Details_Installment, oldPrincipal float64, cycles int, interestPaid, feePaid float64, oldDue, payDate time.Time, userID int, paymentRef string, tenders []model.Payment_Tender) error {
+	renewal := &model.Pawn_Renewal{
+		Bill_Header_InstallmentId: bill.Id,
+		Cycles_Covered:            cycles,
+		Interest_Paid:             round2(interestPaid),
+		Fee_Paid:                  round2(feePaid),
+		Principal:                 bill.Loan_Amount,
+		Principal_Change:          round2(bill.Loan_Amount - oldPrincipal),
+		Old_Due_Date:              oldDue,
+		New_Due_Date:              bill.NextDueDate,
+		Pay_Date:                  payDate,
+		User_Id:                   userID,
+		Payment_Ref:               paymentRef,
+	}
+
+	// ใบเสร็จต่อดอก พร้อมช่องทางรับเงิน (เลขครั้งที่ต่อดอกใส่ตอนบันทึก)
+	var payment *model.Payment
+	if len(tenders) > 0 {
+		receiptNo, err := respository.GeneratePaymentReceipt(s.paymentRepository)
+		if err != nil {
+			return fmt.Errorf("failed to generate receipt: %w", err)
+		}
+		payment = &model.Payment{
+			Receipt_No: receiptNo,
+			Bill_Type:  model.BillTypeInstallment,
+			Bill_Id:    bill.Id,
+			Kind:       model.PaymentKindRenewal,
+		}
+		for _, t := range tenders {
+			payment.Amount += t.Amount
+		}
+		payment.Amount = round2(payment.Amount)
+		applyPaymentTenders(payment, tenders)
+		if userID > 0 {
+			payment.User_Id = &userID
+		}
+	}
+
+	if err := s.billRepository.RenewPawnBill(bill, closed, newDetail, renewal, payment); err != nil {
+		log.Printf("❌ ต่อดอกบิล %d ไม่สำเร็จ: %v", bill.Id, err)
+		return errors.New("บันทึกการต่อดอกไม่สำเร็จ")
+	}
+	bill.RenewCount = renewal.Renewal_No
+	bill.Pending_Loan_Amount = nil
+	return nil
+}
+
+func (s *billService) GetPawnRenewalHistory(billID uint) (*PawnRenewalHistoryResponse, error) {
+	bill, err := s.billRepository.GetInstallmentBillById(billID)
+	if err != nil {
+		return nil, errors.New("ไม่พบบิล")
+	}
+
+	renewals, err := s.billRepository.GetPawnRenewalsByBillId(billID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &PawnRenewalHistoryResponse{
+		BillId:         bill.Id,
+		Invoice:        bill.Invoice,
+		MemberFullName: bill.Member.FullName,
+		RenewCount:     len(renewals),
+		Renewals:       make([]PawnRenewalResponse, 0, len(renewals)),
+	}
+	for _, r := range renewals {
+		resp.Total_Interest += r.Interest_Paid
+		resp.Total_Fee += r.Fee_Paid
+		resp.Renewals = append(resp.Renewals, PawnRenewalResponse{
+			Id:               r.Id,
+			Renewal_No:       r.Renewal_No,
+			Cycles_Covered:   r.Cycles_Covered,
+			Interest_Paid:    r.Interest_Paid,
+			Fee_Paid:         r.Fee_Paid,
+			Principal:        r.Principal,
+			Principal_Change: r.Principal_Change,
+			Old_Due_Date:     r.Old_Due_Date,
+			New_Due_Date:     r.New_Due_Date,
+			Pay_Date:         r.Pay_Date,
+			UserId:           r.User_Id,
+			UserFullName:     r.User.FullName,
+			Payment_Ref:      r.Payment_Ref,
+		})
+	}
+	resp.Total_Interest = round2(resp.Total_Interest)
+	resp.Total_Fee = round2(resp.Total_Fee)
+	return resp, nil
+}
+
+// สำหรับ LINE bot: ดูได้เฉพาะบิลของสมาชิกที่ผูก user_id ไว้
+func (s *billService) GetPawnRenewalHistoryForMember(userId string, billID uint) (*PawnRenewalHistoryResponse, error) {
+	bill, err := s.billRepository.GetInstallmentBillById(billID)
+	if err != nil || bill.Member.UserId == "" || bill.Member.UserId != userId {
+		return nil, errors.New("ไม่พบบิล")
+	}
+	return s.GetPawnRenewalHistory(billID)
+}
+
+func toPawnForfeitPolicyResponse(p *model.Pawn_Forfeit_Policy) *PawnForfeitPolicyResponse {
+	return &PawnForfeitPolicyResponse{
+		Missed_Cycles:     p.Missed_Cycles,
+		Notice_Days:       p.Notice_Days,
+		Valuation_Base:    p.Valuation_Base,
+		Valuation_Percent: p.Valuation_Percent,
+		UpdatedAt:         p.UpdatedAt,
+	}
+}
+
+func toPawnForfeitNoticeResponse(n model.Pawn_Forfeit_Notice) PawnForfeitNoticeResponse {
+	return PawnForfeitNoticeResponse{
+		Id:            n.Id,
+		BillId:        n.Bill_Header_InstallmentId,
+		Invoice:       n.Bill_Header_Installment.Invoice,
+		MemberId:      n.MemberId,
+		MemberName:    n.Member.FullName,
+		MemberUser_id: n.Member.UserId,
+		ProductName:   n.Bill_Header_Installment.Product.Name,
+		Loan_Amount:   n.Bill_Header_Installment.Loan_Amount,
+		Missed_Cycles: n.Missed_Cycles,
+		Notice_Date:   n.Notice_Date,
+		Forfeit_Date:  n.Forfeit_Date,
+		Sent_At:       n.Sent_At,
+		Status:        n.Status,
+	}
+}
+
+// จำนวนรอบ 10 วันที่เลยกำหนดต่อดอก (สูตรเดียวกับ RenewInterest)
+func pawnMissedCycles(nextDue, today time.Time) int {
+	loc, _ := time.LoadLocation("Asia/Bangkok")
+	due := nextDue.In(loc)
+	due = time.Date(due.Year(), due.Month(), due.Day(), 0, 0, 0, 0, loc)
+	if !today.After(due) {
+		return 0
+	}
+	lateDays := int(today.Sub(due).Hours() / 24)
+	if lateDays <= 0 {
+		return 0
+	}
+	return ((lateDays - 1) / 10) + 1
+}
+
+func (s *billService) getPawnForfeitPolicy() (*model.Pawn_Forfeit_Policy, error) {
+	policy, err := s.billRepository.GetPawnForfeitPolicy()
+	if err != nil {
+		return nil, err
+	}
+	if policy.Missed_Cycles <= 0 {
+		policy.Missed_Cycles = 3
+	}
+	if policy.Notice_Days < 0 {
+		policy.Notice_Days = 0
+	}
+	if policy.Valuation_Base != 2 {
+		policy.Valuation_Base = 1
+	}
+	if policy.Valuation_Percent <= 0 {
+		policy.Valuation_Percent = 100
+	}
+	return policy, nil
+}
+
+func (s *billService) GetPawnForfeitPolicy() (*PawnForfeitPolicyResponse, error) {
+	policy, err := s.getPawnForfeitPolicy()
+	if err != nil {
+		return nil, err
+	}
+	return toPawnForfeitPolicyResponse(policy), nil
+}
+
+func (s *billService) UpdatePawnForfeitPolicy(request UpdatePawnForfeitPolicyRequest) (*PawnForfeitPolicyResponse, error) {
+	if request.Missed_Cycles <= 0 {
+		return nil, errors.New("จำนวนรอบที่ขาดต่อดอกต้องมากกว่า 0")
+	}
+	if request.Notice_Days < 0 {
+		return nil, errors.New("จำนวนวันแจ้งเตือนต้องไม่ติดลบ")
+	}
+	if request.Valuation_Base != 1 && request.Valuation_Base != 2 {
+		return nil, errors.New("valuation_base ต้องเป็น 1 (ยอดเงินต้น) หรือ 2 (ราคาสินค้า)")
+	}
+	if request.Valuation_Percent <= 0 {
+		return nil, errors.New("valuation_percent ต้องมากกว่า 0")
+	}
+
+	policy, err := s.getPawnForfeitPolicy()
+	if err != nil {
+		return nil, err
+	}
+	policy.Missed_Cycles = request.Missed_Cycles
+	policy.Notice_Days = request.Notice_Days
+	policy.Valuation_Base = request.Valuation_Base
+	policy.Valuation_Percent = request.Valuation_Percent
+
+	if err := s.billRepository.UpdatePawnForfeitPolicy(policy); err != nil {
+		return nil, err
+	}
+	return s.GetPawnForfeitPolicy()
+}
+
+// ตรวจบิลจำนำที่ขาดต่อดอก: ออกหนังสือแจ้ง → ครบกำหนดแจ้งแล้วยังไม่ต่อดอก → หลุดจำนำ
+func (s *billService) ProcessPawnForfeitures(testDate ...time.Time) error {
+	loc, _ := time.LoadLocation("Asia/Bangkok")
+
+	var today time.Time
+	if len(testDate) > 0 {
+		t := testDate[0].In(loc)
+		today = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	} else {
+		now := time.Now().In(loc)
+		today = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	}
+
+	policy, err := s.getPawnForfeitPolicy()
+	if err != nil {
+		return err
+	}
+
+	bills, err := s.billRepository.GetAllUnpaid10DayBills()
+	if err != nil {
+		return err
+	}
+
+	var noticed, cancelled, forfeited int
+	for i := range bills {
+		bill := &bills[i]
+		missed := pawnMissedCycles(bill.NextDueDate, today)
+
+		notice, err := s.billRepository.GetActiveForfeitNotice(bill.Id)
+		if err != nil {
+			log.Printf("❌ ไม่สามารถดึงหนังสือแจ้งหลุดจำนำ บิล %d: %v", bill.Id, err)
+			continue
+		}
+
+		// ลูกค้ากลับมาต่อดอกแล้ว → ยกเลิกหนังสือแจ้ง
+		if missed < policy.Missed_Cycles {
+			if notice != nil {
+				notice.Status = 2
+				if err := s.billRepository.UpdateForfeitNotice(notice); err != nil {
+					log.Printf("❌ ยกเลิกหนังสือแจ้งบิล %d ไม่สำเร็จ: %v", bill.Id, err)
+				} else {
+					cancelled++
+				}
+			}
+			continue
+		}
+
+		if notice == nil {
+			newNotice := &model.Pawn_Forfeit_Notice{
+				Bill_Header_InstallmentId: bill.Id,
+				MemberId:                  bill.MemberId,
+				Missed_Cycles:             missed,
+				Notice_Date:               today,
+				Forfeit_Date:              today.AddDate(0, 0, policy.Notice_Days),
+				Status:                    0,
+			}
+			if err := s.billRepository.CreateForfeitNotice(newNotice); err != nil {
+				log.Printf("❌ ออกหนังสือแจ้งบิล %d ไม่สำเร็จ: %v", bill.Id, err)
+			} else {
+				noticed++
+			}
+			continue
+		}
+
+		if notice.Missed_Cycles != missed {
+			notice.Missed_Cycles = missed
+			if err := s.billRepository.UpdateForfeitNotice(notice); err != nil {
+				log.Printf("❌ อัปเดตจำนวนรอบค้างในหนังสือแจ้งบิล %d ไม่สำเร็จ: %v", bill.Id, err)
+			}
+		}
+
+		// ต้องแจ้งลูกค้าแล้วและครบระยะเวลาแจ้งก่อนจึงจะหลุดจำนำ
+		if notice.Status != 1 || today.Before(notice.Forfeit_Date) {
+			continue
+		}
+
+		if err := s.forfeitPawnBill(bill, notice, policy, today); err != nil {
+			log.Printf("❌ หลุดจำนำบิล %d ไม่สำเร็จ: %v", bill.Id, err)
+			continue
+		}
+		forfeited++
+	}
+
+	log.Printf("ProcessPawnForfeitures: แจ้งใหม่ %d | ยกเลิก %d | หลุดจำนำ %d", noticed, cancelled, forfeited)
+	return nil
+}
+
+func (s *billService) forfeitPawnBill(bill *model.Bill_Header_Installment, notice *model.Pawn_Forfeit_Notice, policy *model.Pawn_Forfeit_Policy, today time.Time) error {
+	base := bill.Loan_Amount
+	if policy.Valuation_Base == 2 {
+		product, err := s.productRepository.GetProductByID(bill.ProductId)
+		if err != nil {
+			return errors.New("product not found")
+		}
+		base = product.Price
+	}
+	valuation := round2(base * policy.Valuation_Percent / 100)
+
+	forfeitedAt := today
+	forfeitedFrom := bill.Status
+	bill.Status = model.BillStatusForfeited
+	bill.Forfeited_At = &forfeitedAt
+	bill.Remaining_Amount = 0
+	bill.Note = strings.TrimSpace(bill.Note + fmt.Sprintf("\nหลุดจำนำ %s (ขาดต่อดอก %d รอบ)", today.Format("2006-01-02"), notice.Missed_Cycles))
+	notice.Status = 3
+
+	billID := bill.Id
+	unit := &model.Inventory_Unit{
+		ProductId:                 bill.ProductId,
+		Source:                    model.InventorySourceForfeit,
+		Bill_Header_InstallmentId: &billID,
+		Valuation:                 valuation,
+		Status:                    model.InventoryUnitAvailable,
+		Note:                      fmt.Sprintf("หลุดจำนำจากบิล %s", bill.Invoice),
+	}
+	if appraisal, err := s.appraisalRepository.GetAppraisalByBillId(bill.Id); err == nil {
+		unit.Imei = appraisal.Imei
+	}
+
+	if err := s.billRepository.ForfeitInstallmentBill(bill, notice, unit); err != nil {
+		return err
+	}
+	s.logBillTransition(model.BillTypeInstallment, bill.Id, forfeitedFrom, model.BillStatusForfeited, "forfeit", 0, fmt.Sprintf("ขาดต่อดอก %d รอบ", notice.Missed_Cycles))
+	log.Printf("✅ หลุดจำนำบิล %d | ตั้งราคาขาย %.2f", bill.Id, valuation)
+	return nil
+}
+
+func (s *billService) GetPawnForfeitReport() ([]PawnForfeitReportItem, error) {
+	loc, _ := time.LoadLocation("Asia/Bangkok")
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	policy, err := s.getPawnForfeitPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	bills, err := s.billRepository.GetAllUnpaid10DayBills()
+	if err != nil {
+		return nil, err
+	}
+
+	report := []PawnForfeitReportItem{}
+	for _, b := range bills {
+		missed := pawnMissedCycles(b.NextDueDate, today)
+		if missed == 0 {
+			continue
+		}
+
+		full, err := s.billRepository.GetInstallmentBillById(b.Id)
+		if err != nil {
+			continue
+		}
+
+		item := PawnForfeitReportItem{
+			BillId:               full.Id,
+			Invoice:              full.Invoice,
+			MemberId:             full.MemberId,
+			MemberFullName:       full.Member.FullName,
+			MemberTel:            full.Member.Tel,
+			ProductId:            full.ProductId,
+			ProductName:          full.Product.Name,
+			Loan_Amount:          full.Loan_Amount,
+			NextDueDate:          full.NextDueDate,
+			Missed_Cycles:        missed,
+			Cycles_Before_Notice: policy.Missed_Cycles - missed,
+		}
+		if item.Cycles_Before_Notice < 0 {
+			item.Cycles_Before_Notice = 0
+		}
+
+		notice, err := s.billRepository.GetActiveForfeitNotice(b.Id)
+		if err == nil && notice != nil {
+			forfeitDate := notice.Forfeit_Date
+			daysLeft := int(forfeitDate.In(loc).Sub(today).Hours() / 24)
+			item.NoticeId = &notice.Id
+			item.NoticeStatus = &notice.Status
+			item.Forfeit_Date = &forfeitDate
+			item.Days_Left = &daysLeft
+		}
+
+		report = append(report, item)
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].Missed_Cycles > report[j].Missed_Cycles
+	})
+	return report, nil
+}
+
+func (s *billService) GetPendingForfeitNotices() ([]PawnForfeitNoticeResponse, error) {
+	notices, err := s.billRepository.GetPendingForfeitNotices()
+	if err != nil {
+		return nil, err
+	}
+	responses := make([]PawnForfeitNoticeResponse, 0, len(notices))
+	for _, n := range notices {
+		responses = append(responses, toPawnForfeitNoticeResponse(n))
+	}
+	return responses, nil
+}
+
+// บันทึกว่าแจ้งลูกค้าแล้ว วันหลุดจำนำนับจากวันที่แจ้งจริง
+func (s *billService) MarkForfeitNoticeSent(id uint) (*PawnForfeitNoticeResponse, error) {
+	notice, err := s.billRepository.GetForfeitNoticeById(id)
+	if err != nil {
+		return nil, errors.New("ไม่พบหนังสือแจ้ง")
+	}
+	if notice.Status != 0 {
+		return nil, errors.New("หนังสือแจ้งนี้ไม่ได้อยู่ในสถานะรอแจ้ง")
+	}
+
+	policy, err := s.getPawnForfeitPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	loc, _ := time.LoadLocation("Asia/Bangkok")
+	now := time.Now().In(loc)
+	sentDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	notice.Sent_At = &now
+	notice.Forfeit_Date = sentDay.AddDate(0, 0, policy.Notice_Days)
+	notice.Status = 1
+	if err := s.billRepository.UpdateForfeitNotice(notice); err != nil {
+		return nil, err
+	}
+
+	resp := toPawnForfeitNoticeResponse(*notice)
+	return &resp, nil
+}
+
+// เงินต้นที่ลูกค้าค้างอยู่ตอนนี้ (รวมรายการลด/เพิ่มเงินต้นที่ยังไม่ถึงรอบเปลี่ยนฐานดอกเบี้ย)
+func pawnPrincipalOwed(bill *model.Bill_Header_Installment) float64 {
+	if bill.Pending_Loan_Amount != nil {
+		return *bill.Pending_Loan_Amount
+	}
+	return bill.Loan_Amount
+}
+
+// ยอดยืมสูงสุดของบิลจำนำตามใบประเมิน + LTV
+func (s *billService) pawnMaxLoan(bill *model.Bill_Header_Installment) (float64, error) {
+	appraisal, err := s.appraisalRepository.GetAppraisalByBillId(bill.Id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, errors.New("บิลนี้ไม่มีใบประเมิน กรุณาประเมินสภาพเครื่องก่อนเพิ่มเงินต้น")
+	}
+	if err != nil {
+		return 0, err
+	}
+	ltv, err := s.appraisalRepository.FindLtvPolicy(bill.Product.CategoryId, appraisal.Condition_Grade)
+	if err != nil {
+		return 0, fmt.Errorf("ยังไม่ได้กำหนด LTV สำหรับเกรด %s ของหมวดสินค้านี้", appraisal.Condition_Grade)
+	}
+	return round2(appraisal.Appraised_Value * ltv.Max_Ltv_Percent / 100), nil
+}
+
+func (s *billService) changePawnPrincipal(billID uint, changeType int, request PawnPrincipalChangeRequest, userID int) (*PawnPrincipalChangeResponse, error) {
+	if request.Amount <= 0 {
+		return nil, errors.New("จำนวนเงินต้องมากกว่า 0")
+	}
+
+	bill, err := s.billRepository.GetInstallmentBillById(billID)
+	if err != nil {
+		return nil, errors.New("ไม่พบบิล")
+	}
+	if bill.Installment_Day != 10 || bill.TermType != 1 {
+		return nil, errors.New("ลด/เพิ่มเงินต้นได้เฉพาะบิลจำนำ")
+	}
+	if !model.IsOpenBillStatus(bill.Status) {
+		return nil, errors.New("บิลนี้ปิดไปแล้ว")
+	}
+
+	amount := round2(request.Amount)
+	before := pawnPrincipalOwed(bill)
+	var after float64
+
+	if changeType == 1 {
+		after = round2(before - amount)
+		if after <= 0 {
+			return nil, fmt.Errorf("ยอดลดเงินต้น %.2f บาท ต้องน้อยกว่าเงินต้นคงเหลือ %.2f บาท (ถ้าจะไถ่ถอนให้ชำระปิดบิล)", amount, before)
+		}
+	} else {
+		after = round2(before + amount)
+		maxLoan, err := s.pawnMaxLoan(bill)
+		if err != nil {
+			return nil, err
+		}
+		if after > maxLoan {
+			return nil, fmt.Errorf("เงินต้นหลังเพิ่ม %.2f บาท เกินวงเงินสูงสุด %.2f บาท", after, maxLoan)
+		}
+	}
+
+	// ลดเงินต้น = รับเงินจากลูกค้า, เพิ่มเงินต้น = จ่ายเงินให้ลูกค้า (เงินสด / โอนเท่านั้น)
+	reqTenders := request.Tenders
+	if len(reqTenders) == 0 {
+		reqTenders = []PaymentTenderRequest{{Method: model.PaymentMethodCash, Amount: amount, Reference: request.Payment_Ref}}
+	}
+	tenders, err := buildPaymentTenders(reqTenders, amount)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tenders {
+		if t.Method == model.PaymentMethodCredit {
+			return nil, errors.New("ลด/เพิ่มเงินต้นใช้เครดิตคงเหลือไม่ได้")
+		}
+		if changeType == 2 && t.Method != model.PaymentMethodCash && t.Method != model.PaymentMethodTransfer {
+			return nil, fmt.Errorf("จ่ายเงินเพิ่มเงินต้นได้เฉพาะเงินสดหรือโอนเงิน ไม่รองรับ %s", paymentMethodName(t.Method))
+		}
+	}
+	receiptNo, err := respository.GeneratePaymentReceipt(s.paymentRepository)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate receipt: %w", err)
+	}
+	payment := &model.Payment{
+		Receipt_No: receiptNo,
+		Bill_Type:  model.BillTypeInstallment,
+		Bill_Id:    bill.Id,
+		Kind:       model.PaymentKindPrincipal,
+		Amount:     amount,
+		Note:       fmt.Sprintf("ลดเงินต้น %.2f → %.2f", before, after),
+	}
+	if changeType == 2 {
+		payment.Amount = -amount
+		payment.Note = fmt.Sprintf("เพิ่มเงินต้น %.2f → %.2f", before, after)
+		tenders = reverseTenders(tenders)
+	}
+	applyPaymentTenders(payment, tenders)
+	if userID > 0 {
+		payment.User_Id = &userID
+	}
+
+	// ยอดคงเหลือเปลี่ยนทันที แต่ดอกเบี้ยยังคิดจากเงินต้นเดิมจนถึงรอบต่อดอกถัดไป
+	bill.Pending_Loan_Amount = &after
+	bill.Remaining_Amount = round2(bill.Remaining_Amount + (after - before))
+
+	change := &model.Pawn_Principal_Change{
+		Bill_Header_InstallmentId: bill.Id,
+		Type:                      changeType,
+		Amount:                    amount,
+		Principal_Before:          before,
+		Principal_After:           after,
+		Effective_Date:            bill.NextDueDate,
+		User_Id:                   userID,
+		Payment_Ref:               request.Payment_Ref,
+		Note:                      request.Note,
+	}
+	if err := s.billRepository.CreatePrincipalChange(bill, change, payment); err != nil {
+		return nil, errors.New("บันทึกรายการเงินต้นไม่สำเร็จ")
+	}
+
+	log.Printf("✅ เปลี่ยนเงินต้นบิล %d: %.2f → %.2f (มีผลรอบถัดไป %s)", bill.Id, before, after, bill.NextDueDate.Format("2006-01-02"))
+	resp := toPawnPrincipalChangeResponse(*change)
+	return &resp, nil
+}
+
+func (s *billService) ReducePawnPrincipal(billID uint, request PawnPrincipalChangeRequest, userID int) (*PawnPrincipalChangeResponse, error) {
+	return s.changePawnPrincipal(billID, 1, request, userID)
+}
+
+func (s *billService) TopUpPawnPrincipal(billID uint, request PawnPrincipalChangeRequest, userID int) (*PawnPrincipalChangeResponse, error) {
+	return s.changePawnPrincipal(billID, 2, request, userID)
+}
+
+func (s *billService) GetPawnPrincipalChanges(billID uint) ([]PawnPrincipalChangeResponse, error) {
+	changes, err := s.billRepository.GetPrincipalChangesByBillId(billID)
+	if err != nil {
+		return nil, err
+	}
+	responses := make([]PawnPrincipalChangeResponse, 0, len(changes))
+	for _, c := range changes {
+		responses = append(responses, toPawnPrincipalChangeResponse(c))
+	}
+	return responses, nil
+}
+
+func toPawnPrincipalChangeResponse(c model.Pawn_Principal_Change) PawnPrincipalChangeResponse {
+	return PawnPrincipalChangeResponse{
+		Id:               c.Id,
+		BillId:           c.Bill_Header_InstallmentId,
+		Type:             c.Type,
+		Amount:           c.Amount,
+		Principal_Before: c.Principal_Before,
+		Principal_After:  c.Principal_After,
+		Effective_Date:   c.Effective_Date,
+		Applied_At:       c.Applied_At,
+		Pawn_RenewalId:   c.Pawn_RenewalId,
+		UserId:           c.User_Id,
+		UserFullName:     c.User.FullName,
+		Payment_Ref:      c.Payment_Ref,
+		Note:             c.Note,
+		PaymentId:        c.PaymentId,
+		CreatedAt:        c.CreatedAt,
+	}
+}
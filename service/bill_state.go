@@ -0,0 +1,387 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"rrmobile/model"
+	"time"
+)
+
+// ข้อมูลสัญญาที่ใช้ตรวจเงื่อนไขการเปลี่ยนสถานะ
+type billStateSnapshot struct {
+	BillType   int
+	Status     int
+	Remaining  float64
+	HasPayment bool
+	HasOverdue bool
+	IsPawn     bool
+}
+
+type billTransition struct {
+	Name  string
+	From  []int
+	To    int
+	Guard func(b billStateSnapshot) error
+}
+
+var billTransitions = []billTransition{
+	{
+		Name: "activate",
+		From: []int{model.BillStatusDraft},
+		To:   model.BillStatusActive,
+	},
+	{
+		Name: "mark_overdue",
+		From: []int{model.BillStatusActive},
+		To:   model.BillStatusOverdue,
+		Guard: func(b billStateSnapshot) error {
+			if !b.HasOverdue {
+				return errors.New("ยังไม่มีงวดที่เลยกำหนดชำระ")
+			}
+			return nil
+		},
+	},
+	{
+		Name: "cure",
+		From: []int{model.BillStatusOverdue},
+		To:   model.BillStatusActive,
+		Guard: func(b billStateSnapshot) error {
+			if b.HasOverdue {
+				return errors.New("ยังมีงวดที่เลยกำหนดชำระค้างอยู่")
+			}
+			return nil
+		},
+	},
+	{
+		Name: "default",
+		From: []int{model.BillStatusOverdue},
+		To:   model.BillStatusDefaulted,
+	},
+	{
+		Name: "settle",
+		From: model.OpenBillStatuses,
+		To:   model.BillStatusSettled,
+		Guard: func(b billStateSnapshot) error {
+			if b.Remaining > 0 {
+				return fmt.Errorf("ยังมียอดคงเหลือ %.2f บาท", b.Remaining)
+			}
+			return nil
+		},
+	},
+	{
+		Name: "cancel",
+		From: []int{model.BillStatusDraft, model.BillStatusActive},
+		To:   model.BillStatusCancelled,
+		Guard: func(b billStateSnapshot) error {
+			if b.HasPayment {
+				return errors.New("สัญญานี้มีการชำระเงินแล้ว ต้องกลับรายการชำระก่อนยกเลิก")
+			}
+			return nil
+		},
+	},
+	{
+		Name: "forfeit",
+		From: model.OpenBillStatuses,
+		To:   model.BillStatusForfeited,
+		Guard: func(b billStateSnapshot) error {
+			if !b.IsPawn {
+				return errors.New("หลุดจำนำได้เฉพาะบิลจำนำ")
+			}
+			return nil
+		},
+	},
+	{
+		Name: "restructure",
+		From: []int{model.BillStatusOverdue, model.BillStatusDefaulted},
+		To:   model.BillStatusActive,
+		Guard: func(b billStateSnapshot) error {
+			if b.IsPawn {
+				return errors.New("ปรับโครงสร้างหนี้ได้เฉพาะสัญญาผ่อน")
+			}
+			return nil
+		},
+	},
+	{
+		// กลับรายการใบเสร็จของสัญญาที่ปิดแล้ว ทำให้มียอดค้างอีกครั้ง
+		Name: "reopen",
+		From: []int{model.BillStatusSettled},
+		To:   model.BillStatusActive,
+	},
+	{
+		Name: "repossess",
+		From: []int{model.BillStatusOverdue, model.BillStatusDefaulted},
+		To:   model.BillStatusRepossessed,
+		Guard: func(b billStateSnapshot) error {
+			if b.IsPawn {
+				return errors.New("บิลจำนำใช้การหลุดจำนำแทนการยึดคืน")
+			}
+			return nil
+		},
+	},
+}
+
+// สถานะที่มีขั้นตอนเฉพาะ ต้องผ่าน operation ของมันเอง (cron / หลุดจำนำ / ปรับโครงสร้างหนี้ / ยกเลิกสัญญา / กลับรายการชำระ)
+var systemBillTransitions = map[string]bool{"mark_overdue": true, "cure": true, "forfeit": true, "restructure": true, "cancel": true, "reopen": true}
+
+func findBillTransition(name string) (*billTransition, error) {
+	for i := range billTransitions {
+		if billTransitions[i].Name == name {
+			return &billTransitions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("ไม่รู้จักการเปลี่ยนสถานะ %q", name)
+}
+
+// หา transition จากสถานะ from ไป to (ใช้กับ UpdateBill ที่ส่งสถานะมาเป็นตัวเลข)
+func findBillTransitionTo(from, to int) (*billTransition, error) {
+	for i := range billTransitions {
+		t := &billTransitions[i]
+		if t.To == to && containsStatus(t.From, from) {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("ไม่สามารถเปลี่ยนสถานะจาก %s เป็น %s ได้", billStatusName(from), billStatusName(to))
+}
+
+func containsStatus(list []int, status int) bool {
+	for _, s := range list {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func billStatusName(status int) string {
+	if name, ok := model.BillStatusNames[status]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", status)
+}
+
+func (s *billService) loadBillState(billType int, billID uint) (*billStateSnapshot, error) {
+	loc, _ := time.LoadLocation("Asia/Bangkok")
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	switch billType {
+	case model.BillTypeHirePurchase:
+		bill, err := s.billRepository.GetBillById(billID)
+		if err != nil {
+			return nil, errors.New("ไม่พบบิล")
+		}
+		snap := &billStateSnapshot{
+			BillType:  billType,
+			Status:    bill.Status,
+			Remaining: bill.Remaining_Amount,
+			// เงินดาวน์ไม่นับเป็นการชำระ (คืน / ยึดผ่านการยกเลิกสัญญา)
+			HasPayment: bill.Paid_Amount > downPaymentPaidAmount(bill) || bill.Paid_Installments > 0,
+		}
+		for _, d := range bill.BillDetails {
+			if d.Status == 0 && d.Payment_Date.In(loc).Before(today) {
+				snap.HasOverdue = true
+			}
+			if d.Paid_Amount > 0 {
+				snap.HasPayment = true
+			}
+		}
+		return snap, nil
+
+	case model.BillTypeInstallment:
+		bill, err := s.billRepository.GetInstallmentBillById(billID)
+		if err != nil {
+			return nil, errors.New("ไม่พบบิล")
+		}
+		snap := &billStateSnapshot{
+			BillType:   billType,
+			Status:     bill.Status,
+			Remaining:  bill.Remaining_Amount,
+			HasPayment: bill.Paid_Amount > 0 || bill.Paid_Installments > 0 || bill.RenewCount > 0,
+			IsPawn:     bill.Installment_Day == 10 && bill.TermType == 1,
+		}
+		for _, d := range bill.BillDetailsInstallment {
+			if d.Status == 0 && d.Payment_Date.In(loc).Before(today) {
+				snap.HasOverdue = true
+			}
+			if d.Paid_Amount > 0 {
+				snap.HasPayment = true
+			}
+		}
+		return snap, nil
+	}
+	return nil, errors.New("ประเภทบิลไม่ถูกต้อง")
+}
+
+func checkBillTransition(t *billTransition, snap *billStateSnapshot) error {
+	if !containsStatus(t.From, snap.Status) {
+		return fmt.Errorf("ไม่สามารถ %s จากสถานะ %s ได้", t.Name, billStatusName(snap.Status))
+	}
+	if t.Guard != nil {
+		return t.Guard(*snap)
+	}
+	return nil
+}
+
+// ตรวจเงื่อนไขแล้วเปลี่ยนสถานะ + บันทึกประวัติ
+func (s *billService) applyBillTransition(billType int, billID uint, t *billTransition, actorID int, reason string) (*BillTransitionResponse, error) {
+	snap, err := s.loadBillState(billType, billID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkBillTransition(t, snap); err != nil {
+		return nil, err
+	}
+
+	if err := s.billRepository.SetBillStatus(billType, billID, snap.Status, t.To); err != nil {
+		return nil, err
+	}
+	transition := s.logBillTransition(billType, billID, snap.Status, t.To, t.Name, actorID, reason)
+	if transition == nil {
+		return nil, errors.New("บันทึกประวัติสถานะไม่สำเร็จ")
+	}
+	resp := toBillTransitionResponse(*transition)
+	return &resp, nil
+}
+
+// บันทึกประวัติการเปลี่ยนสถานะ (actorID = 0 คือระบบ)
+func (s *billService) logBillTransition(billType int, billID uint, from, to int, name string, actorID int, reason string) *model.Bill_Status_Transition {
+	transition := &model.Bill_Status_Transition{
+		Bill_Type:   billType,
+		Bill_Id:     billID,
+		From_Status: from,
+		To_Status:   to,
+		Transition:  name,
+		Reason:      reason,
+	}
+	if actorID > 0 {
+		transition.Actor_Id = &actorID
+	}
+	if err := s.billRepository.CreateBillTransition(transition); err != nil {
+		log.Printf("❌ บันทึกประวัติสถานะบิล %d ไม่สำเร็จ: %v", billID, err)
+		return nil
+	}
+	return transition
+}
+
+func toBillTransitionResponse(t model.Bill_Status_Transition) BillTransitionResponse {
+	return BillTransitionResponse{
+		Id:          t.Id,
+		Bill_Type:   t.Bill_Type,
+		Bill_Id:     t.Bill_Id,
+		From_Status: t.From_Status,
+		From_Name:   billStatusName(t.From_Status),
+		To_Status:   t.To_Status,
+		To_Name:     billStatusName(t.To_Status),
+		Transition:  t.Transition,
+		Actor_Id:    t.Actor_Id,
+		Actor_Name:  t.Actor.FullName,
+		Reason:      t.Reason,
+		CreatedAt:   t.CreatedAt,
+	}
+}
+
+func (s *billService) TransitionBill(billType int, billID uint, transition string, actorID int, reason string) (*BillTransitionResponse, error) {
+	t, err := findBillTransition(transition)
+	if err != nil {
+		return nil, err
+	}
+	if systemBillTransitions[t.Name] {
+		return nil, fmt.Errorf("%s ต้องทำผ่านขั้นตอนเฉพาะ ไม่สามารถเปลี่ยนสถานะตรงได้", t.Name)
+	}
+	return s.applyBillTransition(billType, billID, t, actorID, reason)
+}
+
+func (s *billService) GetBillStatusHistory(billType int, billID uint) (*BillStatusHistoryResponse, error) {
+	snap, err := s.loadBillState(billType, billID)
+	if err != nil {
+		return nil, err
+	}
+	transitions, err := s.billRepository.GetBillTransitions(billType, billID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &BillStatusHistoryResponse{
+		Bill_Type:   billType,
+		Bill_Id:     billID,
+		Status:      snap.Status,
+		Status_Name: billStatusName(snap.Status),
+		Allowed:     []string{},
+		History:     make([]BillTransitionResponse, 0, len(transitions)),
+	}
+	for _, t := range billTransitions {
+		if !containsStatus(t.From, snap.Status) {
+			continue
+		}
+		if t.Guard != nil && t.Guard(*snap) != nil {
+			continue
+		}
+		resp.Allowed = append(resp.Allowed, t.Name)
+	}
+	for _, t := range transitions {
+		resp.History = append(resp.History, toBillTransitionResponse(t))
+	}
+	return resp, nil
+}
+
+func (s *billService) GetBillStateMachine() []BillStateTransitionInfo {
+	infos := make([]BillStateTransitionInfo, 0, len(billTransitions))
+	for _, t := range billTransitions {
+		from := make([]string, 0, len(t.From))
+		for _, f := range t.From {
+			from = append(from, billStatusName(f))
+		}
+		infos = append(infos, BillStateTransitionInfo{Name: t.Name, From: from, To: billStatusName(t.To)})
+	}
+	return infos
+}
+
+// รันทุกเที่ยงคืน: active ที่มีงวดเลยกำหนด → overdue, overdue ที่จ่ายครบงวดแล้ว → active
+func (s *billService) UpdateOverdueStatuses(testDate ...time.Time) error {
+	loc, _ := time.LoadLocation("Asia/Bangkok")
+	var today time.Time
+	if len(testDate) > 0 {
+		t := testDate[0].In(loc)
+		today = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	} else {
+		now := time.Now().In(loc)
+		today = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	}
+
+	for _, billType := range []int{model.BillTypeHirePurchase, model.BillTypeInstallment} {
+		toOverdue, toActive, err := s.billRepository.GetOverdueStatusChanges(billType, today)
+		if err != nil {
+			return err
+		}
+		for _, id := range toOverdue {
+			if err := s.billRepository.SetBillStatus(billType, id, model.BillStatusActive, model.BillStatusOverdue); err != nil {
+				log.Printf("❌ เปลี่ยนบิล %d เป็นค้างชำระไม่สำเร็จ: %v", id, err)
+				continue
+			}
+			s.logBillTransition(billType, id, model.BillStatusActive, model.BillStatusOverdue, "mark_overdue", 0, "มีงวดเลยกำหนดชำระ")
+		}
+		for _, id := range toActive {
+			if err := s.billRepository.SetBillStatus(billType, id, model.BillStatusOverdue, model.BillStatusActive); err != nil {
+				log.Printf("❌ เปลี่ยนบิล %d กลับเป็นปกติไม่สำเร็จ: %v", id, err)
+				continue
+			}
+			s.logBillTransition(billType, id, model.BillStatusOverdue, model.BillStatusActive, "cure", 0, "ชำระงวดค้างครบแล้ว")
+		}
+		log.Printf("UpdateOverdueStatuses(type %d): ค้างชำระ %d | กลับเป็นปกติ %d", billType, len(toOverdue), len(toActive))
+	}
+	return nil
+}
+
+// ใช้กับ UpdateBill ที่ส่งสถานะปลายทางมาเป็นตัวเลข
+func (s *billService) transitionBillTo(billType int, billID uint, from, to int, actorID int, reason string) error {
+	t, err := findBillTransitionTo(from, to)
+	if err != nil {
+		return err
+	}
+	if systemBillTransitions[t.Name] {
+		return fmt.Errorf("%s ต้องทำผ่านขั้นตอนเฉพาะ ไม่สามารถเปลี่ยนสถานะตรงได้", t.Name)
+	}
+	_, err = s.applyBillTransition(billType, billID, t, actorID, reason)
+	return err
+}
@@ -0,0 +1,240 @@
+package service
+
+import "time"
+
+// ประเภทสินค้า/สัญญาที่ contract engine รองรับ
+const (
+	ContractTypeHirePurchase = "hire_purchase" // ผ่อนสินค้า (Bill_Header)
+	ContractTypePawn         = "pawn"          // จำนำ / เงินกู้ (Bill_Header_Installment)
+	ContractTypeCashSale     = "cash_sale"     // ขายสด (Cash_Sale)
+)
+
+type NewContractRequest struct {
+	Product_Type string `json:"product_type"`
+
+	// ส่งเฉพาะ payload ของประเภทที่เลือก
+	Hire_Purchase *NewBillHeader            `json:"hire_purchase"`
+	Pawn          *NewInstallmentBillHeader `json:"pawn"`
+	Cash_Sale     *NewCashSaleRequest       `json:"cash_sale"`
+}
+
+type NewCashSaleRequest struct {
+	MemberId *uint                   `json:"member_id"`
+	User_Id  int                     `json:"user_id"`
+	Note     string                  `json:"note"`
+	Discount float64                 `json:"discount"` // ส่วนลดท้ายบิล
+	Lines    []NewCashSaleLine       `json:"lines"`
+	Tenders  []CashSaleTenderRequest `json:"tenders"`
+	Trade_In *TradeInRequest         `json:"trade_in"` // เครื่องเก่าที่นำมาหักราคา
+}
+
+type NewCashSaleLine struct {
+	Line_Type        int     `json:"line_type"`         // 1 = เครื่อง, 2 = อุปกรณ์เสริม
+	Inventory_UnitId *uint   `json:"inventory_unit_id"` // จำเป็นสำหรับเครื่อง
+	ProductId        uint    `json:"product_id"`
+	Quantity         int     `json:"quantity"`
+	Unit_Price       float64 `json:"unit_price"` // 0 = ใช้ราคาตั้งขาย
+	Discount         float64 `json:"discount"`
+}
+
+type CashSaleTenderRequest struct {
+	Method    string  `json:"method"` // cash, transfer, card, promptpay
+	Amount    float64 `json:"amount"`
+	Reference string  `json:"reference"`
+}
+
+type ContractPayRequest struct {
+	Product_Type string  `json:"product_type"`
+	Contract_Id  uint    `json:"contract_id"`
+	Detail_Id    uint    `json:"detail_id"`
+	Amount       float64 `json:"amount"`
+
+	Tenders []PaymentTenderRequest `json:"tenders"` // แบ่งจ่ายหลายช่องทาง (ไม่ระบุ = เงินสดเต็มจำนวน)
+}
+
+type ContractListFilter struct {
+	Product_Type string
+	Status       *int
+	MemberId     *uint
+	Search       string
+	DateFrom     *time.Time
+	DateTo       *time.Time
+}
+
+type ContractResponse struct {
+	Product_Type string    `json:"product_type"`
+	Id           uint      `json:"id"`
+	Invoice      string    `json:"invoice"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	MemberId       uint   `json:"member_id"`
+	MemberFullName string `json:"member_full_name"`
+	User_Id        int    `json:"user_id"`
+	UserFullName   string `json:"user_full_name"`
+	ProductId      uint   `json:"product_id"`
+	ProductName    string `json:"product_name"`
+
+	Total_Price      float64 `json:"total_price"`
+	Paid_Amount      float64 `json:"paid_amount"`
+	Remaining_Amount float64 `json:"remaining_amount"`
+	Fee_Amount       float64 `json:"fee_amount"`
+	Credit_Balance   float64 `json:"credit_balance"`
+
+	Down_Payment    float64          `json:"down_payment"`
+	Trade_In_Amount float64          `json:"trade_in_amount"`
+	Trade_In        *TradeInResponse `json:"trade_in,omitempty"`
+	Upfront_Amount  float64          `json:"upfront_amount"`
+
+	Schedule_Version int                       `json:"schedule_version,omitempty"`
+	Restructures     []BillRestructureResponse `json:"restructures,omitempty"`
+
+	Total_Installments int `json:"total_installments"`
+	Paid_Installments  int `json:"paid_installments"`
+
+	Status      int    `json:"status"`
+	Status_Name string `json:"status_name"`
+	Note        string `json:"note"`
+
+	Schedule []ContractScheduleItem `json:"schedule,omitempty"`
+	Lines    []ContractLineItem     `json:"lines,omitempty"`
+}
+
+type ContractScheduleItem struct {
+	Id                uint      `json:"id"`
+	Payment_No        string    `json:"payment_no"`
+	Payment_Date      time.Time `json:"payment_date"`
+	Installment_Price float64   `json:"installment_price"`
+	Paid_Amount       float64   `json:"paid_amount"`
+	Fee_Amount        float64   `json:"fee_amount"`
+	Status            int       `json:"status"`
+	Schedule_Version  int       `json:"schedule_version,omitempty"`
+}
+
+type ContractLineItem struct {
+	Line_Type        int     `json:"line_type"`
+	Line_Type_Name   string  `json:"line_type_name"`
+	ProductId        uint    `json:"product_id"`
+	ProductName      string  `json:"product_name"`
+	Inventory_UnitId *uint   `json:"inventory_unit_id"`
+	Imei             string  `json:"imei"`
+	Unit             string  `json:"unit"`
+	Quantity         int     `json:"quantity"`
+	Unit_Price       float64 `json:"unit_price"`
+	Discount         float64 `json:"discount"`
+	Amount           float64 `json:"amount"`
+	Is_Financed      bool    `json:"is_financed"` // true = รวมในยอดผ่อน
+}
+
+type ContractTenderItem struct {
+	Method      string  `json:"method"`
+	Method_Name string  `json:"method_name"`
+	Amount      float64 `json:"amount"`
+	Received    float64 `json:"received"`
+	Reference   string  `json:"reference"`
+}
+
+// ใบเสร็จขายสด
+type CashSaleReceiptResponse struct {
+	Id        uint      `json:"id"`
+	Invoice   string    `json:"invoice"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Cashier        string `json:"cashier"`
+	MemberId       *uint  `json:"member_id"`
+	MemberFullName string `json:"member_full_name"`
+	MemberTel      string `json:"member_tel"`
+
+	Lines   []ContractLineItem   `json:"lines"`
+	Tenders []ContractTenderItem `json:"tenders"`
+
+	Subtotal        float64          `json:"subtotal"`
+	Discount        float64          `json:"discount"`
+	Total_Price     float64          `json:"total_price"`
+	Trade_In_Amount float64          `json:"trade_in_amount"`
+	Trade_In        *TradeInResponse `json:"trade_in,omitempty"`
+	Received        float64          `json:"received"`
+	Change_Amount   float64          `json:"change_amount"`
+
+	Status      int    `json:"status"`
+	Status_Name string `json:"status_name"`
+	Note        string `json:"note"`
+}
+
+type SalesReportItem struct {
+	Product_Type   string  `json:"product_type"`
+	Contract_Count int64   `json:"contract_count"`
+	Sales_Amount   float64 `json:"sales_amount"`
+}
+
+type TakingsReportItem struct {
+	Method      string  `json:"method"`
+	Method_Name string  `json:"method_name"`
+	Amount      float64 `json:"amount"`
+}
+
+// รายได้แยกตามประเภทรายการ (เครื่อง / อุปกรณ์เสริม / ประกัน / บริการ)
+type LineRevenueItem struct {
+	Line_Type      int     `json:"line_type"`
+	Line_Type_Name string  `json:"line_type_name"`
+	Quantity       int64   `json:"quantity"`
+	Amount         float64 `json:"amount"`
+}
+
+type SalesReportDay struct {
+	Date          string              `json:"date"`
+	Sales         []SalesReportItem   `json:"sales"`
+	Takings       []TakingsReportItem `json:"takings"`
+	Lines         []LineRevenueItem   `json:"lines"`
+	Total_Sales   float64             `json:"total_sales"`
+	Total_Takings float64             `json:"total_takings"`
+}
+
+type SalesReportResponse struct {
+	Days          []SalesReportDay    `json:"days"`
+	Takings       []TakingsReportItem `json:"takings"` // ยอดรวมทั้งช่วงแยกตามช่องทาง ใช้กระทบยอดปิดร้าน
+	Lines         []LineRevenueItem   `json:"lines"`
+	Total_Sales   float64             `json:"total_sales"`
+	Total_Takings float64             `json:"total_takings"`
+}
+
+type ContractPayResponse struct {
+	Contract *ContractResponse      `json:"contract"`
+	Results  []InstallmentPayResult `json:"results"`
+}
+
+type PaginationResponseContract struct {
+	Total       int64              `json:"total"`
+	TotalPages  int                `json:"total_pages"`
+	CurrentPage int                `json:"current_page"`
+	HasNext     bool               `json:"has_next"`
+	HasPrev     bool               `json:"has_prev"`
+	Limit       int                `json:"limit"`
+	Contracts   []ContractResponse `json:"data"`
+}
+
+type ContractSummaryItem struct {
+	Product_Type     string  `json:"product_type"`
+	Contract_Count   int64   `json:"contract_count"`
+	Open_Count       int64   `json:"open_count"`
+	Restructured     int64   `json:"restructured_count"` // สัญญาที่เคยปรับโครงสร้างหนี้
+	Total_Price      float64 `json:"total_price"`
+	Paid_Amount      float64 `json:"paid_amount"`
+	Remaining_Amount float64 `json:"remaining_amount"`
+}
+
+type ContractSummaryResponse struct {
+	Items []ContractSummaryItem `json:"items"`
+	Total ContractSummaryItem   `json:"total"`
+}
+
+type ContractService interface {
+	CreateContract(request NewContractRequest, actor AuditActor) (*ContractResponse, error)
+	PayContract(request ContractPayRequest, actorID int) (*ContractPayResponse, error)
+	GetContract(productType string, id uint) (*ContractResponse, error)
+	GetContracts(filter ContractListFilter, page, limit int) (*PaginationResponseContract, error)
+	GetContractSummary(filter ContractListFilter) (*ContractSummaryResponse, error)
+
+	CreateCashSale(request NewCashSaleRequest, actor AuditActor) (*CashSaleReceiptResponse, error)
+	GetCashSaleReceipt(id uint) (*CashSaleReceiptResponse, error)
+	GetSalesReport(dateFrom, dateTo *time.Time) (*SalesReportResponse, error)
+}
@@ -0,0 +1,346 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"rrmobile/model"
+	"rrmobile/respository"
+	"sort"
+	"time"
+)
+
+type contractService struct {
+	contractRepository respository.ContractRepository
+	cashSaleRepository respository.CashSaleRepository
+	strategies         map[string]contractStrategy
+}
+
+func NewContractService(
+	billService BillService,
+	billRepository respository.BillRepository,
+	contractRepository respository.ContractRepository,
+	cashSaleRepository respository.CashSaleRepository,
+	productRepository respository.ProductRepository,
+	inventoryRepository respository.InventoryRepository,
+	appraisalRepository respository.AppraisalRepository,
+) ContractService {
+	return &contractService{
+		contractRepository: contractRepository,
+		cashSaleRepository: cashSaleRepository,
+		strategies: map[string]contractStrategy{
+			ContractTypeHirePurchase: &hirePurchaseStrategy{billService: billService, billRepository: billRepository},
+			ContractTypePawn:         &pawnStrategy{billService: billService, billRepository: billRepository},
+			ContractTypeCashSale: &cashSaleStrategy{
+				cashSaleRepository:  cashSaleRepository,
+				productRepository:   productRepository,
+				inventoryRepository: inventoryRepository,
+				appraisalRepository: appraisalRepository,
+			},
+		},
+	}
+}
+
+func (s *contractService) strategy(productType string) (contractStrategy, error) {
+	st, ok := s.strategies[productType]
+	if !ok {
+		return nil, fmt.Errorf("ไม่รู้จักประเภทสัญญา %q", productType)
+	}
+	return st, nil
+}
+
+// แปลง bill type (int) กลับเป็นชื่อประเภทสัญญา
+func (s *contractService) productTypeOf(billType int) string {
+	for name, st := range s.strategies {
+		if st.BillType() == billType {
+			return name
+		}
+	}
+	return ""
+}
+
+func (s *contractService) CreateContract(request NewContractRequest, actor AuditActor) (*ContractResponse, error) {
+	st, err := s.strategy(request.Product_Type)
+	if err != nil {
+		return nil, err
+	}
+	id, err := st.Create(request, actor)
+	if err != nil {
+		return nil, err
+	}
+	return st.Get(id)
+}
+
+func (s *contractService) PayContract(request ContractPayRequest, actorID int) (*ContractPayResponse, error) {
+	st, err := s.strategy(request.Product_Type)
+	if err != nil {
+		return nil, err
+	}
+	if request.Contract_Id == 0 {
+		return nil, errors.New("กรุณาระบุสัญญา")
+	}
+	if request.Amount <= 0 {
+		return nil, errors.New("ยอดชำระต้องมากกว่า 0")
+	}
+
+	contract, err := st.Get(request.Contract_Id)
+	if err != nil {
+		return nil, err
+	}
+	if !model.IsOpenBillStatus(contract.Status) {
+		return nil, fmt.Errorf("สัญญาอยู่ในสถานะ %s ไม่สามารถรับชำระได้", contract.Status_Name)
+	}
+
+	results, err := st.Pay(request, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	contract, err = st.Get(request.Contract_Id)
+	if err != nil {
+		return nil, err
+	}
+	return &ContractPayResponse{Contract: contract, Results: results}, nil
+}
+
+func (s *contractService) GetContract(productType string, id uint) (*ContractResponse, error) {
+	st, err := s.strategy(productType)
+	if err != nil {
+		return nil, err
+	}
+	return st.Get(id)
+}
+
+func (s *contractService) toRepositoryFilter(filter ContractListFilter) (respository.ContractFilter, error) {
+	repoFilter := respository.ContractFilter{
+		Status:   filter.Status,
+		MemberId: filter.MemberId,
+		Search:   filter.Search,
+		DateFrom: filter.DateFrom,
+		DateTo:   filter.DateTo,
+	}
+	if filter.Product_Type != "" {
+		st, err := s.strategy(filter.Product_Type)
+		if err != nil {
+			return repoFilter, err
+		}
+		repoFilter.Bill_Type = st.BillType()
+	}
+	return repoFilter, nil
+}
+
+func (s *contractService) GetContracts(filter ContractListFilter, page, limit int) (*PaginationResponseContract, error) {
+	if page < 1 {
+		page = 1
+	}
+	offset := 0
+	if limit > 0 {
+		offset = (page - 1) * limit
+	}
+
+	repoFilter, err := s.toRepositoryFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	total, err := s.contractRepository.CountContracts(repoFilter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count contracts: %w", err)
+	}
+	rows, err := s.contractRepository.GetContracts(repoFilter, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch contracts: %w", err)
+	}
+
+	contracts := make([]ContractResponse, 0, len(rows))
+	for _, r := range rows {
+		contracts = append(contracts, ContractResponse{
+			Product_Type:       s.productTypeOf(r.Bill_Type),
+			Id:                 r.Id,
+			Invoice:            r.Invoice,
+			CreatedAt:          r.CreatedAt,
+			MemberId:           r.MemberId,
+			MemberFullName:     r.Member_Full_Name,
+			User_Id:            r.User_Id,
+			ProductId:          r.ProductId,
+			ProductName:        r.Product_Name,
+			Total_Price:        r.Total_Price,
+			Paid_Amount:        r.Paid_Amount,
+			Remaining_Amount:   r.Remaining_Amount,
+			Total_Installments: r.Total_Installments,
+			Paid_Installments:  r.Paid_Installments,
+			Status:             r.Status,
+			Status_Name:        billStatusName(r.Status),
+			Note:               r.Note,
+		})
+	}
+
+	totalPages := 1
+	if limit > 0 {
+		totalPages = int((total + int64(limit) - 1) / int64(limit))
+	}
+
+	return &PaginationResponseContract{
+		Total:       total,
+		TotalPages:  totalPages,
+		CurrentPage: page,
+		HasNext:     limit > 0 && page < totalPages,
+		HasPrev:     limit > 0 && page > 1,
+		Limit:       limit,
+		Contracts:   contracts,
+	}, nil
+}
+
+func (s *contractService) GetContractSummary(filter ContractListFilter) (*ContractSummaryResponse, error) {
+	repoFilter, err := s.toRepositoryFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.contractRepository.GetContractSummary(repoFilter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize contracts: %w", err)
+	}
+
+	resp := &ContractSummaryResponse{Items: []ContractSummaryItem{}}
+	for _, r := range rows {
+		resp.Items = append(resp.Items, ContractSummaryItem{
+			Product_Type:     s.productTypeOf(r.Bill_Type),
+			Contract_Count:   r.Contract_Count,
+			Open_Count:       r.Open_Count,
+			Restructured:     r.Restructured_Count,
+			Total_Price:      round2(r.Total_Price),
+			Paid_Amount:      round2(r.Paid_Amount),
+			Remaining_Amount: round2(r.Remaining_Amount),
+		})
+		resp.Total.Contract_Count += r.Contract_Count
+		resp.Total.Open_Count += r.Open_Count
+		resp.Total.Restructured += r.Restructured_Count
+		resp.Total.Total_Price += r.Total_Price
+		resp.Total.Paid_Amount += r.Paid_Amount
+		resp.Total.Remaining_Amount += r.Remaining_Amount
+	}
+	resp.Total.Total_Price = round2(resp.Total.Total_Price)
+	resp.Total.Paid_Amount = round2(resp.Total.Paid_Amount)
+	resp.Total.Remaining_Amount = round2(resp.Total.Remaining_Amount)
+	return resp, nil
+}
+
+// หน้าขาย POS: สร้างบิลขายสดผ่าน engine แล้วคืนใบเสร็จ
+func (s *contractService) CreateCashSale(request NewCashSaleRequest, actor AuditActor) (*CashSaleReceiptResponse, error) {
+	contract, err := s.CreateContract(NewContractRequest{
+		Product_Type: ContractTypeCashSale,
+		Cash_Sale:    &request,
+	}, actor)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetCashSaleReceipt(contract.Id)
+}
+
+func (s *contractService) GetCashSaleReceipt(id uint) (*CashSaleReceiptResponse, error) {
+	sale, err := s.cashSaleRepository.GetCashSaleById(id)
+	if err != nil {
+		return nil, errors.New("ไม่พบบิลขายสด")
+	}
+	var tradeIn *model.Trade_In
+	if sale.Trade_In_Amount > 0 {
+		tradeIn, _ = s.cashSaleRepository.GetCashSaleTradeIn(sale.Id)
+	}
+	return toCashSaleReceipt(sale, tradeIn), nil
+}
+
+func (s *contractService) GetSalesReport(dateFrom, dateTo *time.Time) (*SalesReportResponse, error) {
+	sales, err := s.contractRepository.GetDailySales(dateFrom, dateTo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch daily sales: %w", err)
+	}
+	takings, err := s.contractRepository.GetDailyTakings(dateFrom, dateTo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch daily takings: %w", err)
+	}
+	lineRevenue, err := s.contractRepository.GetDailyLineRevenue(dateFrom, dateTo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch line revenue: %w", err)
+	}
+
+	days := map[string]*SalesReportDay{}
+	dayOf := func(t time.Time) *SalesReportDay {
+		key := t.Format("2006-01-02")
+		if d, ok := days[key]; ok {
+			return d
+		}
+		d := &SalesReportDay{Date: key, Sales: []SalesReportItem{}, Takings: []TakingsReportItem{}, Lines: []LineRevenueItem{}}
+		days[key] = d
+		return d
+	}
+
+	resp := &SalesReportResponse{Days: []SalesReportDay{}, Takings: []TakingsReportItem{}, Lines: []LineRevenueItem{}}
+	for _, r := range sales {
+		d := dayOf(r.Day)
+		d.Sales = append(d.Sales, SalesReportItem{
+			Product_Type:   s.productTypeOf(r.Bill_Type),
+			Contract_Count: r.Contract_Count,
+			Sales_Amount:   round2(r.Sales_Amount),
+		})
+		d.Total_Sales = round2(d.Total_Sales + r.Sales_Amount)
+		resp.Total_Sales += r.Sales_Amount
+	}
+	takingTotals := map[string]*TakingsReportItem{}
+	for _, r := range takings {
+		d := dayOf(r.Day)
+		d.Takings = append(d.Takings, TakingsReportItem{
+			Method:      r.Method,
+			Method_Name: model.PaymentMethodNames[r.Method],
+			Amount:      round2(r.Amount),
+		})
+
+		total, ok := takingTotals[r.Method]
+		if !ok {
+			total = &TakingsReportItem{Method: r.Method, Method_Name: model.PaymentMethodNames[r.Method]}
+			takingTotals[r.Method] = total
+		}
+		total.Amount = round2(total.Amount + r.Amount)
+
+		// หักจากเครดิตคงเหลือไม่ใช่เงินเข้าร้าน แสดงแยกแต่ไม่รวมในยอดรับเงิน
+		if r.Method == model.PaymentMethodCredit {
+			continue
+		}
+		d.Total_Takings = round2(d.Total_Takings + r.Amount)
+		resp.Total_Takings += r.Amount
+	}
+	for _, t := range takingTotals {
+		resp.Takings = append(resp.Takings, *t)
+	}
+	sort.Slice(resp.Takings, func(i, j int) bool { return resp.Takings[i].Method < resp.Takings[j].Method })
+
+	lineTotals := map[int]*LineRevenueItem{}
+	for _, r := range lineRevenue {
+		item := LineRevenueItem{
+			Line_Type:      r.Line_Type,
+			Line_Type_Name: model.SaleLineTypeNames[r.Line_Type],
+			Quantity:       r.Quantity,
+			Amount:         round2(r.Amount),
+		}
+		d := dayOf(r.Day)
+		d.Lines = append(d.Lines, item)
+
+		total, ok := lineTotals[r.Line_Type]
+		if !ok {
+			total = &LineRevenueItem{Line_Type: r.Line_Type, Line_Type_Name: item.Line_Type_Name}
+			lineTotals[r.Line_Type] = total
+		}
+		total.Quantity += r.Quantity
+		total.Amount = round2(total.Amount + r.Amount)
+	}
+	for _, t := range lineTotals {
+		resp.Lines = append(resp.Lines, *t)
+	}
+	sort.Slice(resp.Lines, func(i, j int) bool { return resp.Lines[i].Line_Type < resp.Lines[j].Line_Type })
+
+	for _, d := range days {
+		resp.Days = append(resp.Days, *d)
+	}
+	sort.Slice(resp.Days, func(i, j int) bool { return resp.Days[i].Date < resp.Days[j].Date })
+	resp.Total_Sales = round2(resp.Total_Sales)
+	resp.Total_Takings = round2(resp.Total_Takings)
+	return resp, nil
+}
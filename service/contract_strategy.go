@@ -0,0 +1,484 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"rrmobile/model"
+	"rrmobile/respository"
+	"sort"
+	"strings"
+)
+
+// contractStrategy คือส่วนที่ต่างกันตามประเภทสัญญา
+// ส่วนที่เหมือนกัน (ตรวจสถานะ, แบ่งหน้า, สรุปยอด) อยู่ใน contractService
+type contractStrategy interface {
+	BillType() int
+	Create(request NewContractRequest, actor AuditActor) (uint, error)
+	Pay(request ContractPayRequest, actorID int) ([]InstallmentPayResult, error)
+	Get(id uint) (*ContractResponse, error)
+}
+
+// ---------- ผ่อนสินค้า ----------
+
+type hirePurchaseStrategy struct {
+	billService    BillService
+	billRepository respository.BillRepository
+}
+
+func (st *hirePurchaseStrategy) BillType() int { return model.BillTypeHirePurchase }
+
+func (st *hirePurchaseStrategy) Create(request NewContractRequest, actor AuditActor) (uint, error) {
+	if request.Hire_Purchase == nil {
+		return 0, errors.New("กรุณาระบุข้อมูลสัญญาผ่อน (hire_purchase)")
+	}
+	req := *request.Hire_Purchase
+	if req.User_Id == 0 {
+		req.User_Id = actor.User_Id
+	}
+	bill, err := st.billService.CreateBill(req, actor)
+	if err != nil {
+		return 0, err
+	}
+	return bill.Id, nil
+}
+
+func (st *hirePurchaseStrategy) Pay(request ContractPayRequest, actorID int) ([]InstallmentPayResult, error) {
+	return st.billService.PayInstallment(request.Contract_Id, request.Detail_Id, request.Amount, request.Tenders, actorID)
+}
+
+func (st *hirePurchaseStrategy) Get(id uint) (*ContractResponse, error) {
+	bill, err := st.billRepository.GetBillById(id)
+	if err != nil {
+		return nil, errors.New("ไม่พบสัญญาผ่อน")
+	}
+
+	resp := &ContractResponse{
+		Product_Type:       ContractTypeHirePurchase,
+		Id:                 bill.Id,
+		Invoice:            bill.Invoice,
+		CreatedAt:          bill.CreatedAt,
+		MemberId:           bill.MemberId,
+		MemberFullName:     bill.Member.FullName,
+		User_Id:            bill.User_Id,
+		UserFullName:       bill.User.FullName,
+		ProductId:          bill.ProductId,
+		ProductName:        bill.Product.Name,
+		Total_Price:        bill.Total_Price,
+		Paid_Amount:        float64(bill.Paid_Amount),
+		Remaining_Amount:   bill.Remaining_Amount,
+		Fee_Amount:         bill.Fee_Amount,
+		Credit_Balance:     bill.Credit_Balance,
+		Down_Payment:       bill.Down_Payment,
+		Trade_In_Amount:    bill.Trade_In_Amount,
+		Total_Installments: bill.Total_Installments,
+		Paid_Installments:  bill.Paid_Installments,
+		Status:             bill.Status,
+		Status_Name:        billStatusName(bill.Status),
+		Note:               bill.Note,
+		Upfront_Amount:     bill.Upfront_Amount,
+		Schedule_Version:   bill.Schedule_Version,
+		Schedule:           toBillScheduleItems(bill.BillDetails),
+	}
+	if bill.Schedule_Version > 1 {
+		if restructures, err := st.billService.GetBillRestructures(bill.Id); err == nil {
+			resp.Restructures = restructures
+		}
+	}
+	if lines, err := st.billRepository.GetBillLines(model.BillTypeHirePurchase, bill.Id); err == nil {
+		resp.Lines = toBillLineItems(lines)
+	}
+	if bill.Trade_In_Amount > 0 {
+		if tradeIn, err := st.billRepository.GetTradeIn(model.BillTypeHirePurchase, bill.Id); err == nil {
+			resp.Trade_In = toTradeInResponse(tradeIn)
+		}
+	}
+	return resp, nil
+}
+
+// ---------- จำนำ / เงินกู้ ----------
+
+type pawnStrategy struct {
+	billService    BillService
+	billRepository respository.BillRepository
+}
+
+func (st *pawnStrategy) BillType() int { return model.BillTypeInstallment }
+
+func (st *pawnStrategy) Create(request NewContractRequest, actor AuditActor) (uint, error) {
+	if request.Pawn == nil {
+		return 0, errors.New("กรุณาระบุข้อมูลสัญญาจำนำ (pawn)")
+	}
+	req := *request.Pawn
+	if req.User_Id == 0 {
+		req.User_Id = actor.User_Id
+	}
+	bill, err := st.billService.CreateInstallmentBill(req, uint(req.InstallmentId), actor)
+	if err != nil {
+		return 0, err
+	}
+	return bill.Id, nil
+}
+
+func (st *pawnStrategy) Pay(request ContractPayRequest, actorID int) ([]InstallmentPayResult, error) {
+	return st.billService.PayPurchaseInstallment(request.Contract_Id, request.Detail_Id, request.Amount, request.Tenders, actorID)
+}
+
+func (st *pawnStrategy) Get(id uint) (*ContractResponse, error) {
+	bill, err := st.billRepository.GetInstallmentBillById(id)
+	if err != nil {
+		return nil, errors.New("ไม่พบสัญญาจำนำ")
+	}
+
+	details := append([]model.Bill_Details_Installment(nil), bill.BillDetailsInstallment...)
+	sort.Slice(details, func(i, j int) bool { return details[i].Payment_Date.Before(details[j].Payment_Date) })
+
+	schedule := make([]ContractScheduleItem, 0, len(details))
+	for _, d := range details {
+		schedule = append(schedule, ContractScheduleItem{
+			Id:                d.Id,
+			Payment_No:        d.Payment_No,
+			Payment_Date:      d.Payment_Date,
+			Installment_Price: d.Installment_Price,
+			Paid_Amount:       d.Paid_Amount,
+			Fee_Amount:        d.Fee_Amount,
+			Status:            d.Status,
+		})
+	}
+
+	return &ContractResponse{
+		Product_Type:       ContractTypePawn,
+		Id:                 bill.Id,
+		Invoice:            bill.Invoice,
+		CreatedAt:          bill.CreatedAt,
+		MemberId:           bill.MemberId,
+		MemberFullName:     bill.Member.FullName,
+		User_Id:            bill.User_Id,
+		UserFullName:       bill.User.FullName,
+		ProductId:          bill.ProductId,
+		ProductName:        bill.Product.Name,
+		Total_Price:        bill.Total_Price,
+		Paid_Amount:        float64(bill.Paid_Amount),
+		Remaining_Amount:   bill.Remaining_Amount,
+		Fee_Amount:         bill.Fee_Amount,
+		Credit_Balance:     bill.Credit_Balance,
+		Total_Installments: bill.Total_Installments,
+		Paid_Installments:  bill.Paid_Installments,
+		Status:             bill.Status,
+		Status_Name:        billStatusName(bill.Status),
+		Note:               bill.Note,
+		Schedule:           schedule,
+	}, nil
+}
+
+// ---------- ขายสด ----------
+
+type cashSaleStrategy struct {
+	cashSaleRepository  respository.CashSaleRepository
+	productRepository   respository.ProductRepository
+	inventoryRepository respository.InventoryRepository
+	appraisalRepository respository.AppraisalRepository
+}
+
+func (st *cashSaleStrategy) BillType() int { return model.BillTypeCashSale }
+
+func (st *cashSaleStrategy) Create(request NewContractRequest, actor AuditActor) (uint, error) {
+	req := request.Cash_Sale
+	if req == nil {
+		return 0, errors.New("กรุณาระบุข้อมูลการขายสด (cash_sale)")
+	}
+	if len(req.Lines) == 0 {
+		return 0, errors.New("กรุณาระบุรายการสินค้า")
+	}
+
+	userID := req.User_Id
+	if userID == 0 {
+		userID = actor.User_Id
+	}
+
+	lines, subtotal, err := st.buildLines(req.Lines)
+	if err != nil {
+		return 0, err
+	}
+
+	discount := round2(req.Discount)
+	if discount < 0 || discount > subtotal {
+		return 0, errors.New("ส่วนลดท้ายบิลไม่ถูกต้อง")
+	}
+	total := round2(subtotal - discount)
+
+	// เครื่องเทิร์นหักจากยอดที่ต้องรับเงิน
+	var tradeIn *model.Trade_In
+	tradeInAmount := 0.0
+	if req.Trade_In != nil {
+		tradeIn, err = newTradeIn(st.appraisalRepository, st.productRepository, *req.Trade_In, model.BillTypeCashSale, userID)
+		if err != nil {
+			return 0, err
+		}
+		if tradeIn.Agreed_Value > total {
+			return 0, errors.New("มูลค่าเครื่องเทิร์นเกินยอดบิล")
+		}
+		tradeInAmount = tradeIn.Agreed_Value
+	}
+
+	tenders, change, err := buildCashSaleTenders(req.Tenders, round2(total-tradeInAmount))
+	if err != nil {
+		return 0, err
+	}
+
+	invoice, err := respository.GenerateCashSaleInv(st.cashSaleRepository)
+	if err != nil {
+		return 0, err
+	}
+
+	sale := &model.Cash_Sale{
+		Invoice:       invoice,
+		MemberId:      req.MemberId,
+		User_Id:       userID,
+		Subtotal:      subtotal,
+		Discount:      discount,
+		Total_Price:   total,
+		Paid_Amount:   total,
+		Change_Amount: change,
+		Status:        model.BillStatusSettled,
+		Note:          strings.TrimSpace(req.Note),
+		Lines:         lines,
+		Tenders:       tenders,
+
+		Trade_In_Amount: tradeInAmount,
+	}
+
+	var tradeInUnit *model.Inventory_Unit
+	if tradeIn != nil {
+		tradeInUnit = tradeInInventoryUnit(tradeIn, invoice)
+	}
+	if err := st.cashSaleRepository.CreateCashSale(sale, tradeIn, tradeInUnit); err != nil {
+		return 0, err
+	}
+	return sale.Id, nil
+}
+
+// คำนวณราคาแต่ละบรรทัด: เครื่องตัดจากสต็อกรายเครื่อง, อุปกรณ์ตัดจากจำนวนสต็อก
+func (st *cashSaleStrategy) buildLines(reqLines []NewCashSaleLine) ([]model.Cash_Sale_Line, float64, error) {
+	var lines []model.Cash_Sale_Line
+	subtotal := 0.0
+	usedUnits := map[uint]bool{}
+
+	for _, l := range reqLines {
+		lineType := l.Line_Type
+		if lineType == 0 {
+			lineType = model.SaleLineAccessory
+			if l.Inventory_UnitId != nil {
+				lineType = model.SaleLineHandset
+			}
+		}
+
+		line := model.Cash_Sale_Line{Line_Type: lineType, Quantity: l.Quantity}
+		listPrice := 0.0
+
+		switch lineType {
+		case model.SaleLineHandset:
+			if l.Inventory_UnitId == nil {
+				return nil, 0, errors.New("รายการเครื่องต้องระบุ inventory_unit_id")
+			}
+			unitID := *l.Inventory_UnitId
+			if usedUnits[unitID] {
+				return nil, 0, fmt.Errorf("เครื่อง #%d ถูกใส่ในบิลซ้ำ", unitID)
+			}
+			usedUnits[unitID] = true
+
+			unit, err := st.inventoryRepository.GetInventoryUnitById(unitID)
+			if err != nil {
+				return nil, 0, fmt.Errorf("ไม่พบเครื่อง #%d ในสต็อก", unitID)
+			}
+			if unit.Status != 0 {
+				return nil, 0, fmt.Errorf("เครื่อง #%d ไม่อยู่ในสถานะพร้อมขาย", unitID)
+			}
+			line.ProductId = unit.ProductId
+			line.Inventory_UnitId = &unitID
+			line.Quantity = 1
+			listPrice = unit.Valuation
+			if listPrice <= 0 {
+				listPrice = unit.Product.Price
+			}
+		case model.SaleLineAccessory, model.SaleLineInsurance, model.SaleLineService:
+			if l.Quantity <= 0 {
+				return nil, 0, errors.New("จำนวนสินค้าต้องมากกว่า 0")
+			}
+			product, err := st.productRepository.GetProductByID(l.ProductId)
+			if err != nil {
+				return nil, 0, fmt.Errorf("ไม่พบสินค้า %d", l.ProductId)
+			}
+			line.ProductId = l.ProductId
+			listPrice = product.Price
+		default:
+			return nil, 0, fmt.Errorf("ไม่รู้จักประเภทรายการ %d", lineType)
+		}
+
+		line.Unit_Price = listPrice
+		if l.Unit_Price > 0 {
+			line.Unit_Price = round2(l.Unit_Price)
+		}
+		gross := round2(line.Unit_Price * float64(line.Quantity))
+		line.Discount = round2(l.Discount)
+		if line.Discount < 0 || line.Discount > gross {
+			return nil, 0, errors.New("ส่วนลดรายการไม่ถูกต้อง")
+		}
+		line.Amount = round2(gross - line.Discount)
+
+		subtotal += line.Amount
+		lines = append(lines, line)
+	}
+	return lines, round2(subtotal), nil
+}
+
+// แบ่งจ่ายหลายช่องทาง เงินทอนหักจากเงินสดเท่านั้น
+func buildCashSaleTenders(reqTenders []CashSaleTenderRequest, total float64) ([]model.Cash_Sale_Tender, float64, error) {
+	if total > 0 && len(reqTenders) == 0 {
+		return nil, 0, errors.New("กรุณาระบุการรับเงิน")
+	}
+
+	var tenders []model.Cash_Sale_Tender
+	received, cashReceived := 0.0, 0.0
+	for _, t := range reqTenders {
+		method := strings.ToLower(strings.TrimSpace(t.Method))
+		if _, ok := model.PaymentMethodNames[method]; !ok {
+			return nil, 0, fmt.Errorf("ไม่รู้จักช่องทางชำระ %q", t.Method)
+		}
+		if method == model.PaymentMethodCredit {
+			return nil, 0, errors.New("บิลขายสดรับชำระด้วยเครดิตคงเหลือไม่ได้")
+		}
+		amount := round2(t.Amount)
+		if amount <= 0 {
+			return nil, 0, errors.New("ยอดรับเงินต้องมากกว่า 0")
+		}
+		received += amount
+		if method == model.PaymentMethodCash {
+			cashReceived += amount
+		}
+		tenders = append(tenders, model.Cash_Sale_Tender{
+			Method:    method,
+			Amount:    amount,
+			Received:  amount,
+			Reference: strings.TrimSpace(t.Reference),
+		})
+	}
+
+	received = round2(received)
+	if received < total {
+		return nil, 0, fmt.Errorf("ยอดรับเงิน %.2f ไม่พอกับยอดบิล %.2f", received, total)
+	}
+	change := round2(received - total)
+	if change > round2(cashReceived) {
+		return nil, 0, errors.New("รับเงินเกินยอดบิลได้เฉพาะเงินสด (สำหรับทอน)")
+	}
+
+	// หักเงินทอนออกจากรายการเงินสด เพื่อให้ Amount = ยอดที่เข้าบิลจริง
+	left := change
+	for i := len(tenders) - 1; i >= 0 && left > 0; i-- {
+		if tenders[i].Method != model.PaymentMethodCash {
+			continue
+		}
+		cut := math.Min(tenders[i].Amount, left)
+		tenders[i].Amount = round2(tenders[i].Amount - cut)
+		left = round2(left - cut)
+	}
+	return tenders, change, nil
+}
+
+func (st *cashSaleStrategy) Pay(request ContractPayRequest, actorID int) ([]InstallmentPayResult, error) {
+	return nil, errors.New("บิลขายสดชำระครบตอนขายแล้ว")
+}
+
+func (st *cashSaleStrategy) Get(id uint) (*ContractResponse, error) {
+	sale, err := st.cashSaleRepository.GetCashSaleById(id)
+	if err != nil {
+		return nil, errors.New("ไม่พบบิลขายสด")
+	}
+
+	resp := &ContractResponse{
+		Product_Type:     ContractTypeCashSale,
+		Id:               sale.Id,
+		Invoice:          sale.Invoice,
+		CreatedAt:        sale.CreatedAt,
+		MemberFullName:   sale.Member.FullName,
+		User_Id:          sale.User_Id,
+		UserFullName:     sale.User.FullName,
+		Total_Price:      sale.Total_Price,
+		Paid_Amount:      sale.Paid_Amount,
+		Remaining_Amount: round2(sale.Total_Price - sale.Paid_Amount),
+		Trade_In_Amount:  sale.Trade_In_Amount,
+		Status:           sale.Status,
+		Status_Name:      billStatusName(sale.Status),
+		Note:             sale.Note,
+		Lines:            toCashSaleLineItems(sale.Lines),
+	}
+	if sale.MemberId != nil {
+		resp.MemberId = *sale.MemberId
+	}
+	if sale.Trade_In_Amount > 0 {
+		if tradeIn, err := st.cashSaleRepository.GetCashSaleTradeIn(sale.Id); err == nil {
+			resp.Trade_In = toTradeInResponse(tradeIn)
+		}
+	}
+	return resp, nil
+}
+
+func toCashSaleLineItems(lines []model.Cash_Sale_Line) []ContractLineItem {
+	items := make([]ContractLineItem, 0, len(lines))
+	for _, l := range lines {
+		item := ContractLineItem{
+			Line_Type:        l.Line_Type,
+			Line_Type_Name:   model.SaleLineTypeNames[l.Line_Type],
+			ProductId:        l.ProductId,
+			ProductName:      l.Product.Name,
+			Inventory_UnitId: l.Inventory_UnitId,
+			Quantity:         l.Quantity,
+			Unit_Price:       l.Unit_Price,
+			Discount:         l.Discount,
+			Amount:           l.Amount,
+		}
+		if l.Inventory_Unit != nil {
+			item.Imei = l.Inventory_Unit.Imei
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+func toCashSaleReceipt(sale *model.Cash_Sale, tradeIn *model.Trade_In) *CashSaleReceiptResponse {
+	receipt := &CashSaleReceiptResponse{
+		Id:             sale.Id,
+		Invoice:        sale.Invoice,
+		CreatedAt:      sale.CreatedAt,
+		Cashier:        sale.User.FullName,
+		MemberId:       sale.MemberId,
+		MemberFullName: sale.Member.FullName,
+		MemberTel:      sale.Member.Tel,
+		Lines:          toCashSaleLineItems(sale.Lines),
+		Tenders:        make([]ContractTenderItem, 0, len(sale.Tenders)),
+		Subtotal:       sale.Subtotal,
+		Discount:       sale.Discount,
+		Total_Price:    sale.Total_Price,
+		Change_Amount:  sale.Change_Amount,
+
+		Trade_In_Amount: sale.Trade_In_Amount,
+
+		Status:      sale.Status,
+		Status_Name: billStatusName(sale.Status),
+		Note:        sale.Note,
+	}
+	for _, t := range sale.Tenders {
+		receipt.Received += t.Received
+		receipt.Tenders = append(receipt.Tenders, ContractTenderItem{
+			Method:      t.Method,
+			Method_Name: model.PaymentMethodNames[t.Method],
+			Amount:      t.Amount,
+			Received:    t.Received,
+			Reference:   t.Reference,
+		})
+	}
+	receipt.Received = round2(receipt.Received)
+	receipt.Trade_In = toTradeInResponse(tradeIn)
+	return receipt
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"rrmobile/model"
+	"rrmobile/respository"
+	"strings"
+)
+
+// ตรวจช่องทางรับเงิน ไม่ระบุ = เงินสด
+func normalizePaymentMethod(method string) (string, error) {
+	m := strings.ToLower(strings.TrimSpace(method))
+	if m == "" {
+		return model.PaymentMethodCash, nil
+	}
+	if _, ok := model.PaymentMethodNames[m]; !ok {
+		return "", fmt.Errorf("ไม่รู้จักช่องทางชำระ %q", method)
+	}
+	return m, nil
+}
+
+// ยอดเงินดาวน์ที่รับเป็นเงิน (ไม่รวมเครื่องเทิร์น) นับเข้ายอดชำระของหัวบิลตั้งแต่ทำสัญญา
+func downPaymentPaidAmount(bill *respository.Bill_Header) int {
+	cashDown := math.Round(bill.Down_Payment - bill.Trade_In_Amount)
+	if cashDown < 0 {
+		return 0
+	}
+	return int(cashDown)
+}
+
+// เตรียมใบเสร็จเงินที่รับตอนทำสัญญา (เงินดาวน์ส่วนที่เป็นเงิน + รายการเสริมที่จ่ายสด)
+// บันทึกพร้อมสัญญาใน transaction เดียว ส่วนที่จ่ายด้วยเครื่องเทิร์นไม่ใช่เงินเข้าร้าน จึงไม่รวมในใบเสร็จ
+func (s *billService) newDownPayment(billType int, cashDown, upfront float64, method, reference string, actorID int) (*model.Payment, error) {
+	amount := round2(cashDown + upfront)
+	if amount <= 0 {
+		return nil, nil
+	}
+
+	receiptNo, err := respository.GeneratePaymentReceipt(s.paymentRepository)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate receipt: %w", err)
+	}
+	payment := &model.Payment{
+		Receipt_No: receiptNo,
+		Bill_Type:  billType,
+		Kind:       model.PaymentKindDownPayment,
+		Amount:     amount,
+	}
+	applyPaymentTenders(payment, []model.Payment_Tender{{Method: method, Amount: amount, Reference: strings.TrimSpace(reference)}})
+	if upfront > 0 {
+		payment.Note = fmt.Sprintf("เงินดาวน์ %.2f + รายการเสริมจ่ายสด %.2f", round2(cashDown), round2(upfront))
+	}
+	if actorID > 0 {
+		payment.User_Id = &actorID
+	}
+	return payment, nil
+}
+
+// สร้างรายการคืนเงิน (ยอดติดลบ ช่องทางและรายการตัดเงินเดียวกับใบเสร็จเดิม)
+func newReversalPayment(payments respository.PaymentRepository, original *model.Payment, actorID int, reason string) (*model.Payment, error) {
+	if original.Status != model.PaymentStatusPosted {
+		return nil, fmt.Errorf("ใบเสร็จ %s ถูกกลับรายการไปแล้ว", original.Receipt_No)
+	}
+	receiptNo, err := respository.GeneratePaymentReceipt(payments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate receipt: %w", err)
+	}
+	originalID := original.Id
+	reversal := &model.Payment{
+		Receipt_No:  receiptNo,
+		Bill_Type:   original.Bill_Type,
+		Bill_Id:     original.Bill_Id,
+		Kind:        model.PaymentKindRefund,
+		Amount:      -original.Amount,
+		Method:      original.Method,
+		Reference:   original.Reference,
+		Reversal_Of: &originalID,
+		Note:        reason,
+		Tenders:     reverseTenders(original.Tenders),
+	}
+	for _, a := range original.Allocations {
+		reversal.Allocations = append(reversal.Allocations, model.Payment_Allocation{
+			Bill_DetailsId: a.Bill_DetailsId,
+			Payment_No:     a.Payment_No,
+			Component:      a.Component,
+			Amount:         -a.Amount,
+		})
+	}
+	if actorID > 0 {
+		reversal.User_Id = &actorID
+	}
+	return reversal, nil
+}
@@ -16,8 +16,8 @@ type UpdateFineRequest struct {
 type FineService interface {
 	GetFines(limit, offset int) ([]FineResponse, error)
 	GetFineById(id uint) (*FineResponse, error)
-	CreateFine(request NewFineRequest) (*FineResponse, error)
-	UpdateFine(id uint, request UpdateFineRequest) (*FineResponse, error)
-	DeleteFine(id uint) error
+	CreateFine(request NewFineRequest, actor AuditActor) (*FineResponse, error)
+	UpdateFine(id uint, request UpdateFineRequest, actor AuditActor) (*FineResponse, error)
+	DeleteFine(id uint, actor AuditActor) error
 	CountFines() (int64, error)
 }
@@ -15,8 +15,8 @@ type UpdateFineCategoryRequest struct {
 type FineCategoryService interface {
 	GetFineCategories(limit, offset int) ([]FineCategoryResponse, error)
 	GetFineCategoryById(id uint) (*FineCategoryResponse, error)
-	CreateFineCategory(request NewFineCategoryRequest) (*FineCategoryResponse, error)
-	UpdateFineCategory(id uint, request UpdateFineCategoryRequest) (*FineCategoryResponse, error)
-	DeleteFineCategory(id uint) error
+	CreateFineCategory(request NewFineCategoryRequest, actor AuditActor) (*FineCategoryResponse, error)
+	UpdateFineCategory(id uint, request UpdateFineCategoryRequest, actor AuditActor) (*FineCategoryResponse, error)
+	DeleteFineCategory(id uint, actor AuditActor) error
 	CountFineCategories() (int64, error)
 }
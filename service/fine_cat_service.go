@@ -1,13 +1,17 @@
 package service
 
-import "rrmobile/respository"
+import (
+	"rrmobile/model"
+	"rrmobile/respository"
+)
 
 type fineCategoryService struct {
 	fineCategoryRepository respository.FineCategoryRepository
+	auditRepository        respository.AuditRepository
 }
 
-func NewFineCategoryService(fineCategoryRepository respository.FineCategoryRepository) FineCategoryService {
-	return &fineCategoryService{fineCategoryRepository: fineCategoryRepository}
+func NewFineCategoryService(fineCategoryRepository respository.FineCategoryRepository, auditRepository respository.AuditRepository) FineCategoryService {
+	return &fineCategoryService{fineCategoryRepository: fineCategoryRepository, auditRepository: auditRepository}
 }
 func (s *fineCategoryService) GetFineCategories(limit, offset int) ([]FineCategoryResponse, error) {
 	categories, err := s.fineCategoryRepository.GetFineCategories(limit, offset)
@@ -34,7 +38,7 @@ func (s *fineCategoryService) GetFineCategoryById(id uint) (*FineCategoryRespons
 		Name: category.Name,
 	}, nil
 }
-func (s *fineCategoryService) CreateFineCategory(request NewFineCategoryRequest) (*FineCategoryResponse, error) {
+func (s *fineCategoryService) CreateFineCategory(request NewFineCategoryRequest, actor AuditActor) (*FineCategoryResponse, error) {
 	category := respository.Fine_System_Category{
 		Name: request.Name,
 	}
@@ -42,13 +46,16 @@ func (s *fineCategoryService) CreateFineCategory(request NewFineCategoryRequest)
 	if err != nil {
 		return nil, err
 	}
-	return &FineCategoryResponse{
+	resp := &FineCategoryResponse{
 		Id:   createdCategory.Id,
 		Name: createdCategory.Name,
-	}, nil
+	}
+	recordAudit(s.auditRepository, actor, model.AuditActionCreate, model.AuditEntityFineCategory, resp.Id, nil, resp)
+	return resp, nil
 }
 
-func (s *fineCategoryService) UpdateFineCategory(id uint, request UpdateFineCategoryRequest) (*FineCategoryResponse, error) {
+func (s *fineCategoryService) UpdateFineCategory(id uint, request UpdateFineCategoryRequest, actor AuditActor) (*FineCategoryResponse, error) {
+	before, _ := s.GetFineCategoryById(id)
 	category := respository.Fine_System_Category{
 		Id:   id,
 		Name: request.Name,
@@ -57,14 +64,21 @@ func (s *fineCategoryService) UpdateFineCategory(id uint, request UpdateFineCate
 	if err != nil {
 		return nil, err
 	}
-	return &FineCategoryResponse{
+	resp := &FineCategoryResponse{
 		Id:   updatedCategory.Id,
 		Name: updatedCategory.Name,
-	}, nil
+	}
+	recordAudit(s.auditRepository, actor, model.AuditActionUpdate, model.AuditEntityFineCategory, id, before, resp)
+	return resp, nil
 }
 
-func (s *fineCategoryService) DeleteFineCategory(id uint) error {
-	return s.fineCategoryRepository.DeleteFineCategory(id)
+func (s *fineCategoryService) DeleteFineCategory(id uint, actor AuditActor) error {
+	before, _ := s.GetFineCategoryById(id)
+	if err := s.fineCategoryRepository.DeleteFineCategory(id); err != nil {
+		return err
+	}
+	recordAudit(s.auditRepository, actor, model.AuditActionDelete, model.AuditEntityFineCategory, id, before, nil)
+	return nil
 }
 func (s *fineCategoryService) CountFineCategories() (int64, error) {
 	var count int64
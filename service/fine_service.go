@@ -2,15 +2,17 @@ package service
 
 import (
 	"fmt"
+	"rrmobile/model"
 	"rrmobile/respository"
 )
 
 type fineService struct {
-	fineRepository respository.FineRepository
+	fineRepository  respository.FineRepository
+	auditRepository respository.AuditRepository
 }
 
-func NewFineService(fineRepository respository.FineRepository) FineService {
-	return &fineService{fineRepository: fineRepository}
+func NewFineService(fineRepository respository.FineRepository, auditRepository respository.AuditRepository) FineService {
+	return &fineService{fineRepository: fineRepository, auditRepository: auditRepository}
 }
 
 func (s *fineService) GetFines(limit, offset int) ([]FineResponse, error) {
@@ -41,7 +43,7 @@ func (s *fineService) GetFineById(id uint) (*FineResponse, error) {
 	}, nil
 }
 
-func (s *fineService) CreateFine(request NewFineRequest) (*FineResponse, error) {
+func (s *fineService) CreateFine(request NewFineRequest, actor AuditActor) (*FineResponse, error) {
 	fine := respository.Fine_System{
 		FineAmount:             request.FineAmount,
 		Fine_System_CategoryId: request.FineCategoryId,
@@ -53,13 +55,16 @@ func (s *fineService) CreateFine(request NewFineRequest) (*FineResponse, error)
 	if err != nil {
 		return nil, err
 	}
-	return &FineResponse{
+	resp := &FineResponse{
 		Id:             createdFine.Id,
 		FineAmount:     createdFine.FineAmount,
 		FineCategoryId: createdFine.Fine_System_CategoryId,
-	}, nil
+	}
+	recordAudit(s.auditRepository, actor, model.AuditActionCreate, model.AuditEntityFine, resp.Id, nil, resp)
+	return resp, nil
 }
-func (s *fineService) UpdateFine(id uint, request UpdateFineRequest) (*FineResponse, error) {
+func (s *fineService) UpdateFine(id uint, request UpdateFineRequest, actor AuditActor) (*FineResponse, error) {
+	before, _ := s.GetFineById(id)
 	fine := respository.Fine_System{
 		Id:                     id,
 		FineAmount:             request.FineAmount,
@@ -69,15 +74,22 @@ func (s *fineService) UpdateFine(id uint, request UpdateFineRequest) (*FineRespo
 	if err != nil {
 		return nil, err
 	}
-	return &FineResponse{
+	resp := &FineResponse{
 		Id:             updatedFine.Id,
 		FineAmount:     updatedFine.FineAmount,
 		FineCategoryId: updatedFine.Fine_System_CategoryId,
-	}, nil
+	}
+	recordAudit(s.auditRepository, actor, model.AuditActionUpdate, model.AuditEntityFine, id, before, resp)
+	return resp, nil
 }
 
-func (s *fineService) DeleteFine(id uint) error {
-	return s.fineRepository.DeleteFine(id)
+func (s *fineService) DeleteFine(id uint, actor AuditActor) error {
+	before, _ := s.GetFineById(id)
+	if err := s.fineRepository.DeleteFine(id); err != nil {
+		return err
+	}
+	recordAudit(s.auditRepository, actor, model.AuditActionDelete, model.AuditEntityFine, id, before, nil)
+	return nil
 }
 func (s *fineService) CountFines() (int64, error) {
 	var count int64
@@ -0,0 +1,66 @@
+package service
+
+import "time"
+
+type InventoryUnitResponse struct {
+	Id              uint      `json:"id"`
+	ProductId       uint      `json:"product_id"`
+	ProductSku      string    `json:"product_sku"`
+	ProductName     string    `json:"product_name"`
+	ProductCategory string    `json:"product_category"`
+	Source          int       `json:"source"`
+	BillInstallment *uint     `json:"bill_header_installment_id"`
+	Imei            string    `json:"imei"`
+	CashSaleId      *uint     `json:"cash_sale_id"`
+	Valuation       float64   `json:"valuation"`
+	Status          int       `json:"status"`
+	Note            string    `json:"note"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type PaginationResponseInventory struct {
+	Total       int64                   `json:"total"`
+	TotalPages  int                     `json:"total_pages"`
+	CurrentPage int                     `json:"current_page"`
+	HasNext     bool                    `json:"has_next"`
+	HasPrev     bool                    `json:"has_prev"`
+	Limit       int                     `json:"limit"`
+	Units       []InventoryUnitResponse `json:"data"`
+}
+
+type UpdateInventoryUnitRequest struct {
+	Valuation *float64 `json:"valuation"`
+	Status    *int     `json:"status"`
+	Note      *string  `json:"note"`
+}
+
+type NewInventoryUnitRequest struct {
+	ProductId uint    `json:"product_id"`
+	Imei      string  `json:"imei"`
+	Valuation float64 `json:"valuation"`
+	Note      string  `json:"note"`
+}
+
+type ProductStockResponse struct {
+	ProductId       uint      `json:"product_id"`
+	ProductSku      string    `json:"product_sku"`
+	ProductName     string    `json:"product_name"`
+	ProductCategory string    `json:"product_category"`
+	Quantity        int       `json:"quantity"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type AdjustProductStockRequest struct {
+	Quantity int `json:"quantity"` // + รับเข้า / - ตัดออก
+}
+
+type InventoryService interface {
+	GetAllInventoryUnits(source, status *int, page, limit int) (*PaginationResponseInventory, error)
+	GetInventoryUnitById(id uint) (*InventoryUnitResponse, error)
+	UpdateInventoryUnit(id uint, request UpdateInventoryUnitRequest) (*InventoryUnitResponse, error)
+	CreateInventoryUnit(request NewInventoryUnitRequest) (*InventoryUnitResponse, error)
+
+	GetProductStocks() ([]ProductStockResponse, error)
+	AdjustProductStock(productID uint, request AdjustProductStockRequest) (*ProductStockResponse, error)
+}
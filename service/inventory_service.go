@@ -0,0 +1,167 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"rrmobile/model"
+	"rrmobile/respository"
+)
+
+type inventoryService struct {
+	inventoryRepository respository.InventoryRepository
+}
+
+func NewInventoryService(inventoryRepository respository.InventoryRepository) InventoryService {
+	return &inventoryService{inventoryRepository: inventoryRepository}
+}
+
+func toInventoryUnitResponse(u model.Inventory_Unit) InventoryUnitResponse {
+	return InventoryUnitResponse{
+		Id:              u.Id,
+		ProductId:       u.ProductId,
+		ProductSku:      u.Product.Sku,
+		ProductName:     u.Product.Name,
+		ProductCategory: u.Product.Category.Name,
+		Source:          u.Source,
+		BillInstallment: u.Bill_Header_InstallmentId,
+		Imei:            u.Imei,
+		CashSaleId:      u.Cash_SaleId,
+		Valuation:       u.Valuation,
+		Status:          u.Status,
+		Note:            u.Note,
+		CreatedAt:       u.CreatedAt,
+		UpdatedAt:       u.UpdatedAt,
+	}
+}
+
+func (s *inventoryService) GetAllInventoryUnits(source, status *int, page, limit int) (*PaginationResponseInventory, error) {
+	if page < 1 {
+		page = 1
+	}
+	offset := 0
+	if limit > 0 {
+		offset = (page - 1) * limit
+	}
+
+	filter := respository.InventoryFilter{Source: source, Status: status}
+
+	total, err := s.inventoryRepository.CountInventoryUnits(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count inventory: %w", err)
+	}
+	units, err := s.inventoryRepository.GetInventoryUnits(filter, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch inventory: %w", err)
+	}
+
+	responses := make([]InventoryUnitResponse, 0, len(units))
+	for _, u := range units {
+		responses = append(responses, toInventoryUnitResponse(u))
+	}
+
+	totalPages := 1
+	if limit > 0 {
+		totalPages = int((total + int64(limit) - 1) / int64(limit))
+	}
+
+	return &PaginationResponseInventory{
+		Total:       total,
+		TotalPages:  totalPages,
+		CurrentPage: page,
+		HasNext:     limit > 0 && page < totalPages,
+		HasPrev:     limit > 0 && page > 1,
+		Limit:       limit,
+		Units:       responses,
+	}, nil
+}
+
+func (s *inventoryService) GetInventoryUnitById(id uint) (*InventoryUnitResponse, error) {
+	unit, err := s.inventoryRepository.GetInventoryUnitById(id)
+	if err != nil {
+		return nil, errors.New("ไม่พบสินค้าในสต็อก")
+	}
+	resp := toInventoryUnitResponse(*unit)
+	return &resp, nil
+}
+
+func (s *inventoryService) UpdateInventoryUnit(id uint, request UpdateInventoryUnitRequest) (*InventoryUnitResponse, error) {
+	unit, err := s.inventoryRepository.GetInventoryUnitById(id)
+	if err != nil {
+		return nil, errors.New("ไม่พบสินค้าในสต็อก")
+	}
+	if request.Valuation != nil {
+		if *request.Valuation < 0 {
+			return nil, errors.New("มูลค่าต้องไม่ติดลบ")
+		}
+		unit.Valuation = round2(*request.Valuation)
+	}
+	if request.Status != nil {
+		unit.Status = *request.Status
+	}
+	if request.Note != nil {
+		unit.Note = *request.Note
+	}
+	if err := s.inventoryRepository.UpdateInventoryUnit(unit); err != nil {
+		return nil, err
+	}
+	resp := toInventoryUnitResponse(*unit)
+	return &resp, nil
+}
+
+// รับเครื่องเข้าสต็อก (ซื้อเข้าร้าน)
+func (s *inventoryService) CreateInventoryUnit(request NewInventoryUnitRequest) (*InventoryUnitResponse, error) {
+	if request.ProductId == 0 {
+		return nil, errors.New("กรุณาระบุสินค้า")
+	}
+	if request.Valuation < 0 {
+		return nil, errors.New("มูลค่าต้องไม่ติดลบ")
+	}
+	unit := &model.Inventory_Unit{
+		ProductId: request.ProductId,
+		Source:    model.InventorySourceStock,
+		Imei:      strings.TrimSpace(request.Imei),
+		Valuation: round2(request.Valuation),
+		Status:    model.InventoryUnitAvailable,
+		Note:      request.Note,
+	}
+	if err := s.inventoryRepository.CreateInventoryUnit(unit); err != nil {
+		return nil, err
+	}
+	return s.GetInventoryUnitById(unit.Id)
+}
+
+func toProductStockResponse(st model.Product_Stock) ProductStockResponse {
+	return ProductStockResponse{
+		ProductId:       st.ProductId,
+		ProductSku:      st.Product.Sku,
+		ProductName:     st.Product.Name,
+		ProductCategory: st.Product.Category.Name,
+		Quantity:        st.Quantity,
+		UpdatedAt:       st.UpdatedAt,
+	}
+}
+
+func (s *inventoryService) GetProductStocks() ([]ProductStockResponse, error) {
+	stocks, err := s.inventoryRepository.GetProductStocks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch stock: %w", err)
+	}
+	responses := make([]ProductStockResponse, 0, len(stocks))
+	for _, st := range stocks {
+		responses = append(responses, toProductStockResponse(st))
+	}
+	return responses, nil
+}
+
+func (s *inventoryService) AdjustProductStock(productID uint, request AdjustProductStockRequest) (*ProductStockResponse, error) {
+	if request.Quantity == 0 {
+		return nil, errors.New("กรุณาระบุจำนวนที่ต้องการปรับ")
+	}
+	stock, err := s.inventoryRepository.AdjustProductStock(productID, request.Quantity)
+	if err != nil {
+		return nil, err
+	}
+	resp := toProductStockResponse(*stock)
+	return &resp, nil
+}
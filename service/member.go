@@ -43,11 +43,11 @@ type MemberService interface {
 		page, limit int,
 	) (*PaginationResponseMember, error)
 	GetMemberById(id uint) (*MemberResponse, error)
-	CreateMember(req NewMemberRequest) (*MemberResponse, error)
-	EditMember(id uint, req UpdateMemberRequest) (*MemberResponse, error)
-	DeleteMember(id uint) error
+	CreateMember(req NewMemberRequest, actor AuditActor) (*MemberResponse, error)
+	EditMember(id uint, req UpdateMemberRequest, actor AuditActor) (*MemberResponse, error)
+	DeleteMember(id uint, actor AuditActor) error
 
 	GetMemberByUserId(userID string) (*MemberResponse, error)
-	UpdateMemberByTel(req UpdateMemberRequest1) (*MemberResponse, error)
+	UpdateMemberByTel(req UpdateMemberRequest1, actor AuditActor) (*MemberResponse, error)
 }
 
@@ -2,15 +2,31 @@ package service
 
 import (
 	"fmt"
+	"rrmobile/model"
 	"rrmobile/respository"
 )
 
 type memberService struct {
 	memberRepository respository.MemberRepository
+	auditRepository  respository.AuditRepository
 }
 
-func NewMemberService(memberRepository respository.MemberRepository) MemberService {
-	return &memberService{memberRepository: memberRepository}
+func NewMemberService(memberRepository respository.MemberRepository, auditRepository respository.AuditRepository) MemberService {
+	return &memberService{memberRepository: memberRepository, auditRepository: auditRepository}
+}
+
+// ข้อมูลสมาชิกที่เก็บในประวัติการแก้ไข (MemberResponse ไม่มี user_id)
+type memberAudit struct {
+	FullName string `json:"full_name"`
+	Tel      string `json:"tel"`
+	UserId   string `json:"user_id"`
+}
+
+func toMemberAudit(m *respository.Member) *memberAudit {
+	if m == nil {
+		return nil
+	}
+	return &memberAudit{FullName: m.FullName, Tel: m.Tel, UserId: m.UserId}
 }
 
 
@@ -76,7 +92,7 @@ func (s *memberService) GetMemberById(id uint) (*MemberResponse, error) {
 		Tel:      member.Tel,
 	}, nil
 }
-func (s *memberService) CreateMember(req NewMemberRequest) (*MemberResponse, error) {
+func (s *memberService) CreateMember(req NewMemberRequest, actor AuditActor) (*MemberResponse, error) {
 	member := respository.Member{
 		FullName: req.FullName,
 		Tel:      req.Tel,
@@ -86,6 +102,7 @@ func (s *memberService) CreateMember(req NewMemberRequest) (*MemberResponse, err
 	if err != nil {
 		return nil, err
 	}
+	recordAudit(s.auditRepository, actor, model.AuditActionCreate, model.AuditEntityMember, createdMember.Id, nil, toMemberAudit(createdMember))
 	return &MemberResponse{
 		Id:       createdMember.Id,
 		FullName: createdMember.FullName,
@@ -93,12 +110,13 @@ func (s *memberService) CreateMember(req NewMemberRequest) (*MemberResponse, err
 	}, nil
 }
 
-func (s *memberService) EditMember(id uint, req UpdateMemberRequest) (*MemberResponse, error) {
+func (s *memberService) EditMember(id uint, req UpdateMemberRequest, actor AuditActor) (*MemberResponse, error) {
 	// ดึงข้อมูลเดิม
 	member, err := s.memberRepository.GetMemberById(id)
 	if err != nil {
 		return nil, err
 	}
+	before := toMemberAudit(member)
 
 	// อัปเดตเฉพาะ field ที่ถูกส่งมา
 	if req.FullName != "" && req.FullName != member.FullName {
@@ -117,6 +135,7 @@ func (s *memberService) EditMember(id uint, req UpdateMemberRequest) (*MemberRes
 	if err != nil {
 		return nil, err
 	}
+	recordAudit(s.auditRepository, actor, model.AuditActionUpdate, model.AuditEntityMember, id, before, toMemberAudit(updatedMember))
 
 	return &MemberResponse{
 		Id:       updatedMember.Id,
@@ -125,8 +144,13 @@ func (s *memberService) EditMember(id uint, req UpdateMemberRequest) (*MemberRes
 	}, nil
 }
 
-func (s *memberService) DeleteMember(id uint) error {
-	return s.memberRepository.DeleteMember(id)
+func (s *memberService) DeleteMember(id uint, actor AuditActor) error {
+	member, _ := s.memberRepository.GetMemberById(id)
+	if err := s.memberRepository.DeleteMember(id); err != nil {
+		return err
+	}
+	recordAudit(s.auditRepository, actor, model.AuditActionDelete, model.AuditEntityMember, id, toMemberAudit(member), nil)
+	return nil
 }
 
 func (s *memberService) GetMemberByUserId(userID string) (*MemberResponse, error) {
@@ -142,7 +166,7 @@ func (s *memberService) GetMemberByUserId(userID string) (*MemberResponse, error
 	}, nil
 }
 
-func (s *memberService) UpdateMemberByTel(req UpdateMemberRequest1) (*MemberResponse, error) {
+func (s *memberService) UpdateMemberByTel(req UpdateMemberRequest1, actor AuditActor) (*MemberResponse, error) {
 	// ต้องส่งเบอร์โทรมา เพื่อหา member
 	if req.Tel == nil || *req.Tel == "" {
 		return nil, fmt.Errorf("tel is required")
@@ -159,6 +183,8 @@ func (s *memberService) UpdateMemberByTel(req UpdateMemberRequest1) (*MemberResp
 		return nil, fmt.Errorf("มีข้อมูลแล้ว สามารถชำระได้เลย")
 	}
 
+	before := toMemberAudit(member)
+
 	// ถ้าจะใส่ user_id ใหม่
 	if req.UserId != nil {
 		if *req.UserId == "" {
@@ -177,6 +203,7 @@ func (s *memberService) UpdateMemberByTel(req UpdateMemberRequest1) (*MemberResp
 	if err != nil {
 		return nil, err
 	}
+	recordAudit(s.auditRepository, actor, model.AuditActionUpdate, model.AuditEntityMember, updated.Id, before, toMemberAudit(updated))
 
 	return &MemberResponse{
 		Id:       updated.Id,
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// นโยบายรหัสผ่านตั้งจาก .env:
+// PASSWORD_MIN_LENGTH (ค่าเริ่มต้น 8), PASSWORD_HISTORY ห้ามซ้ำกี่รหัสล่าสุด (ค่าเริ่มต้น 5),
+// PASSWORD_MAX_AGE_DAYS อายุรหัสผ่าน ครบแล้วต้องเปลี่ยน (0 = ไม่หมดอายุ)
+type PasswordPolicyResponse struct {
+	Min_Length   int `json:"min_length"`
+	History      int `json:"history"`
+	Max_Age_Days int `json:"max_age_days"`
+}
+
+func currentPasswordPolicy() PasswordPolicyResponse {
+	policy := PasswordPolicyResponse{Min_Length: 8, History: 5}
+	if viper.IsSet("PASSWORD_MIN_LENGTH") {
+		policy.Min_Length = viper.GetInt("PASSWORD_MIN_LENGTH")
+	}
+	if viper.IsSet("PASSWORD_HISTORY") {
+		policy.History = viper.GetInt("PASSWORD_HISTORY")
+	}
+	policy.Max_Age_Days = viper.GetInt("PASSWORD_MAX_AGE_DAYS")
+	if policy.Min_Length < 1 {
+		policy.Min_Length = 1
+	}
+	if policy.History < 0 {
+		policy.History = 0
+	}
+	if policy.Max_Age_Days < 0 {
+		policy.Max_Age_Days = 0
+	}
+	return policy
+}
+
+// รหัสผ่านไม่ผ่านนโยบาย (ข้อความแสดงให้ผู้ใช้ได้)
+type PasswordPolicyError struct {
+	Message string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return e.Message
+}
+
+func (p PasswordPolicyResponse) validate(password string) error {
+	if len([]rune(password)) < p.Min_Length {
+		return &PasswordPolicyError{Message: fmt.Sprintf("รหัสผ่านต้องมีอย่างน้อย %d ตัวอักษร", p.Min_Length)}
+	}
+	// bcrypt ใช้แค่ 72 ไบต์แรก ยาวกว่านี้ส่วนที่เกินจะไม่ถูกตรวจ
+	if len(password) > 72 {
+		return &PasswordPolicyError{Message: "รหัสผ่านยาวเกิน 72 ไบต์"}
+	}
+	return nil
+}
+
+// ต้องเปลี่ยนรหัสผ่านก่อนใช้งาน: ผู้ดูแลระบบสร้าง / รีเซ็ตให้ หรือรหัสผ่านหมดอายุ
+// ผู้ใช้เดิมที่ยังไม่เคยเปลี่ยนรหัสนับอายุจากวันที่สร้างบัญชี
+func passwordChangeRequired(mustChange bool, changedAt *time.Time, createdAt time.Time) bool {
+	if mustChange {
+		return true
+	}
+	policy := currentPasswordPolicy()
+	if policy.Max_Age_Days == 0 {
+		return false
+	}
+	since := createdAt
+	if changedAt != nil {
+		since = *changedAt
+	}
+	return time.Since(since) > time.Duration(policy.Max_Age_Days)*24*time.Hour
+}
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("Failed to hash password")
+	}
+	return string(hash), nil
+}
@@ -0,0 +1,90 @@
+package service
+
+import "time"
+
+type PaymentAllocationPolicyResponse struct {
+	Component_Order   []string  `json:"component_order"`
+	Scope             int       `json:"scope"`             // 1 = ปิดทีละงวด, 2 = ตัดทีละองค์ประกอบ
+	Installment_Order int       `json:"installment_order"` // 1 = งวดเก่าก่อน, 2 = งวดใหม่ก่อน
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+type UpdatePaymentAllocationPolicyRequest struct {
+	Component_Order   []string `json:"component_order"` // เช่น ["fee","interest","principal"]
+	Scope             int      `json:"scope"`
+	Installment_Order int      `json:"installment_order"`
+}
+
+type PaymentAllocationItem struct {
+	Bill_DetailsId uint    `json:"bill_details_id"`
+	Payment_No     string  `json:"payment_no"`
+	Component      string  `json:"component"`
+	Component_Name string  `json:"component_name"`
+	Amount         float64 `json:"amount"`
+}
+
+// ช่องทางรับเงินหนึ่งรายการ (ใบเสร็จเดียวแบ่งจ่ายได้หลายช่องทาง)
+type PaymentTenderRequest struct {
+	Method    string  `json:"method"` // cash, transfer, promptpay, card, credit
+	Amount    float64 `json:"amount"`
+	Reference string  `json:"reference"`
+	Account   string  `json:"account"` // บัญชีที่รับเงิน
+}
+
+type PaymentTenderItem struct {
+	Method      string  `json:"method"`
+	Method_Name string  `json:"method_name"`
+	Amount      float64 `json:"amount"`
+	Reference   string  `json:"reference"`
+	Account     string  `json:"account"`
+}
+
+// ใบเสร็จรับเงินค่างวด แสดงว่าเงินแต่ละบาทไปตัดค่าปรับ / ดอกเบี้ย / เงินต้นของงวดไหน
+type PaymentReceiptResponse struct {
+	Id         uint      `json:"id"`
+	Receipt_No string    `json:"receipt_no"`
+	Bill_Type  int       `json:"bill_type"`
+	Bill_Id    uint      `json:"bill_id"`
+	Kind       int       `json:"kind"`
+	Kind_Name  string    `json:"kind_name"`
+	CreatedAt  time.Time `json:"created_at"`
+
+	Method      string     `json:"method"`
+	Method_Name string     `json:"method_name"`
+	Reference   string     `json:"reference"`
+	Status      int        `json:"status"` // 0 = ปกติ, 1 = ถูกกลับรายการ
+	Reversal_Of *uint      `json:"reversal_of,omitempty"`
+	Reversed_At *time.Time `json:"reversed_at,omitempty"`
+
+	Amount       float64 `json:"amount"`
+	Credit_Used  float64 `json:"credit_used"`
+	Credit_Added float64 `json:"credit_added"`
+
+	Fee_Paid       float64 `json:"fee_paid"`
+	Interest_Paid  float64 `json:"interest_paid"`
+	Principal_Paid float64 `json:"principal_paid"`
+
+	User_Id *int   `json:"user_id"`
+	Cashier string `json:"cashier"`
+	Note    string `json:"note"`
+
+	Allocations []PaymentAllocationItem `json:"allocations"`
+	Tenders     []PaymentTenderItem     `json:"tenders"`
+}
+
+type PaymentLedgerResponse struct {
+	Bill_Type      int                      `json:"bill_type"`
+	Bill_Id        uint                     `json:"bill_id"`
+	Payments       []PaymentReceiptResponse `json:"payments"`
+	Total_Amount   float64                  `json:"total_amount"`
+	Fee_Paid       float64                  `json:"fee_paid"`
+	Interest_Paid  float64                  `json:"interest_paid"`
+	Principal_Paid float64                  `json:"principal_paid"`
+}
+
+type PaymentService interface {
+	GetAllocationPolicy() (*PaymentAllocationPolicyResponse, error)
+	UpdateAllocationPolicy(request UpdatePaymentAllocationPolicyRequest) (*PaymentAllocationPolicyResponse, error)
+	GetPaymentReceipt(id uint) (*PaymentReceiptResponse, error)
+	GetBillLedger(billType int, billID uint) (*PaymentLedgerResponse, error)
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"rrmobile/model"
+	"rrmobile/respository"
+	"sort"
+	"strings"
+)
+
+// ลำดับตัดเงินเริ่มต้น: ค่าปรับ → ดอกเบี้ย → เงินต้น ปิดงวดเก่าก่อน
+var defaultComponentOrder = []string{model.AllocationFee, model.AllocationInterest, model.AllocationPrincipal}
+
+const (
+	AllocationScopeInstallment = 1 // ปิดทีละงวด (ทุกองค์ประกอบของงวดเก่าก่อน)
+	AllocationScopeComponent   = 2 // ตัดองค์ประกอบแรกของทุกงวดก่อน แล้วค่อยองค์ประกอบถัดไป
+
+	InstallmentOrderOldest = 1
+	InstallmentOrderNewest = 2
+)
+
+type allocationPolicy struct {
+	Order            []string
+	Scope            int
+	InstallmentOrder int
+}
+
+func parseComponentOrder(raw string) ([]string, error) {
+	parts := strings.Split(raw, ",")
+	order := make([]string, 0, len(parts))
+	seen := map[string]bool{}
+	for _, p := range parts {
+		comp := strings.ToLower(strings.TrimSpace(p))
+		if _, ok := model.AllocationComponentNames[comp]; !ok {
+			return nil, fmt.Errorf("ไม่รู้จักองค์ประกอบ %q (ใช้ fee, interest, principal)", p)
+		}
+		if seen[comp] {
+			return nil, fmt.Errorf("องค์ประกอบ %s ซ้ำ", comp)
+		}
+		seen[comp] = true
+		order = append(order, comp)
+	}
+	if len(order) != len(model.AllocationComponentNames) {
+		return nil, errors.New("ต้องระบุลำดับครบทั้ง fee, interest, principal")
+	}
+	return order, nil
+}
+
+func toAllocationPolicy(p *model.Payment_Allocation_Policy) allocationPolicy {
+	policy := allocationPolicy{Order: defaultComponentOrder, Scope: AllocationScopeInstallment, InstallmentOrder: InstallmentOrderOldest}
+	if p == nil {
+		return policy
+	}
+	if order, err := parseComponentOrder(p.Component_Order); err == nil {
+		policy.Order = order
+	}
+	if p.Scope == AllocationScopeComponent {
+		policy.Scope = AllocationScopeComponent
+	}
+	if p.Installment_Order == InstallmentOrderNewest {
+		policy.InstallmentOrder = InstallmentOrderNewest
+	}
+	return policy
+}
+
+func installmentComponentDue(d *respository.Bill_Details, component string) float64 {
+	switch component {
+	case model.AllocationFee:
+		return round2(d.Fee_Amount - d.Fee_Paid)
+	case model.AllocationInterest:
+		return round2(d.Interest_Amount - d.Interest_Paid)
+	default:
+		return round2(d.Installment_Price - d.Fee_Amount - d.Interest_Amount - d.Principal_Paid)
+	}
+}
+
+func addInstallmentComponentPaid(d *respository.Bill_Details, component string, amount float64) {
+	switch component {
+	case model.AllocationFee:
+		d.Fee_Paid = round2(d.Fee_Paid + amount)
+	case model.AllocationInterest:
+		d.Interest_Paid = round2(d.Interest_Paid + amount)
+	default:
+		d.Principal_Paid = round2(d.Principal_Paid + amount)
+	}
+}
+
+// งวดเก่าที่สร้างก่อนมีการแยกองค์ประกอบ: คำนวณดอกเบี้ยของงวดจาก % ส่วนเพิ่ม
+// และกระจายยอดที่เคยจ่ายไว้แล้วตามลำดับของนโยบาย
+func prepareInstallmentComponents(d *respository.Bill_Details, extraPercent int, order []string) {
+	if d.Interest_Amount == 0 && extraPercent > 0 && d.Schedule_Version <= 1 {
+		base := d.Installment_Price - d.Fee_Amount
+		d.Interest_Amount = round2(base * float64(extraPercent) / float64(100+extraPercent))
+	}
+
+	unassigned := round2(d.Paid_Amount - d.Fee_Paid - d.Interest_Paid - d.Principal_Paid)
+	for _, comp := range order {
+		if unassigned <= 0 {
+			break
+		}
+		due := installmentComponentDue(d, comp)
+		if due <= 0 {
+			continue
+		}
+		take := due
+		if unassigned < take {
+			take = unassigned
+		}
+		addInstallmentComponentPaid(d, comp, take)
+		unassigned = round2(unassigned - take)
+	}
+}
+
+// ตัดเงินตาม waterfall ของนโยบาย คืนรายการตัดเงินและยอดที่เหลือ (ไปเป็นเครดิต)
+// details ถูกแก้ไขในที่ (Paid_Amount, ยอดจ่ายแต่ละองค์ประกอบ, Status)
+func allocatePayment(details []respository.Bill_Details, policy allocationPolicy, extraPercent int, available float64) ([]model.Payment_Allocation, float64) {
+	idx := make([]int, 0, len(details))
+	for i := range details {
+		prepareInstallmentComponents(&details[i], extraPercent, policy.Order)
+		idx = append(idx, i)
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		da, db := details[idx[a]], details[idx[b]]
+		if policy.InstallmentOrder == InstallmentOrderNewest {
+			return da.Payment_Date.After(db.Payment_Date)
+		}
+		return da.Payment_Date.Before(db.Payment_Date)
+	})
+
+	type slot struct {
+		detail    int
+		component string
+	}
+	var slots []slot
+	if policy.Scope == AllocationScopeComponent {
+		for _, comp := range policy.Order {
+			for _, i := range idx {
+				slots = append(slots, slot{i, comp})
+			}
+		}
+	} else {
+		for _, i := range idx {
+			for _, comp := range policy.Order {
+				slots = append(slots, slot{i, comp})
+			}
+		}
+	}
+
+	var allocations []model.Payment_Allocation
+	available = round2(available)
+	for _, sl := range slots {
+		if available <= 0 {
+			break
+		}
+		d := &details[sl.detail]
+		due := installmentComponentDue(d, sl.component)
+		if due <= 0 {
+			continue
+		}
+		take := due
+		if available < take {
+			take = available
+		}
+		addInstallmentComponentPaid(d, sl.component, take)
+		d.Paid_Amount = round2(d.Paid_Amount + take)
+		available = round2(available - take)
+
+		allocations = append(allocations, model.Payment_Allocation{
+			Bill_DetailsId: d.Id,
+			Payment_No:     d.Payment_No,
+			Component:      sl.component,
+			Amount:         take,
+		})
+	}
+
+	for i := range details {
+		if details[i].Paid_Amount >= details[i].Installment_Price-0.005 {
+			details[i].Status = model.BillDetailStatusPaid
+		}
+	}
+	return allocations, available
+}
+
+// งวดของสัญญา installment ไม่ได้แยกเก็บยอดแต่ละองค์ประกอบ จึงแปลงเป็นงวดผ่อนแล้วใช้ waterfall เดียวกัน
+// (ยอดที่จ่ายสะสมกระจายใหม่ตามนโยบายทุกครั้ง) แล้วคัดยอดจ่าย / สถานะกลับเข้างวดเดิม
+// คืนงวดที่แปลงแล้วไว้ใช้สรุปผลด้วย
+func allocateInstallmentBillPayment(details []model.Bill_Details_Installment, policy allocationPolicy, available float64) ([]model.Payment_Allocation, []respository.Bill_Details, float64) {
+	view := make([]respository.Bill_Details, len(details))
+	for i, d := range details {
+		view[i] = respository.Bill_Details{
+			Id:                d.Id,
+			Bill_HeaderId:     d.Bill_Header_InstallmentId,
+			Installment_Price: d.Installment_Price,
+			Paid_Amount:       d.Paid_Amount,
+			Payment_Date:      d.Payment_Date,
+			Fee_Amount:        d.Fee_Amount,
+			Status:            d.Status,
+			Credit_Balance:    d.Credit_Balance,
+			Payment_No:        d.Payment_No,
+		}
+	}
+	allocations, leftover := allocatePayment(view, policy, 0, available)
+	for i := range details {
+		details[i].Paid_Amount = view[i].Paid_Amount
+		details[i].Status = view[i].Status
+	}
+	return allocations, view, leftover
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"errors"
+	"rrmobile/model"
+	"rrmobile/respository"
+	"strings"
+)
+
+type paymentService struct {
+	paymentRepository respository.PaymentRepository
+}
+
+func NewPaymentService(paymentRepository respository.PaymentRepository) PaymentService {
+	return &paymentService{paymentRepository: paymentRepository}
+}
+
+func (s *paymentService) GetAllocationPolicy() (*PaymentAllocationPolicyResponse, error) {
+	policy, err := s.paymentRepository.GetAllocationPolicy()
+	if err != nil {
+		return nil, err
+	}
+	parsed := toAllocationPolicy(policy)
+	return &PaymentAllocationPolicyResponse{
+		Component_Order:   parsed.Order,
+		Scope:             parsed.Scope,
+		Installment_Order: parsed.InstallmentOrder,
+		UpdatedAt:         policy.UpdatedAt,
+	}, nil
+}
+
+func (s *paymentService) UpdateAllocationPolicy(request UpdatePaymentAllocationPolicyRequest) (*PaymentAllocationPolicyResponse, error) {
+	order, err := parseComponentOrder(strings.Join(request.Component_Order, ","))
+	if err != nil {
+		return nil, err
+	}
+	if request.Scope != AllocationScopeInstallment && request.Scope != AllocationScopeComponent {
+		return nil, errors.New("scope ต้องเป็น 1 (ปิดทีละงวด) หรือ 2 (ตัดทีละองค์ประกอบ)")
+	}
+	if request.Installment_Order != InstallmentOrderOldest && request.Installment_Order != InstallmentOrderNewest {
+		return nil, errors.New("installment_order ต้องเป็น 1 (งวดเก่าก่อน) หรือ 2 (งวดใหม่ก่อน)")
+	}
+
+	policy, err := s.paymentRepository.GetAllocationPolicy()
+	if err != nil {
+		return nil, err
+	}
+	policy.Component_Order = strings.Join(order, ",")
+	policy.Scope = request.Scope
+	policy.Installment_Order = request.Installment_Order
+
+	if err := s.paymentRepository.UpdateAllocationPolicy(policy); err != nil {
+		return nil, err
+	}
+	return s.GetAllocationPolicy()
+}
+
+func (s *paymentService) GetPaymentReceipt(id uint) (*PaymentReceiptResponse, error) {
+	payment, err := s.paymentRepository.GetPaymentById(id)
+	if err != nil {
+		return nil, errors.New("ไม่พบใบเสร็จ")
+	}
+	resp := toPaymentReceiptResponse(*payment)
+	return &resp, nil
+}
+
+func (s *paymentService) GetBillLedger(billType int, billID uint) (*PaymentLedgerResponse, error) {
+	payments, err := s.paymentRepository.GetPaymentsByBill(billType, billID)
+	if err != nil {
+		return nil, err
+	}
+
+	ledger := &PaymentLedgerResponse{
+		Bill_Type: billType,
+		Bill_Id:   billID,
+		Payments:  make([]PaymentReceiptResponse, 0, len(payments)),
+	}
+	for _, p := range payments {
+		receipt := toPaymentReceiptResponse(p)
+		ledger.Payments = append(ledger.Payments, receipt)
+		ledger.Total_Amount += receipt.Amount
+		ledger.Fee_Paid += receipt.Fee_Paid
+		ledger.Interest_Paid += receipt.Interest_Paid
+		ledger.Principal_Paid += receipt.Principal_Paid
+	}
+	ledger.Total_Amount = round2(ledger.Total_Amount)
+	ledger.Fee_Paid = round2(ledger.Fee_Paid)
+	ledger.Interest_Paid = round2(ledger.Interest_Paid)
+	ledger.Principal_Paid = round2(ledger.Principal_Paid)
+	return ledger, nil
+}
+
+func toPaymentReceiptResponse(p model.Payment) PaymentReceiptResponse {
+	resp := PaymentReceiptResponse{
+		Id:           p.Id,
+		Receipt_No:   p.Receipt_No,
+		Bill_Type:    p.Bill_Type,
+		Bill_Id:      p.Bill_Id,
+		Kind:         p.Kind,
+		Kind_Name:    model.PaymentKindNames[p.Kind],
+		CreatedAt:    p.CreatedAt,
+		Method:       p.Method,
+		Method_Name:  paymentMethodName(p.Method),
+		Reference:    p.Reference,
+		Status:       p.Status,
+		Reversal_Of:  p.Reversal_Of,
+		Reversed_At:  p.Reversed_At,
+		Amount:       p.Amount,
+		Credit_Used:  p.Credit_Used,
+		Credit_Added: p.Credit_Added,
+		User_Id:      p.User_Id,
+		Cashier:      p.User.FullName,
+		Note:         p.Note,
+		Allocations:  make([]PaymentAllocationItem, 0, len(p.Allocations)),
+		Tenders:      make([]PaymentTenderItem, 0, len(p.Tenders)),
+	}
+	for _, t := range p.Tenders {
+		resp.Tenders = append(resp.Tenders, PaymentTenderItem{
+			Method:      t.Method,
+			Method_Name: model.PaymentMethodNames[t.Method],
+			Amount:      t.Amount,
+			Reference:   t.Reference,
+			Account:     t.Account,
+		})
+	}
+	for _, a := range p.Allocations {
+		resp.Allocations = append(resp.Allocations, PaymentAllocationItem{
+			Bill_DetailsId: a.Bill_DetailsId,
+			Payment_No:     a.Payment_No,
+			Component:      a.Component,
+			Component_Name: model.AllocationComponentNames[a.Component],
+			Amount:         a.Amount,
+		})
+		switch a.Component {
+		case model.AllocationFee:
+			resp.Fee_Paid += a.Amount
+		case model.AllocationInterest:
+			resp.Interest_Paid += a.Amount
+		default:
+			resp.Principal_Paid += a.Amount
+		}
+	}
+	resp.Fee_Paid = round2(resp.Fee_Paid)
+	resp.Interest_Paid = round2(resp.Interest_Paid)
+	resp.Principal_Paid = round2(resp.Principal_Paid)
+	return resp
+}
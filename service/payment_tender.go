@@ -0,0 +1,101 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"rrmobile/model"
+	"strings"
+)
+
+// แยกช่องทางรับเงินของใบเสร็จ ไม่ระบุช่องทาง = รับเงินสดเต็มจำนวน
+// ถ้าระบุทั้งยอดและช่องทาง ยอดรวมของทุกช่องทางต้องเท่ากับยอดชำระ
+func buildPaymentTenders(reqTenders []PaymentTenderRequest, amount float64) ([]model.Payment_Tender, error) {
+	amount = round2(amount)
+	if len(reqTenders) == 0 {
+		if amount <= 0 {
+			return nil, nil
+		}
+		return []model.Payment_Tender{{Method: model.PaymentMethodCash, Amount: amount}}, nil
+	}
+
+	tenders := make([]model.Payment_Tender, 0, len(reqTenders))
+	total := 0.0
+	for _, t := range reqTenders {
+		method, err := normalizePaymentMethod(t.Method)
+		if err != nil {
+			return nil, err
+		}
+		value := round2(t.Amount)
+		if value <= 0 {
+			return nil, errors.New("ยอดแต่ละช่องทางต้องมากกว่า 0")
+		}
+		total += value
+		tenders = append(tenders, model.Payment_Tender{
+			Method:    method,
+			Amount:    value,
+			Reference: strings.TrimSpace(t.Reference),
+			Account:   strings.TrimSpace(t.Account),
+		})
+	}
+	total = round2(total)
+	if amount > 0 && math.Abs(total-amount) > 0.005 {
+		return nil, fmt.Errorf("ยอดรวมทุกช่องทาง %.2f ไม่ตรงกับยอดชำระ %.2f", total, amount)
+	}
+	return tenders, nil
+}
+
+// แยกส่วนที่หักจากเครดิตคงเหลือออกจากเงินที่รับเข้าร้านจริง
+func splitCreditTenders(tenders []model.Payment_Tender) (received []model.Payment_Tender, cashIn, credit float64) {
+	for _, t := range tenders {
+		if t.Method == model.PaymentMethodCredit {
+			credit += t.Amount
+			continue
+		}
+		received = append(received, t)
+		cashIn += t.Amount
+	}
+	return received, round2(cashIn), round2(credit)
+}
+
+// ผูกช่องทางรับเงินเข้ากับใบเสร็จ ช่องทางเดียวเก็บไว้ที่หัวใบเสร็จด้วย ส่วนหลายช่องทาง = split
+func applyPaymentTenders(payment *model.Payment, tenders []model.Payment_Tender) {
+	payment.Tenders = tenders
+	payment.Method = model.PaymentMethodCash
+	payment.Reference = ""
+	if len(tenders) == 0 {
+		return
+	}
+	payment.Method = tenders[0].Method
+	if len(tenders) == 1 {
+		payment.Reference = tenders[0].Reference
+		return
+	}
+	for _, t := range tenders[1:] {
+		if t.Method != payment.Method {
+			payment.Method = model.PaymentMethodSplit
+			return
+		}
+	}
+}
+
+// รายการคืนเงิน: ช่องทางเดียวกับใบเสร็จเดิม ยอดติดลบ
+func reverseTenders(tenders []model.Payment_Tender) []model.Payment_Tender {
+	reversed := make([]model.Payment_Tender, 0, len(tenders))
+	for _, t := range tenders {
+		reversed = append(reversed, model.Payment_Tender{
+			Method:    t.Method,
+			Amount:    -t.Amount,
+			Reference: t.Reference,
+			Account:   t.Account,
+		})
+	}
+	return reversed
+}
+
+func paymentMethodName(method string) string {
+	if method == model.PaymentMethodSplit {
+		return "หลายช่องทาง"
+	}
+	return model.PaymentMethodNames[method]
+}
@@ -115,9 +115,9 @@ type UpdateProductCategoryRequest struct {
 type ProductCategoryService interface {
 	// CountCategories(name []string) (int64, error)
 	GetCategories(name string, page, limit int) (*PaginationResponseCategory, error) // GetCategoryById(id uint) (*ProductCategoryResponse, error)
-	CreateCategory(NewProductCategoryRequest) (*ProductCategoryResponse, error)
-	EditCategory(id uint, updateCategoryRequest UpdateProductCategoryRequest) (*ProductCategoryResponse, error)
-	DeleteCategory(id uint) error
+	CreateCategory(NewProductCategoryRequest, AuditActor) (*ProductCategoryResponse, error)
+	EditCategory(id uint, updateCategoryRequest UpdateProductCategoryRequest, actor AuditActor) (*ProductCategoryResponse, error)
+	DeleteCategory(id uint, actor AuditActor) error
 	GetCategoryByID(id uint) (*ProductCategoryResponse, error)
 }
 
@@ -130,9 +130,9 @@ type ProductService interface {
 		sortPrice string,
 		page, limit int,
 	) (*PaginationResponseProduct, error)
-	CreateProduct(req NewProductRequest) (*ProductResponse, error)
-	EditProduct(id uint, req UpdateProductRequest) (*ProductResponse, error)
-	DeleteProduct(id uint) error
+	CreateProduct(req NewProductRequest, actor AuditActor) (*ProductResponse, error)
+	EditProduct(id uint, req UpdateProductRequest, actor AuditActor) (*ProductResponse, error)
+	DeleteProduct(id uint, actor AuditActor) error
 	GetProductByID(productID uint) (*ProductResponse, error)
 	GetProductByIDDetail(productID uint) (*ProductResponse, error)
 }
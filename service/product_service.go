@@ -3,6 +3,7 @@ package service
 import (
 	"fmt"
 	"path/filepath"
+	"rrmobile/model"
 	"rrmobile/respository"
 	"rrmobile/util"
 	"strconv"
@@ -14,20 +15,22 @@ import (
 
 type productCategoryService struct {
 	productcategoryRepository respository.ProductCategoryRepository
+	auditRepository           respository.AuditRepository
 }
 
-func NewProductCategoryService(productcategoryRepository respository.ProductCategoryRepository) ProductCategoryService {
-	return &productCategoryService{productcategoryRepository: productcategoryRepository}
+func NewProductCategoryService(productcategoryRepository respository.ProductCategoryRepository, auditRepository respository.AuditRepository) ProductCategoryService {
+	return &productCategoryService{productcategoryRepository: productcategoryRepository, auditRepository: auditRepository}
 }
 
 type productService struct {
 	db *gorm.DB
 
 	productRepository respository.ProductRepository
+	auditRepository   respository.AuditRepository
 }
 
-func NewProductService(productRepository respository.ProductRepository) *productService {
-	return &productService{productRepository: productRepository}
+func NewProductService(productRepository respository.ProductRepository, auditRepository respository.AuditRepository) *productService {
+	return &productService{productRepository: productRepository, auditRepository: auditRepository}
 }
 func (s *productCategoryService) GetCategories(name string, page, limit int) (*PaginationResponseCategory, error) {
 	if page < 1 {
@@ -90,7 +93,7 @@ func (s *productCategoryService) GetCategoryByID(id uint) (*ProductCategoryRespo
 		IsActive:  category.IsActive,
 	}, nil
 }
-func (s *productCategoryService) CreateCategory(req NewProductCategoryRequest) (*ProductCategoryResponse, error) {
+func (s *productCategoryService) CreateCategory(req NewProductCategoryRequest, actor AuditActor) (*ProductCategoryResponse, error) {
 	// แปลง request → entity
 	category := respository.ProductCategory{
 		Name:     req.Name,
@@ -104,19 +107,22 @@ func (s *productCategoryService) CreateCategory(req NewProductCategoryRequest) (
 	}
 
 	// แปลง entity → response
-	return &ProductCategoryResponse{
+	resp := &ProductCategoryResponse{
 		Id:        createdCategory.Id,
 		Name:      createdCategory.Name,
 		CreatedAt: createdCategory.CreatedAt.Format("2006-01-02 15:04:05"),
 		UpdatedAt: createdCategory.UpdatedAt.Format("2006-01-02 15:04:05"),
 		IsActive:  createdCategory.IsActive,
-	}, nil
+	}
+	recordAudit(s.auditRepository, actor, model.AuditActionCreate, model.AuditEntityProductCategory, resp.Id, nil, resp)
+	return resp, nil
 }
 
-func (s *productCategoryService) EditCategory(id uint, updateCategoryRequest UpdateProductCategoryRequest) (*ProductCategoryResponse, error) {
+func (s *productCategoryService) EditCategory(id uint, updateCategoryRequest UpdateProductCategoryRequest, actor AuditActor) (*ProductCategoryResponse, error) {
 	if id == 0 {
 		return nil, fmt.Errorf("invalid category ID")
 	}
+	before, _ := s.GetCategoryByID(id)
 	category := respository.ProductCategory{
 		Name:     updateCategoryRequest.Name,
 		IsActive: updateCategoryRequest.IsActive,
@@ -125,27 +131,31 @@ func (s *productCategoryService) EditCategory(id uint, updateCategoryRequest Upd
 	if err != nil {
 		return nil, fmt.Errorf("failed to update category: ", err)
 	}
-	return &ProductCategoryResponse{
+	resp := &ProductCategoryResponse{
 		Id:        updatedCategory.Id,
 		Name:      updatedCategory.Name,
 		CreatedAt: updatedCategory.CreatedAt.Format("2006-01-02 15:04:05"),
 		UpdatedAt: updatedCategory.UpdatedAt.Format("2006-01-02 15:04:05"),
 		IsActive:  updatedCategory.IsActive,
-	}, nil
+	}
+	recordAudit(s.auditRepository, actor, model.AuditActionUpdate, model.AuditEntityProductCategory, id, before, resp)
+	return resp, nil
 }
 
-func (s *productCategoryService) DeleteCategory(id uint) error {
+func (s *productCategoryService) DeleteCategory(id uint, actor AuditActor) error {
 	if id == 0 {
 		return fmt.Errorf("invalid category ID")
 	}
+	before, _ := s.GetCategoryByID(id)
 	err := s.productcategoryRepository.DeleteCategory(id)
 	if err != nil {
 		return fmt.Errorf("failed to delete category: ", err)
 	}
+	recordAudit(s.auditRepository, actor, model.AuditActionDelete, model.AuditEntityProductCategory, id, before, nil)
 	return nil
 }
 
-func (s *productService) CreateProduct(req NewProductRequest) (*ProductResponse, error) {
+func (s *productService) CreateProduct(req NewProductRequest, actor AuditActor) (*ProductResponse, error) {
 	// แปลงราคา
 	priceFloat, err := strconv.ParseFloat(req.Price, 64)
 	if err != nil {
@@ -191,7 +201,7 @@ func (s *productService) CreateProduct(req NewProductRequest) (*ProductResponse,
 		})
 	}
 
-	return &ProductResponse{
+	resp := &ProductResponse{
 		Id:          createdProduct.Id,
 		Name:        createdProduct.Name,
 		Sku:         createdProduct.Sku,
@@ -202,7 +212,9 @@ func (s *productService) CreateProduct(req NewProductRequest) (*ProductResponse,
 		Images:      imagesResponse,
 		CreatedAt:   createdProduct.CreatedAt.Format("2006-01-02 15:04:05"),
 		UpdatedAt:   createdProduct.UpdatedAt.Format("2006-01-02 15:04:05"),
-	}, nil
+	}
+	recordAudit(s.auditRepository, actor, model.AuditActionCreate, model.AuditEntityProduct, resp.Id, nil, resp)
+	return resp, nil
 }
 func (s *productService) GenerateProductResponse(products []ProductResponse) []ProductResponse {
 	resp := make([]ProductResponse, 0, len(products))
@@ -334,10 +346,11 @@ func (s *productService) GetAllProducts(
 	}, nil
 }
 
-func (s *productService) EditProduct(id uint, req UpdateProductRequest) (*ProductResponse, error) {
+func (s *productService) EditProduct(id uint, req UpdateProductRequest, actor AuditActor) (*ProductResponse, error) {
 	if id == 0 {
 		return nil, fmt.Errorf("invalid product ID")
 	}
+	before, _ := s.GetProductByID(id)
 
 	product := respository.Product{
 		Name:        req.Name,
@@ -361,6 +374,9 @@ func (s *productService) EditProduct(id uint, req UpdateProductRequest) (*Produc
 	if err != nil {
 		return nil, fmt.Errorf("failed to update product: ", err)
 	}
+	// เทียบกับข้อมูลที่อ่านแบบเดียวกับ before (URL รูปใน response มี token ที่เปลี่ยนทุกครั้ง)
+	after, _ := s.GetProductByID(id)
+	recordAudit(s.auditRepository, actor, model.AuditActionUpdate, model.AuditEntityProduct, id, before, after)
 
 	var imagesResponse []ProductImageResponse
 	for _, img := range updatedProduct.ProductImages {
@@ -390,12 +406,17 @@ func (s *productService) EditProduct(id uint, req UpdateProductRequest) (*Produc
 	}, nil
 }
 
-func (s *productService) DeleteProduct(id uint) error {
+func (s *productService) DeleteProduct(id uint, actor AuditActor) error {
 	if id == 0 {
 		return fmt.Errorf("invalid product ID")
 	}
 
-	return s.productRepository.DeleteProductWithImages(id)
+	before, _ := s.GetProductByID(id)
+	if err := s.productRepository.DeleteProductWithImages(id); err != nil {
+		return err
+	}
+	recordAudit(s.auditRepository, actor, model.AuditActionDelete, model.AuditEntityProduct, id, before, nil)
+	return nil
 }
 
 func (s *productService) GetProductByID(productID uint) (*ProductResponse, error) {
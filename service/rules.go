@@ -31,7 +31,7 @@ type RulesService interface {
 	GetAllRules(limit, offset int) ([]RulesResponse, error)
 	CountRules() (int64, error)
 	GetRuleByID(id uint) (*RulesResponse, error)
-	CreateRule(request NewRuleRequest) (*RulesResponse, error)
-	EditRule(id uint, request UpdateRuleRequest) (*RulesResponse, error)
-	DeleteRule(id uint) error
+	CreateRule(request NewRuleRequest, actor AuditActor) (*RulesResponse, error)
+	EditRule(id uint, request UpdateRuleRequest, actor AuditActor) (*RulesResponse, error)
+	DeleteRule(id uint, actor AuditActor) error
 }
@@ -2,16 +2,18 @@ package service
 
 import (
 	"fmt"
+	"rrmobile/model"
 	"rrmobile/respository"
 	"time"
 )
 
 type rulesService struct {
 	rulesRepository respository.RulesRepository
+	auditRepository respository.AuditRepository
 }
 
-func NewRulesService(rulesRepository respository.RulesRepository) RulesService {
-	return &rulesService{rulesRepository: rulesRepository}
+func NewRulesService(rulesRepository respository.RulesRepository, auditRepository respository.AuditRepository) RulesService {
+	return &rulesService{rulesRepository: rulesRepository, auditRepository: auditRepository}
 }
 
 func (s *rulesService) GetAllRules(limit, offset int) ([]RulesResponse, error) {
@@ -43,7 +45,7 @@ func (s *rulesService) CountRules() (int64, error) {
 	return count, nil
 }
 
-func (s *rulesService) CreateRule(request NewRuleRequest) (*RulesResponse, error) {
+func (s *rulesService) CreateRule(request NewRuleRequest, actor AuditActor) (*RulesResponse, error) {
 	if request.Threshold_Months <= 0 {
 		err := fmt.Errorf("Threshold months must be greater than 0")
 		return nil, err
@@ -72,6 +74,7 @@ func (s *rulesService) CreateRule(request NewRuleRequest) (*RulesResponse, error
 		CreatedAt:        newRule.CreatedAt.Format(time.RFC3339),
 		UpdatedAt:        newRule.UpdatedAt.Format(time.RFC3339),
 	}
+	recordAudit(s.auditRepository, actor, model.AuditActionCreate, model.AuditEntityRule, response.Id, nil, response)
 
 	return &response, nil
 }
@@ -92,7 +95,7 @@ func (s *rulesService) GetRuleByID(id uint) (*RulesResponse, error) {
 	return &response, nil
 }
 
-func (s *rulesService) EditRule(id uint, request UpdateRuleRequest) (*RulesResponse, error) {
+func (s *rulesService) EditRule(id uint, request UpdateRuleRequest, actor AuditActor) (*RulesResponse, error) {
 	if request.Threshold_Months <= 0 {
 		return nil, fmt.Errorf("Threshold months must be greater than 0")
 	}
@@ -100,6 +103,7 @@ func (s *rulesService) EditRule(id uint, request UpdateRuleRequest) (*RulesRespo
 		return nil, fmt.Errorf("Discount amount cannot be negative")
 	}
 
+	before, _ := s.GetRuleByID(id)
 	rule := respository.Rules{
 		Threshold_Months: request.Threshold_Months,
 		Type_Discount:    request.Type_Discount,
@@ -119,14 +123,17 @@ func (s *rulesService) EditRule(id uint, request UpdateRuleRequest) (*RulesRespo
 		CreatedAt:        updatedRule.CreatedAt.Format(time.RFC3339),
 		UpdatedAt:        updatedRule.UpdatedAt.Format(time.RFC3339),
 	}
+	recordAudit(s.auditRepository, actor, model.AuditActionUpdate, model.AuditEntityRule, id, before, response)
 
 	return &response, nil
 }
 
-func (s *rulesService) DeleteRule(id uint) error {
+func (s *rulesService) DeleteRule(id uint, actor AuditActor) error {
+	before, _ := s.GetRuleByID(id)
 	err := s.rulesRepository.DeleteRule(id)
 	if err != nil {
 		return fmt.Errorf("Failed to delete rule with ID ", id)
 	}
+	recordAudit(s.auditRepository, actor, model.AuditActionDelete, model.AuditEntityRule, id, before, nil)
 	return nil
 }
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"rrmobile/model"
+	"rrmobile/respository"
+	"strings"
+	"time"
+)
+
+type TradeInRequest struct {
+	AppraisalId     *uint   `json:"appraisal_id"` // ใช้ข้อมูลจากใบประเมินสภาพเครื่อง
+	ProductId       uint    `json:"product_id"`
+	Imei            string  `json:"imei"`
+	Condition_Grade string  `json:"condition_grade"`
+	Agreed_Value    float64 `json:"agreed_value"`
+	Note            string  `json:"note"`
+}
+
+type TradeInResponse struct {
+	Id               uint      `json:"id"`
+	ProductId        uint      `json:"product_id"`
+	ProductName      string    `json:"product_name"`
+	AppraisalId      *uint     `json:"appraisal_id"`
+	Imei             string    `json:"imei"`
+	Condition_Grade  string    `json:"condition_grade"`
+	Appraised_Value  float64   `json:"appraised_value"`
+	Agreed_Value     float64   `json:"agreed_value"`
+	Inventory_UnitId *uint     `json:"inventory_unit_id"`
+	Note             string    `json:"note"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// ตรวจข้อมูลเครื่องเทิร์น ยังไม่บันทึก (รอผูกกับบิลหลังสร้างบิลสำเร็จ)
+func newTradeIn(appraisals respository.AppraisalRepository, products respository.ProductRepository, req TradeInRequest, billType int, userID int) (*model.Trade_In, error) {
+	agreed := round2(req.Agreed_Value)
+	if agreed <= 0 {
+		return nil, errors.New("กรุณาระบุราคารับเทิร์น")
+	}
+
+	tradeIn := &model.Trade_In{
+		Bill_Type:       billType,
+		ProductId:       req.ProductId,
+		Imei:            strings.TrimSpace(req.Imei),
+		Condition_Grade: strings.ToUpper(strings.TrimSpace(req.Condition_Grade)),
+		Agreed_Value:    agreed,
+		User_Id:         userID,
+		Note:            strings.TrimSpace(req.Note),
+	}
+
+	if req.AppraisalId != nil {
+		appraisal, err := appraisals.GetAppraisalById(*req.AppraisalId)
+		if err != nil {
+			return nil, errors.New("ไม่พบใบประเมินเครื่องเทิร์น")
+		}
+		if appraisal.Bill_Header_InstallmentId != nil {
+			return nil, errors.New("ใบประเมินนี้ถูกใช้กับบิลจำนำแล้ว")
+		}
+		appraisalID := appraisal.Id
+		tradeIn.Pawn_AppraisalId = &appraisalID
+		tradeIn.ProductId = appraisal.ProductId
+		tradeIn.Imei = appraisal.Imei
+		tradeIn.Condition_Grade = appraisal.Condition_Grade
+		tradeIn.Appraised_Value = appraisal.Appraised_Value
+	}
+
+	if tradeIn.ProductId == 0 {
+		return nil, errors.New("กรุณาระบุรุ่นเครื่องเทิร์น")
+	}
+	if _, err := products.GetProductByID(tradeIn.ProductId); err != nil {
+		return nil, errors.New("ไม่พบรุ่นเครื่องเทิร์น")
+	}
+	if tradeIn.Imei == "" {
+		return nil, errors.New("กรุณาระบุ IMEI เครื่องเทิร์น")
+	}
+	return tradeIn, nil
+}
+
+// เครื่องเทิร์นเข้าสต็อกมือสอง ตั้งมูลค่าเท่าราคาที่รับมา
+func tradeInInventoryUnit(tradeIn *model.Trade_In, invoice string) *model.Inventory_Unit {
+	return &model.Inventory_Unit{
+		ProductId: tradeIn.ProductId,
+		Source:    model.InventorySourceTradeIn,
+		Imei:      tradeIn.Imei,
+		Valuation: tradeIn.Agreed_Value,
+		Status:    model.InventoryUnitAvailable,
+		Note:      fmt.Sprintf("เครื่องเทิร์นจากบิล %s", invoice),
+	}
+}
+
+func toTradeInResponse(t *model.Trade_In) *TradeInResponse {
+	if t == nil {
+		return nil
+	}
+	return &TradeInResponse{
+		Id:               t.Id,
+		ProductId:        t.ProductId,
+		ProductName:      t.Product.Name,
+		AppraisalId:      t.Pawn_AppraisalId,
+		Imei:             t.Imei,
+		Condition_Grade:  t.Condition_Grade,
+		Appraised_Value:  t.Appraised_Value,
+		Agreed_Value:     t.Agreed_Value,
+		Inventory_UnitId: t.Inventory_UnitId,
+		Note:             t.Note,
+		CreatedAt:        t.CreatedAt,
+	}
+}
@@ -11,6 +11,8 @@ type UserResponse struct {
 
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	Password_Change_Required bool `json:"password_change_required"`
 }
 
 type UserResponseAll struct {
@@ -20,8 +22,9 @@ type UserResponseAll struct {
 	RoleID    int    `json:"role_id"`
 	Is_active bool   `json:"is_active"`
 
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	Last_Login_At *time.Time `json:"last_login_at"`
 }
 type NewUserRequest struct {
 	Username  string `json:"username"  validate:"required"`
@@ -49,8 +52,8 @@ type UsersService interface {
 	// CountUsers() (int, error)
 	CountUsers(usernames []string, date string) (int, error)
 	GetUserById(id uint) (*UserResponse, error)
-	CreateUser(NewUserRequest) (*UserResponse, error)
-	EditUser(id uint, newUsername string, newFullName string, newPassword string, newActive *bool) (*UserResponse, error)
-	DeleteUser(id uint) error
-	ResetPasswordUser(id uint, newPassword string) (*UserResponse, error)
+	CreateUser(NewUserRequest, AuditActor) (*UserResponse, error)
+	EditUser(id uint, newUsername string, newFullName string, newPassword string, newActive *bool, actor AuditActor) (*UserResponse, error)
+	DeleteUser(id uint, actor AuditActor) error
+	ResetPasswordUser(id uint, newPassword string, actor AuditActor) (*UserResponse, error)
 }
@@ -3,21 +3,45 @@ package service
 import (
 	"fmt"
 	"rrmobile/config"
+	"rrmobile/model"
 	"rrmobile/respository"
 
 	"time"
 
 	"github.com/go-playground/validator"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type usersService struct {
 	usersRepository respository.UserRepository
+	authService     AuthService
+	auditRepository respository.AuditRepository
 }
 
-func NewUsersService(usersRepository respository.UserRepository) UsersService {
-	return &usersService{usersRepository: usersRepository}
+func NewUsersService(usersRepository respository.UserRepository, authService AuthService, auditRepository respository.AuditRepository) UsersService {
+	return &usersService{usersRepository: usersRepository, authService: authService, auditRepository: auditRepository}
 }
+
+// ข้อมูลผู้ใช้ที่เก็บในประวัติการแก้ไข (ไม่เก็บรหัสผ่าน บันทึกแค่ว่ามีการเปลี่ยน)
+type userAudit struct {
+	Username  string `json:"username"`
+	FullName  string `json:"full_name"`
+	RoleID    uint   `json:"role_id"`
+	Is_active bool   `json:"is_active"`
+}
+
+func toUserAudit(u *respository.User) *userAudit {
+	if u == nil {
+		return nil
+	}
+	audit := &userAudit{Username: u.Username, FullName: u.FullName, RoleID: u.RoleID}
+	if u.Is_active != nil {
+		audit.Is_active = *u.Is_active
+	}
+	return audit
+}
+
+var passwordAuditChange = AuditChange{Before: "********", After: "********"}
+
 func (s usersService) GetAllUsers(usernames []string, date string, limit, offset int) ([]UserResponseAll, error) {
 	users, err := s.usersRepository.GetAllUsers(usernames, date, limit, offset)
 	if err != nil {
@@ -39,6 +63,8 @@ func (s usersService) GetAllUsers(usernames []string, date string, limit, offset
 			FullName:  user.FullName,
 			RoleID:    int(user.RoleID),
 			Is_active: isActive,
+
+			Last_Login_At: user.Last_Login_At,
 		})
 	}
 
@@ -72,20 +98,27 @@ func (s *usersService) GetUserById(id uint) (*UserResponse, error) {
 		RoleID:    int(user.RoleID),
 		CreatedAt: user.CreatedAt,
 		Is_active: isActive,
+
+		Password_Change_Required: passwordChangeRequired(user.Must_Change_Password, user.Password_Changed_At, user.CreatedAt),
 	}
 	return &response, nil
 
 }
-func (s *usersService) CreateUser(request NewUserRequest) (*UserResponse, error) {
+func (s *usersService) CreateUser(request NewUserRequest, actor AuditActor) (*UserResponse, error) {
 	isActive := true
+	now := time.Now()
 
+	// ผู้ดูแลระบบรู้รหัสผ่านนี้ ผู้ใช้ต้องเปลี่ยนเองตอนเข้าสู่ระบบครั้งแรก
 	user := respository.User{
 		FullName:  request.FullName,
 		Password:  request.Password,
 		Username:  request.Username,
-		CreatedAt: time.Now(),
+		CreatedAt: now,
 		RoleID:    2,
 		Is_active: &isActive,
+
+		Must_Change_Password: true,
+		Password_Changed_At:  &now,
 	}
 
 	validate := validator.New()
@@ -101,15 +134,19 @@ func (s *usersService) CreateUser(request NewUserRequest) (*UserResponse, error)
 	if err != nil {
 		return nil, fmt.Errorf("Insert Cannot emtpy")
 	}
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	if err := currentPasswordPolicy().validate(user.Password); err != nil {
+		return nil, err
+	}
+	passwordHash, err := hashPassword(user.Password)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to hash password")
+		return nil, err
 	}
-	user.Password = string(passwordHash) // Store hashed password
+	user.Password = passwordHash // Store hashed password
 	newUser, err := s.usersRepository.AddUser(user)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to create user")
 	}
+	recordAudit(s.auditRepository, actor, model.AuditActionCreate, model.AuditEntityUser, newUser.Id, nil, toUserAudit(newUser))
 	createdAtInThai, err := config.ConvertToThaiTime(newUser.CreatedAt)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to convert time to Thai format")
@@ -125,21 +162,25 @@ func (s *usersService) CreateUser(request NewUserRequest) (*UserResponse, error)
 	return &response, nil
 }
 
-func (s *usersService) EditUser(id uint, newUsername string, newFullName string, newPassword string, newActive *bool) (*UserResponse, error) {
+func (s *usersService) EditUser(id uint, newUsername string, newFullName string, newPassword string, newActive *bool, actor AuditActor) (*UserResponse, error) {
 	user, err := s.usersRepository.GetUserByID(id)
 	if err != nil {
 		return nil, fmt.Errorf("User with ID %d not found", id)
 	}
+	before := toUserAudit(user)
 
 	user.FullName = newFullName
 	user.Username = newUsername
 
+	policy := currentPasswordPolicy()
+	var passwordHash string
 	if newPassword != "" {
-		passwordHash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
-		if err != nil {
-			return nil, fmt.Errorf("Failed to hash password")
+		if err := policy.validate(newPassword); err != nil {
+			return nil, err
+		}
+		if passwordHash, err = hashPassword(newPassword); err != nil {
+			return nil, err
 		}
-		user.Password = string(passwordHash)
 	}
 
 	if newActive != nil {
@@ -150,6 +191,20 @@ func (s *usersService) EditUser(id uint, newUsername string, newFullName string,
 	if err != nil {
 		return nil, fmt.Errorf("Failed to update user: %w", err)
 	}
+	// รหัสผ่านที่ผู้ดูแลระบบตั้งให้ ผู้ใช้ต้องเปลี่ยนเองในการเข้าสู่ระบบครั้งถัดไป
+	if passwordHash != "" {
+		if err := s.usersRepository.SetPassword(id, passwordHash, true, policy.History); err != nil {
+			return nil, fmt.Errorf("Failed to update user: %w", err)
+		}
+		if err := s.authService.RevokeSessionsForPasswordReset(uint(actor.User_Id), id, actor.Ip); err != nil {
+			return nil, fmt.Errorf("Failed to revoke user sessions: %w", err)
+		}
+	}
+	changes := auditDiff(before, toUserAudit(updatedUser))
+	if newPassword != "" {
+		changes["password"] = passwordAuditChange
+	}
+	recordAuditChanges(s.auditRepository, actor, model.AuditActionUpdate, model.AuditEntityUser, id, changes)
 
 	loc, _ := time.LoadLocation("Asia/Bangkok")
 	thaiTime := updatedUser.UpdatedAt.In(loc)
@@ -163,26 +218,40 @@ func (s *usersService) EditUser(id uint, newUsername string, newFullName string,
 	}, nil
 }
 
-func (s *usersService) DeleteUser(id uint) error {
-	return s.usersRepository.DeleteUser(id)
+func (s *usersService) DeleteUser(id uint, actor AuditActor) error {
+	user, _ := s.usersRepository.GetUserByID(id)
+	if err := s.usersRepository.DeleteUser(id); err != nil {
+		return err
+	}
+	recordAudit(s.auditRepository, actor, model.AuditActionDelete, model.AuditEntityUser, id, toUserAudit(user), nil)
+	return nil
 }
 
-func (s *usersService) ResetPasswordUser(id uint, newPassword string) (*UserResponse, error) {
+func (s *usersService) ResetPasswordUser(id uint, newPassword string, actor AuditActor) (*UserResponse, error) {
 	user, err := s.usersRepository.GetUserByID(id)
 	if err != nil {
 		return nil, fmt.Errorf("User with ID not found", id)
 	}
+	policy := currentPasswordPolicy()
+	if err := policy.validate(newPassword); err != nil {
+		return nil, err
+	}
 	// Hash the new password
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	passwordHash, err := hashPassword(newPassword)
 	if err != nil {
-		return nil, fmt.Errorf("Failed to hash password")
+		return nil, err
 	}
-	user.Password = string(passwordHash) // Store the hashed password
 
-	updatedUser, err := s.usersRepository.UpdateUser(id, *user)
-	if err != nil {
+	// ผู้ดูแลระบบรู้รหัสผ่านนี้ ผู้ใช้ต้องเปลี่ยนเองในการเข้าสู่ระบบครั้งถัดไป
+	if err := s.usersRepository.SetPassword(id, passwordHash, true, policy.History); err != nil {
 		return nil, fmt.Errorf("Failed to update user password")
 	}
+	if err := s.authService.RevokeSessionsForPasswordReset(uint(actor.User_Id), id, actor.Ip); err != nil {
+		return nil, fmt.Errorf("Failed to revoke user sessions: %w", err)
+	}
+	updatedUser := user
+	recordAuditChanges(s.auditRepository, actor, model.AuditActionUpdate, model.AuditEntityUser, id,
+		map[string]AuditChange{"password": passwordAuditChange})
 	response := UserResponse{
 		Id:        updatedUser.Id,
 		Username:  updatedUser.Username,
@@ -9,11 +9,20 @@ import (
 	"golang.org/x/time/rate"
 )
 
+type limiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
 var (
-	limiters = make(map[string]*rate.Limiter)
-	mu       sync.Mutex
+	limiters  = make(map[string]*limiterEntry)
+	mu        sync.Mutex
+	lastSweep time.Time
 )
 
+// limiter ที่ไม่ถูกเรียกเกินเวลานี้ถูกลบทิ้ง (ถึงตอนนั้น token ก็เต็มถังแล้ว สร้างใหม่ได้ผลเหมือนเดิม)
+const limiterIdleTTL = 10 * time.Minute
+
 // func GetLimiter(ip string) *rate.Limiter {
 // 	mu.Lock()
 // 	defer mu.Unlock()
@@ -30,13 +39,25 @@ func GetLimiter(ip string) *rate.Limiter {
 	mu.Lock()
 	defer mu.Unlock()
 
-	limiter, exists := limiters[ip]
+	now := time.Now()
+	// กวาด limiter ที่ไม่ได้ใช้ออก อย่างมากนาทีละครั้ง ไม่ให้ map โตไปเรื่อยๆ
+	if now.Sub(lastSweep) > time.Minute {
+		for key, entry := range limiters {
+			if now.Sub(entry.lastSeen) > limiterIdleTTL {
+				delete(limiters, key)
+			}
+		}
+		lastSweep = now
+	}
+
+	entry, exists := limiters[ip]
 	if !exists {
 		// 5 events per minute (i.e., 1 event every 12 seconds), burst of 5
-		limiter = rate.NewLimiter(rate.Every(12*time.Second), 5)
-		limiters[ip] = limiter
+		entry = &limiterEntry{limiter: rate.NewLimiter(rate.Every(12*time.Second), 5)}
+		limiters[ip] = entry
 	}
-	return limiter
+	entry.lastSeen = now
+	return entry.limiter
 }
 
 func IsUniqueConstraintError(err error) bool {